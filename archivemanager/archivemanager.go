@@ -0,0 +1,270 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package archivemanager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/swinslow/peridot/config"
+	"github.com/swinslow/peridot/database"
+	"github.com/swinslow/peridot/hashmanager"
+	"github.com/swinslow/peridot/progress"
+)
+
+// ArchiveManager holds the data objects needed to manage unpacked copies of
+// source archives (tarballs and zip files) scanned by peridot.
+type ArchiveManager struct {
+	ArchivesPath string
+	db           *database.DB
+}
+
+// PrepareAM is called with existing Config and Database objects and
+// initializes the archive manager's on-disk storage location.
+func (am *ArchiveManager) PrepareAM(cfg *config.Config, db *database.DB) error {
+	if am == nil {
+		return fmt.Errorf("must pass non-nil ArchiveManager")
+	}
+	if cfg == nil || cfg.ArchivesLocation == "" {
+		return fmt.Errorf("must pass config string")
+	}
+	if db == nil {
+		return fmt.Errorf("must prepare and pass database")
+	}
+
+	err := am.setArchivesLocation(cfg.ArchivesLocation)
+	if err != nil {
+		return err
+	}
+
+	am.db = db
+	return nil
+}
+
+func (am *ArchiveManager) setArchivesLocation(path string) error {
+	// check whether path exists in filesystem
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	// check whether path is a directory
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+
+	// check whether path is writable
+	if unix.Access(path, unix.W_OK) != nil {
+		return fmt.Errorf("%s is not writable", path)
+	}
+
+	// we're good
+	am.ArchivesPath = path
+	return nil
+}
+
+// GetPathToArchive takes a repo structure and the SHA256 hash of one of its
+// source archives, and returns the full on-disk pathname where that
+// archive's contents are unpacked.
+func (am *ArchiveManager) GetPathToArchive(repo *database.Repo, hSHA256 string) string {
+	return filepath.Join(am.ArchivesPath, repo.OrgName, repo.RepoName, hSHA256)
+}
+
+// GetArchiveInfo takes a path to a source archive on disk, and returns its
+// name and hashes.
+func GetArchiveInfo(archivePath string) (*database.ArchiveInfo, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := hashmanager.GetReaderHashes(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	ai := &database.ArchiveInfo{
+		ArchiveName:   filepath.Base(archivePath),
+		ArchiveSHA1:   hashes.SHA1,
+		ArchiveSHA256: hashes.SHA256,
+		ArchiveMD5:    hashes.MD5,
+	}
+	return ai, nil
+}
+
+// UnpackArchive takes a Repo, a path to one of its source archives on disk
+// and that archive's SHA256 hash, and unpacks it into its location under
+// GetPathToArchive. It returns the path to the unpacked contents. If the
+// archive has already been unpacked, it is not unpacked again. Cancelling
+// ctx stops the unpack and removes whatever was unpacked so far.
+func (am *ArchiveManager) UnpackArchive(ctx context.Context, repo *database.Repo, archivePath string, hSHA256 string) (string, error) {
+	format, err := GetArchiveFormat(archivePath)
+	if err != nil {
+		return "", err
+	}
+
+	dstPath := am.GetPathToArchive(repo, hSHA256)
+	_, err = os.Stat(dstPath)
+	if err == nil {
+		return dstPath, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", fmt.Errorf("couldn't check archive location: %v", err)
+	}
+
+	// unpack to a temporary sibling first, so that a failed or interrupted
+	// unpack never leaves partial contents at dstPath
+	err = os.MkdirAll(filepath.Dir(dstPath), 0700)
+	if err != nil {
+		return "", fmt.Errorf("couldn't create archive subdir: %v", err)
+	}
+	tmpPath, err := os.MkdirTemp(filepath.Dir(dstPath), ".unpack-")
+	if err != nil {
+		return "", fmt.Errorf("couldn't create temporary unpack dir: %v", err)
+	}
+
+	err = unpack(ctx, format, archivePath, tmpPath)
+	if err != nil {
+		os.RemoveAll(tmpPath)
+		return "", fmt.Errorf("couldn't unpack %s: %v", archivePath, err)
+	}
+
+	err = os.Rename(tmpPath, dstPath)
+	if err != nil {
+		os.RemoveAll(tmpPath)
+		return "", fmt.Errorf("couldn't move unpacked archive into place: %v", err)
+	}
+
+	return dstPath, nil
+}
+
+// GetAllFilepaths takes the path to an unpacked archive and returns a string
+// slice containing the slash-separated relative paths for all regular files
+// and symlinks within it. Symlinks are not followed.
+func GetAllFilepaths(pathRoot string) ([]string, error) {
+	var filePaths []string
+	err := filepath.Walk(pathRoot, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// symlinks are included, but never followed
+		if !fi.Mode().IsRegular() && fi.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(pathRoot, path)
+		if err != nil {
+			return err
+		}
+		filePaths = append(filePaths, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return filePaths, nil
+}
+
+// GetFileHashes takes the path to an unpacked archive and the paths of files
+// within it, and returns a map of strings from path to that file's Hashes.
+// A symlink's hashes are those of its target string.
+func GetFileHashes(ctx context.Context, pathRoot string, allPaths []string) (map[string]database.Hashes, error) {
+	pathsToHashes := make(map[string]database.Hashes)
+	t := progress.Start(ctx, progress.PhaseHash, len(allPaths))
+
+	for _, path := range allPaths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		f, size, err := hashmanager.OpenNoFollowWithSize(filepath.Join(pathRoot, filepath.FromSlash(path)))
+		if err != nil {
+			return nil, err
+		}
+		// don't defer f.Close() here, b/c we're in a loop
+
+		cr := &progress.CountingReader{R: f}
+		hashes, err := hashmanager.GetReaderHashes(cr, size)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		pathsToHashes[path] = hashes
+		t.Add(1, cr.N)
+	}
+
+	return pathsToHashes, nil
+}
+
+// getSafePath takes the root directory for unpacking and the name of an
+// entry from an archive, and returns the path where that entry should be
+// written. It returns an error if the name is absolute or would resolve to
+// a location outside of root.
+func getSafePath(root string, name string) (string, error) {
+	name = strings.Replace(name, "\\", "/", -1)
+	if strings.HasPrefix(name, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("archive entry has absolute path: %s", name)
+	}
+
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if cleaned == "." {
+		return root, nil
+	}
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry is outside of unpack dir: %s", name)
+	}
+
+	return filepath.Join(root, cleaned), nil
+}
+
+// isSafeSymlink returns true if a symlink at linkPath (within root) with the
+// given target would resolve to a location within root. The target is
+// walked one component at a time against what is already unpacked on disk,
+// and ".." is only allowed to step out of a real directory: stepping out of
+// a symlink, or out of something that might later become one, could leave
+// root even though the target looks safe lexically.
+func isSafeSymlink(root string, linkPath string, target string) bool {
+	if target == "" || filepath.IsAbs(target) || strings.HasPrefix(target, "/") {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, filepath.Dir(linkPath))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	var parts []string
+	if rel != "." {
+		parts = strings.Split(rel, string(filepath.Separator))
+	}
+
+	for _, part := range strings.Split(filepath.FromSlash(target), string(filepath.Separator)) {
+		switch part {
+		case "", ".":
+		case "..":
+			if len(parts) == 0 {
+				return false
+			}
+			fi, err := os.Lstat(filepath.Join(root, filepath.Join(parts...)))
+			if err != nil || !fi.IsDir() {
+				return false
+			}
+			parts = parts[:len(parts)-1]
+		default:
+			parts = append(parts, part)
+		}
+	}
+
+	return true
+}
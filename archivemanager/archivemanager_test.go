@@ -0,0 +1,242 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package archivemanager
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetSafePathAcceptsRelativePaths(t *testing.T) {
+	root := "/tmp/unpack"
+	path, err := getSafePath(root, "proj-1.0/src/main.c")
+	if err != nil {
+		t.Errorf("got error when calling getSafePath: %v", err)
+	}
+	if path != filepath.Join(root, "proj-1.0", "src", "main.c") {
+		t.Errorf("expected %s, got %s", filepath.Join(root, "proj-1.0", "src", "main.c"), path)
+	}
+
+	path, err = getSafePath(root, "proj-1.0/../other/file")
+	if err != nil {
+		t.Errorf("got error when calling getSafePath: %v", err)
+	}
+	if path != filepath.Join(root, "other", "file") {
+		t.Errorf("expected %s, got %s", filepath.Join(root, "other", "file"), path)
+	}
+}
+
+func TestGetSafePathRejectsAbsolutePaths(t *testing.T) {
+	_, err := getSafePath("/tmp/unpack", "/etc/passwd")
+	if err == nil {
+		t.Errorf("expected error for absolute path, got nil")
+	}
+}
+
+func TestGetSafePathRejectsTraversal(t *testing.T) {
+	_, err := getSafePath("/tmp/unpack", "../../etc/passwd")
+	if err == nil {
+		t.Errorf("expected error for path traversal, got nil")
+	}
+	_, err = getSafePath("/tmp/unpack", "proj/../../escape")
+	if err == nil {
+		t.Errorf("expected error for path traversal, got nil")
+	}
+	_, err = getSafePath("/tmp/unpack", `..\escape`)
+	if err == nil {
+		t.Errorf("expected error for backslash path traversal, got nil")
+	}
+}
+
+func TestIsSafeSymlink(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "a"), 0700); err != nil {
+		t.Fatalf("couldn't create dir: %v", err)
+	}
+	if !isSafeSymlink(root, filepath.Join(root, "a", "link"), "../b/file") {
+		t.Errorf("expected symlink within root to be safe")
+	}
+	if isSafeSymlink(root, filepath.Join(root, "a", "link"), "../../outside") {
+		t.Errorf("expected symlink outside of root to be unsafe")
+	}
+	if isSafeSymlink(root, filepath.Join(root, "a", "link"), "/etc/passwd") {
+		t.Errorf("expected absolute symlink to be unsafe")
+	}
+}
+
+func TestIsSafeSymlinkRejectsChainedSymlinks(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "d"), 0700); err != nil {
+		t.Fatalf("couldn't create dir: %v", err)
+	}
+
+	// d/l1 -> .. is safe on its own, since it points at root
+	if !isSafeSymlink(root, filepath.Join(root, "d", "l1"), "..") {
+		t.Fatalf("expected d/l1 -> .. to be safe")
+	}
+	if err := os.Symlink("..", filepath.Join(root, "d", "l1")); err != nil {
+		t.Fatalf("couldn't create symlink: %v", err)
+	}
+
+	// but l2 -> d/l1/.. resolves to root's parent, not to d
+	if isSafeSymlink(root, filepath.Join(root, "l2"), "d/l1/..") {
+		t.Errorf("expected l2 -> d/l1/.. to be unsafe")
+	}
+	// and stepping out of something not yet unpacked is refused too,
+	// since it could still turn out to be a symlink
+	if isSafeSymlink(root, filepath.Join(root, "l3"), "x/..") {
+		t.Errorf("expected l3 -> x/.. to be unsafe")
+	}
+}
+
+func TestGetArchiveFormat(t *testing.T) {
+	tests := map[string]ArchiveFormat{
+		"proj-1.0.tar.gz":  FormatTarGz,
+		"proj-1.0.tgz":     FormatTarGz,
+		"proj-1.0.tar.xz":  FormatTarXz,
+		"proj-1.0.tar.bz2": FormatTarBz2,
+		"proj-1.0.tar":     FormatTar,
+		"PROJ-1.0.ZIP":     FormatZip,
+	}
+	for name, want := range tests {
+		got, err := GetArchiveFormat(name)
+		if err != nil {
+			t.Errorf("got error when calling GetArchiveFormat(%s): %v", name, err)
+		}
+		if got != want {
+			t.Errorf("expected %v for %s, got %v", want, name, got)
+		}
+	}
+
+	_, err := GetArchiveFormat("proj-1.0.rar")
+	if err == nil {
+		t.Errorf("expected error for unsupported format, got nil")
+	}
+}
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	body     string
+}
+
+func makeTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname,
+			Mode: 0644, Size: int64(len(e.body))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("couldn't write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatalf("couldn't write tar body: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("couldn't close tar writer: %v", err)
+	}
+	return buf
+}
+
+func TestUnpackTarWritesFilesAndSafeLinks(t *testing.T) {
+	dst := t.TempDir()
+	buf := makeTar(t, []tarEntry{
+		{name: "proj/", typeflag: tar.TypeDir},
+		{name: "proj/a.txt", typeflag: tar.TypeReg, body: "hello"},
+		{name: "proj/b.txt", typeflag: tar.TypeSymlink, linkname: "a.txt"},
+		{name: "proj/c.txt", typeflag: tar.TypeLink, linkname: "proj/a.txt"},
+	})
+
+	err := unpackTar(context.Background(), buf, dst)
+	if err != nil {
+		t.Fatalf("got error when calling unpackTar: %v", err)
+	}
+
+	paths, err := GetAllFilepaths(dst)
+	if err != nil {
+		t.Fatalf("got error when calling GetAllFilepaths: %v", err)
+	}
+	if len(paths) != 3 || paths[0] != "proj/a.txt" || paths[1] != "proj/b.txt" || paths[2] != "proj/c.txt" {
+		t.Errorf("expected [proj/a.txt proj/b.txt proj/c.txt], got %v", paths)
+	}
+
+	target, err := os.Readlink(filepath.Join(dst, "proj", "b.txt"))
+	if err != nil || target != "a.txt" {
+		t.Errorf("expected symlink to a.txt, got %s (%v)", target, err)
+	}
+}
+
+func TestUnpackTarRejectsTraversal(t *testing.T) {
+	dst := t.TempDir()
+	buf := makeTar(t, []tarEntry{
+		{name: "../escape.txt", typeflag: tar.TypeReg, body: "bad"},
+	})
+
+	err := unpackTar(context.Background(), buf, dst)
+	if err == nil {
+		t.Errorf("expected error for path traversal, got nil")
+	}
+}
+
+func TestUnpackTarRejectsEscapingSymlinks(t *testing.T) {
+	dst := t.TempDir()
+	buf := makeTar(t, []tarEntry{
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "../../etc"},
+	})
+
+	err := unpackTar(context.Background(), buf, dst)
+	if err == nil {
+		t.Errorf("expected error for escaping symlink, got nil")
+	}
+}
+
+func TestUnpackTarRejectsLinksBeneathSymlinks(t *testing.T) {
+	dst := t.TempDir()
+	buf := makeTar(t, []tarEntry{
+		{name: "d/l1", typeflag: tar.TypeSymlink, linkname: ".."},
+		{name: "d/l1/l2", typeflag: tar.TypeSymlink, linkname: ".."},
+	})
+
+	err := unpackTar(context.Background(), buf, dst)
+	if err == nil {
+		t.Errorf("expected error for symlink beneath symlink, got nil")
+	}
+}
+
+func TestUnpackTarRejectsChainedSymlinks(t *testing.T) {
+	dst := t.TempDir()
+	buf := makeTar(t, []tarEntry{
+		{name: "d/", typeflag: tar.TypeDir},
+		{name: "d/l1", typeflag: tar.TypeSymlink, linkname: ".."},
+		{name: "l2", typeflag: tar.TypeSymlink, linkname: "d/l1/.."},
+	})
+
+	err := unpackTar(context.Background(), buf, dst)
+	if err == nil {
+		t.Errorf("expected error for symlink through symlink, got nil")
+	}
+}
+
+func TestUnpackTarStopsWhenCancelled(t *testing.T) {
+	dst := t.TempDir()
+	buf := makeTar(t, []tarEntry{
+		{name: "a.txt", typeflag: tar.TypeReg, body: "hello"},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := unpackTar(ctx, buf, dst)
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be unpacked after cancel")
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package archivemanager
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/swinslow/peridot/database"
+)
+
+// ParsePackageName takes an ecosystem and a package's full name as it is
+// usually written in that ecosystem, and splits it into the namespace and
+// name used for its Repo coordinates:
+//
+//	npm:    "@scope/name" or "name"
+//	pypi:   "name"
+//	golang: "github.com/org/name" (namespace is everything before the last /)
+//	maven:  "group.id:artifact"
+func ParsePackageName(ecosystem string, fullName string) (string, string, error) {
+	if fullName == "" {
+		return "", "", fmt.Errorf("empty package name")
+	}
+
+	switch ecosystem {
+	case database.EcosystemNPM:
+		if strings.HasPrefix(fullName, "@") {
+			sp := strings.SplitN(fullName, "/", 2)
+			if len(sp) != 2 || sp[1] == "" {
+				return "", "", fmt.Errorf("invalid scoped npm package name: %s", fullName)
+			}
+			return sp[0], sp[1], nil
+		}
+		return "", fullName, nil
+
+	case database.EcosystemPyPI:
+		return "", fullName, nil
+
+	case database.EcosystemGolang:
+		i := strings.LastIndex(fullName, "/")
+		if i < 0 {
+			return "", fullName, nil
+		}
+		return fullName[:i], fullName[i+1:], nil
+
+	case database.EcosystemMaven:
+		sp := strings.SplitN(fullName, ":", 2)
+		if len(sp) != 2 || sp[0] == "" || sp[1] == "" {
+			return "", "", fmt.Errorf("invalid maven artifact name, expected group:artifact: %s", fullName)
+		}
+		return sp[0], sp[1], nil
+
+	default:
+		return "", "", fmt.Errorf("unsupported package ecosystem: %s", ecosystem)
+	}
+}
+
+// GetPackageName is the reverse of ParsePackageName, and returns the full
+// name of a package with the given ecosystem, namespace and name, as it is
+// written in that ecosystem.
+func GetPackageName(ecosystem string, namespace string, name string) string {
+	switch {
+	case namespace == "":
+		return name
+	case ecosystem == database.EcosystemMaven:
+		return namespace + ":" + name
+	default:
+		return namespace + "/" + name
+	}
+}
+
+// GetPURL returns the package URL (purl) for a package with the given
+// ecosystem, namespace, name and version.
+func GetPURL(ecosystem string, namespace string, name string, version string) string {
+	purl := "pkg:" + ecosystem + "/"
+	if namespace != "" {
+		// escape each segment, but keep the slashes between them
+		var segments []string
+		for _, segment := range strings.Split(namespace, "/") {
+			segments = append(segments, escapePURLSegment(segment))
+		}
+		purl += strings.Join(segments, "/") + "/"
+	}
+	if ecosystem == database.EcosystemPyPI {
+		// per the purl spec, pypi names are lowercased with _ replaced by -
+		name = strings.Replace(strings.ToLower(name), "_", "-", -1)
+	}
+	purl += escapePURLSegment(name)
+	if version != "" {
+		purl += "@" + escapePURLSegment(version)
+	}
+	return purl
+}
+
+// escapePURLSegment percent-encodes a purl segment. Unlike in URL paths,
+// "@" must be encoded, since it separates the version.
+func escapePURLSegment(segment string) string {
+	return strings.Replace(url.PathEscape(segment), "@", "%40", -1)
+}
+
+// GetPackageRoot takes a package Repo, the package's version and the path
+// to its unpacked artifact, and returns the directory within it that holds
+// the package's own files, following each ecosystem's layout conventions.
+func GetPackageRoot(repo *database.Repo, version string, unpackedPath string) (string, error) {
+	switch repo.Ecosystem {
+	case database.EcosystemNPM:
+		// npm tarballs put everything under package/, though some older
+		// ones use a different single top-level directory
+		return getSingleTopLevelDir(unpackedPath)
+
+	case database.EcosystemPyPI:
+		// sdists have a single name-version/ directory; wheels have their
+		// files at the top level
+		matches, err := filepath.Glob(filepath.Join(unpackedPath, "*.dist-info"))
+		if err != nil {
+			return "", err
+		}
+		if len(matches) > 0 {
+			return unpackedPath, nil
+		}
+		return getSingleTopLevelDir(unpackedPath)
+
+	case database.EcosystemGolang:
+		// module zips put everything under module/path@version/
+		modulePath := repo.RepoName
+		if repo.OrgName != "" {
+			modulePath = repo.OrgName + "/" + repo.RepoName
+		}
+		root := filepath.Join(unpackedPath, filepath.FromSlash(modulePath+"@"+version))
+		fi, err := os.Stat(root)
+		if err != nil || !fi.IsDir() {
+			return "", fmt.Errorf("couldn't find %s@%s in module zip", modulePath, version)
+		}
+		return root, nil
+
+	case database.EcosystemMaven:
+		return unpackedPath, nil
+
+	default:
+		return "", fmt.Errorf("unsupported package ecosystem: %s", repo.Ecosystem)
+	}
+}
+
+// getSingleTopLevelDir returns the only directory within path if there is
+// exactly one entry and it is a directory, or else returns path itself.
+func getSingleTopLevelDir(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(path, entries[0].Name()), nil
+	}
+	return path, nil
+}
+
+// GetDeclaredLicense takes a package's ecosystem and the path to its package
+// root, and returns the license expression declared in its package metadata
+// along with the metadata file's path relative to the root. It returns
+// empty strings if no declared license is found. Go modules have no license
+// field in go.mod, so nothing is ever returned for them.
+func GetDeclaredLicense(ecosystem string, pkgRoot string) (string, string, error) {
+	switch ecosystem {
+	case database.EcosystemNPM:
+		return getDeclaredLicenseFromFile(pkgRoot, "package.json", parseNPMLicense)
+
+	case database.EcosystemPyPI:
+		// sdist
+		expr, source, err := getDeclaredLicenseFromFile(pkgRoot, "PKG-INFO", parsePkgInfoLicense)
+		if err != nil || expr != "" {
+			return expr, source, err
+		}
+		// wheel
+		matches, err := filepath.Glob(filepath.Join(pkgRoot, "*.dist-info", "METADATA"))
+		if err != nil || len(matches) == 0 {
+			return "", "", err
+		}
+		rel, err := filepath.Rel(pkgRoot, matches[0])
+		if err != nil {
+			return "", "", err
+		}
+		return getDeclaredLicenseFromFile(pkgRoot, filepath.ToSlash(rel), parsePkgInfoLicense)
+
+	case database.EcosystemGolang:
+		return "", "", nil
+
+	case database.EcosystemMaven:
+		// a bare pom.xml, or the one that maven embeds in jars
+		expr, source, err := getDeclaredLicenseFromFile(pkgRoot, "pom.xml", parsePOMLicense)
+		if err != nil || expr != "" {
+			return expr, source, err
+		}
+		matches, err := filepath.Glob(filepath.Join(pkgRoot, "META-INF", "maven", "*", "*", "pom.xml"))
+		if err != nil || len(matches) == 0 {
+			return "", "", err
+		}
+		rel, err := filepath.Rel(pkgRoot, matches[0])
+		if err != nil {
+			return "", "", err
+		}
+		return getDeclaredLicenseFromFile(pkgRoot, filepath.ToSlash(rel), parsePOMLicense)
+
+	default:
+		return "", "", fmt.Errorf("unsupported package ecosystem: %s", ecosystem)
+	}
+}
+
+func getDeclaredLicenseFromFile(pkgRoot string, relPath string, parse func(io.Reader) (string, error)) (string, string, error) {
+	f, err := os.Open(filepath.Join(pkgRoot, filepath.FromSlash(relPath)))
+	if os.IsNotExist(err) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	expr, err := parse(f)
+	if err != nil {
+		return "", "", fmt.Errorf("couldn't parse %s: %v", relPath, err)
+	}
+	if expr == "" {
+		return "", "", nil
+	}
+	return expr, relPath, nil
+}
+
+// parseNPMLicense returns the license from a package.json file. It accepts
+// the current "license" string, as well as the deprecated object and
+// "licenses" array forms.
+func parseNPMLicense(r io.Reader) (string, error) {
+	type licenseObj struct {
+		Type string `json:"type"`
+	}
+	var pkg struct {
+		License  json.RawMessage `json:"license"`
+		Licenses []licenseObj    `json:"licenses"`
+	}
+	err := json.NewDecoder(r).Decode(&pkg)
+	if err != nil {
+		return "", err
+	}
+
+	if len(pkg.License) > 0 {
+		var s string
+		if json.Unmarshal(pkg.License, &s) == nil {
+			return strings.TrimSpace(s), nil
+		}
+		var obj licenseObj
+		if json.Unmarshal(pkg.License, &obj) == nil {
+			return strings.TrimSpace(obj.Type), nil
+		}
+		return "", fmt.Errorf("invalid license field: %s", string(pkg.License))
+	}
+
+	var types []string
+	for _, lic := range pkg.Licenses {
+		if t := strings.TrimSpace(lic.Type); t != "" {
+			types = append(types, t)
+		}
+	}
+	return joinLicenseChoices(types), nil
+}
+
+// parsePkgInfoLicense returns the license from a Python PKG-INFO or METADATA
+// file, preferring License-Expression over the free-text License field.
+func parsePkgInfoLicense(r io.Reader) (string, error) {
+	var license, licenseExpr string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// headers end at the first blank line; the description follows
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		// skip continuation lines of multi-line headers
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		sp := strings.SplitN(line, ":", 2)
+		if len(sp) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(sp[1])
+		switch strings.ToLower(strings.TrimSpace(sp[0])) {
+		case "license-expression":
+			licenseExpr = value
+		case "license":
+			license = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if licenseExpr != "" {
+		return licenseExpr, nil
+	}
+	if strings.EqualFold(license, "UNKNOWN") {
+		return "", nil
+	}
+	return license, nil
+}
+
+// parsePOMLicense returns the license names from a Maven pom.xml file.
+func parsePOMLicense(r io.Reader) (string, error) {
+	var pom struct {
+		Licenses []struct {
+			Name string `xml:"name"`
+		} `xml:"licenses>license"`
+	}
+	err := xml.NewDecoder(r).Decode(&pom)
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, lic := range pom.Licenses {
+		if n := strings.TrimSpace(lic.Name); n != "" {
+			names = append(names, n)
+		}
+	}
+	return joinLicenseChoices(names), nil
+}
+
+// joinLicenseChoices combines a list of licenses from package metadata.
+// Both npm's deprecated "licenses" array and Maven's <licenses> list mean
+// that the user may choose among them.
+func joinLicenseChoices(lics []string) string {
+	if len(lics) <= 1 {
+		return strings.Join(lics, "")
+	}
+	return strings.Join(lics, " OR ")
+}
@@ -0,0 +1,161 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package archivemanager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/swinslow/peridot/database"
+)
+
+func TestParsePackageName(t *testing.T) {
+	tests := []struct {
+		ecosystem string
+		fullName  string
+		namespace string
+		name      string
+	}{
+		{database.EcosystemNPM, "@babel/core", "@babel", "core"},
+		{database.EcosystemNPM, "lodash", "", "lodash"},
+		{database.EcosystemPyPI, "requests", "", "requests"},
+		{database.EcosystemGolang, "github.com/pkg/errors", "github.com/pkg", "errors"},
+		{database.EcosystemMaven, "org.apache.commons:commons-lang3", "org.apache.commons", "commons-lang3"},
+	}
+	for _, tt := range tests {
+		namespace, name, err := ParsePackageName(tt.ecosystem, tt.fullName)
+		if err != nil {
+			t.Errorf("got error when calling ParsePackageName(%s, %s): %v", tt.ecosystem, tt.fullName, err)
+		}
+		if namespace != tt.namespace || name != tt.name {
+			t.Errorf("expected (%s, %s) for %s, got (%s, %s)", tt.namespace, tt.name, tt.fullName, namespace, name)
+		}
+	}
+}
+
+func TestParsePackageNameRejectsInvalidNames(t *testing.T) {
+	_, _, err := ParsePackageName(database.EcosystemMaven, "no-group")
+	if err == nil {
+		t.Errorf("expected error for maven name without group, got nil")
+	}
+	_, _, err = ParsePackageName(database.EcosystemNPM, "@scope")
+	if err == nil {
+		t.Errorf("expected error for npm scope without name, got nil")
+	}
+	_, _, err = ParsePackageName("cargo", "serde")
+	if err == nil {
+		t.Errorf("expected error for unsupported ecosystem, got nil")
+	}
+}
+
+func TestGetPackageName(t *testing.T) {
+	tests := []struct {
+		ecosystem string
+		namespace string
+		name      string
+		fullName  string
+	}{
+		{database.EcosystemNPM, "@babel", "core", "@babel/core"},
+		{database.EcosystemNPM, "", "lodash", "lodash"},
+		{database.EcosystemPyPI, "", "requests", "requests"},
+		{database.EcosystemGolang, "github.com/pkg", "errors", "github.com/pkg/errors"},
+		{database.EcosystemMaven, "org.apache.commons", "commons-lang3", "org.apache.commons:commons-lang3"},
+	}
+	for _, tt := range tests {
+		got := GetPackageName(tt.ecosystem, tt.namespace, tt.name)
+		if got != tt.fullName {
+			t.Errorf("expected %s, got %s", tt.fullName, got)
+		}
+	}
+}
+
+func TestGetPURL(t *testing.T) {
+	tests := []struct {
+		ecosystem string
+		namespace string
+		name      string
+		version   string
+		purl      string
+	}{
+		{database.EcosystemNPM, "@babel", "core", "7.0.0", "pkg:npm/%40babel/core@7.0.0"},
+		{database.EcosystemPyPI, "", "Django_Utils", "1.0", "pkg:pypi/django-utils@1.0"},
+		{database.EcosystemGolang, "github.com/pkg", "errors", "v0.9.1", "pkg:golang/github.com/pkg/errors@v0.9.1"},
+		{database.EcosystemMaven, "org.apache.commons", "commons-lang3", "3.9", "pkg:maven/org.apache.commons/commons-lang3@3.9"},
+	}
+	for _, tt := range tests {
+		purl := GetPURL(tt.ecosystem, tt.namespace, tt.name, tt.version)
+		if purl != tt.purl {
+			t.Errorf("expected %s, got %s", tt.purl, purl)
+		}
+	}
+}
+
+func TestParseNPMLicense(t *testing.T) {
+	tests := map[string]string{
+		`{"name": "x", "license": "MIT"}`:                                      "MIT",
+		`{"name": "x", "license": {"type": "ISC"}}`:                            "ISC",
+		`{"name": "x", "licenses": [{"type": "MIT"}, {"type": "Apache-2.0"}]}`: "MIT OR Apache-2.0",
+		`{"name": "x"}`: "",
+	}
+	for pkgJSON, want := range tests {
+		got, err := parseNPMLicense(strings.NewReader(pkgJSON))
+		if err != nil {
+			t.Errorf("got error when calling parseNPMLicense(%s): %v", pkgJSON, err)
+		}
+		if got != want {
+			t.Errorf("expected %s for %s, got %s", want, pkgJSON, got)
+		}
+	}
+}
+
+func TestParsePkgInfoLicense(t *testing.T) {
+	pkgInfo := "Metadata-Version: 2.1\nName: x\nLicense: BSD\nClassifier: License :: OSI Approved\n\nLicense: not a header\n"
+	got, err := parsePkgInfoLicense(strings.NewReader(pkgInfo))
+	if err != nil {
+		t.Errorf("got error when calling parsePkgInfoLicense: %v", err)
+	}
+	if got != "BSD" {
+		t.Errorf("expected BSD, got %s", got)
+	}
+
+	pkgInfo = "Metadata-Version: 2.4\nLicense: UNKNOWN\nLicense-Expression: MIT OR Apache-2.0\n"
+	got, err = parsePkgInfoLicense(strings.NewReader(pkgInfo))
+	if err != nil {
+		t.Errorf("got error when calling parsePkgInfoLicense: %v", err)
+	}
+	if got != "MIT OR Apache-2.0" {
+		t.Errorf("expected MIT OR Apache-2.0, got %s", got)
+	}
+
+	pkgInfo = "Metadata-Version: 1.0\nLicense: UNKNOWN\n"
+	got, err = parsePkgInfoLicense(strings.NewReader(pkgInfo))
+	if err != nil {
+		t.Errorf("got error when calling parsePkgInfoLicense: %v", err)
+	}
+	if got != "" {
+		t.Errorf("expected empty string for UNKNOWN, got %s", got)
+	}
+}
+
+func TestParsePOMLicense(t *testing.T) {
+	pom := `<?xml version="1.0"?>
+<project>
+  <licenses>
+    <license>
+      <name>Apache-2.0</name>
+      <url>https://www.apache.org/licenses/LICENSE-2.0.txt</url>
+    </license>
+    <license>
+      <name>MIT</name>
+    </license>
+  </licenses>
+</project>`
+	got, err := parsePOMLicense(strings.NewReader(pom))
+	if err != nil {
+		t.Errorf("got error when calling parsePOMLicense: %v", err)
+	}
+	if got != "Apache-2.0 OR MIT" {
+		t.Errorf("expected Apache-2.0 OR MIT, got %s", got)
+	}
+}
@@ -0,0 +1,327 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package archivemanager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+
+	"github.com/swinslow/peridot/progress"
+)
+
+// ArchiveFormat represents the supported formats of source archives.
+type ArchiveFormat int
+
+const (
+	// FormatUnknown indicates an unsupported archive format
+	FormatUnknown ArchiveFormat = iota
+
+	// FormatTar indicates an uncompressed tar archive
+	FormatTar
+
+	// FormatTarGz indicates a gzip-compressed tar archive
+	FormatTarGz
+
+	// FormatTarXz indicates an xz-compressed tar archive
+	FormatTarXz
+
+	// FormatTarBz2 indicates a bzip2-compressed tar archive
+	FormatTarBz2
+
+	// FormatZip indicates a zip archive, including Python wheels and
+	// Java jars
+	FormatZip
+)
+
+// GetArchiveFormat returns the ArchiveFormat for a source archive based on
+// its filename extension. It returns an error for unsupported formats.
+func GetArchiveFormat(archivePath string) (ArchiveFormat, error) {
+	name := strings.ToLower(filepath.Base(archivePath))
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(name, ".tar.xz"), strings.HasSuffix(name, ".txz"):
+		return FormatTarXz, nil
+	case strings.HasSuffix(name, ".tar.bz2"), strings.HasSuffix(name, ".tbz2"):
+		return FormatTarBz2, nil
+	case strings.HasSuffix(name, ".tar"):
+		return FormatTar, nil
+	case strings.HasSuffix(name, ".zip"), strings.HasSuffix(name, ".whl"),
+		strings.HasSuffix(name, ".jar"):
+		return FormatZip, nil
+	default:
+		return FormatUnknown, fmt.Errorf("unsupported archive format: %s", archivePath)
+	}
+}
+
+// pendingLink records a symlink or hard link that will be created only after
+// all regular files have been written, so that no file is ever written
+// through a link.
+type pendingLink struct {
+	path   string
+	target string
+	isHard bool
+}
+
+func unpack(ctx context.Context, format ArchiveFormat, archivePath string, dstPath string) error {
+	if format == FormatZip {
+		return unpackZip(ctx, archivePath, dstPath)
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader
+	switch format {
+	case FormatTar:
+		r = f
+	case FormatTarGz:
+		gzr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gzr.Close()
+		r = gzr
+	case FormatTarXz:
+		xzr, err := xz.NewReader(f)
+		if err != nil {
+			return err
+		}
+		r = xzr
+	case FormatTarBz2:
+		r = bzip2.NewReader(f)
+	default:
+		return fmt.Errorf("unsupported archive format: %v", format)
+	}
+
+	return unpackTar(ctx, r, dstPath)
+}
+
+func unpackTar(ctx context.Context, r io.Reader, dstPath string) error {
+	var links []pendingLink
+	tr := tar.NewReader(r)
+	t := progress.Start(ctx, progress.PhaseUnpack, 0)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		path, err := getSafePath(dstPath, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, 0700)
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeFile(path, tr, hdr.FileInfo().Mode())
+		case tar.TypeSymlink:
+			links = append(links, pendingLink{path: path, target: hdr.Linkname})
+		case tar.TypeLink:
+			links = append(links, pendingLink{path: path, target: hdr.Linkname, isHard: true})
+		default:
+			// skip devices, fifos and other special entries
+		}
+		if err != nil {
+			return err
+		}
+		t.Add(1, hdr.Size)
+	}
+
+	return createLinks(dstPath, links)
+}
+
+func unpackZip(ctx context.Context, archivePath string, dstPath string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	var links []pendingLink
+	t := progress.Start(ctx, progress.PhaseUnpack, len(zr.File))
+	for _, zf := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path, err := getSafePath(dstPath, zf.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := zf.Mode()
+		switch {
+		case mode.IsDir():
+			err = os.MkdirAll(path, 0700)
+		case mode&os.ModeSymlink != 0:
+			// a zip symlink's contents are its target
+			var target string
+			target, err = readZipSymlink(zf)
+			links = append(links, pendingLink{path: path, target: target})
+		case mode.IsRegular():
+			var rc io.ReadCloser
+			rc, err = zf.Open()
+			if err == nil {
+				err = writeFile(path, rc, mode)
+				rc.Close()
+			}
+		}
+		if err != nil {
+			return err
+		}
+		t.Add(1, int64(zf.UncompressedSize64))
+	}
+
+	return createLinks(dstPath, links)
+}
+
+func readZipSymlink(zf *zip.File) (string, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	// symlink targets are short; refuse anything unreasonably long
+	b, err := io.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func writeFile(path string, src io.Reader, mode os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	// never write through an existing entry, since it might be a link
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
+
+func createLinks(dstPath string, links []pendingLink) error {
+	for _, link := range links {
+		// never create a link beneath an earlier symlink, where it would
+		// end up somewhere other than its path in the archive says
+		err := checkNoSymlinkParents(dstPath, link.path)
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(filepath.Dir(link.path), 0700)
+		if err != nil {
+			return err
+		}
+
+		if link.isHard {
+			// hard link targets are paths from the archive root; copy the
+			// already-unpacked file rather than linking to it
+			targetPath, err := getSafePath(dstPath, link.target)
+			if err != nil {
+				return err
+			}
+			err = checkNoSymlinkParents(dstPath, targetPath)
+			if err != nil {
+				return err
+			}
+			err = copyUnpackedFile(targetPath, link.path)
+			if err != nil {
+				return fmt.Errorf("couldn't create hard link %s: %v", link.path, err)
+			}
+			continue
+		}
+
+		if !isSafeSymlink(dstPath, link.path, link.target) {
+			return fmt.Errorf("archive symlink points outside of unpack dir: %s -> %s", link.path, link.target)
+		}
+		err = os.Symlink(link.target, link.path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyUnpackedFile(srcPath string, dstPath string) error {
+	fi, err := os.Lstat(srcPath)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", srcPath)
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return writeFile(dstPath, src, fi.Mode())
+}
+
+// checkNoSymlinkParents returns an error if any existing directory between
+// root and path is a symlink.
+func checkNoSymlinkParents(root string, path string) error {
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+
+	cur := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		cur = filepath.Join(cur, part)
+		fi, err := os.Lstat(cur)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("archive entry is beneath a symlink: %s", path)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/swinslow/peridot/archivemanager"
+	"github.com/swinslow/peridot/config"
+	"github.com/swinslow/peridot/coordinator"
+	"github.com/swinslow/peridot/database"
+)
+
+// CmdCat provides the "cat" cli command, which writes the contents of a
+// file from the hash store to stdout. Since stdout carries the contents,
+// errors go to stderr.
+func CmdCat(ctx context.Context, co *coordinator.Coordinator, db *database.DB, cfg *config.Config) {
+	if len(os.Args) < 3 {
+		fmt.Printf("Usage: %s cat (HASH | REPO:PATH[@RETRIEVAL])\n", os.Args[0])
+		fmt.Printf("  HASH is a file's SHA1, SHA256, MD5 or SHA512 hash, git blob object ID or SWHID\n")
+		fmt.Printf("  REPO is ORG/REPO for a GitHub repo, or ECOSYSTEM:NAME for a package\n")
+		fmt.Printf("  RETRIEVAL is a retrieval ID or commit hash; defaults to the latest\n")
+		return
+	}
+	spec := os.Args[2]
+
+	var rc io.ReadCloser
+	var err error
+	if strings.Contains(spec, ":") && !strings.HasPrefix(strings.ToLower(spec), "swh:") {
+		var repoFileID int
+		repoFileID, err = lookupRepoFile(db, spec)
+		if err == nil {
+			rc, err = co.OpenRepoFile(repoFileID)
+		}
+	} else {
+		rc, err = co.OpenBlobByHash(spec)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in 'cat': %v\n", err)
+		return
+	}
+	defer rc.Close()
+
+	_, err = io.Copy(os.Stdout, rc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in 'cat': %v\n", err)
+	}
+}
+
+// lookupRepoFile takes a spec of the form REPO:PATH[@RETRIEVAL], where REPO
+// is as for parseRepoCoords, and returns the ID of the RepoFile it names.
+func lookupRepoFile(db *database.DB, spec string) (int, error) {
+	coords, path, ok := cutRepoCoords(spec)
+	if !ok || path == "" {
+		return 0, fmt.Errorf("expected ORG/REPO:PATH[@RETRIEVAL] or ECOSYSTEM:NAME:PATH[@RETRIEVAL], got %s", spec)
+	}
+	path, retrieval := cutRetrieval(path)
+
+	repoRetrieval, err := lookupRepoRetrieval(db, coords, retrieval)
+	if err != nil {
+		return 0, err
+	}
+
+	repoFile, err := db.GetRepoFileByPath(repoRetrieval.ID, path)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get repo file: %v", err)
+	}
+	if repoFile == nil {
+		return 0, fmt.Errorf("%s not found in retrieval %d of %s", path, repoRetrieval.ID, coords)
+	}
+	return repoFile.ID, nil
+}
+
+// parseRepoCoords takes a Repo's coordinates as given on the command line,
+// either ORG/REPO for a GitHub repo or ECOSYSTEM:NAME for a package, with
+// NAME as for "package ingest", and returns its ecosystem, org name and
+// repo name.
+func parseRepoCoords(coords string) (string, string, string, error) {
+	if ecosystem, fullName, ok := strings.Cut(coords, ":"); ok && !strings.Contains(ecosystem, "/") {
+		namespace, name, err := archivemanager.ParsePackageName(ecosystem, fullName)
+		if err != nil {
+			return "", "", "", err
+		}
+		return ecosystem, namespace, name, nil
+	}
+
+	orgName, repoName, ok := strings.Cut(coords, "/")
+	if !ok || orgName == "" || repoName == "" {
+		return "", "", "", fmt.Errorf("expected ORG/REPO or ECOSYSTEM:NAME, got %s", coords)
+	}
+	return database.EcosystemGitHub, orgName, repoName, nil
+}
+
+// cutRepoCoords splits spec into the Repo coordinates at its start, as for
+// parseRepoCoords, and whatever follows the ":" after them. Since a Maven
+// NAME contains a ":" itself, the coordinates end at its second ":".
+func cutRepoCoords(spec string) (string, string, bool) {
+	ecosystem, _, _ := strings.Cut(spec, ":")
+	colons := 1
+	if !strings.Contains(ecosystem, "/") {
+		colons = 2
+		if ecosystem == database.EcosystemMaven {
+			colons = 3
+		}
+	}
+
+	i := 0
+	for n := 0; n < colons; n++ {
+		j := strings.Index(spec[i:], ":")
+		if j < 0 {
+			return "", "", false
+		}
+		i += j + 1
+	}
+	return spec[:i-1], spec[i:], true
+}
+
+// cutRetrieval splits a trailing @RETRIEVAL from s, if there is one. Paths
+// and package names can contain "@" too, so only what follows the last one
+// is treated as a retrieval, and only if it looks like one.
+func cutRetrieval(s string) (string, string) {
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		suffix := s[i+1:]
+		if _, err := strconv.Atoi(suffix); err == nil || len(suffix) == 40 {
+			return s[:i], suffix
+		}
+	}
+	return s, ""
+}
+
+// lookupRepoRetrieval returns the RepoRetrieval of the Repo at coords, as
+// for parseRepoCoords, named by retrieval, which is a retrieval ID or
+// commit hash, or is empty for the latest retrieval.
+func lookupRepoRetrieval(db *database.DB, coords string, retrieval string) (*database.RepoRetrieval, error) {
+	ecosystem, orgName, repoName, err := parseRepoCoords(coords)
+	if err != nil {
+		return nil, err
+	}
+	repoID, err := db.GetRepoIDFromEcosystemCoords(ecosystem, orgName, repoName)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get repo ID: %v", err)
+	}
+	if repoID == 0 {
+		return nil, fmt.Errorf("%s not found in database", coords)
+	}
+
+	var repoRetrieval *database.RepoRetrieval
+	if retrieval == "" {
+		repoRetrieval, err = db.GetRepoRetrievalLatest(repoID)
+	} else if id, convErr := strconv.Atoi(retrieval); convErr == nil {
+		repoRetrieval, err = db.GetRepoRetrievalByID(id)
+		if err == nil && repoRetrieval.RepoID != repoID {
+			return nil, fmt.Errorf("retrieval %d is not for %s", id, coords)
+		}
+	} else {
+		repoRetrieval, err = db.GetRepoRetrievalByCommit(repoID, retrieval)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get repo retrieval: %v", err)
+	}
+	if repoRetrieval == nil {
+		return nil, fmt.Errorf("no retrieval of %s for commit %s", coords, retrieval)
+	}
+	return repoRetrieval, nil
+}
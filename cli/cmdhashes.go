@@ -0,0 +1,170 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swinslow/peridot/config"
+	"github.com/swinslow/peridot/coordinator"
+	"github.com/swinslow/peridot/database"
+	"github.com/swinslow/peridot/hashmanager"
+	"github.com/swinslow/peridot/progress"
+)
+
+// CmdHashes provides the "hashes" cli command, which is used to maintain
+// the hash store.
+func CmdHashes(ctx context.Context, co *coordinator.Coordinator, db *database.DB, cfg *config.Config) {
+	if len(os.Args) < 3 {
+		fmt.Printf("Usage: %s hashes SUBCOMMAND ...\n", os.Args[0])
+		fmt.Printf("Available subcommands:\n")
+		printHashesSubcommands()
+		return
+	}
+
+	switch os.Args[2] {
+	case "verify":
+		subcmdHashesVerify(ctx, co, os.Args[3:])
+	case "gc":
+		subcmdHashesGC(ctx, co, os.Args[3:])
+	case "compress":
+		subcmdHashesCompress(ctx, co, cfg, os.Args[3:])
+	default:
+		printHashesSubcommands()
+	}
+}
+
+func printHashesSubcommands() {
+	fmt.Printf("  verify [--resume] [--quarantine] [--workers=N]\n")
+	fmt.Printf("  gc [--dry-run] [--grace=DURATION]\n")
+	fmt.Printf("  compress [--to=none|gzip|zstd]\n")
+}
+
+func subcmdHashesVerify(ctx context.Context, co *coordinator.Coordinator, args []string) {
+	opts := coordinator.VerifyOptions{}
+	for _, arg := range args {
+		switch {
+		case arg == "--resume":
+			opts.Resume = true
+		case arg == "--quarantine":
+			opts.Quarantine = true
+		case strings.HasPrefix(arg, "--workers="):
+			n, err := strconv.Atoi(strings.TrimPrefix(arg, "--workers="))
+			if err != nil || n < 1 {
+				fmt.Printf("Error in 'hashes verify': invalid worker count %s\n", arg)
+				return
+			}
+			opts.Workers = n
+		default:
+			fmt.Printf("Error in 'hashes verify': unknown option %s\n", arg)
+			return
+		}
+	}
+
+	fmt.Printf("Verifying hash store...\n")
+	report, err := co.DoVerifyHashes(ctx, opts)
+	if report != nil {
+		printVerifyReport(report)
+	}
+	if err == context.Canceled {
+		fmt.Printf("Verification interrupted; run again with --resume to continue\n")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error verifying hash store: %v\n", err)
+	}
+}
+
+func printVerifyReport(report *coordinator.VerifyReport) {
+	counts := map[string]int{}
+	for _, issue := range report.Issues {
+		counts[issue.Kind]++
+		fmt.Printf("  %s: %s (%s)\n", issue.Kind, issue.Path, issue.Detail)
+	}
+
+	fmt.Printf("Checked %d blobs: %d missing, %d corrupt, %d orphaned\n", report.Checked,
+		counts[hashmanager.IssueMissing], counts[hashmanager.IssueCorrupt],
+		counts[hashmanager.IssueOrphaned])
+}
+
+// defaultGCGrace is how recently an unreferenced blob must have been
+// written for "hashes gc" to keep it, unless --grace is given.
+const defaultGCGrace = 24 * time.Hour
+
+func subcmdHashesGC(ctx context.Context, co *coordinator.Coordinator, args []string) {
+	opts := coordinator.GCOptions{GracePeriod: defaultGCGrace}
+	for _, arg := range args {
+		switch {
+		case arg == "--dry-run":
+			opts.DryRun = true
+		case strings.HasPrefix(arg, "--grace="):
+			d, err := time.ParseDuration(strings.TrimPrefix(arg, "--grace="))
+			if err != nil || d < 0 {
+				fmt.Printf("Error in 'hashes gc': invalid grace period %s\n", arg)
+				return
+			}
+			opts.GracePeriod = d
+		default:
+			fmt.Printf("Error in 'hashes gc': unknown option %s\n", arg)
+			return
+		}
+	}
+
+	report, err := co.DoCollectGarbage(ctx, opts)
+	if report != nil {
+		verb := "Removed"
+		if opts.DryRun {
+			verb = "Would remove"
+			for _, path := range report.Removed {
+				fmt.Printf("  %s\n", path)
+			}
+		}
+		fmt.Printf("%s %d blobs (%s) and %d hash files; kept %d unreferenced blobs newer than %s\n",
+			verb, len(report.Removed), progress.FormatBytes(report.Bytes),
+			report.HashFilesRemoved, report.Kept, opts.GracePeriod)
+	}
+	if err != nil {
+		fmt.Printf("Error collecting garbage from hash store: %v\n", err)
+	}
+}
+
+func subcmdHashesCompress(ctx context.Context, co *coordinator.Coordinator, cfg *config.Config, args []string) {
+	// by default, bring the whole store in line with the configuration
+	c := cfg.HashesCompression
+	if c == "" {
+		c = hashmanager.CompressionNone
+	}
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--to="):
+			c = strings.TrimPrefix(arg, "--to=")
+			if !hashmanager.IsValidCompression(c) {
+				fmt.Printf("Error in 'hashes compress': invalid compression %s\n", c)
+				return
+			}
+		default:
+			fmt.Printf("Error in 'hashes compress': unknown option %s\n", arg)
+			return
+		}
+	}
+
+	fmt.Printf("Converting hash store to %s...\n", c)
+	report, err := co.DoCompressHashes(ctx, c)
+	if report != nil {
+		fmt.Printf("Converted %d blobs from %s to %s\n", report.Converted,
+			progress.FormatBytes(report.BytesBefore), progress.FormatBytes(report.BytesAfter))
+	}
+	if err == context.Canceled {
+		fmt.Printf("Conversion interrupted; run again to continue\n")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error converting hash store: %v\n", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/swinslow/peridot/archivemanager"
+	"github.com/swinslow/peridot/config"
+	"github.com/swinslow/peridot/coordinator"
+	"github.com/swinslow/peridot/database"
+)
+
+// CmdPackage provides the "package" cli command, which is used to ingest
+// package artifacts from package ecosystems into peridot.
+func CmdPackage(ctx context.Context, co *coordinator.Coordinator, db *database.DB, cfg *config.Config) {
+	if len(os.Args) < 3 {
+		fmt.Printf("Usage: %s package SUBCOMMAND ...\n", os.Args[0])
+		fmt.Printf("Available subcommands:\n")
+		printPackageSubcommands()
+		return
+	}
+
+	switch os.Args[2] {
+	case "ingest":
+		subcmdPackageIngest(ctx, co, db)
+	default:
+		printPackageSubcommands()
+	}
+}
+
+func printPackageSubcommands() {
+	fmt.Printf("  ingest ECOSYSTEM NAME VERSION ARTIFACT\n")
+	fmt.Printf("    ECOSYSTEM is one of: npm, pypi, golang, maven\n")
+	fmt.Printf("    NAME is e.g. @scope/name (npm), github.com/org/name (golang),\n")
+	fmt.Printf("    or group.id:artifact (maven)\n")
+}
+
+func subcmdPackageIngest(ctx context.Context, co *coordinator.Coordinator, db *database.DB) {
+	if len(os.Args) < 7 {
+		fmt.Printf("Usage: %s package ingest ECOSYSTEM NAME VERSION ARTIFACT\n", os.Args[0])
+		return
+	}
+	ecosystem := os.Args[3]
+	fullName := os.Args[4]
+	version := os.Args[5]
+	archivePath := os.Args[6]
+
+	namespace, name, err := archivemanager.ParsePackageName(ecosystem, fullName)
+	if err != nil {
+		fmt.Printf("Error in 'package ingest': %v\n", err)
+		return
+	}
+
+	// packages are registered as repos on first ingestion
+	repoID, err := db.GetRepoIDFromEcosystemCoords(ecosystem, namespace, name)
+	if err != nil {
+		fmt.Printf("Error getting package repo ID: %v\n", err)
+		return
+	}
+	if repoID == 0 {
+		repo, err := db.InsertEcosystemRepo(ecosystem, namespace, name)
+		if err != nil {
+			fmt.Printf("Error adding package repo to DB: %v\n", err)
+			return
+		}
+		repoID = repo.ID
+	}
+
+	purl := archivemanager.GetPURL(ecosystem, namespace, name, version)
+	fmt.Printf("Ingesting %s from %s...\n", purl, archivePath)
+	ingested, err := co.DoIngestPackage(ctx, repoID, version, archivePath)
+	if err != nil {
+		fmt.Printf("Error ingesting package: %v\n", err)
+		return
+	}
+
+	if ingested {
+		fmt.Printf("Ingested package\n")
+	} else {
+		fmt.Printf("Package artifact was already ingested\n")
+	}
+}
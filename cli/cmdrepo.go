@@ -4,26 +4,32 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/swinslow/peridot/config"
 	"github.com/swinslow/peridot/coordinator"
 	"github.com/swinslow/peridot/database"
+	"github.com/swinslow/peridot/pathrules"
 )
 
 type repoCallData struct {
+	ctx      context.Context
 	co       *coordinator.Coordinator
 	db       *database.DB
 	cfg      *config.Config
 	subCmd   string
 	orgName  string
 	repoName string
+	args     []string
 }
 
 // CmdRepo provides the "repo" cli command, which is used to initialize or update
 // a repo within peridot.
-func CmdRepo(co *coordinator.Coordinator, db *database.DB, cfg *config.Config) {
+func CmdRepo(ctx context.Context, co *coordinator.Coordinator, db *database.DB, cfg *config.Config) {
 	if len(os.Args) < 5 {
 		fmt.Printf("Usage: %s repo SUBCOMMAND orgName repoName\n", os.Args[0])
 		fmt.Printf("Available subcommands:\n")
@@ -31,7 +37,7 @@ func CmdRepo(co *coordinator.Coordinator, db *database.DB, cfg *config.Config) {
 		return
 	}
 
-	rcd := &repoCallData{co, db, cfg, os.Args[2], os.Args[3], os.Args[4]}
+	rcd := &repoCallData{ctx, co, db, cfg, os.Args[2], os.Args[3], os.Args[4], os.Args[5:]}
 
 	switch rcd.subCmd {
 	case "init":
@@ -40,6 +46,16 @@ func CmdRepo(co *coordinator.Coordinator, db *database.DB, cfg *config.Config) {
 		subcmdRepoUpdate(rcd)
 	case "info":
 		subcmdRepoInfo(rcd)
+	case "backfill":
+		subcmdRepoBackfill(rcd)
+	case "ingest":
+		subcmdRepoIngest(rcd)
+	case "rules":
+		subcmdRepoRules(rcd)
+	case "rule-add":
+		subcmdRepoRuleAdd(rcd)
+	case "rule-delete":
+		subcmdRepoRuleDelete(rcd)
 	default:
 		printRepoSubcommands()
 	}
@@ -48,7 +64,12 @@ func CmdRepo(co *coordinator.Coordinator, db *database.DB, cfg *config.Config) {
 func printRepoSubcommands() {
 	fmt.Printf("  init\n")
 	fmt.Printf("  update\n")
-	fmt.Printf("  info   (NOT YET IMPLEMENTED)\n")
+	fmt.Printf("  info\n")
+	fmt.Printf("  backfill (--tags | --commits A..B)\n")
+	fmt.Printf("  ingest ARCHIVE (.tar.gz, .tar.xz, .tar.bz2, .zip)\n")
+	fmt.Printf("  rules\n")
+	fmt.Printf("  rule-add (excluded | vendored | test | documentation) PATTERN\n")
+	fmt.Printf("  rule-delete RULE_ID\n")
 	fmt.Printf("  delete (NOT YET IMPLEMENTED)\n")
 }
 
@@ -78,7 +99,7 @@ func subcmdRepoInit(rcd *repoCallData) {
 
 	// go clone the repo from remote
 	fmt.Printf("Getting Github repo %s/%s...\n", rcd.orgName, rcd.repoName)
-	err = rcd.co.DoCloneRepo(repo.ID)
+	err = rcd.co.DoCloneRepo(rcd.ctx, repo.ID)
 	if err != nil {
 		fmt.Printf("Error cloning repo: %v\n", err)
 		return
@@ -86,7 +107,7 @@ func subcmdRepoInit(rcd *repoCallData) {
 	fmt.Printf("Cloned repo\n")
 
 	// and prepare the directories and files in the database
-	err = rcd.co.DoPrepareFiles(repoID)
+	err = rcd.co.DoPrepareFiles(rcd.ctx, repoID)
 	if err != nil {
 		fmt.Printf("Error preparing files: %v\n", err)
 		return
@@ -112,7 +133,7 @@ func subcmdRepoUpdate(rcd *repoCallData) {
 
 	// repo already exists, so let's update it
 	fmt.Printf("Checking Github repo %s/%s for updates...\n", rcd.orgName, rcd.repoName)
-	needsFilesPrepared, err = rcd.co.DoUpdateRepo(repoID)
+	needsFilesPrepared, err = rcd.co.DoUpdateRepo(rcd.ctx, repoID)
 	if err != nil {
 		fmt.Printf("Error updating repo: %v\n", err)
 		return
@@ -121,7 +142,7 @@ func subcmdRepoUpdate(rcd *repoCallData) {
 
 	if needsFilesPrepared {
 		fmt.Printf("Updates found, so preparing directories and files\n")
-		err = rcd.co.DoPrepareFiles(repoID)
+		err = rcd.co.DoPrepareFiles(rcd.ctx, repoID)
 		if err != nil {
 			fmt.Printf("Error preparing files: %v\n", err)
 			return
@@ -162,5 +183,221 @@ func subcmdRepoInfo(rcd *repoCallData) {
 	fmt.Printf("Info for repo %s/%s:\n", rcd.orgName, rcd.repoName)
 	fmt.Printf("  Repo ID: %d\n", repo.ID)
 	fmt.Printf("  Last retrieved: %v\n", repoRetrieval.LastRetrieval)
+	if repoRetrieval.VerificationCode != "" {
+		fmt.Printf("  Verification code: %s\n", repoRetrieval.VerificationCode)
+	}
+	if repoRetrieval.SourceType == database.SourcePackage {
+		fmt.Printf("  Latest package: %s\n", repoRetrieval.PURL)
+	}
+	if repoRetrieval.SourceType != database.SourceGit {
+		fmt.Printf("  Latest archive: %s\n", repoRetrieval.ArchiveName)
+		fmt.Printf("  Archive SHA1: %s\n", repoRetrieval.ArchiveSHA1)
+		fmt.Printf("  Archive SHA256: %s\n", repoRetrieval.ArchiveSHA256)
+		fmt.Printf("  Archive MD5: %s\n", repoRetrieval.ArchiveMD5)
+		return
+	}
 	fmt.Printf("  Latest commit hash: %s\n", repoRetrieval.CommitHash)
+	fmt.Printf("  Ref: %s\n", repoRetrieval.RefName)
+	fmt.Printf("  Tree hash: %s\n", repoRetrieval.TreeHash)
+	fmt.Printf("  Author: %s\n", repoRetrieval.CommitAuthor)
+	fmt.Printf("  Committer: %s\n", repoRetrieval.CommitCommitter)
+	fmt.Printf("  Commit time: %v\n", repoRetrieval.CommitTime)
+	fmt.Printf("  Subject: %s\n", repoRetrieval.CommitSubject)
+}
+
+func subcmdRepoBackfill(rcd *repoCallData) {
+	var err error
+	var repoID int
+	var count int
+
+	if len(rcd.args) < 1 {
+		fmt.Printf("Usage: %s repo backfill orgName repoName (--tags | --commits A..B)\n", os.Args[0])
+		return
+	}
+
+	// first make sure that the repo is already in the database
+	repoID, err = rcd.db.GetRepoIDFromCoords(rcd.orgName, rcd.repoName)
+	if err != nil {
+		fmt.Printf("Error getting repo ID: %v\n", err)
+		return
+	}
+	if repoID == 0 {
+		fmt.Printf("Error in 'repo backfill': %s/%s not found in database\n", rcd.orgName, rcd.repoName)
+		fmt.Printf("Did you mean to call 'repo init' first?\n")
+		return
+	}
+
+	switch rcd.args[0] {
+	case "--tags":
+		fmt.Printf("Backfilling tags for %s/%s...\n", rcd.orgName, rcd.repoName)
+		count, err = rcd.co.DoBackfillTags(rcd.ctx, repoID)
+	case "--commits":
+		if len(rcd.args) < 2 {
+			fmt.Printf("Error in 'repo backfill': --commits requires a range A..B\n")
+			return
+		}
+		from, to, ok := parseCommitRange(rcd.args[1])
+		if !ok {
+			fmt.Printf("Error in 'repo backfill': invalid commit range %s, expected A..B\n", rcd.args[1])
+			return
+		}
+		fmt.Printf("Backfilling commits %s for %s/%s...\n", rcd.args[1], rcd.orgName, rcd.repoName)
+		count, err = rcd.co.DoBackfillCommits(rcd.ctx, repoID, from, to)
+	default:
+		fmt.Printf("Error in 'repo backfill': unknown option %s\n", rcd.args[0])
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error backfilling repo (%d retrievals added): %v\n", count, err)
+		return
+	}
+
+	fmt.Printf("Added %d retrievals\n", count)
+}
+
+// parseCommitRange splits a range in the form "A..B" into its endpoints.
+// A may be empty, meaning all commits reachable from B.
+func parseCommitRange(rng string) (string, string, bool) {
+	sp := strings.SplitN(rng, "..", 2)
+	if len(sp) != 2 || sp[1] == "" {
+		return "", "", false
+	}
+	return sp[0], sp[1], true
+}
+
+func subcmdRepoIngest(rcd *repoCallData) {
+	var err error
+	var repoID int
+
+	if len(rcd.args) < 1 {
+		fmt.Printf("Usage: %s repo ingest orgName repoName ARCHIVE\n", os.Args[0])
+		return
+	}
+	archivePath := rcd.args[0]
+
+	// archives can be ingested for new or existing repos
+	repoID, err = rcd.db.GetRepoIDFromCoords(rcd.orgName, rcd.repoName)
+	if err != nil {
+		fmt.Printf("Error getting repo ID: %v\n", err)
+		return
+	}
+	if repoID == 0 {
+		repo, err := rcd.db.InsertRepo(rcd.orgName, rcd.repoName)
+		if err != nil {
+			fmt.Printf("Error adding repo to DB: %v\n", err)
+			return
+		}
+		repoID = repo.ID
+	}
+
+	fmt.Printf("Ingesting archive %s for %s/%s...\n", archivePath, rcd.orgName, rcd.repoName)
+	ingested, err := rcd.co.DoIngestArchive(rcd.ctx, repoID, archivePath)
+	if err != nil {
+		fmt.Printf("Error ingesting archive: %v\n", err)
+		return
+	}
+
+	if ingested {
+		fmt.Printf("Ingested archive\n")
+	} else {
+		fmt.Printf("Archive was already ingested\n")
+	}
+}
+
+func subcmdRepoRules(rcd *repoCallData) {
+	repoID, err := rcd.db.GetRepoIDFromCoords(rcd.orgName, rcd.repoName)
+	if err != nil {
+		fmt.Printf("Error getting repo ID: %v\n", err)
+		return
+	}
+	if repoID == 0 {
+		fmt.Printf("%s/%s not found in database\n", rcd.orgName, rcd.repoName)
+		return
+	}
+
+	rules, err := rcd.db.GetPathRulesForRepo(repoID)
+	if err != nil {
+		fmt.Printf("Error getting path rules: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Path rules for repo %s/%s (later rules take precedence):\n", rcd.orgName, rcd.repoName)
+	if len(rules) == 0 {
+		fmt.Printf("  (none)\n")
+	}
+	for _, rule := range rules {
+		fmt.Printf("  %d: %s => %s\n", rule.ID, rule.Pattern, rule.Classification)
+	}
+}
+
+func subcmdRepoRuleAdd(rcd *repoCallData) {
+	if len(rcd.args) < 2 {
+		fmt.Printf("Usage: %s repo rule-add orgName repoName CLASSIFICATION PATTERN\n", os.Args[0])
+		return
+	}
+	classification := rcd.args[0]
+	pattern := rcd.args[1]
+
+	if !database.IsValidClassification(classification) {
+		fmt.Printf("Error in 'repo rule-add': unknown classification %s\n", classification)
+		return
+	}
+	err := pathrules.ValidatePattern(pattern)
+	if err != nil {
+		fmt.Printf("Error in 'repo rule-add': %v\n", err)
+		return
+	}
+
+	repoID, err := rcd.db.GetRepoIDFromCoords(rcd.orgName, rcd.repoName)
+	if err != nil {
+		fmt.Printf("Error getting repo ID: %v\n", err)
+		return
+	}
+	if repoID == 0 {
+		fmt.Printf("Error in 'repo rule-add': %s/%s not found in database\n", rcd.orgName, rcd.repoName)
+		return
+	}
+
+	rule, err := rcd.db.InsertPathRule(repoID, pattern, classification)
+	if err != nil {
+		fmt.Printf("Error adding path rule to DB: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Added path rule %d: %s => %s\n", rule.ID, rule.Pattern, rule.Classification)
+	fmt.Printf("It will apply to retrievals from now on.\n")
+}
+
+func subcmdRepoRuleDelete(rcd *repoCallData) {
+	if len(rcd.args) < 1 {
+		fmt.Printf("Usage: %s repo rule-delete orgName repoName RULE_ID\n", os.Args[0])
+		return
+	}
+	ruleID, err := strconv.Atoi(rcd.args[0])
+	if err != nil {
+		fmt.Printf("Error in 'repo rule-delete': invalid rule ID %s\n", rcd.args[0])
+		return
+	}
+
+	repoID, err := rcd.db.GetRepoIDFromCoords(rcd.orgName, rcd.repoName)
+	if err != nil {
+		fmt.Printf("Error getting repo ID: %v\n", err)
+		return
+	}
+	if repoID == 0 {
+		fmt.Printf("Error in 'repo rule-delete': %s/%s not found in database\n", rcd.orgName, rcd.repoName)
+		return
+	}
+
+	deleted, err := rcd.db.DeletePathRule(repoID, ruleID)
+	if err != nil {
+		fmt.Printf("Error deleting path rule: %v\n", err)
+		return
+	}
+	if !deleted {
+		fmt.Printf("Error in 'repo rule-delete': no rule %d for %s/%s\n", ruleID, rcd.orgName, rcd.repoName)
+		return
+	}
+
+	fmt.Printf("Deleted path rule %d\n", ruleID)
 }
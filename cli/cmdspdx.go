@@ -0,0 +1,280 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/swinslow/peridot/config"
+	"github.com/swinslow/peridot/coordinator"
+	"github.com/swinslow/peridot/database"
+	"github.com/swinslow/peridot/licenses"
+	"github.com/swinslow/peridot/spdxtvmanager"
+)
+
+// CmdSPDX provides the "spdx" cli command, which is used to exchange SPDX
+// documents with peridot.
+func CmdSPDX(ctx context.Context, co *coordinator.Coordinator, db *database.DB, cfg *config.Config) {
+	if len(os.Args) < 3 {
+		fmt.Printf("Usage: %s spdx SUBCOMMAND ...\n", os.Args[0])
+		fmt.Printf("Available subcommands:\n")
+		printSPDXSubcommands()
+		return
+	}
+
+	switch os.Args[2] {
+	case "import":
+		subcmdSPDXImport(ctx, co, db, os.Args[3:])
+	case "export":
+		subcmdSPDXExport(co, db, os.Args[3:])
+	case "convert":
+		subcmdSPDXConvert(os.Args[3:])
+	case "validate":
+		subcmdSPDXValidate(cfg, os.Args[3:])
+	default:
+		printSPDXSubcommands()
+	}
+}
+
+func printSPDXSubcommands() {
+	fmt.Printf("  import FILE [REPO[@RETRIEVAL]]\n")
+	fmt.Printf("    without REPO, files are matched by checksum across all repos\n")
+	fmt.Printf("  export REPO[@RETRIEVAL] [OUTFILE]\n")
+	fmt.Printf("    writes an SPDX document to OUTFILE, or as tag-value to stdout\n")
+	fmt.Printf("  convert INFILE OUTFILE\n")
+	fmt.Printf("    formats are chosen by file extension: .spdx3.json/.jsonld for SPDX 3.0,\n")
+	fmt.Printf("    .json, .yaml/.yml, .rdf/.xml, or tag-value otherwise\n")
+	fmt.Printf("  validate FILE\n")
+	fmt.Printf("    reports problems with an SPDX document, with line numbers for tag-value\n")
+	fmt.Printf("  REPO is ORG/REPO for a GitHub repo, or ECOSYSTEM:NAME for a package\n")
+	fmt.Printf("  RETRIEVAL is a retrieval ID or commit hash; defaults to the latest\n")
+}
+
+// readSPDXFile reads the SPDX document at docPath, in the format given by
+// its file extension.
+func readSPDXFile(docPath string) (*spdxtvmanager.Document, error) {
+	f, err := os.Open(docPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	doc, err := spdxtvmanager.ParseFormat(f, spdxtvmanager.FormatForPath(docPath))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse %s: %v", docPath, err)
+	}
+	return doc, nil
+}
+
+// parseRetrievalSpec takes a spec of the form REPO[@RETRIEVAL], where REPO
+// is as for parseRepoCoords, and returns the RepoRetrieval it names.
+func parseRetrievalSpec(db *database.DB, spec string) (*database.RepoRetrieval, error) {
+	coords, retrieval := cutRetrieval(spec)
+	return lookupRepoRetrieval(db, coords, retrieval)
+}
+
+func subcmdSPDXImport(ctx context.Context, co *coordinator.Coordinator, db *database.DB, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Printf("Usage: %s spdx import FILE [REPO[@RETRIEVAL]]\n", os.Args[0])
+		return
+	}
+	docPath := args[0]
+
+	repoRetrievalID := 0
+	if len(args) == 2 {
+		repoRetrieval, err := parseRetrievalSpec(db, args[1])
+		if err != nil {
+			fmt.Printf("Error in 'spdx import': %v\n", err)
+			return
+		}
+		repoRetrievalID = repoRetrieval.ID
+	}
+
+	// tag-value documents are streamed, so that findings are recorded as
+	// the document is read; other formats are read in full first
+	if spdxtvmanager.FormatForPath(docPath) == spdxtvmanager.FormatTagValue {
+		f, err := os.Open(docPath)
+		if err != nil {
+			fmt.Printf("Error reading SPDX document: %v\n", err)
+			return
+		}
+		defer f.Close()
+		report, err := co.DoImportSPDXStream(ctx, f, filepath.Base(docPath), repoRetrievalID)
+		printSPDXImportReport(report, err)
+		return
+	}
+
+	doc, err := readSPDXFile(docPath)
+	if err != nil {
+		fmt.Printf("Error reading SPDX document: %v\n", err)
+		return
+	}
+	report, err := co.DoImportSPDX(ctx, doc, filepath.Base(docPath), repoRetrievalID)
+	printSPDXImportReport(report, err)
+}
+
+// printSPDXImportReport prints the results of importing an SPDX document,
+// which may be partial if err isn't nil.
+func printSPDXImportReport(report *coordinator.ImportReport, err error) {
+	if report != nil {
+		fmt.Printf("Matched %d files by checksum and %d by path; added %d findings\n",
+			report.MatchedByChecksum, report.MatchedByPath, report.FindingsAdded)
+		if len(report.Unmatched) > 0 {
+			fmt.Printf("%d files didn't match any file in peridot:\n", len(report.Unmatched))
+			for _, p := range report.Unmatched {
+				fmt.Printf("  %s\n", p)
+			}
+		}
+		if len(report.Unparsed) > 0 {
+			fmt.Printf("%d license expressions couldn't be parsed, and were recorded as text only:\n", len(report.Unparsed))
+			for _, expr := range report.Unparsed {
+				fmt.Printf("  %s\n", expr)
+			}
+		}
+		if report.LicenseRefsAdded > 0 {
+			fmt.Printf("Added %d LicenseRef- licenses\n", report.LicenseRefsAdded)
+		}
+		if len(report.RenamedLicenseRefs) > 0 {
+			fmt.Printf("%d LicenseRef- licenses were recorded under other identifiers:\n", len(report.RenamedLicenseRefs))
+			ids := make([]string, 0, len(report.RenamedLicenseRefs))
+			for id := range report.RenamedLicenseRefs {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			for _, id := range ids {
+				fmt.Printf("  %s => %s\n", id, report.RenamedLicenseRefs[id])
+			}
+		}
+		if vm := report.VerificationMismatch; vm != nil {
+			fmt.Printf("Warning: package %s has verification code %s, but the retrieval's files have %s;\n", vm.Package, vm.Declared, vm.Computed)
+			fmt.Printf("  the document describes a different set of files\n")
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error importing SPDX document: %v\n", err)
+	}
+}
+
+// subcmdSPDXExport writes an SPDX document for a retrieval to a file, in
+// the format given by its extension, or to stdout as tag-value. Since
+// stdout may carry the document, errors go to stderr.
+func subcmdSPDXExport(co *coordinator.Coordinator, db *database.DB, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Printf("Usage: %s spdx export REPO[@RETRIEVAL] [OUTFILE]\n", os.Args[0])
+		return
+	}
+
+	repoRetrieval, err := parseRetrievalSpec(db, args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in 'spdx export': %v\n", err)
+		return
+	}
+	doc, err := co.DoExportSPDX(repoRetrieval.ID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting SPDX document: %v\n", err)
+		return
+	}
+	if len(args) == 1 {
+		err = spdxtvmanager.Write(os.Stdout, doc)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing SPDX document: %v\n", err)
+		}
+		return
+	}
+
+	outPath := args[1]
+	f, err := os.Create(outPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", outPath, err)
+		return
+	}
+	err = spdxtvmanager.WriteFormat(f, doc, spdxtvmanager.FormatForPath(outPath))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing SPDX document: %v\n", err)
+	}
+}
+
+func subcmdSPDXConvert(args []string) {
+	if len(args) != 2 {
+		fmt.Printf("Usage: %s spdx convert INFILE OUTFILE\n", os.Args[0])
+		return
+	}
+	inPath, outPath := args[0], args[1]
+
+	doc, err := readSPDXFile(inPath)
+	if err != nil {
+		fmt.Printf("Error reading SPDX document: %v\n", err)
+		return
+	}
+	f, err := os.Create(outPath)
+	if err != nil {
+		fmt.Printf("Error creating %s: %v\n", outPath, err)
+		return
+	}
+	outFormat := spdxtvmanager.FormatForPath(outPath)
+	err = spdxtvmanager.WriteFormat(f, doc, outFormat)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Printf("Error writing SPDX document: %v\n", err)
+		return
+	}
+	fmt.Printf("Converted %s to %s (%s)\n", inPath, outPath, outFormat)
+}
+
+func subcmdSPDXValidate(cfg *config.Config, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Usage: %s spdx validate FILE\n", os.Args[0])
+		return
+	}
+	docPath := args[0]
+
+	// only tag-value documents have line numbers for their problems
+	var doc *spdxtvmanager.Document
+	var pos *spdxtvmanager.Positions
+	f, err := os.Open(docPath)
+	if err != nil {
+		fmt.Printf("Error reading SPDX document: %v\n", err)
+		return
+	}
+	format := spdxtvmanager.FormatForPath(docPath)
+	if format == spdxtvmanager.FormatTagValue {
+		doc, pos, err = spdxtvmanager.ParseWithPositions(f)
+	} else {
+		doc, err = spdxtvmanager.ParseFormat(f, format)
+	}
+	f.Close()
+	if err != nil {
+		fmt.Printf("%s: %v\n", docPath, err)
+		return
+	}
+
+	ll, err := licenses.LoadFromJSON(cfg.SPDXLLJSONLocation)
+	if err != nil {
+		fmt.Printf("Warning: couldn't load SPDX License List, so license identifiers won't be checked: %v\n", err)
+		ll = nil
+	}
+
+	var nErrors, nWarnings int
+	for _, p := range spdxtvmanager.Validate(doc, pos, ll) {
+		if p.Line > 0 {
+			fmt.Printf("%s:%d: %s: %s\n", docPath, p.Line, p.Severity, p.Message)
+		} else {
+			fmt.Printf("%s: %s: %s\n", docPath, p.Severity, p.Message)
+		}
+		if p.Severity == spdxtvmanager.SeverityError {
+			nErrors++
+		} else {
+			nWarnings++
+		}
+	}
+	fmt.Printf("%d errors, %d warnings\n", nErrors, nWarnings)
+}
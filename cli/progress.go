@@ -0,0 +1,45 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/swinslow/peridot/progress"
+)
+
+// SetupProgress looks for a "--progress=MODE" flag anywhere in os.Args and
+// removes it, so that commands see only their own arguments. It returns a
+// copy of ctx carrying a progress Reporter for MODE, which is one of "bar"
+// (the default), "json" or "none", and a function to call once the command
+// has finished. Progress is written to stderr, so that a JSON stream can be
+// read separately from the command's own output.
+func SetupProgress(ctx context.Context) (context.Context, func(), error) {
+	mode := "bar"
+	args := os.Args[:1]
+	for _, arg := range os.Args[1:] {
+		if strings.HasPrefix(arg, "--progress=") {
+			mode = strings.TrimPrefix(arg, "--progress=")
+			continue
+		}
+		args = append(args, arg)
+	}
+	os.Args = args
+
+	switch mode {
+	case "bar":
+		br := progress.NewBarReporter(os.Stderr)
+		return progress.NewContext(ctx, br), func() { br.Close() }, nil
+	case "json":
+		jr := progress.NewJSONReporter(os.Stderr)
+		return progress.NewContext(ctx, jr), func() { jr.Close() }, nil
+	case "none":
+		return ctx, func() {}, nil
+	default:
+		return ctx, func() {}, fmt.Errorf("unknown progress mode %s; expected bar, json or none", mode)
+	}
+}
@@ -3,14 +3,40 @@
 
 package config
 
-// Config represents data for configuring peridot.
+// Config represents data for configuring peridot. HashesCompression
+// selects whether new blobs are stored as is ("none", the default) or
+// compressed with "gzip" or "zstd"; blobs stored either way can still be
+// read after it is changed. HashesBackend selects where blobs are stored:
+// "local" (the default) for a directory at HashesLocation, or "s3" for the
+// bucket described by HashesS3. HashesLink selects whether files are
+// copied into a local hash store ("none", the default), or shared with the
+// clone they came from when on the same filesystem ("hardlink" or
+// "reflink"); linking requires HashesCompression "none".
 type Config struct {
 	DBConnectString    string
 	ReposLocation      string
 	HashesLocation     string
+	HashesCompression  string
+	HashesBackend      string
+	HashesLink         string
+	HashesS3           S3Config
+	ArchivesLocation   string
 	SPDXLLJSONLocation string
 }
 
+// S3Config represents data for connecting to an S3-compatible object store.
+// Endpoint is a URL such as "https://s3.amazonaws.com" or
+// "http://localhost:9000". Prefix, if set, is prepended to every key in
+// Bucket.
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
 // SetDBConnectString is called with database config paramters to create the
 // appropriate connection string in its Config object.
 func (cfg *Config) SetDBConnectString(
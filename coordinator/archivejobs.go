@@ -0,0 +1,188 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/swinslow/peridot/archivemanager"
+	"github.com/swinslow/peridot/database"
+	"github.com/swinslow/peridot/filetypes"
+)
+
+// DoIngestArchive is the function for JobIngestArchive, and unpacks a local
+// source archive as a new RepoRetrieval for the given repo, then prepares
+// its files in the database and hash manager. It returns false if this
+// archive has already been ingested for the repo.
+func (co *Coordinator) DoIngestArchive(ctx context.Context, repoID int, archivePath string) (bool, error) {
+	repo, err := co.db.GetRepoByID(repoID)
+	if err != nil {
+		return false, fmt.Errorf("couldn't get repo from DB: %v", err)
+	}
+
+	ai, err := archivemanager.GetArchiveInfo(archivePath)
+	if err != nil {
+		return false, fmt.Errorf("couldn't read archive: %v", err)
+	}
+
+	existing, err := co.db.GetRepoRetrievalByArchive(repoID, ai.ArchiveSHA256)
+	if err != nil {
+		return false, fmt.Errorf("couldn't check for existing repo retrieval: %v", err)
+	}
+	if existing != nil && !existing.Prepared {
+		// an earlier ingest of this archive didn't finish, so throw away
+		// what it left behind and start over
+		err = co.db.DeleteRepoRetrieval(context.Background(), existing.ID)
+		if err != nil {
+			return false, fmt.Errorf("couldn't delete unprepared repo retrieval: %v", err)
+		}
+	} else if existing != nil {
+		return false, nil
+	}
+
+	pathRoot, err := co.am.UnpackArchive(ctx, repo, archivePath, ai.ArchiveSHA256)
+	if err != nil {
+		return false, fmt.Errorf("couldn't unpack archive: %v", err)
+	}
+
+	repoRetrieval, err := co.db.InsertArchiveRepoRetrieval(repoID, time.Now(), *ai)
+	if err != nil {
+		return false, fmt.Errorf("couldn't insert repo retrieval into DB: %v", err)
+	}
+
+	err = co.prepareUnpackedFiles(ctx, repoID, repoRetrieval.ID, pathRoot)
+	if err == nil {
+		err = co.db.UpdateRepoRetrievalPrepared(repoRetrieval.ID)
+	}
+	if err != nil {
+		return false, co.discardRepoRetrieval(repoRetrieval.ID, err)
+	}
+
+	return true, nil
+}
+
+// DoIngestPackage is the function for JobIngestPackage, and unpacks a local
+// package artifact as a new RepoRetrieval for the given package Repo, then
+// prepares its files in the database and hash manager. The license declared
+// in the package's metadata, if any, is recorded as a FindingDeclared. It
+// returns false if this artifact has already been ingested for the package.
+func (co *Coordinator) DoIngestPackage(ctx context.Context, repoID int, version string, archivePath string) (bool, error) {
+	repo, err := co.db.GetRepoByID(repoID)
+	if err != nil {
+		return false, fmt.Errorf("couldn't get repo from DB: %v", err)
+	}
+
+	ai, err := archivemanager.GetArchiveInfo(archivePath)
+	if err != nil {
+		return false, fmt.Errorf("couldn't read package artifact: %v", err)
+	}
+
+	existing, err := co.db.GetRepoRetrievalByArchive(repoID, ai.ArchiveSHA256)
+	if err != nil {
+		return false, fmt.Errorf("couldn't check for existing repo retrieval: %v", err)
+	}
+	if existing != nil && !existing.Prepared {
+		// an earlier ingest of this artifact didn't finish, so throw away
+		// what it left behind and start over
+		err = co.db.DeleteRepoRetrieval(context.Background(), existing.ID)
+		if err != nil {
+			return false, fmt.Errorf("couldn't delete unprepared repo retrieval: %v", err)
+		}
+	} else if existing != nil {
+		return false, nil
+	}
+
+	unpackedPath, err := co.am.UnpackArchive(ctx, repo, archivePath, ai.ArchiveSHA256)
+	if err != nil {
+		return false, fmt.Errorf("couldn't unpack package artifact: %v", err)
+	}
+
+	pathRoot, err := archivemanager.GetPackageRoot(repo, version, unpackedPath)
+	if err != nil {
+		return false, fmt.Errorf("couldn't find package root: %v", err)
+	}
+
+	declared, source, err := archivemanager.GetDeclaredLicense(repo.Ecosystem, pathRoot)
+	if err != nil {
+		return false, fmt.Errorf("couldn't get declared license: %v", err)
+	}
+
+	purl := archivemanager.GetPURL(repo.Ecosystem, repo.OrgName, repo.RepoName, version)
+	repoRetrieval, err := co.db.InsertPackageRepoRetrieval(repoID, time.Now(), *ai, version, purl)
+	if err != nil {
+		return false, fmt.Errorf("couldn't insert repo retrieval into DB: %v", err)
+	}
+
+	err = co.preparePackageFiles(ctx, repoID, repoRetrieval.ID, pathRoot, declared, source)
+	if err != nil {
+		return false, co.discardRepoRetrieval(repoRetrieval.ID, err)
+	}
+
+	return true, nil
+}
+
+// preparePackageFiles is like prepareUnpackedFiles, but also records the
+// package's declared license, if any, before marking the RepoRetrieval as
+// prepared.
+func (co *Coordinator) preparePackageFiles(ctx context.Context, repoID int, repoRetrievalID int, pathRoot string, declared string, source string) error {
+	err := co.prepareUnpackedFiles(ctx, repoID, repoRetrievalID, pathRoot)
+	if err != nil {
+		return err
+	}
+
+	if declared != "" {
+		_, err = co.insertFinding(repoRetrievalID, 0, database.FindingDeclared, declared, source)
+		if err != nil {
+			return fmt.Errorf("couldn't insert declared license finding: %v", err)
+		}
+	}
+
+	return co.db.UpdateRepoRetrievalPrepared(repoRetrievalID)
+}
+
+// prepareUnpackedFiles sets up the files found under pathRoot for a
+// RepoRetrieval of the given Repo in the database and hash manager.
+func (co *Coordinator) prepareUnpackedFiles(ctx context.Context, repoID int, repoRetrievalID int, pathRoot string) error {
+	allPaths, err := archivemanager.GetAllFilepaths(pathRoot)
+	if err != nil {
+		return fmt.Errorf("couldn't get filepaths: %v", err)
+	}
+
+	pathsToHashes, err := archivemanager.GetFileHashes(ctx, pathRoot, allPaths)
+	if err != nil {
+		return fmt.Errorf("couldn't get file hashes: %v", err)
+	}
+
+	pathsToDetails, err := filetypes.GetAllDiskFileDetails(pathRoot, allPaths)
+	if err != nil {
+		return fmt.Errorf("couldn't get file details: %v", err)
+	}
+
+	pathsToCopy, err := co.classifyFiles(repoID, pathsToHashes, pathsToDetails)
+	if err != nil {
+		return err
+	}
+
+	// hold off garbage collection until the new files are referenced and
+	// copied into the hash store
+	unlock, err := co.hm.LockShared()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = co.insertDirsAndFiles(ctx, repoRetrievalID, allPaths, pathsToHashes, pathsToDetails)
+	if err != nil {
+		return err
+	}
+
+	_, err = co.hm.CopyAllFilesToHash(ctx, pathRoot, pathsToCopy)
+	if err != nil {
+		return fmt.Errorf("couldn't copy files to hashes: %v", err)
+	}
+
+	return co.insertHashFiles(ctx, pathsToCopy)
+}
@@ -0,0 +1,20 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package coordinator
+
+import (
+	"io"
+)
+
+// OpenBlobByHash opens the contents of the file in the hash store with h as
+// any one of its SHA1, SHA256 or MD5 hashes.
+func (co *Coordinator) OpenBlobByHash(h string) (io.ReadCloser, error) {
+	return co.hm.OpenBlobByHash(h)
+}
+
+// OpenRepoFile opens the contents of the RepoFile with the given ID from
+// the hash store.
+func (co *Coordinator) OpenRepoFile(repoFileID int) (io.ReadCloser, error) {
+	return co.hm.OpenBlobForRepoFile(repoFileID)
+}
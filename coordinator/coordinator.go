@@ -6,6 +6,7 @@ package coordinator
 import (
 	"fmt"
 
+	"github.com/swinslow/peridot/archivemanager"
 	"github.com/swinslow/peridot/config"
 	"github.com/swinslow/peridot/database"
 	"github.com/swinslow/peridot/hashmanager"
@@ -17,6 +18,7 @@ import (
 type Coordinator struct {
 	rm  *repomanager.RepoManager
 	hm  *hashmanager.HashManager
+	am  *archivemanager.ArchiveManager
 	db  *database.DB
 	cfg *config.Config
 }
@@ -50,6 +52,12 @@ func (co *Coordinator) Prepare(cfg *config.Config, db *database.DB) error {
 		return err
 	}
 
+	co.am = &archivemanager.ArchiveManager{}
+	err = co.am.PrepareAM(cfg, co.db)
+	if err != nil {
+		return err
+	}
+
 	err = co.db.InsertFromLicenseList(cfg.SPDXLLJSONLocation)
 	if err != nil {
 		return err
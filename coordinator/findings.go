@@ -0,0 +1,26 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package coordinator
+
+import (
+	"github.com/swinslow/peridot/database"
+	"github.com/swinslow/peridot/licenses"
+)
+
+// insertFinding records a license expression as a Finding. If the expression
+// can be parsed and all of its identifiers are known LicenseLeafs, the
+// Finding points to its LicenseNode; otherwise the expression is kept as
+// text only, with a LicenseNodeID of 0.
+func (co *Coordinator) insertFinding(repoRetrievalID int, repoFileID int, findingType int, expr string, source string) (*database.Finding, error) {
+	nodeID := 0
+	pln, err := licenses.GetNodesForExpression(expr)
+	if err == nil && pln != nil {
+		ln, err := co.db.CheckAndInsertLicenseNodes(pln)
+		if err == nil && ln != nil {
+			nodeID = ln.ID
+		}
+	}
+
+	return co.db.InsertFinding(repoRetrievalID, repoFileID, findingType, expr, nodeID, source)
+}
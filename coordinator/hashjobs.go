@@ -0,0 +1,400 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/swinslow/peridot/hashmanager"
+	"github.com/swinslow/peridot/progress"
+)
+
+// VerifyOptions configures DoVerifyHashes. Workers is the number of blobs
+// to re-hash in parallel, or 0 for one per CPU. If Resume is true, blobs
+// already checked by an interrupted run are skipped. If Quarantine is true,
+// corrupt blobs are moved out of the hash store.
+type VerifyOptions struct {
+	Workers    int
+	Resume     bool
+	Quarantine bool
+}
+
+// VerifyReport is the result of DoVerifyHashes. Checked is the number of
+// blobs re-hashed in this run; Issues includes those found by an earlier,
+// resumed run.
+type VerifyReport struct {
+	Checked int
+	Issues  []*hashmanager.VerifyIssue
+}
+
+// checkpointEvery is how many blobs are checked between saves of the
+// verify state.
+const checkpointEvery = 1000
+
+type verifyResult struct {
+	index int
+	issue *hashmanager.VerifyIssue
+}
+
+// DoVerifyHashes is the function for JobVerifyHashes, and re-hashes every
+// blob in the hash store, comparing it against its path and against the
+// hashfiles table. If ctx is cancelled, its progress is saved so that it
+// can be resumed, and the partial report is returned along with ctx's
+// error.
+func (co *Coordinator) DoVerifyHashes(ctx context.Context, opts VerifyOptions) (*VerifyReport, error) {
+	// a concurrent garbage collection would show up as missing blobs
+	unlock, err := co.hm.LockShared()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	hashFiles, err := co.db.GetAllHashFiles()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get hash files from DB: %v", err)
+	}
+	// blobs are named by SHA1 and SHA256, so look up MD5s by those
+	md5s := make(map[string]string, len(hashFiles))
+	for _, hf := range hashFiles {
+		md5s[hf.HashSHA1+"."+hf.HashSHA256] = hf.HashMD5
+	}
+
+	blobs, invalid, err := co.hm.ListBlobs()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list blobs in hash store: %v", err)
+	}
+
+	state := &hashmanager.VerifyState{}
+	if opts.Resume {
+		saved, err := co.hm.LoadVerifyState()
+		if err != nil {
+			return nil, err
+		}
+		if saved != nil {
+			state = saved
+		}
+	}
+
+	report := &VerifyReport{}
+
+	// missing and invalid files are found from the listing alone, so they
+	// are cheap to find again on every run
+	onDisk := make(map[string]bool, len(blobs))
+	for _, b := range blobs {
+		onDisk[b.HashSHA1+"."+b.HashSHA256] = true
+	}
+	for _, hf := range hashFiles {
+		if !onDisk[hf.HashSHA1+"."+hf.HashSHA256] {
+			report.Issues = append(report.Issues, &hashmanager.VerifyIssue{
+				Kind:       hashmanager.IssueMissing,
+				Key:        hashmanager.GetKeyForHash(hf.HashSHA1, hf.HashSHA256, hf.HashMD5),
+				Path:       co.hm.GetPathToHash(hf.HashSHA1, hf.HashSHA256, hf.HashMD5),
+				HashSHA1:   hf.HashSHA1,
+				HashSHA256: hf.HashSHA256,
+				Detail:     "in database but not in hash store",
+			})
+		}
+	}
+	for _, key := range invalid {
+		report.Issues = append(report.Issues, &hashmanager.VerifyIssue{
+			Kind:   hashmanager.IssueOrphaned,
+			Key:    key,
+			Path:   co.hm.Store.Location(key),
+			Detail: "not named like a blob",
+		})
+	}
+	report.Issues = append(report.Issues, state.Issues...)
+
+	var toCheck []hashmanager.Blob
+	for _, b := range blobs {
+		if b.Key > state.LastKey {
+			toCheck = append(toCheck, b)
+		}
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan int)
+	results := make(chan verifyResult)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				b := toCheck[i]
+				results <- verifyResult{i, co.verifyBlob(b, md5s, opts.Quarantine)}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range toCheck {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// results arrive out of order, so the checkpoint only advances past
+	// blobs once every blob before them is done too
+	t := progress.Start(ctx, progress.PhaseVerify, len(toCheck))
+	done := make([]bool, len(toCheck))
+	next := 0
+	var saveErr error
+	for r := range results {
+		done[r.index] = true
+		report.Checked++
+		if r.issue != nil {
+			report.Issues = append(report.Issues, r.issue)
+			state.Issues = append(state.Issues, r.issue)
+		}
+		for next < len(done) && done[next] {
+			state.LastKey = toCheck[next].Key
+			next++
+		}
+		// keep draining results after a failed save, so no worker is stuck
+		if report.Checked%checkpointEvery == 0 && saveErr == nil {
+			saveErr = co.hm.SaveVerifyState(state)
+		}
+		t.Add(1, 0)
+	}
+	if saveErr != nil {
+		return report, fmt.Errorf("couldn't save verify state: %v", saveErr)
+	}
+
+	if err := ctx.Err(); err != nil {
+		// issues past the checkpoint will be found again on resume
+		var kept []*hashmanager.VerifyIssue
+		for _, issue := range state.Issues {
+			if issue.Key <= state.LastKey {
+				kept = append(kept, issue)
+			}
+		}
+		state.Issues = kept
+		saveErr = co.hm.SaveVerifyState(state)
+		if saveErr != nil {
+			return report, fmt.Errorf("couldn't save verify state: %v", saveErr)
+		}
+		return report, err
+	}
+
+	err = co.hm.ClearVerifyState()
+	if err != nil {
+		return report, fmt.Errorf("couldn't clear verify state: %v", err)
+	}
+	return report, nil
+}
+
+// verifyBlob checks a single blob, returning an issue if it is corrupt or
+// orphaned, or nil if it is fine.
+func (co *Coordinator) verifyBlob(b hashmanager.Blob, md5s map[string]string, quarantine bool) *hashmanager.VerifyIssue {
+	hMD5, inDB := md5s[b.HashSHA1+"."+b.HashSHA256]
+	issue := &hashmanager.VerifyIssue{
+		Key:        b.Key,
+		Path:       co.hm.Store.Location(b.Key),
+		HashSHA1:   b.HashSHA1,
+		HashSHA256: b.HashSHA256,
+	}
+
+	detail, err := co.hm.VerifyBlob(b, hMD5)
+	if err != nil {
+		detail = fmt.Sprintf("couldn't read: %v", err)
+	}
+	if detail == "" {
+		if inDB {
+			return nil
+		}
+		issue.Kind = hashmanager.IssueOrphaned
+		issue.Detail = "in hash store but not in database"
+		return issue
+	}
+
+	issue.Kind = hashmanager.IssueCorrupt
+	issue.Detail = detail
+	if quarantine && !os.IsNotExist(err) {
+		newPath, qErr := co.hm.QuarantineBlob(b.Key)
+		if qErr != nil {
+			issue.Detail += fmt.Sprintf("; %v", qErr)
+		} else {
+			issue.Quarantined = true
+			issue.Detail += "; moved to " + newPath
+		}
+	}
+	return issue
+}
+
+// GCOptions configures DoCollectGarbage. Unreferenced blobs modified within
+// GracePeriod are kept. If DryRun is true, nothing is removed, and the
+// report describes what would have been.
+type GCOptions struct {
+	GracePeriod time.Duration
+	DryRun      bool
+}
+
+// GCReport is the result of DoCollectGarbage. Removed lists the locations of
+// the blobs removed, and Bytes their total size. Kept is the number of
+// unreferenced blobs kept because they are within the grace period.
+type GCReport struct {
+	Removed          []string
+	Bytes            int64
+	HashFilesRemoved int
+	Kept             int
+}
+
+// DoCollectGarbage is the function for JobCollectGarbage, and removes blobs
+// and hashfiles that aren't referenced by any RepoFile. It fails straight
+// away if files are being prepared, rather than waiting for them. If ctx is
+// cancelled partway through, blobs not yet removed are left for the next
+// run, and the partial report is returned along with ctx's error.
+func (co *Coordinator) DoCollectGarbage(ctx context.Context, opts GCOptions) (*GCReport, error) {
+	unlock, err := co.hm.TryLockExclusive()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// mark
+	referenced, err := co.db.GetReferencedHashes()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get referenced hashes from DB: %v", err)
+	}
+	hashFiles, err := co.db.GetAllHashFiles()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get hash files from DB: %v", err)
+	}
+	blobs, _, err := co.hm.ListBlobs()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list blobs in hash store: %v", err)
+	}
+
+	report := &GCReport{}
+	cutoff := time.Now().Add(-opts.GracePeriod)
+	var toRemove []hashmanager.Blob
+	kept := map[[2]string]bool{}
+	for _, b := range blobs {
+		hashes := [2]string{b.HashSHA1, b.HashSHA256}
+		if referenced[hashes] {
+			continue
+		}
+		if b.ModTime.After(cutoff) {
+			kept[hashes] = true
+			report.Kept++
+			continue
+		}
+		toRemove = append(toRemove, b)
+		report.Removed = append(report.Removed, co.hm.Store.Location(b.Key))
+		report.Bytes += b.Size
+	}
+
+	// hashfiles go along with their blobs, or on their own if their blob
+	// is already missing
+	var hashFileIDs []int
+	for _, hf := range hashFiles {
+		hashes := [2]string{hf.HashSHA1, hf.HashSHA256}
+		if !referenced[hashes] && !kept[hashes] {
+			hashFileIDs = append(hashFileIDs, hf.ID)
+		}
+	}
+	report.HashFilesRemoved = len(hashFileIDs)
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	// sweep, dropping hashfiles first so that an interrupted sweep only
+	// leaves orphaned blobs, which the next run will find again
+	err = co.db.BulkDeleteHashFiles(ctx, hashFileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't delete hash files from DB: %v", err)
+	}
+
+	t := progress.Start(ctx, progress.PhaseGC, len(toRemove))
+	report.Removed = nil
+	report.Bytes = 0
+	for _, b := range toRemove {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		size, err := co.hm.RemoveBlob(b.Key)
+		if err != nil {
+			return report, fmt.Errorf("couldn't remove blob: %v", err)
+		}
+		report.Removed = append(report.Removed, co.hm.Store.Location(b.Key))
+		report.Bytes += size
+		t.Add(1, size)
+	}
+
+	return report, nil
+}
+
+// CompressReport is the result of DoCompressHashes. Converted is the number
+// of blobs rewritten, and BytesBefore and BytesAfter are their total sizes
+// before and after.
+type CompressReport struct {
+	Converted   int
+	BytesBefore int64
+	BytesAfter  int64
+}
+
+// DoCompressHashes is the function for JobCompressHashes, and rewrites each
+// blob in the hash store that isn't already stored with compression c. It
+// fails straight away if files are being prepared, rather than waiting for
+// them. If ctx is cancelled, blobs already rewritten are kept, and running
+// it again continues from where it left off.
+func (co *Coordinator) DoCompressHashes(ctx context.Context, c string) (*CompressReport, error) {
+	if !hashmanager.IsValidCompression(c) {
+		return nil, fmt.Errorf("invalid compression %s", c)
+	}
+
+	unlock, err := co.hm.TryLockExclusive()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	blobs, _, err := co.hm.ListBlobs()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list blobs in hash store: %v", err)
+	}
+
+	var toConvert []hashmanager.Blob
+	for _, b := range blobs {
+		if b.Compression != c {
+			toConvert = append(toConvert, b)
+		}
+	}
+
+	report := &CompressReport{}
+	t := progress.Start(ctx, progress.PhaseCompress, len(toConvert))
+	for _, b := range toConvert {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		_, before, after, err := co.hm.RecompressBlob(b, c)
+		if err != nil {
+			return report, fmt.Errorf("couldn't compress %s: %v", co.hm.Store.Location(b.Key), err)
+		}
+		report.Converted++
+		report.BytesBefore += before
+		report.BytesAfter += after
+		t.Add(1, before)
+	}
+
+	return report, nil
+}
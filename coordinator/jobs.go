@@ -25,12 +25,50 @@ const (
 	// and hash manager
 	JobPrepareFiles
 
+	// JobBackfillRepo signifies a job to create RepoRetrievals for
+	// historical commits or tags of an existing, previously-cloned repo,
+	// and to prepare their files from each commit's tree
+	JobBackfillRepo
+
+	// JobIngestArchive signifies a job to unpack a local source archive
+	// (tarball or zip file) as a new retrieval for a repo, and to prepare
+	// its files
+	JobIngestArchive
+
+	// JobIngestPackage signifies a job to unpack a local package artifact
+	// (npm, PyPI, Go module or Maven) as a new retrieval for a package, and
+	// to prepare its files and declared license
+	JobIngestPackage
+
+	// ===== SPDX =====
+
+	// JobImportSPDX signifies a job to record the licenses in an SPDX
+	// document as findings for the files that it describes
+	JobImportSPDX
+
+	// JobExportSPDX signifies a job to build an SPDX document describing a
+	// retrieval's files and the licenses found for them
+	JobExportSPDX
+
 	// ===== Maintenance =====
 
 	// JobReset signifies a job that is called to partially reset peridot by
 	// dropping all DB tables; USE CAUTION before calling this!
 	JobReset
 
-	// TO DO: Scan through database, repos and hash manager and check for
-	// inconsistencies
+	// JobVerifyHashes signifies a job to check every blob in the hash store
+	// against its hashes and the database, reporting missing, corrupt and
+	// orphaned blobs
+	JobVerifyHashes
+
+	// JobCollectGarbage signifies a job to remove blobs and hashfiles that
+	// are no longer referenced by any RepoFile from the hash store
+	JobCollectGarbage
+
+	// JobCompressHashes signifies a job to rewrite every blob in the hash
+	// store with a given compression, such as after changing the configured
+	// compression
+	JobCompressHashes
+
+	// TO DO: Scan through database and repos and check for inconsistencies
 )
@@ -4,20 +4,24 @@
 package coordinator
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/swinslow/peridot/database"
+	"github.com/swinslow/peridot/pathrules"
+	"github.com/swinslow/peridot/progress"
 )
 
 // DoCloneRepo is the function for JobCloneRepo, and performs the first
 // retrieval of files for a new repo.
-func (co *Coordinator) DoCloneRepo(repoID int) error {
+func (co *Coordinator) DoCloneRepo(ctx context.Context, repoID int) error {
 	repo, err := co.db.GetRepoByID(repoID)
 	if err != nil {
 		return fmt.Errorf("couldn't get repo data from DB: %v", err)
 	}
 
-	err = co.rm.CloneRepo(repo)
+	err = co.rm.CloneRepo(ctx, repo)
 	if err != nil {
 		return fmt.Errorf("couldn't clone repo: %v", err)
 	}
@@ -27,86 +31,329 @@ func (co *Coordinator) DoCloneRepo(repoID int) error {
 
 // DoUpdateRepo is the function for JobUpdateRepo, and returns true if
 // an update occurred, or false if there are no changes to the repo.
-func (co *Coordinator) DoUpdateRepo(repoID int) (bool, error) {
+func (co *Coordinator) DoUpdateRepo(ctx context.Context, repoID int) (bool, error) {
 	repo, err := co.db.GetRepoByID(repoID)
 	if err != nil {
 		return false, fmt.Errorf("couldn't get repo from DB: %v", err)
 	}
 
-	repoRetBefore, err := co.db.GetRepoRetrievalLatest(repoID)
-	if err != nil {
-		return false, fmt.Errorf("couldn't get repo retrieval from DB before update: %v", err)
-	}
-
-	err = co.rm.UpdateRepo(repo)
+	err = co.rm.UpdateRepo(ctx, repo)
 	if err != nil {
 		return false, fmt.Errorf("couldn't checking remote for updates: %v", err)
 	}
 
-	repoRetAfter, err := co.db.GetRepoRetrievalLatest(repoID)
+	// if there was an update, a new RepoRetrieval was created, and its
+	// files aren't prepared yet
+	repoRetAfter, err := co.db.GetRepoRetrievalLatestUnprepared(repoID)
 	if err != nil {
 		return false, fmt.Errorf("couldn't get repo retrieval from DB after update: %v", err)
 	}
 
-	if repoRetBefore.ID == repoRetAfter.ID {
-		// no update occurred
-		return false, nil
-	}
-
-	// a new RepoRetrieval was created, so there was an update
-	return true, nil
+	return repoRetAfter != nil, nil
 }
 
 // DoPrepareFiles is the function for JobPrepareFiles, and is called after a
 // JobCloneRepo or JobUpdateRepo to set up the files in the repo and hash
-// managers.
-func (co *Coordinator) DoPrepareFiles(repoID int) error {
+// managers. If this fails, the new RepoRetrieval is deleted, so that it
+// isn't mistaken for a complete one.
+func (co *Coordinator) DoPrepareFiles(ctx context.Context, repoID int) error {
 	repo, err := co.db.GetRepoByID(repoID)
 	if err != nil {
 		return fmt.Errorf("couldn't get repo from DB: %v", err)
 	}
 
-	repoRetrieval, err := co.db.GetRepoRetrievalLatest(repoID)
+	repoRetrieval, err := co.db.GetRepoRetrievalLatestUnprepared(repoID)
 	if err != nil {
 		return fmt.Errorf("couldn't get repo retrieval from DB: %v", err)
 	}
+	if repoRetrieval == nil {
+		return fmt.Errorf("no repo retrieval is waiting to be prepared")
+	}
 
+	err = co.prepareFilesFromWorktree(ctx, repo, repoRetrieval)
+	if err == nil {
+		err = co.db.UpdateRepoRetrievalPrepared(repoRetrieval.ID)
+	}
+	if err != nil {
+		return co.discardRepoRetrieval(repoRetrieval.ID, err)
+	}
+
+	return nil
+}
+
+// prepareFilesFromWorktree sets up the directories, files and contents of
+// the repo's worktree on disk for repoRetrieval.
+func (co *Coordinator) prepareFilesFromWorktree(ctx context.Context, repo *database.Repo, repoRetrieval *database.RepoRetrieval) error {
 	allPaths, err := co.rm.GetAllFilepaths(repo)
 	if err != nil {
 		return fmt.Errorf("couldn't get filepaths for repo: %v", err)
 	}
 
-	dirPaths := database.ExtractDirsFromPaths(allPaths)
+	pathsToHashes, err := co.rm.GetFileHashes(ctx, repo)
+	if err != nil {
+		return fmt.Errorf("couldn't get file hashes: %v", err)
+	}
 
-	// split and add directories to DB
-	err = co.db.BulkInsertRepoDirs(repoRetrieval.ID, dirPaths)
+	pathsToDetails, err := co.rm.GetFileDetails(repo)
 	if err != nil {
-		return fmt.Errorf("couldn't insert repo directories into DB: %v", err)
+		return fmt.Errorf("couldn't get file details: %v", err)
 	}
 
-	pathsToHashes, err := co.rm.GetFileHashes(repo)
+	// classify files using the repo's path rules
+	pathsToCopy, err := co.classifyFiles(repo.ID, pathsToHashes, pathsToDetails)
 	if err != nil {
-		return fmt.Errorf("couldn't get file hashes: %v", err)
+		return err
 	}
 
-	// add files to DB for this repo
-	err = co.db.BulkInsertRepoFiles(repoRetrieval.ID, pathsToHashes)
+	// hold off garbage collection until the new files are referenced and
+	// copied into the hash store
+	unlock, err := co.hm.LockShared()
 	if err != nil {
-		return fmt.Errorf("couldn't insert repo files into DB: %v", err)
+		return err
+	}
+	defer unlock()
+
+	// add directories and files to DB for this repo
+	err = co.insertDirsAndFiles(ctx, repoRetrieval.ID, allPaths, pathsToHashes, pathsToDetails)
+	if err != nil {
+		return err
 	}
 
-	// also add files to hashmanager
+	// also add files to hashmanager, other than excluded ones
 	pathRoot := co.rm.GetPathToRepo(repo)
-	copiedPathsToHashes, err := co.hm.CopyAllFilesToHash(pathRoot, pathsToHashes)
+	_, err = co.hm.CopyAllFilesToHash(ctx, pathRoot, pathsToCopy)
 	if err != nil {
 		return fmt.Errorf("couldn't copy files to hashes: %v", err)
 	}
 
-	// and finally add just the newly-copied files as hashfiles to DB
-	err = co.db.BulkInsertHashFiles(copiedPathsToHashes)
+	// and finally add the files as hashfiles to DB
+	return co.insertHashFiles(ctx, pathsToCopy)
+}
+
+// DoBackfillTags is a function for JobBackfillRepo, and creates and prepares
+// a backfilled RepoRetrieval for each commit pointed to by one of the repo's
+// tags. It returns the number of new RepoRetrievals created; commits that
+// already have a RepoRetrieval are skipped.
+func (co *Coordinator) DoBackfillTags(ctx context.Context, repoID int) (int, error) {
+	repo, err := co.db.GetRepoByID(repoID)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get repo from DB: %v", err)
+	}
+
+	cis, err := co.rm.GetTagCommits(repo)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get tags for repo: %v", err)
+	}
+
+	return co.backfillCommits(ctx, repo, cis)
+}
+
+// DoBackfillCommits is a function for JobBackfillRepo, and creates and
+// prepares a backfilled RepoRetrieval for each commit in the range
+// from..to. It returns the number of new RepoRetrievals created; commits
+// that already have a RepoRetrieval are skipped.
+func (co *Coordinator) DoBackfillCommits(ctx context.Context, repoID int, from string, to string) (int, error) {
+	repo, err := co.db.GetRepoByID(repoID)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get repo from DB: %v", err)
+	}
+
+	cis, err := co.rm.GetCommitRange(repo, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get commits for repo: %v", err)
+	}
+
+	return co.backfillCommits(ctx, repo, cis)
+}
+
+func (co *Coordinator) backfillCommits(ctx context.Context, repo *database.Repo, cis []*database.CommitInfo) (int, error) {
+	count := 0
+	t := progress.Start(ctx, progress.PhaseBackfill, len(cis))
+	for _, ci := range cis {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		existing, err := co.db.GetRepoRetrievalByCommit(repo.ID, ci.CommitHash)
+		if err != nil {
+			return count, fmt.Errorf("couldn't check for existing repo retrieval for %s: %v", ci.CommitHash, err)
+		}
+		if existing != nil && existing.IsBackfill && !existing.Prepared {
+			// an earlier backfill was interrupted part-way through this
+			// commit, so throw away what it left behind and start over
+			err = co.db.DeleteRepoRetrieval(context.Background(), existing.ID)
+			if err != nil {
+				return count, fmt.Errorf("couldn't delete unprepared repo retrieval for %s: %v", ci.CommitHash, err)
+			}
+		} else if existing != nil {
+			t.Add(1, 0)
+			continue
+		}
+
+		repoRetrieval, err := co.db.InsertBackfillRepoRetrieval(repo.ID, time.Now(), *ci)
+		if err != nil {
+			return count, fmt.Errorf("couldn't insert repo retrieval for %s: %v", ci.CommitHash, err)
+		}
+
+		err = co.prepareFilesFromCommit(ctx, repo, repoRetrieval)
+		if err == nil {
+			err = co.db.UpdateRepoRetrievalPrepared(repoRetrieval.ID)
+		}
+		if err != nil {
+			return count, co.discardRepoRetrieval(repoRetrieval.ID,
+				fmt.Errorf("couldn't prepare files for %s: %v", ci.CommitHash, err))
+		}
+		count++
+		t.Add(1, 0)
+	}
+
+	return count, nil
+}
+
+// discardRepoRetrieval deletes a RepoRetrieval whose files could not be
+// fully prepared, so that a later run will retry it rather than skip it. It
+// returns prepErr, together with any error from the cleanup itself.
+func (co *Coordinator) discardRepoRetrieval(repoRetrievalID int, prepErr error) error {
+	// use a fresh context, since prepErr may be from ctx being cancelled
+	err := co.db.DeleteRepoRetrieval(context.Background(), repoRetrievalID)
+	if err != nil {
+		return fmt.Errorf("%v (and couldn't delete repo retrieval %d: %v)", prepErr, repoRetrievalID, err)
+	}
+	return prepErr
+}
+
+// prepareFilesFromCommit is like prepareFilesFromWorktree, but takes the directories,
+// files and contents from the retrieval's commit tree rather than from the
+// repo's worktree on disk.
+func (co *Coordinator) prepareFilesFromCommit(ctx context.Context, repo *database.Repo, repoRetrieval *database.RepoRetrieval) error {
+	allPaths, err := co.rm.GetAllFilepathsForCommit(repo, repoRetrieval.CommitHash)
+	if err != nil {
+		return fmt.Errorf("couldn't get filepaths for commit: %v", err)
+	}
+
+	pathsToHashes, err := co.rm.GetFileHashesForCommit(ctx, repo, repoRetrieval.CommitHash)
+	if err != nil {
+		return fmt.Errorf("couldn't get file hashes: %v", err)
+	}
+
+	pathsToDetails, err := co.rm.GetFileDetailsForCommit(repo, repoRetrieval.CommitHash)
+	if err != nil {
+		return fmt.Errorf("couldn't get file details: %v", err)
+	}
+
+	pathsToCopy, err := co.classifyFiles(repo.ID, pathsToHashes, pathsToDetails)
+	if err != nil {
+		return err
+	}
+
+	// hold off garbage collection until the new files are referenced and
+	// copied into the hash store
+	unlock, err := co.hm.LockShared()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = co.insertDirsAndFiles(ctx, repoRetrieval.ID, allPaths, pathsToHashes, pathsToDetails)
+	if err != nil {
+		return err
+	}
+
+	// blobs already in the hash store are reused, not re-copied
+	opener, err := co.rm.GetCommitFileOpener(repo, repoRetrieval.CommitHash)
+	if err != nil {
+		return fmt.Errorf("couldn't open commit tree: %v", err)
+	}
+	_, err = co.hm.CopyAllFilesToHashWithOpener(ctx, opener, pathsToCopy)
+	if err != nil {
+		return fmt.Errorf("couldn't copy files to hashes: %v", err)
+	}
+
+	return co.insertHashFiles(ctx, pathsToCopy)
+}
+
+// insertHashFiles adds a HashFile to the database for each of the hashes in
+// pathsToHashes that doesn't already have one. This includes blobs that
+// were already in the hash store, not just newly-copied ones, since an
+// earlier attempt that failed part-way may have stored them without
+// recording them.
+func (co *Coordinator) insertHashFiles(ctx context.Context, pathsToHashes map[string]database.Hashes) error {
+	hashFiles, err := co.db.GetAllHashFiles()
+	if err != nil {
+		return fmt.Errorf("couldn't get hash files from DB: %v", err)
+	}
+	known := make(map[[2]string]bool, len(hashFiles))
+	for _, hf := range hashFiles {
+		known[[2]string{hf.HashSHA1, hf.HashSHA256}] = true
+	}
+
+	missing := make(map[string]database.Hashes)
+	for path, hashes := range pathsToHashes {
+		key := [2]string{hashes.SHA1, hashes.SHA256}
+		if !known[key] {
+			missing[path] = hashes
+			known[key] = true
+		}
+	}
+
+	err = co.db.BulkInsertHashFiles(ctx, missing)
 	if err != nil {
 		return fmt.Errorf("couldn't insert hash files into DB: %v", err)
 	}
+	return nil
+}
+
+// insertDirsAndFiles adds the RepoDirs and RepoFiles for a RepoRetrieval to
+// the database, and records the RepoRetrieval's package verification code.
+func (co *Coordinator) insertDirsAndFiles(ctx context.Context, repoRetrievalID int, allPaths []string, pathsToHashes map[string]database.Hashes, pathsToDetails map[string]database.FileDetails) error {
+	dirPaths := database.ExtractDirsFromPaths(allPaths)
+	t := progress.Start(ctx, progress.PhaseInsert, len(dirPaths)+len(pathsToHashes))
+
+	// split and add directories to DB
+	err := co.db.BulkInsertRepoDirs(ctx, repoRetrievalID, dirPaths)
+	if err != nil {
+		return fmt.Errorf("couldn't insert repo directories into DB: %v", err)
+	}
+
+	t.Add(len(dirPaths), 0)
+
+	// add files to DB
+	err = co.db.BulkInsertRepoFiles(ctx, repoRetrievalID, pathsToHashes, pathsToDetails)
+	if err != nil {
+		return fmt.Errorf("couldn't insert repo files into DB: %v", err)
+	}
+	t.Add(len(pathsToHashes), 0)
+
+	// record the SPDX package verification code for the files, as it will
+	// appear in an exported SPDX document
+	code, _ := packageVerificationCode(pathsToHashes, pathsToDetails)
+	err = co.db.UpdateRepoRetrievalVerificationCode(repoRetrievalID, code)
+	if err != nil {
+		return fmt.Errorf("couldn't update verification code in DB: %v", err)
+	}
 
 	return nil
 }
+
+// classifyFiles fills in the Classification in pathsToDetails for each file,
+// using the Repo's PathRules. It returns the subset of pathsToHashes that
+// should be copied into the hash store, leaving out excluded files.
+func (co *Coordinator) classifyFiles(repoID int, pathsToHashes map[string]database.Hashes, pathsToDetails map[string]database.FileDetails) (map[string]database.Hashes, error) {
+	rules, err := co.db.GetPathRulesForRepo(repoID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get path rules from DB: %v", err)
+	}
+
+	pathsToCopy := make(map[string]database.Hashes)
+	for path, hashes := range pathsToHashes {
+		fd := pathsToDetails[path]
+		fd.Classification = pathrules.Classify(rules, path)
+		pathsToDetails[path] = fd
+
+		if fd.Classification != database.ClassExcluded {
+			pathsToCopy[path] = hashes
+		}
+	}
+
+	return pathsToCopy, nil
+}
@@ -0,0 +1,740 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package coordinator
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/swinslow/peridot/archivemanager"
+	"github.com/swinslow/peridot/database"
+	"github.com/swinslow/peridot/licenses"
+	"github.com/swinslow/peridot/spdxtvmanager"
+)
+
+// ImportReport describes the results of importing an SPDX document.
+// Unmatched lists the paths of files in the document that matched no
+// RepoFile, and Unparsed lists the license expressions that were recorded
+// as text only because they couldn't be parsed. VerificationMismatch is
+// set if the document's package verification code doesn't match the
+// RepoRetrieval's files. LicenseRefsAdded counts the document's LicenseRef-
+// licenses that were new to the catalog, and RenamedLicenseRefs maps the
+// identifiers of those that were recorded under a different identifier to
+// that identifier.
+type ImportReport struct {
+	MatchedByChecksum    int
+	MatchedByPath        int
+	Unmatched            []string
+	FindingsAdded        int
+	Unparsed             []string
+	VerificationMismatch *VerificationMismatch
+	LicenseRefsAdded     int
+	RenamedLicenseRefs   map[string]string
+}
+
+// VerificationMismatch is a package verification code declared in an SPDX
+// document that differs from the one Computed from a RepoRetrieval's files,
+// which means that the document describes a different set of files.
+type VerificationMismatch struct {
+	Package  string
+	Declared string
+	Computed string
+}
+
+// DoImportSPDX records the licenses concluded and found in each file of
+// an SPDX document as Findings, with source as their Source. Files are
+// matched to RepoFiles by their SHA1 and SHA256 checksums. If
+// repoRetrievalID is non-zero, only that RepoRetrieval's files are
+// considered, and files without a checksum match are matched by path
+// instead; otherwise, every RepoFile in the catalog with matching checksums
+// gets the Findings. If the document describes a single package for the
+// RepoRetrieval, its verification code is also checked against the
+// RepoRetrieval's files, and any mismatch is noted in the ImportReport.
+// The document's LicenseRef- licenses are first recorded as LicenseLeafs,
+// as described for importLicenseRefs. If ctx is cancelled, it stops before
+// the next file, and keeps the Findings already added.
+func (co *Coordinator) DoImportSPDX(ctx context.Context, doc *spdxtvmanager.Document, source string, repoRetrievalID int) (*ImportReport, error) {
+	imp, err := co.newSPDXImporter(source, repoRetrievalID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = co.importLicenseRefs(imp.report, doc.OtherLicenses)
+	if err != nil {
+		return imp.report, err
+	}
+	imp.licenseRefsKnown = true
+
+	for _, pkg := range doc.Packages {
+		imp.addPackage(pkg)
+	}
+	for _, f := range doc.Files {
+		if err := ctx.Err(); err != nil {
+			return imp.report, err
+		}
+		if err := imp.importFile(f); err != nil {
+			return imp.report, err
+		}
+	}
+
+	return imp.report, imp.finish()
+}
+
+// DoImportSPDXStream is like DoImportSPDX, but reads an SPDX tag-value
+// document from r with spdxtvmanager.Stream, and records each file's
+// Findings as soon as the file has been read, so that very large documents
+// don't need to be held in memory. Since LicenseRef- licenses are defined
+// at the end of a document, Findings that refer to them are held back
+// until the end. If the document has an error, the Findings for the files
+// before it are kept.
+func (co *Coordinator) DoImportSPDXStream(ctx context.Context, r io.Reader, source string, repoRetrievalID int) (*ImportReport, error) {
+	imp, err := co.newSPDXImporter(source, repoRetrievalID)
+	if err != nil {
+		return nil, err
+	}
+
+	var others []*spdxtvmanager.OtherLicense
+	err = spdxtvmanager.Stream(r, &spdxtvmanager.StreamHandler{
+		Package: func(pkg *spdxtvmanager.Package) error {
+			imp.addPackage(pkg)
+			return nil
+		},
+		File: func(f *spdxtvmanager.File, pkg *spdxtvmanager.Package) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return imp.importFile(f)
+		},
+		OtherLicense: func(ol *spdxtvmanager.OtherLicense) error {
+			others = append(others, ol)
+			return nil
+		},
+	})
+	if err != nil {
+		return imp.report, err
+	}
+
+	err = co.importLicenseRefs(imp.report, others)
+	if err != nil {
+		return imp.report, err
+	}
+	imp.licenseRefsKnown = true
+	for _, df := range imp.deferred {
+		err = imp.importFinding(df.repoRetrievalID, df.repoFileID, df.findingType, df.expr)
+		if err != nil {
+			return imp.report, err
+		}
+	}
+	imp.deferred = nil
+
+	return imp.report, imp.finish()
+}
+
+// spdxImporter records the Findings from an SPDX document for
+// DoImportSPDX and DoImportSPDXStream, one part of the document at a time.
+type spdxImporter struct {
+	co              *Coordinator
+	report          *ImportReport
+	unparsed        map[string]bool
+	source          string
+	repoRetrievalID int
+	repoFiles       map[int]*database.RepoFile
+	idx             *repoFileIndex
+	packages        []*spdxtvmanager.Package
+
+	// until the document's LicenseRef- licenses have been recorded,
+	// Findings that refer to them are deferred
+	licenseRefsKnown bool
+	deferred         []deferredFinding
+}
+
+type deferredFinding struct {
+	repoRetrievalID int
+	repoFileID      int
+	findingType     int
+	expr            string
+}
+
+func (co *Coordinator) newSPDXImporter(source string, repoRetrievalID int) (*spdxImporter, error) {
+	imp := &spdxImporter{
+		co:              co,
+		report:          &ImportReport{RenamedLicenseRefs: map[string]string{}},
+		unparsed:        map[string]bool{},
+		source:          source,
+		repoRetrievalID: repoRetrievalID,
+	}
+	if repoRetrievalID != 0 {
+		repoFiles, err := co.db.GetRepoFilesForRepoRetrieval(repoRetrievalID)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get repo files from DB: %v", err)
+		}
+		imp.repoFiles = repoFiles
+		imp.idx = newRepoFileIndex(repoFiles)
+	}
+	return imp, nil
+}
+
+// addPackage notes a package in the document. Its Files aren't used.
+func (imp *spdxImporter) addPackage(pkg *spdxtvmanager.Package) {
+	imp.packages = append(imp.packages, pkg)
+}
+
+// importFile records the Findings for a file in the document.
+func (imp *spdxImporter) importFile(f *spdxtvmanager.File) error {
+	report := imp.report
+	sha1, sha256 := f.Checksums["SHA1"], f.Checksums["SHA256"]
+	var matches []*database.RepoFile
+	if imp.idx != nil {
+		var byPath bool
+		matches, byPath = imp.idx.match(sha1, sha256, normalizeSPDXPath(f.Path))
+		if byPath {
+			report.MatchedByPath++
+		} else if len(matches) > 0 {
+			report.MatchedByChecksum++
+		}
+	} else if sha1 != "" || sha256 != "" {
+		var err error
+		matches, err = imp.co.db.GetRepoFilesByHashes(sha1, sha256)
+		if err != nil {
+			return fmt.Errorf("couldn't look up repo files for %s in DB: %v", f.Path, err)
+		}
+		if len(matches) > 0 {
+			report.MatchedByChecksum++
+		}
+	}
+	if len(matches) == 0 {
+		report.Unmatched = append(report.Unmatched, f.Path)
+		return nil
+	}
+
+	for _, rf := range matches {
+		if isAssertedLicense(f.LicenseConcluded) || f.LicenseConcluded == "NONE" {
+			err := imp.importFinding(rf.RepoRetrievalID, rf.ID, database.FindingConcluded, f.LicenseConcluded)
+			if err != nil {
+				return err
+			}
+		}
+		for _, expr := range f.LicenseInfoInFile {
+			if !isAssertedLicense(expr) {
+				continue
+			}
+			err := imp.importFinding(rf.RepoRetrievalID, rf.ID, database.FindingInFile, expr)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// finish records what depends on the document as a whole, once all of its
+// files have been imported. A document with just one package describes
+// the RepoRetrieval as a whole, so its declared license applies to it, and
+// its verification code should match the RepoRetrieval's files.
+func (imp *spdxImporter) finish() error {
+	if imp.repoRetrievalID == 0 || len(imp.packages) != 1 {
+		return nil
+	}
+	pkg := imp.packages[0]
+
+	if isAssertedLicense(pkg.LicenseDeclared) {
+		err := imp.importFinding(imp.repoRetrievalID, 0, database.FindingDeclared, pkg.LicenseDeclared)
+		if err != nil {
+			return err
+		}
+	}
+	if pkg.VerificationCode.Value != "" {
+		computed := repoFilesVerificationCode(imp.repoFiles, pkg.VerificationCode.ExcludedFiles)
+		if computed != pkg.VerificationCode.Value {
+			imp.report.VerificationMismatch = &VerificationMismatch{
+				Package:  pkg.Name,
+				Declared: pkg.VerificationCode.Value,
+				Computed: computed,
+			}
+		}
+	}
+	return nil
+}
+
+// repoFilesVerificationCode returns the package verification code for
+// repoFiles, other than those at the paths in excluded.
+func repoFilesVerificationCode(repoFiles map[int]*database.RepoFile, excluded []string) string {
+	pathsToSHA1s := make(map[string]string, len(repoFiles))
+	for _, rf := range repoFiles {
+		pathsToSHA1s[rf.Path] = rf.HashSHA1
+	}
+	return spdxtvmanager.ComputeVerificationCodeExcluding(pathsToSHA1s, excluded)
+}
+
+// packageVerificationCode returns the package verification code for a
+// retrieval's files, given by path, along with the paths that it leaves
+// out, sorted and prefixed with "./" as in an SPDX document. Files that
+// path rules mark ClassExcluded are left out, just as they are left out of
+// the hash store.
+func packageVerificationCode(pathsToHashes map[string]database.Hashes, pathsToDetails map[string]database.FileDetails) (string, []string) {
+	pathsToSHA1s := make(map[string]string, len(pathsToHashes))
+	var excluded []string
+	for p, hashes := range pathsToHashes {
+		pathsToSHA1s[p] = hashes.SHA1
+		if pathsToDetails[p].Classification == database.ClassExcluded {
+			excluded = append(excluded, "./"+p)
+		}
+	}
+	sort.Strings(excluded)
+	return spdxtvmanager.ComputeVerificationCodeExcluding(pathsToSHA1s, excluded), excluded
+}
+
+// importLicenseRefs records the LicenseRef- licenses defined in an SPDX
+// document as LicenseLeafs with their extracted text. LicenseRef-
+// identifiers are only unique within a document, so licenses are matched
+// to existing LicenseLeafs by their text, ignoring differences in
+// whitespace: one whose text is already in the catalog under another
+// identifier is renamed to that identifier, and one whose identifier is
+// already in the catalog with different text is given a new identifier.
+// Renamed identifiers are noted in report.
+func (co *Coordinator) importLicenseRefs(report *ImportReport, others []*spdxtvmanager.OtherLicense) error {
+	leafs, err := co.db.GetLicenseLeafAll()
+	if err != nil {
+		return fmt.Errorf("couldn't get license leafs from DB: %v", err)
+	}
+	byIdentifier := map[string]*database.LicenseLeaf{}
+	byText := map[string]*database.LicenseLeaf{}
+	for _, leaf := range leafs {
+		byIdentifier[leaf.Identifier] = leaf
+		if !leaf.IsSPDX && leaf.ExtractedText != "" {
+			byText[normalizeLicenseText(leaf.ExtractedText)] = leaf
+		}
+	}
+
+	for _, ol := range others {
+		if !strings.HasPrefix(ol.LicenseID, "LicenseRef-") {
+			continue
+		}
+		text := ol.ExtractedText
+		if text == "NOASSERTION" {
+			text = ""
+		}
+		name := ol.Name
+		if name == "" || name == "NOASSERTION" {
+			name = ol.LicenseID
+		}
+
+		if leaf, ok := byText[normalizeLicenseText(text)]; ok && text != "" {
+			if leaf.Identifier != ol.LicenseID {
+				report.RenamedLicenseRefs[ol.LicenseID] = leaf.Identifier
+			}
+			continue
+		}
+
+		leaf, ok := byIdentifier[ol.LicenseID]
+		switch {
+		case ok && text == "":
+			// nothing to add to what's already known
+			continue
+		case ok && leaf.ExtractedText == "":
+			err = co.db.UpdateLicenseLeafExtractedText(leaf, name, text, ol.CrossReferences, ol.Comment)
+			if err != nil {
+				return fmt.Errorf("couldn't update license leaf for %s in DB: %v", ol.LicenseID, err)
+			}
+			byText[normalizeLicenseText(text)] = leaf
+			continue
+		}
+
+		// a new license, possibly needing a new identifier
+		identifier := ol.LicenseID
+		for i := 2; byIdentifier[identifier] != nil; i++ {
+			identifier = fmt.Sprintf("%s-%d", ol.LicenseID, i)
+		}
+		leaf, err = co.db.InsertLicenseRefLeaf(identifier, name, text, ol.CrossReferences, ol.Comment)
+		if err != nil {
+			return fmt.Errorf("couldn't insert license leaf for %s into DB: %v", identifier, err)
+		}
+		report.LicenseRefsAdded++
+		if identifier != ol.LicenseID {
+			report.RenamedLicenseRefs[ol.LicenseID] = identifier
+		}
+		byIdentifier[identifier] = leaf
+		if text != "" {
+			byText[normalizeLicenseText(text)] = leaf
+		}
+	}
+
+	return nil
+}
+
+// normalizeLicenseText collapses runs of whitespace in a license's text,
+// so that texts differing only in line wrapping or indentation match.
+func normalizeLicenseText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// licenseRefRegexp matches LicenseRef- identifiers in license expressions,
+// along with any colon before them, which means that they belong to another
+// document.
+var licenseRefRegexp = regexp.MustCompile(`:?LicenseRef-[A-Za-z0-9.\-]+`)
+
+// renameLicenseRefs replaces the LicenseRef- identifiers in expr that are
+// in renames.
+func renameLicenseRefs(expr string, renames map[string]string) string {
+	if len(renames) == 0 {
+		return expr
+	}
+	return licenseRefRegexp.ReplaceAllStringFunc(expr, func(id string) string {
+		if renamed, ok := renames[id]; ok {
+			return renamed
+		}
+		return id
+	})
+}
+
+// importFinding records a Finding, and notes it in the report. LicenseRef-
+// identifiers in expr are renamed as importLicenseRefs decided, or if it
+// hasn't run yet, the Finding is deferred until it has.
+func (imp *spdxImporter) importFinding(repoRetrievalID int, repoFileID int, findingType int, expr string) error {
+	if !imp.licenseRefsKnown && strings.Contains(expr, "LicenseRef-") {
+		imp.deferred = append(imp.deferred, deferredFinding{repoRetrievalID, repoFileID, findingType, expr})
+		return nil
+	}
+
+	expr = renameLicenseRefs(expr, imp.report.RenamedLicenseRefs)
+	finding, err := imp.co.insertFinding(repoRetrievalID, repoFileID, findingType, expr, imp.source)
+	if err != nil {
+		return fmt.Errorf("couldn't insert finding into DB: %v", err)
+	}
+	imp.report.FindingsAdded++
+	if finding.LicenseNodeID == 0 && !imp.unparsed[expr] {
+		imp.unparsed[expr] = true
+		imp.report.Unparsed = append(imp.report.Unparsed, expr)
+	}
+	return nil
+}
+
+// isAssertedLicense returns false for a license value that doesn't state a
+// license: empty, NONE or NOASSERTION.
+func isAssertedLicense(expr string) bool {
+	return expr != "" && expr != "NONE" && expr != "NOASSERTION"
+}
+
+// normalizeSPDXPath turns a file name from an SPDX document, which is
+// usually relative to the package root and starts with "./", into the form
+// used for RepoFile paths.
+func normalizeSPDXPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// repoFileIndex looks up the RepoFiles of a single RepoRetrieval by their
+// hashes and paths.
+type repoFileIndex struct {
+	bySHA1   map[string][]*database.RepoFile
+	bySHA256 map[string][]*database.RepoFile
+	byPath   map[string]*database.RepoFile
+}
+
+func newRepoFileIndex(repoFiles map[int]*database.RepoFile) *repoFileIndex {
+	idx := &repoFileIndex{
+		bySHA1:   map[string][]*database.RepoFile{},
+		bySHA256: map[string][]*database.RepoFile{},
+		byPath:   map[string]*database.RepoFile{},
+	}
+	for _, rf := range repoFiles {
+		idx.bySHA1[rf.HashSHA1] = append(idx.bySHA1[rf.HashSHA1], rf)
+		idx.bySHA256[rf.HashSHA256] = append(idx.bySHA256[rf.HashSHA256], rf)
+		idx.byPath[rf.Path] = rf
+	}
+	return idx
+}
+
+// match returns the RepoFiles with the given hashes, either of which can be
+// empty. If several have them, such as copies of the same file, only the
+// one at p is returned if there is one. If none have them, the RepoFile at
+// p is returned instead, with byPath set to true.
+func (idx *repoFileIndex) match(sha1 string, sha256 string, p string) (matches []*database.RepoFile, byPath bool) {
+	var candidates []*database.RepoFile
+	switch {
+	case sha256 != "":
+		for _, rf := range idx.bySHA256[sha256] {
+			if sha1 == "" || rf.HashSHA1 == sha1 {
+				candidates = append(candidates, rf)
+			}
+		}
+	case sha1 != "":
+		candidates = idx.bySHA1[sha1]
+	}
+
+	for _, rf := range candidates {
+		if rf.Path == p {
+			return []*database.RepoFile{rf}, false
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates, false
+	}
+
+	if rf, ok := idx.byPath[p]; ok {
+		return []*database.RepoFile{rf}, true
+	}
+	return nil, false
+}
+
+// DoExportSPDX builds an SPDX document describing a RepoRetrieval as a
+// single package containing each of its RepoFiles. Licenses come from the
+// retrieval's Findings: the latest concluded Finding for each file, and the
+// licenses in all of its in-file Findings. Files without any are marked
+// NOASSERTION, as are expressions that couldn't be parsed. Files that path
+// rules exclude are listed, but left out of the package verification code.
+func (co *Coordinator) DoExportSPDX(repoRetrievalID int) (*spdxtvmanager.Document, error) {
+	repoRetrieval, err := co.db.GetRepoRetrievalByID(repoRetrievalID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get repo retrieval from DB: %v", err)
+	}
+	repo, err := co.db.GetRepoByID(repoRetrieval.RepoID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get repo from DB: %v", err)
+	}
+	repoFiles, err := co.db.GetRepoFilesForRepoRetrieval(repoRetrievalID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get repo files from DB: %v", err)
+	}
+	findings, err := co.db.GetFindingsForRepoRetrieval(repoRetrievalID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get findings from DB: %v", err)
+	}
+	namespace, err := newDocumentNamespace(repo, repoRetrieval)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create document namespace: %v", err)
+	}
+
+	// findings are returned in the order they were added, so later ones
+	// replace earlier ones here
+	declared := "NOASSERTION"
+	concluded := map[int]string{}
+	inFile := map[int][]string{}
+	for _, f := range findings {
+		if f.LicenseNodeID == 0 {
+			continue
+		}
+		switch f.Type {
+		case database.FindingDeclared:
+			if f.RepoFileID == 0 {
+				declared = f.Expression
+			}
+		case database.FindingConcluded:
+			concluded[f.RepoFileID] = f.Expression
+		case database.FindingInFile:
+			inFile[f.RepoFileID] = append(inFile[f.RepoFileID], f.Expression)
+		}
+	}
+
+	pkg := &spdxtvmanager.Package{
+		Name:             exportPackageName(repo, repoRetrieval),
+		SPDXIdentifier:   "SPDXRef-Package",
+		Supplier:         "NOASSERTION",
+		DownloadLocation: "NOASSERTION",
+		FilesAnalyzed:    true,
+		Checksums:        map[string]string{},
+		LicenseConcluded: "NOASSERTION",
+		LicenseDeclared:  declared,
+		CopyrightText:    "NOASSERTION",
+	}
+	switch repoRetrieval.SourceType {
+	case database.SourceGit:
+		pkg.Version = repoRetrieval.CommitHash
+		pkg.DownloadLocation = "git+" + co.rm.GetURLToRepo(repo) + "@" + repoRetrieval.CommitHash
+		pkg.SourceInfo = fmt.Sprintf("commit %s (%s) by %s, committed %s: %s",
+			repoRetrieval.CommitHash, repoRetrieval.RefName, repoRetrieval.CommitAuthor,
+			repoRetrieval.CommitTime.UTC().Format(time.RFC3339), repoRetrieval.CommitSubject)
+	case database.SourcePackage:
+		pkg.Version = repoRetrieval.PackageVersion
+		if repoRetrieval.PURL != "" {
+			pkg.ExternalRefs = append(pkg.ExternalRefs, &spdxtvmanager.ExternalRef{
+				Category: "PACKAGE-MANAGER",
+				Type:     "purl",
+				Locator:  repoRetrieval.PURL,
+			})
+		}
+		fallthrough
+	case database.SourceArchive:
+		pkg.FileName = repoRetrieval.ArchiveName
+		for alg, sum := range map[string]string{
+			"SHA1":   repoRetrieval.ArchiveSHA1,
+			"SHA256": repoRetrieval.ArchiveSHA256,
+			"MD5":    repoRetrieval.ArchiveMD5,
+		} {
+			if sum != "" {
+				pkg.Checksums[alg] = sum
+			}
+		}
+	}
+
+	doc := &spdxtvmanager.Document{
+		CreationInfo: spdxtvmanager.CreationInfo{
+			SPDXVersion:       "SPDX-2.2",
+			DataLicense:       "CC0-1.0",
+			SPDXIdentifier:    "SPDXRef-DOCUMENT",
+			DocumentName:      pkg.Name,
+			DocumentNamespace: namespace,
+			Creators:          []spdxtvmanager.Creator{{Type: "Tool", Name: "peridot"}},
+			Created:           time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		},
+		Packages: []*spdxtvmanager.Package{pkg},
+		Relationships: []*spdxtvmanager.Relationship{
+			{RefA: "SPDXRef-DOCUMENT", Type: "DESCRIBES", RefB: pkg.SPDXIdentifier},
+		},
+	}
+
+	sorted := make([]*database.RepoFile, 0, len(repoFiles))
+	for _, rf := range repoFiles {
+		sorted = append(sorted, rf)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	pkgLicenses := map[string]bool{}
+	pathsToHashes := make(map[string]database.Hashes, len(sorted))
+	pathsToDetails := make(map[string]database.FileDetails, len(sorted))
+	for _, rf := range sorted {
+		pathsToHashes[rf.Path] = rf.Hashes()
+		pathsToDetails[rf.Path] = rf.FileDetails
+		f := &spdxtvmanager.File{
+			Path:           "./" + rf.Path,
+			SPDXIdentifier: fmt.Sprintf("SPDXRef-File-%d", rf.ID),
+			Checksums: map[string]string{
+				"SHA1":   rf.HashSHA1,
+				"SHA256": rf.HashSHA256,
+				"MD5":    rf.HashMD5,
+			},
+			LicenseConcluded: "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+		}
+		if expr, ok := concluded[rf.ID]; ok {
+			f.LicenseConcluded = expr
+		}
+		fileLicenses := map[string]bool{}
+		for _, expr := range inFile[rf.ID] {
+			for _, id := range licenseIdentifiers(expr) {
+				fileLicenses[id] = true
+				pkgLicenses[id] = true
+			}
+		}
+		f.LicenseInfoInFile = sortedKeysOrNoAssertion(fileLicenses)
+
+		doc.Files = append(doc.Files, f)
+		pkg.Files = append(pkg.Files, f)
+	}
+	pkg.LicenseInfoFromFiles = sortedKeysOrNoAssertion(pkgLicenses)
+	pkg.VerificationCode.Value, pkg.VerificationCode.ExcludedFiles = packageVerificationCode(pathsToHashes, pathsToDetails)
+
+	// every LicenseRef- used in the document needs to be defined in it
+	refs := map[string]bool{}
+	for _, expr := range append([]string{declared}, pkg.LicenseInfoFromFiles...) {
+		for _, id := range licenseIdentifiers(expr) {
+			refs[id] = true
+		}
+	}
+	for _, expr := range concluded {
+		for _, id := range licenseIdentifiers(expr) {
+			refs[id] = true
+		}
+	}
+	for _, id := range sortedKeysOrNoAssertion(refs) {
+		if !strings.HasPrefix(id, "LicenseRef-") {
+			continue
+		}
+		ol := &spdxtvmanager.OtherLicense{
+			LicenseID:     id,
+			ExtractedText: "NOASSERTION",
+			Name:          id,
+		}
+		if leaf, err := co.db.GetLicenseLeafByIdentifier(id); err == nil {
+			ol.Name = leaf.Name
+			if leaf.ExtractedText != "" {
+				ol.ExtractedText = leaf.ExtractedText
+			}
+			ol.CrossReferences = leaf.CrossReferences
+			ol.Comment = leaf.Comment
+		}
+		doc.OtherLicenses = append(doc.OtherLicenses, ol)
+	}
+
+	return doc, nil
+}
+
+// licenseIdentifiers returns the license identifiers in a license
+// expression, such as "MIT" and "GPL-2.0+" for "MIT OR GPL-2.0+", leaving
+// out exceptions. It returns nil if expr can't be parsed.
+func licenseIdentifiers(expr string) []string {
+	pln, err := licenses.GetNodesForExpression(expr)
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	var walk func(n *licenses.ParsedLicenseNode)
+	walk = func(n *licenses.ParsedLicenseNode) {
+		switch {
+		case n == nil:
+		case n.NodeType == licenses.NodeIdentifier || n.NodeType == licenses.NodePlus:
+			ids = append(ids, n.Expression)
+		case n.NodeType == licenses.NodeWith:
+			walk(n.LeftChild)
+		default:
+			walk(n.LeftChild)
+			walk(n.RightChild)
+		}
+	}
+	walk(pln)
+	return ids
+}
+
+// sortedKeysOrNoAssertion returns the keys of m in sorted order, or just
+// NOASSERTION if m is empty.
+func sortedKeysOrNoAssertion(m map[string]bool) []string {
+	if len(m) == 0 {
+		return []string{"NOASSERTION"}
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// exportPackageName returns the name of the package describing
+// repoRetrieval in an exported SPDX document: a package's name as it is
+// written in its ecosystem, or ORG/REPO otherwise.
+func exportPackageName(repo *database.Repo, repoRetrieval *database.RepoRetrieval) string {
+	if repoRetrieval.SourceType == database.SourcePackage {
+		return archivemanager.GetPackageName(repo.Ecosystem, repo.OrgName, repo.RepoName)
+	}
+	if repo.OrgName == "" {
+		return repo.RepoName
+	}
+	return repo.OrgName + "/" + repo.RepoName
+}
+
+// newDocumentNamespace returns a unique namespace for a new SPDX document
+// describing repoRetrieval.
+func newDocumentNamespace(repo *database.Repo, repoRetrieval *database.RepoRetrieval) (string, error) {
+	u := make([]byte, 16)
+	_, err := rand.Read(u)
+	if err != nil {
+		return "", err
+	}
+	// random (version 4) UUID
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	coords := repo.RepoName
+	if repo.OrgName != "" {
+		coords = repo.OrgName + "-" + repo.RepoName
+	}
+	return fmt.Sprintf("https://spdx.org/spdxdocs/peridot-%s-%d-%x-%x-%x-%x-%x",
+		url.PathEscape(coords), repoRetrieval.ID, u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
@@ -0,0 +1,60 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package coordinator
+
+import (
+	"testing"
+
+	"github.com/swinslow/peridot/database"
+	"github.com/swinslow/peridot/spdxtvmanager"
+)
+
+func TestPackageVerificationCodeLeavesOutExcludedFiles(t *testing.T) {
+	pathsToHashes := map[string]database.Hashes{
+		"src/main.c":      {SHA1: "85ed0817af83a24ad8da68c2b5094de69833983c"},
+		"vendor/lib/io.c": {SHA1: "d6a770ba38583ed4bb4525bd96e50461655d2758"},
+	}
+	pathsToDetails := map[string]database.FileDetails{
+		"src/main.c":      {},
+		"vendor/lib/io.c": {Classification: database.ClassExcluded},
+	}
+
+	code, excluded := packageVerificationCode(pathsToHashes, pathsToDetails)
+	want := spdxtvmanager.ComputeVerificationCode([]string{"85ed0817af83a24ad8da68c2b5094de69833983c"})
+	if code != want {
+		t.Errorf("expected %s, got %s", want, code)
+	}
+	if len(excluded) != 1 || excluded[0] != "./vendor/lib/io.c" {
+		t.Errorf("expected [./vendor/lib/io.c], got %v", excluded)
+	}
+
+	// and it matches what a reader of the exported document would compute
+	pathsToSHA1s := map[string]string{}
+	for p, hashes := range pathsToHashes {
+		pathsToSHA1s[p] = hashes.SHA1
+	}
+	got := spdxtvmanager.ComputeVerificationCodeExcluding(pathsToSHA1s, excluded)
+	if got != code {
+		t.Errorf("expected %s from the exported files and excludes, got %s", code, got)
+	}
+}
+
+func TestPackageVerificationCodeWithNoExcludedFiles(t *testing.T) {
+	pathsToHashes := map[string]database.Hashes{
+		"src/main.c": {SHA1: "85ed0817af83a24ad8da68c2b5094de69833983c"},
+		"README":     {SHA1: "d6a770ba38583ed4bb4525bd96e50461655d2758"},
+	}
+	pathsToDetails := map[string]database.FileDetails{
+		"src/main.c": {},
+		"README":     {Classification: database.ClassDocumentation},
+	}
+
+	code, excluded := packageVerificationCode(pathsToHashes, pathsToDetails)
+	if code != "c33d5f0525e78ad34e7ff8befb239bba2245dffb" {
+		t.Errorf("expected c33d5f0525e78ad34e7ff8befb239bba2245dffb, got %s", code)
+	}
+	if len(excluded) != 0 {
+		t.Errorf("expected no excluded files, got %v", excluded)
+	}
+}
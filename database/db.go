@@ -69,6 +69,24 @@ func (db *DB) PrepareDB(cfg *config.Config) error {
 	return nil
 }
 
+// addColumnsIfNotExists adds columns to a table that was created by an
+// earlier version of peridot, before they were in its CREATE TABLE
+// statement, so that existing databases are upgraded in place rather than
+// needing a reset. Each column is given as in ALTER TABLE, with a default
+// for the existing rows, such as "size BIGINT NOT NULL DEFAULT 0".
+func (db *DB) addColumnsIfNotExists(tablename string, columns []string) error {
+	// as with ResetDB, the table and column definitions are ours, not
+	// user input
+	for _, column := range columns {
+		_, err := db.sqldb.Exec("ALTER TABLE " + tablename + " ADD COLUMN IF NOT EXISTS " + column)
+		if err != nil {
+			return fmt.Errorf("couldn't add column to %s: %v", tablename, err)
+		}
+	}
+
+	return nil
+}
+
 // ResetDB drops any existing peridot-controlled tables in the DB.
 func (db *DB) ResetDB() error {
 	// we control the contents of tables, it isn't dependent on user input,
@@ -0,0 +1,118 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package database
+
+import (
+	"database/sql"
+)
+
+func (db *DB) createDBFindingsTableIfNotExists() error {
+	_, err := db.sqldb.Exec(`
+		CREATE TABLE IF NOT EXISTS findings (
+			id SERIAL NOT NULL PRIMARY KEY,
+			reporetrieval_id INTEGER NOT NULL,
+			repofile_id INTEGER,
+			type INTEGER NOT NULL,
+			expression TEXT NOT NULL,
+			licensenode_id INTEGER NOT NULL,
+			source TEXT NOT NULL,
+			FOREIGN KEY (reporetrieval_id) REFERENCES reporetrievals (id),
+			FOREIGN KEY (repofile_id) REFERENCES repofiles (id),
+			FOREIGN KEY (licensenode_id) REFERENCES licensenodes (id)
+		)
+	`)
+	return err
+}
+
+const (
+	// FindingDeclared indicates a license declared for a whole retrieval,
+	// such as in a package's metadata
+	FindingDeclared = 1
+
+	// FindingConcluded indicates a license concluded for a file
+	FindingConcluded = 2
+
+	// FindingInFile indicates a license found in a file
+	FindingInFile = 3
+)
+
+// Finding represents a license expression that was found, declared or
+// concluded for a RepoRetrieval, or for a single RepoFile within it.
+// RepoFileID is 0 for findings that apply to the whole RepoRetrieval.
+// Expression is the expression as originally given, and LicenseNodeID
+// points to its parsed form, or is 0 if it could not be parsed. Source
+// records where the finding came from (e.g., "package.json").
+type Finding struct {
+	ID              int
+	RepoRetrievalID int
+	RepoFileID      int
+	Type            int
+	Expression      string
+	LicenseNodeID   int
+	Source          string
+}
+
+// GetFindingsForRepoRetrieval takes the ID of a RepoRetrieval and returns a
+// slice of all Findings for that RepoRetrieval and its RepoFiles.
+func (db *DB) GetFindingsForRepoRetrieval(repoRetrievalID int) ([]*Finding, error) {
+	stmt, err := db.getStatement(stmtFindingGetForRepoRetrieval)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(repoRetrievalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var findings []*Finding
+	for rows.Next() {
+		f := &Finding{}
+		var repoFileID sql.NullInt64
+		err := rows.Scan(&f.ID, &f.RepoRetrievalID, &repoFileID, &f.Type,
+			&f.Expression, &f.LicenseNodeID, &f.Source)
+		if err != nil {
+			return nil, err
+		}
+		f.RepoFileID = int(repoFileID.Int64)
+		findings = append(findings, f)
+	}
+
+	// check at end for error
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return findings, nil
+}
+
+// InsertFinding takes data for a finding, creates a new Finding struct, adds
+// it to the database, and returns the new struct with its ID from the DB.
+// Pass 0 for repoFileID if the finding applies to the whole RepoRetrieval.
+func (db *DB) InsertFinding(repoRetrievalID int, repoFileID int, findingType int,
+	expression string, licenseNodeID int, source string) (*Finding, error) {
+	stmt, err := db.getStatement(stmtFindingInsert)
+	if err != nil {
+		return nil, err
+	}
+
+	var repoFileIDVal sql.NullInt64
+	if repoFileID != 0 {
+		repoFileIDVal = sql.NullInt64{Int64: int64(repoFileID), Valid: true}
+	}
+
+	var id int
+	err = stmt.QueryRow(repoRetrievalID, repoFileIDVal, findingType,
+		expression, licenseNodeID, source).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Finding{ID: id, RepoRetrievalID: repoRetrievalID,
+		RepoFileID: repoFileID, Type: findingType, Expression: expression,
+		LicenseNodeID: licenseNodeID, Source: source}
+	return f, nil
+}
@@ -0,0 +1,49 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package database
+
+import (
+	"strings"
+)
+
+// SWHIDContentPrefix begins the Software Heritage identifier of every file's
+// contents. The rest of the SWHID is the file's git blob object ID.
+const SWHIDContentPrefix = "swh:1:cnt:"
+
+// Hashes stores the content identifiers that peridot calculates for each
+// file, as lowercase hex strings. GitOID is the SHA1 of the file as a git
+// blob object, and is empty if the file's size wasn't known when it was
+// hashed.
+type Hashes struct {
+	SHA1   string
+	SHA256 string
+	MD5    string
+	SHA512 string
+	GitOID string
+}
+
+// SWHID returns the Software Heritage identifier for the file's contents,
+// or an empty string if its GitOID is unknown.
+func (h Hashes) SWHID() string {
+	if h.GitOID == "" {
+		return ""
+	}
+	return SWHIDContentPrefix + h.GitOID
+}
+
+// GitOIDFromSWHID returns the git blob object ID from a content SWHID, and
+// false if swhid isn't one. Any qualifiers after ";" are ignored.
+func GitOIDFromSWHID(swhid string) (string, bool) {
+	if !strings.HasPrefix(swhid, SWHIDContentPrefix) {
+		return "", false
+	}
+	oid := strings.TrimPrefix(swhid, SWHIDContentPrefix)
+	if i := strings.Index(oid, ";"); i >= 0 {
+		oid = oid[:i]
+	}
+	if len(oid) != 40 {
+		return "", false
+	}
+	return oid, true
+}
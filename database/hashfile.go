@@ -3,25 +3,81 @@
 
 package database
 
+import (
+	"context"
+)
+
 func (db *DB) createDBHashFilesTableIfNotExists() error {
 	_, err := db.sqldb.Exec(`
 		CREATE TABLE IF NOT EXISTS hashfiles (
 			id SERIAL NOT NULL PRIMARY KEY,
 			hash_sha1 TEXT NOT NULL,
 			hash_sha256 TEXT NOT NULL,
-			hash_md5 TEXT NOT NULL
+			hash_md5 TEXT NOT NULL,
+			hash_sha512 TEXT NOT NULL,
+			hash_gitoid TEXT NOT NULL
 		)
 	`)
-	return err
+	if err != nil {
+		return err
+	}
+
+	return db.addColumnsIfNotExists("hashfiles", []string{
+		"hash_sha512 TEXT NOT NULL DEFAULT ''",
+		"hash_gitoid TEXT NOT NULL DEFAULT ''",
+	})
 }
 
 // HashFile stores the hash values of a given file that peridot has
-// seen before.
+// seen before. HashGitOID is the file's git blob object ID, which is also
+// the last part of its SWHID.
 type HashFile struct {
 	ID         int
 	HashSHA1   string
 	HashSHA256 string
 	HashMD5    string
+	HashSHA512 string
+	HashGitOID string
+}
+
+// Hashes returns the HashFile's hash values as Hashes.
+func (hf *HashFile) Hashes() Hashes {
+	return Hashes{SHA1: hf.HashSHA1, SHA256: hf.HashSHA256, MD5: hf.HashMD5,
+		SHA512: hf.HashSHA512, GitOID: hf.HashGitOID}
+}
+
+// GetAllHashFiles returns a slice of all HashFiles in the database.
+func (db *DB) GetAllHashFiles() ([]*HashFile, error) {
+	stmt, err := db.getStatement(stmtHashFileGetAll)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashFiles []*HashFile
+	for rows.Next() {
+		hashFile := &HashFile{}
+		err := rows.Scan(&hashFile.ID,
+			&hashFile.HashSHA1, &hashFile.HashSHA256, &hashFile.HashMD5,
+			&hashFile.HashSHA512, &hashFile.HashGitOID)
+		if err != nil {
+			return nil, err
+		}
+		hashFiles = append(hashFiles, hashFile)
+	}
+
+	// check at end for error
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return hashFiles, nil
 }
 
 // GetHashFileByID looks up and returns a HashFile in the database by its ID.
@@ -34,7 +90,8 @@ func (db *DB) GetHashFileByID(id int) (*HashFile, error) {
 
 	var hashfile HashFile
 	err = stmt.QueryRow(id).Scan(&hashfile.ID,
-		&hashfile.HashSHA1, &hashfile.HashSHA256, &hashfile.HashMD5)
+		&hashfile.HashSHA1, &hashfile.HashSHA256, &hashfile.HashMD5,
+		&hashfile.HashSHA512, &hashfile.HashGitOID)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +112,8 @@ func (db *DB) GetHashFileByHashes(hSHA1 string, hSHA256 string) (*HashFile, erro
 	// FIXME this assumes that there will only ever be one file in the catalog
 	// FIXME with a given SHA1 X SHA256. consider whether that's okay.
 	err = stmt.QueryRow(hSHA1, hSHA256).Scan(&hashfile.ID,
-		&hashfile.HashSHA1, &hashfile.HashSHA256, &hashfile.HashMD5)
+		&hashfile.HashSHA1, &hashfile.HashSHA256, &hashfile.HashMD5,
+		&hashfile.HashSHA512, &hashfile.HashGitOID)
 	if err != nil {
 		return nil, err
 	}
@@ -63,23 +121,59 @@ func (db *DB) GetHashFileByHashes(hSHA1 string, hSHA256 string) (*HashFile, erro
 	return &hashfile, nil
 }
 
+// GetHashFilesByHash returns all HashFiles with h as any one of their SHA1,
+// SHA256, MD5 or SHA512 hashes or their git blob object ID.
+func (db *DB) GetHashFilesByHash(h string) ([]*HashFile, error) {
+	stmt, err := db.getStatement(stmtHashFileGetByAnyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(h)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashFiles []*HashFile
+	for rows.Next() {
+		hashFile := &HashFile{}
+		err := rows.Scan(&hashFile.ID,
+			&hashFile.HashSHA1, &hashFile.HashSHA256, &hashFile.HashMD5,
+			&hashFile.HashSHA512, &hashFile.HashGitOID)
+		if err != nil {
+			return nil, err
+		}
+		hashFiles = append(hashFiles, hashFile)
+	}
+
+	// check at end for error
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return hashFiles, nil
+}
+
 // BulkInsertHashFiles inserts a collection of hash files into the database,
-// wrapped in a single transaction. It takes a map from a path to a 3-element
-// string array, with SHA1, SHA256 and MD5 hashes in that order.
-func (db *DB) BulkInsertHashFiles(pathsToHashes map[string][3]string) error {
+// wrapped in a single transaction. It takes a map from a path to its Hashes.
+// If ctx is cancelled, the transaction is rolled back.
+func (db *DB) BulkInsertHashFiles(ctx context.Context, pathsToHashes map[string]Hashes) error {
 	// we're ignoring the paths, just getting the hashes
 
 	// get a transaction and prepare a stmt on it
 	// (we can't use stmts prepared on the main DB from within a Tx)
-	tx, err := db.sqldb.Begin()
+	tx, err := db.sqldb.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
 	insertStmt, err := tx.Prepare(`
-		INSERT INTO hashfiles (hash_sha1, hash_sha256, hash_md5)
-		VALUES ($1, $2, $3)
+		INSERT INTO hashfiles (hash_sha1, hash_sha256, hash_md5, hash_sha512,
+			hash_gitoid)
+		VALUES ($1, $2, $3, $4, $5)
 	`)
 	if err != nil {
 		return err
@@ -87,11 +181,42 @@ func (db *DB) BulkInsertHashFiles(pathsToHashes map[string][3]string) error {
 	defer insertStmt.Close()
 
 	for _, hashes := range pathsToHashes {
-		hashSHA1 := hashes[0]
-		hashSHA256 := hashes[1]
-		hashMD5 := hashes[2]
+		_, err = insertStmt.Exec(hashes.SHA1, hashes.SHA256, hashes.MD5,
+			hashes.SHA512, hashes.GitOID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// BulkDeleteHashFiles deletes the hash files with the given IDs from the
+// database, wrapped in a single transaction. If ctx is cancelled, the
+// transaction is rolled back.
+func (db *DB) BulkDeleteHashFiles(ctx context.Context, ids []int) error {
+	tx, err := db.sqldb.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deleteStmt, err := tx.Prepare(`
+		DELETE FROM hashfiles
+		WHERE id = $1
+	`)
+	if err != nil {
+		return err
+	}
+	defer deleteStmt.Close()
 
-		_, err = insertStmt.Exec(hashSHA1, hashSHA256, hashMD5)
+	for _, id := range ids {
+		_, err = deleteStmt.Exec(id)
 		if err != nil {
 			return err
 		}
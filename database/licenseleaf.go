@@ -7,6 +7,7 @@ import (
 	"database/sql"
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/swinslow/peridot/licenses"
 )
@@ -18,12 +19,23 @@ func (db *DB) createDBLicenseLeafsTableIfNotExists() error {
 			identifier TEXT NOT NULL,
 			name TEXT NOT NULL,
 			is_spdx INTEGER NOT NULL,
-			type INTEGER NOT NULL
+			type INTEGER NOT NULL,
+			extracted_text TEXT NOT NULL,
+			cross_refs TEXT NOT NULL,
+			comment TEXT NOT NULL
 		)
 	`)
 	if err != nil {
 		return err
 	}
+	err = db.addColumnsIfNotExists("licenseleafs", []string{
+		"extracted_text TEXT NOT NULL DEFAULT ''",
+		"cross_refs TEXT NOT NULL DEFAULT ''",
+		"comment TEXT NOT NULL DEFAULT ''",
+	})
+	if err != nil {
+		return err
+	}
 
 	// check for zero row and add if not present
 	var tmp int
@@ -31,7 +43,7 @@ func (db *DB) createDBLicenseLeafsTableIfNotExists() error {
 
 	switch {
 	case err == sql.ErrNoRows:
-		_, err := db.sqldb.Exec(`INSERT INTO licenseleafs (id, identifier, name, is_spdx, type) VALUES (0, 'N/A', 'N/A', 0, 0)`)
+		_, err := db.sqldb.Exec(`INSERT INTO licenseleafs (id, identifier, name, is_spdx, type, extracted_text, cross_refs, comment) VALUES (0, 'N/A', 'N/A', 0, 0, '', '', '')`)
 		return err
 	case err != nil:
 		return err
@@ -46,12 +58,35 @@ func (db *DB) createDBLicenseLeafsTableIfNotExists() error {
 // Under current SPDX naming rules, it should either be taken from the
 // SPDX License List (IsSPDX=True) or else its name should begin with
 // "LicenseRef-" (IsSPDX=False).
+// For LicenseRef- leafs, ExtractedText, CrossReferences and Comment hold
+// the license's text and details, as given by the SPDX document that
+// defined it; ExtractedText is empty if the text isn't known.
 type LicenseLeaf struct {
-	ID         int
-	Identifier string
-	Name       string
-	IsSPDX     bool
-	Type       int
+	ID              int
+	Identifier      string
+	Name            string
+	IsSPDX          bool
+	Type            int
+	ExtractedText   string
+	CrossReferences []string
+	Comment         string
+}
+
+// scanLicenseLeaf scans a row with the columns in licenseLeafColumns.
+// CrossReferences are stored one per line.
+func scanLicenseLeaf(row rowScanner) (*LicenseLeaf, error) {
+	var ll LicenseLeaf
+	var crossRefs string
+	err := row.Scan(&ll.ID, &ll.Identifier, &ll.Name, &ll.IsSPDX, &ll.Type,
+		&ll.ExtractedText, &crossRefs, &ll.Comment)
+	if err != nil {
+		return nil, err
+	}
+	if crossRefs != "" {
+		ll.CrossReferences = strings.Split(crossRefs, "\n")
+	}
+
+	return &ll, nil
 }
 
 // GetLicenseLeafAll looks up and returns a map of IDs to LicenseLeafs for
@@ -70,8 +105,7 @@ func (db *DB) GetLicenseLeafAll() (map[int]*LicenseLeaf, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		ll := &LicenseLeaf{}
-		err = rows.Scan(&ll.ID, &ll.Identifier, &ll.Name, &ll.IsSPDX, &ll.Type)
+		ll, err := scanLicenseLeaf(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -96,15 +130,7 @@ func (db *DB) GetLicenseLeafByID(id int) (*LicenseLeaf, error) {
 		return nil, err
 	}
 
-	var licenseLeaf LicenseLeaf
-	err = stmt.QueryRow(id).Scan(&licenseLeaf.ID,
-		&licenseLeaf.Identifier, &licenseLeaf.Name,
-		&licenseLeaf.IsSPDX, &licenseLeaf.Type)
-	if err != nil {
-		return nil, err
-	}
-
-	return &licenseLeaf, nil
+	return scanLicenseLeaf(stmt.QueryRow(id))
 }
 
 // GetLicenseLeafByIdentifier looks up and returns a LicenseLeaf in the
@@ -116,15 +142,7 @@ func (db *DB) GetLicenseLeafByIdentifier(identifier string) (*LicenseLeaf, error
 		return nil, err
 	}
 
-	var licenseLeaf LicenseLeaf
-	err = stmt.QueryRow(identifier).Scan(&licenseLeaf.ID,
-		&licenseLeaf.Identifier, &licenseLeaf.Name,
-		&licenseLeaf.IsSPDX, &licenseLeaf.Type)
-	if err != nil {
-		return nil, err
-	}
-
-	return &licenseLeaf, nil
+	return scanLicenseLeaf(stmt.QueryRow(identifier))
 }
 
 // InsertLicenseLeaf takes data for a license leaf, creates a new LicenseLeaf
@@ -132,26 +150,82 @@ func (db *DB) GetLicenseLeafByIdentifier(identifier string) (*LicenseLeaf, error
 // from the DB.
 func (db *DB) InsertLicenseLeaf(identifier string, name string,
 	isSPDX bool, licType int) (*LicenseLeaf, error) {
-	stmt, err := db.getStatement(stmtLicenseLeafInsert)
+	ll := &LicenseLeaf{Identifier: identifier, Name: name,
+		IsSPDX: isSPDX, Type: licType}
+	err := db.insertLicenseLeaf(ll)
+	if err != nil {
+		return nil, err
+	}
+	return ll, nil
+}
+
+// InsertLicenseRefLeaf is like InsertLicenseLeaf, but for a license that
+// isn't on the SPDX License List, with its extracted text and details from
+// an SPDX document.
+func (db *DB) InsertLicenseRefLeaf(identifier string, name string,
+	extractedText string, crossRefs []string, comment string) (*LicenseLeaf, error) {
+	// same type as the leafs loaded from the SPDX License List
+	ll := &LicenseLeaf{Identifier: identifier, Name: name,
+		IsSPDX: false, Type: 1, ExtractedText: extractedText,
+		CrossReferences: crossRefs, Comment: comment}
+	err := db.insertLicenseLeaf(ll)
 	if err != nil {
 		return nil, err
 	}
+	return ll, nil
+}
+
+// insertLicenseLeaf adds the given LicenseLeaf to the database, and sets
+// its ID from the DB.
+func (db *DB) insertLicenseLeaf(ll *LicenseLeaf) error {
+	stmt, err := db.getStatement(stmtLicenseLeafInsert)
+	if err != nil {
+		return err
+	}
 
-	var id int
 	var isSPDXInt int
-	if isSPDX {
+	if ll.IsSPDX {
 		isSPDXInt = 1
 	} else {
 		isSPDXInt = 0
 	}
-	err = stmt.QueryRow(identifier, name, isSPDXInt, licType).Scan(&id)
+	return stmt.QueryRow(ll.Identifier, ll.Name, isSPDXInt, ll.Type,
+		ll.ExtractedText, strings.Join(ll.CrossReferences, "\n"),
+		ll.Comment).Scan(&ll.ID)
+}
+
+// UpdateLicenseLeafExtractedText updates a given LicenseLeaf's name,
+// extracted text and details in both the database and its in-memory
+// struct.
+func (db *DB) UpdateLicenseLeafExtractedText(licenseLeaf *LicenseLeaf, name string,
+	extractedText string, crossRefs []string, comment string) error {
+	stmt, err := db.getStatement(stmtLicenseLeafUpdateExtractedText)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	ll := &LicenseLeaf{ID: id, Identifier: identifier, Name: name,
-		IsSPDX: isSPDX, Type: licType}
-	return ll, nil
+	res, err := stmt.Exec(name, extractedText, strings.Join(crossRefs, "\n"),
+		comment, licenseLeaf.ID)
+	if err != nil {
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowCount != 1 {
+		return fmt.Errorf("UpdateLicenseLeafExtractedText for ID %d modified %d rows, should be 1",
+			licenseLeaf.ID, rowCount)
+	}
+
+	// update in-memory copy of leaf
+	licenseLeaf.Name = name
+	licenseLeaf.ExtractedText = extractedText
+	licenseLeaf.CrossReferences = crossRefs
+	licenseLeaf.Comment = comment
+
+	return nil
 }
 
 // InsertFromLicenseList takes a path to the SPDX license list data JSON
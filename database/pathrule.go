@@ -0,0 +1,125 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package database
+
+func (db *DB) createDBPathRulesTableIfNotExists() error {
+	_, err := db.sqldb.Exec(`
+		CREATE TABLE IF NOT EXISTS pathrules (
+			id SERIAL NOT NULL PRIMARY KEY,
+			repo_id INTEGER NOT NULL,
+			pattern TEXT NOT NULL,
+			classification TEXT NOT NULL,
+			FOREIGN KEY (repo_id) REFERENCES repos (id)
+		)
+	`)
+	return err
+}
+
+const (
+	// ClassExcluded indicates files that are recorded, but are not copied
+	// into the hash store
+	ClassExcluded = "excluded"
+
+	// ClassVendored indicates files that are third-party code vendored
+	// into the repo
+	ClassVendored = "vendored"
+
+	// ClassTest indicates test code and fixtures
+	ClassTest = "test"
+
+	// ClassDocumentation indicates documentation
+	ClassDocumentation = "documentation"
+)
+
+// IsValidClassification returns true if c is one of the Class* constants.
+func IsValidClassification(c string) bool {
+	switch c {
+	case ClassExcluded, ClassVendored, ClassTest, ClassDocumentation:
+		return true
+	}
+	return false
+}
+
+// PathRule represents a gitignore-style glob pattern for a Repo, and the
+// classification given to paths that match it. When more than one of a
+// Repo's rules matches a path, the one added last wins.
+type PathRule struct {
+	ID             int
+	RepoID         int
+	Pattern        string
+	Classification string
+}
+
+// GetPathRulesForRepo takes the ID of a Repo and returns a slice of its
+// PathRules, in the order that they were added.
+func (db *DB) GetPathRulesForRepo(repoID int) ([]*PathRule, error) {
+	stmt, err := db.getStatement(stmtPathRuleGetForRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(repoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*PathRule
+	for rows.Next() {
+		rule := &PathRule{}
+		err := rows.Scan(&rule.ID, &rule.RepoID, &rule.Pattern, &rule.Classification)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	// check at end for error
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// InsertPathRule takes a Repo ID, pattern and classification, creates a new
+// PathRule struct, adds it to the database, and returns the new struct with
+// its ID from the DB.
+func (db *DB) InsertPathRule(repoID int, pattern string, classification string) (*PathRule, error) {
+	stmt, err := db.getStatement(stmtPathRuleInsert)
+	if err != nil {
+		return nil, err
+	}
+
+	var id int
+	err = stmt.QueryRow(repoID, pattern, classification).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &PathRule{ID: id, RepoID: repoID, Pattern: pattern,
+		Classification: classification}
+	return rule, nil
+}
+
+// DeletePathRule removes the PathRule with the given ID from the given
+// Repo. It returns false if the Repo has no such PathRule.
+func (db *DB) DeletePathRule(repoID int, id int) (bool, error) {
+	stmt, err := db.getStatement(stmtPathRuleDelete)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := stmt.Exec(id, repoID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -11,19 +11,47 @@ func (db *DB) createDBReposTableIfNotExists() error {
 	_, err := db.sqldb.Exec(`
 		CREATE TABLE IF NOT EXISTS repos (
 			id SERIAL NOT NULL PRIMARY KEY,
+			ecosystem TEXT NOT NULL,
 			org_name TEXT NOT NULL,
 			repo_name TEXT NOT NULL
 		)
 	`)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// repos from before ecosystems were added are all GitHub repos
+	return db.addColumnsIfNotExists("repos", []string{
+		"ecosystem TEXT NOT NULL DEFAULT '" + EcosystemGitHub + "'",
+	})
 }
 
+const (
+	// EcosystemGitHub indicates a Repo cloned from GitHub
+	EcosystemGitHub = "github"
+
+	// EcosystemNPM indicates an npm package
+	EcosystemNPM = "npm"
+
+	// EcosystemPyPI indicates a PyPI package
+	EcosystemPyPI = "pypi"
+
+	// EcosystemGolang indicates a Go module
+	EcosystemGolang = "golang"
+
+	// EcosystemMaven indicates a Maven artifact
+	EcosystemMaven = "maven"
+)
+
 // Repo stores the coordinates for a source code repository that is being
-// tracked in peridot.
+// tracked in peridot. For package ecosystems, OrgName holds the package's
+// namespace (npm scope, Maven group ID or Go module path prefix; empty if
+// none) and RepoName holds the package name.
 type Repo struct {
-	ID       int
-	OrgName  string
-	RepoName string
+	ID        int
+	Ecosystem string
+	OrgName   string
+	RepoName  string
 }
 
 // GetRepoByID looks up and returns a Repo in the database by its ID.
@@ -35,7 +63,7 @@ func (db *DB) GetRepoByID(id int) (*Repo, error) {
 	}
 
 	var repo Repo
-	err = stmt.QueryRow(id).Scan(&repo.ID, &repo.OrgName, &repo.RepoName)
+	err = stmt.QueryRow(id).Scan(&repo.ID, &repo.Ecosystem, &repo.OrgName, &repo.RepoName)
 	if err != nil {
 		return nil, err
 	}
@@ -46,13 +74,20 @@ func (db *DB) GetRepoByID(id int) (*Repo, error) {
 // GetRepoIDFromCoords takes a Github repo's coordinates and returns their ID
 // from the database, or returns 0, nil if repo not found for these coords.
 func (db *DB) GetRepoIDFromCoords(orgName string, repoName string) (int, error) {
+	return db.GetRepoIDFromEcosystemCoords(EcosystemGitHub, orgName, repoName)
+}
+
+// GetRepoIDFromEcosystemCoords takes an ecosystem and a repo's or package's
+// coordinates within it and returns their ID from the database, or returns
+// 0, nil if repo not found for these coords.
+func (db *DB) GetRepoIDFromEcosystemCoords(ecosystem string, orgName string, repoName string) (int, error) {
 	stmt, err := db.getStatement(stmtRepoGetByCoords)
 	if err != nil {
 		return -1, err
 	}
 
 	var id int
-	err = stmt.QueryRow(orgName, repoName).Scan(&id)
+	err = stmt.QueryRow(ecosystem, orgName, repoName).Scan(&id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return 0, nil
@@ -66,17 +101,24 @@ func (db *DB) GetRepoIDFromCoords(orgName string, repoName string) (int, error)
 // InsertRepo takes a Github repo's coordinates, creates a new Repo struct,
 // adds it to the database, and returns the new struct with its ID from the DB.
 func (db *DB) InsertRepo(orgName string, repoName string) (*Repo, error) {
+	return db.InsertEcosystemRepo(EcosystemGitHub, orgName, repoName)
+}
+
+// InsertEcosystemRepo takes an ecosystem and a repo's or package's
+// coordinates within it, creates a new Repo struct, adds it to the database,
+// and returns the new struct with its ID from the DB.
+func (db *DB) InsertEcosystemRepo(ecosystem string, orgName string, repoName string) (*Repo, error) {
 	stmt, err := db.getStatement(stmtRepoInsert)
 	if err != nil {
 		return nil, err
 	}
 
 	var id int
-	err = stmt.QueryRow(orgName, repoName).Scan(&id)
+	err = stmt.QueryRow(ecosystem, orgName, repoName).Scan(&id)
 	if err != nil {
 		return nil, err
 	}
 
-	repo := &Repo{ID: id, OrgName: orgName, RepoName: repoName}
+	repo := &Repo{ID: id, Ecosystem: ecosystem, OrgName: orgName, RepoName: repoName}
 	return repo, nil
 }
@@ -4,6 +4,7 @@
 package database
 
 import (
+	"context"
 	"path/filepath"
 	"sort"
 )
@@ -149,11 +150,12 @@ func ExtractDirsFromPaths(paths []string) []string {
 
 // BulkInsertRepoDirs inserts a collection of directory paths into the
 // database, wrapped in a single transaction. It takes the ID of the
-// corresponding RepoRetrieval and a slice of string paths to insert.
-func (db *DB) BulkInsertRepoDirs(repoRetrievalID int, dirs []string) error {
+// corresponding RepoRetrieval and a slice of string paths to insert. If ctx
+// is cancelled, the transaction is rolled back.
+func (db *DB) BulkInsertRepoDirs(ctx context.Context, repoRetrievalID int, dirs []string) error {
 	// first, get a transaction and prepare a stmt on it
 	// (we can't use stmts prepared on the main DB from within a Tx)
-	tx, err := db.sqldb.Begin()
+	tx, err := db.sqldb.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -4,6 +4,8 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"path/filepath"
 	"sort"
@@ -22,13 +24,66 @@ func (db *DB) createDBRepoFilesTableIfNotExists() error {
 			hash_sha1 TEXT NOT NULL,
 			hash_sha256 TEXT NOT NULL,
 			hash_md5 TEXT NOT NULL,
+			hash_sha512 TEXT NOT NULL,
+			hash_gitoid TEXT NOT NULL,
+			file_type TEXT NOT NULL,
+			size BIGINT NOT NULL,
+			is_executable BOOLEAN NOT NULL,
+			symlink_target TEXT NOT NULL,
+			is_binary BOOLEAN NOT NULL,
+			mime_type TEXT NOT NULL,
+			language TEXT NOT NULL,
+			classification TEXT NOT NULL,
 			FOREIGN KEY (reporetrieval_id) REFERENCES reporetrievals (id),
 			FOREIGN KEY (dir_parent_id) REFERENCES repodirs (id),
 			FOREIGN KEY (nextfile_id) REFERENCES repofiles (id),
 			FOREIGN KEY (prevfile_id) REFERENCES repofiles (id)
 		)
 	`)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// files from before these columns were added are recorded as
+	// unclassified regular files, with no details
+	return db.addColumnsIfNotExists("repofiles", []string{
+		"hash_sha512 TEXT NOT NULL DEFAULT ''",
+		"hash_gitoid TEXT NOT NULL DEFAULT ''",
+		"file_type TEXT NOT NULL DEFAULT '" + FileTypeRegular + "'",
+		"size BIGINT NOT NULL DEFAULT 0",
+		"is_executable BOOLEAN NOT NULL DEFAULT FALSE",
+		"symlink_target TEXT NOT NULL DEFAULT ''",
+		"is_binary BOOLEAN NOT NULL DEFAULT FALSE",
+		"mime_type TEXT NOT NULL DEFAULT ''",
+		"language TEXT NOT NULL DEFAULT ''",
+		"classification TEXT NOT NULL DEFAULT ''",
+	})
+}
+
+const (
+	// FileTypeRegular indicates a RepoFile that is a regular file
+	FileTypeRegular = "regular"
+
+	// FileTypeSymlink indicates a RepoFile that is a symbolic link. Its
+	// contents and hashes are those of the link target string, as in git.
+	FileTypeSymlink = "symlink"
+)
+
+// FileDetails stores the type and classification of a RepoFile. FileType
+// is one of the FileType* constants, and SymlinkTarget is only filled in
+// for FileTypeSymlink. Language is the programming language detected from
+// the file's name, or empty if unknown or if the file is binary.
+// Classification is one of the Class* constants from the Repo's PathRules,
+// or empty if no rule matched.
+type FileDetails struct {
+	FileType       string
+	Size           int64
+	IsExecutable   bool
+	SymlinkTarget  string
+	IsBinary       bool
+	MIMEType       string
+	Language       string
+	Classification string
 }
 
 // RepoFile represents a file within a single retrieval fo a source code
@@ -43,6 +98,31 @@ type RepoFile struct {
 	HashSHA1        string
 	HashSHA256      string
 	HashMD5         string
+	HashSHA512      string
+	HashGitOID      string
+	FileDetails
+}
+
+// Hashes returns the RepoFile's hash values as Hashes.
+func (rf *RepoFile) Hashes() Hashes {
+	return Hashes{SHA1: rf.HashSHA1, SHA256: rf.HashSHA256, MD5: rf.HashMD5,
+		SHA512: rf.HashSHA512, GitOID: rf.HashGitOID}
+}
+
+func scanRepoFile(row rowScanner) (*RepoFile, error) {
+	var repoFile RepoFile
+	err := row.Scan(&repoFile.ID, &repoFile.RepoRetrievalID,
+		&repoFile.DirParentID, &repoFile.NextFileID, &repoFile.PrevFileID,
+		&repoFile.Path,
+		&repoFile.HashSHA1, &repoFile.HashSHA256, &repoFile.HashMD5,
+		&repoFile.HashSHA512, &repoFile.HashGitOID,
+		&repoFile.FileType, &repoFile.Size, &repoFile.IsExecutable,
+		&repoFile.SymlinkTarget, &repoFile.IsBinary, &repoFile.MIMEType,
+		&repoFile.Language, &repoFile.Classification)
+	if err != nil {
+		return nil, err
+	}
+	return &repoFile, nil
 }
 
 // GetRepoFileByID looks up and returns a RepoFile in the database by its ID.
@@ -53,16 +133,58 @@ func (db *DB) GetRepoFileByID(id int) (*RepoFile, error) {
 		return nil, err
 	}
 
-	var repofile RepoFile
-	err = stmt.QueryRow(id).Scan(&repofile.ID, &repofile.RepoRetrievalID,
-		&repofile.DirParentID, &repofile.NextFileID, &repofile.PrevFileID,
-		&repofile.Path,
-		&repofile.HashSHA1, &repofile.HashSHA256, &repofile.HashMD5)
+	return scanRepoFile(stmt.QueryRow(id))
+}
+
+// GetRepoFileByPath looks up and returns the RepoFile at the given path in a
+// RepoRetrieval. It returns nil, nil if there is no file at that path.
+func (db *DB) GetRepoFileByPath(repoRetrievalID int, path string) (*RepoFile, error) {
+	stmt, err := db.getStatement(stmtRepoFileGetByPath)
+	if err != nil {
+		return nil, err
+	}
+
+	repoFile, err := scanRepoFile(stmt.QueryRow(repoRetrievalID, path))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return repoFile, err
+}
+
+// GetRepoFilesByHashes returns all RepoFiles, in any RepoRetrieval, whose
+// contents have the given SHA1 and SHA256 hashes. Either hash can be empty
+// to match on the other one alone.
+func (db *DB) GetRepoFilesByHashes(hSHA1 string, hSHA256 string) ([]*RepoFile, error) {
+	stmt, err := db.getStatement(stmtRepoFileGetByHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(hSHA1, hSHA256)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var repoFiles []*RepoFile
+	for rows.Next() {
+		repoFile, err := scanRepoFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		// an empty hash never matches, and both must match if given
+		if (hSHA1 == "" || repoFile.HashSHA1 == hSHA1) && (hSHA256 == "" || repoFile.HashSHA256 == hSHA256) {
+			repoFiles = append(repoFiles, repoFile)
+		}
+	}
+
+	// check at end for error
+	err = rows.Err()
 	if err != nil {
 		return nil, err
 	}
 
-	return &repofile, nil
+	return repoFiles, nil
 }
 
 // GetRepoFilesForRepoRetrieval takes the ID of a RepoRetrieval and returns a
@@ -82,11 +204,41 @@ func (db *DB) GetRepoFilesForRepoRetrieval(repoRetrievalID int) (map[int]*RepoFi
 	defer rows.Close()
 
 	for rows.Next() {
-		repoFile := &RepoFile{}
-		err := rows.Scan(&repoFile.ID, &repoFile.RepoRetrievalID,
-			&repoFile.DirParentID, &repoFile.NextFileID, &repoFile.PrevFileID,
-			&repoFile.Path,
-			&repoFile.HashSHA1, &repoFile.HashSHA256, &repoFile.HashMD5)
+		repoFile, err := scanRepoFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		repoFiles[repoFile.ID] = repoFile
+	}
+
+	// check at end for error
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return repoFiles, nil
+}
+
+// GetRepoFilesForRepoRetrievalByClassification is like
+// GetRepoFilesForRepoRetrieval, but only returns the RepoFiles that were
+// given the requested classification by their Repo's PathRules. Pass an
+// empty string to get the RepoFiles that matched no rule.
+func (db *DB) GetRepoFilesForRepoRetrievalByClassification(repoRetrievalID int, classification string) (map[int]*RepoFile, error) {
+	stmt, err := db.getStatement(stmtRepoFileGetForRepoRetrievalByClass)
+	if err != nil {
+		return nil, err
+	}
+
+	repoFiles := make(map[int]*RepoFile)
+	rows, err := stmt.Query(repoRetrievalID, classification)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		repoFile, err := scanRepoFile(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -103,9 +255,10 @@ func (db *DB) GetRepoFilesForRepoRetrieval(repoRetrievalID int) (map[int]*RepoFi
 }
 
 // BulkInsertRepoFiles inserts a collection of files into the database,
-// wrapped in a single transaction. It takes a map from a path to a 3-element
-// string array, with SHA1, SHA256 and MD5 hashes in that order.
-func (db *DB) BulkInsertRepoFiles(repoRetrievalID int, pathsToHashes map[string][3]string) error {
+// wrapped in a single transaction. It takes a map from a path to its Hashes,
+// and a map from each of the same paths to its FileDetails. If ctx is
+// cancelled, the transaction is rolled back.
+func (db *DB) BulkInsertRepoFiles(ctx context.Context, repoRetrievalID int, pathsToHashes map[string]Hashes, pathsToDetails map[string]FileDetails) error {
 	// first, get the corresponding repo directories from the database
 	repoDirs, err := db.GetRepoDirsForRepoRetrievalByPath(repoRetrievalID)
 	if err != nil {
@@ -114,15 +267,17 @@ func (db *DB) BulkInsertRepoFiles(repoRetrievalID int, pathsToHashes map[string]
 
 	// now, get a transaction and prepare a stmt on it
 	// (we can't use stmts prepared on the main DB from within a Tx)
-	tx, err := db.sqldb.Begin()
+	tx, err := db.sqldb.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
 	insertStmt, err := tx.Prepare(`
-		INSERT INTO repofiles (reporetrieval_id, dir_parent_id, path, hash_sha1, hash_sha256, hash_md5)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO repofiles (reporetrieval_id, dir_parent_id, path, hash_sha1, hash_sha256, hash_md5,
+			hash_sha512, hash_gitoid, file_type, size, is_executable, symlink_target, is_binary,
+			mime_type, language, classification)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 		RETURNING id
 	`)
 	if err != nil {
@@ -137,25 +292,29 @@ func (db *DB) BulkInsertRepoFiles(repoRetrievalID int, pathsToHashes map[string]
 	var repoFile *RepoFile
 
 	for path, hashes := range pathsToHashes {
-		hashSHA1 := hashes[0]
-		hashSHA256 := hashes[1]
-		hashMD5 := hashes[2]
-
 		dirParentPath := filepath.Dir(path)
 		dirParent, ok := repoDirs[dirParentPath]
 		if !ok {
 			return fmt.Errorf("Couldn't find parent directory object for file %s", path)
 		}
+		fd, ok := pathsToDetails[path]
+		if !ok {
+			return fmt.Errorf("Couldn't find file details for file %s", path)
+		}
 
 		var id int
 		err = insertStmt.QueryRow(repoRetrievalID, dirParent.ID, path,
-			hashSHA1, hashSHA256, hashMD5).Scan(&id)
+			hashes.SHA1, hashes.SHA256, hashes.MD5, hashes.SHA512, hashes.GitOID,
+			fd.FileType, fd.Size, fd.IsExecutable, fd.SymlinkTarget,
+			fd.IsBinary, fd.MIMEType, fd.Language, fd.Classification).Scan(&id)
 		if err != nil {
 			return err
 		}
 		repoFile = &RepoFile{ID: id, RepoRetrievalID: repoRetrievalID,
 			DirParentID: dirParent.ID, Path: path,
-			HashSHA1: hashSHA1, HashSHA256: hashSHA256, HashMD5: hashMD5}
+			HashSHA1: hashes.SHA1, HashSHA256: hashes.SHA256, HashMD5: hashes.MD5,
+			HashSHA512: hashes.SHA512, HashGitOID: hashes.GitOID,
+			FileDetails: fd}
 		repoFiles[path] = repoFile
 		repoFilePaths = append(repoFilePaths, path)
 	}
@@ -255,3 +414,36 @@ func fillInNextAndPrevRepoFiles(repoFiles map[string]*RepoFile, repoFilePaths []
 
 	return nil
 }
+
+// GetReferencedHashes returns the set of SHA1 and SHA256 hash pairs, in that
+// order, of every file that is referenced by at least one RepoFile.
+func (db *DB) GetReferencedHashes() (map[[2]string]bool, error) {
+	stmt, err := db.getStatement(stmtRepoFileGetReferencedHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	referenced := make(map[[2]string]bool)
+	for rows.Next() {
+		var hashes [2]string
+		err := rows.Scan(&hashes[0], &hashes[1])
+		if err != nil {
+			return nil, err
+		}
+		referenced[hashes] = true
+	}
+
+	// check at end for error
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return referenced, nil
+}
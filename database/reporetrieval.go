@@ -4,6 +4,8 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"strconv"
 	"time"
@@ -16,19 +18,131 @@ func (db *DB) createDBRepoRetrievalsTableIfNotExists() error {
 			repo_id INTEGER NOT NULL,
 			last_retrieval TIMESTAMP NOT NULL,
 			commit_hash TEXT NOT NULL,
+			commit_author TEXT NOT NULL,
+			commit_committer TEXT NOT NULL,
+			commit_time TIMESTAMP NOT NULL,
+			commit_subject TEXT NOT NULL,
+			tree_hash TEXT NOT NULL,
+			ref_name TEXT NOT NULL,
+			is_backfill BOOLEAN NOT NULL,
+			source_type TEXT NOT NULL,
+			archive_name TEXT NOT NULL,
+			archive_sha1 TEXT NOT NULL,
+			archive_sha256 TEXT NOT NULL,
+			archive_md5 TEXT NOT NULL,
+			package_version TEXT NOT NULL,
+			purl TEXT NOT NULL,
+			verification_code TEXT NOT NULL,
+			prepared BOOLEAN NOT NULL,
 			FOREIGN KEY (repo_id) REFERENCES repos (id)
 		)
 	`)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// retrievals from before these columns were added were all of git
+	// commits, with no metadata or verification code recorded, and were
+	// only kept if their files were prepared
+	return db.addColumnsIfNotExists("reporetrievals", []string{
+		"commit_author TEXT NOT NULL DEFAULT ''",
+		"commit_committer TEXT NOT NULL DEFAULT ''",
+		"commit_time TIMESTAMP NOT NULL DEFAULT 'epoch'",
+		"commit_subject TEXT NOT NULL DEFAULT ''",
+		"tree_hash TEXT NOT NULL DEFAULT ''",
+		"ref_name TEXT NOT NULL DEFAULT ''",
+		"is_backfill BOOLEAN NOT NULL DEFAULT FALSE",
+		"source_type TEXT NOT NULL DEFAULT '" + SourceGit + "'",
+		"archive_name TEXT NOT NULL DEFAULT ''",
+		"archive_sha1 TEXT NOT NULL DEFAULT ''",
+		"archive_sha256 TEXT NOT NULL DEFAULT ''",
+		"archive_md5 TEXT NOT NULL DEFAULT ''",
+		"package_version TEXT NOT NULL DEFAULT ''",
+		"purl TEXT NOT NULL DEFAULT ''",
+		"verification_code TEXT NOT NULL DEFAULT ''",
+		"prepared BOOLEAN NOT NULL DEFAULT TRUE",
+	})
+}
+
+// CommitInfo stores the metadata for the commit that was checked out for a
+// RepoRetrieval.
+type CommitInfo struct {
+	CommitHash      string
+	CommitAuthor    string
+	CommitCommitter string
+	CommitTime      time.Time
+	CommitSubject   string
+	TreeHash        string
+	RefName         string
+}
+
+// ArchiveInfo stores the name and hashes of the source archive that was
+// unpacked for a RepoRetrieval.
+type ArchiveInfo struct {
+	ArchiveName   string
+	ArchiveSHA1   string
+	ArchiveSHA256 string
+	ArchiveMD5    string
 }
 
+const (
+	// SourceGit indicates a RepoRetrieval of a commit from a git repo
+	SourceGit = "git"
+
+	// SourceArchive indicates a RepoRetrieval of an unpacked source archive
+	SourceArchive = "archive"
+
+	// SourcePackage indicates a RepoRetrieval of an unpacked package
+	// artifact from a package ecosystem
+	SourcePackage = "package"
+)
+
 // RepoRetrieval stores the data for a single point-in-time retrieval of a
-// source code repository that is being tracked in peridot.
+// source code repository that is being tracked in peridot. IsBackfill is
+// true for retrievals of historical commits, which are never treated as the
+// latest retrieval for their repo. SourceType is one of the Source*
+// constants; CommitInfo is only filled in for SourceGit, ArchiveInfo is
+// only filled in for SourceArchive and SourcePackage, and PackageVersion and
+// PURL are only filled in for SourcePackage. VerificationCode is the SPDX
+// package verification code for all of its RepoFiles, or empty if it
+// hasn't been computed. Prepared is only set once all of its RepoDirs,
+// RepoFiles and Findings have been recorded.
 type RepoRetrieval struct {
 	ID            int
 	RepoID        int
 	LastRetrieval time.Time
-	CommitHash    string
+	CommitInfo
+	IsBackfill bool
+	SourceType string
+	ArchiveInfo
+	PackageVersion   string
+	PURL             string
+	VerificationCode string
+	Prepared         bool
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRepoRetrieval(row rowScanner) (*RepoRetrieval, error) {
+	var repoRetrieval RepoRetrieval
+	err := row.Scan(&repoRetrieval.ID, &repoRetrieval.RepoID,
+		&repoRetrieval.LastRetrieval, &repoRetrieval.CommitHash,
+		&repoRetrieval.CommitAuthor, &repoRetrieval.CommitCommitter,
+		&repoRetrieval.CommitTime, &repoRetrieval.CommitSubject,
+		&repoRetrieval.TreeHash, &repoRetrieval.RefName,
+		&repoRetrieval.IsBackfill, &repoRetrieval.SourceType,
+		&repoRetrieval.ArchiveName, &repoRetrieval.ArchiveSHA1,
+		&repoRetrieval.ArchiveSHA256, &repoRetrieval.ArchiveMD5,
+		&repoRetrieval.PackageVersion, &repoRetrieval.PURL,
+		&repoRetrieval.VerificationCode, &repoRetrieval.Prepared)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repoRetrieval, nil
 }
 
 // GetRepoRetrievalByID looks up and returns a RepoRetrieval in the database
@@ -39,63 +153,139 @@ func (db *DB) GetRepoRetrievalByID(id int) (*RepoRetrieval, error) {
 		return nil, err
 	}
 
-	var repoRetrieval RepoRetrieval
-	err = stmt.QueryRow(id).Scan(&repoRetrieval.ID, &repoRetrieval.RepoID,
-		&repoRetrieval.LastRetrieval, &repoRetrieval.CommitHash)
+	return scanRepoRetrieval(stmt.QueryRow(id))
+}
+
+// GetRepoRetrievalLatest looks up and returns the most recent prepared
+// RepoRetrieval in the database for a given Repo's ID. It returns nil if no
+// Repo for the requested Repo ID is found.
+func (db *DB) GetRepoRetrievalLatest(repoID int) (*RepoRetrieval, error) {
+	stmt, err := db.getStatement(stmtRepoRetrievalGetLatest)
 	if err != nil {
 		return nil, err
 	}
 
-	return &repoRetrieval, nil
+	return scanRepoRetrieval(stmt.QueryRow(repoID))
 }
 
-// GetRepoRetrievalLatest looks up and returns the most recent RepoRetrieval
-// in the database for a given Repo's ID. It returns nil if no Repo for the
-// requested Repo ID is found.
-func (db *DB) GetRepoRetrievalLatest(repoID int) (*RepoRetrieval, error) {
-	stmt, err := db.getStatement(stmtRepoRetrievalGetLatest)
+// GetRepoRetrievalLatestUnprepared looks up and returns the most recent
+// RepoRetrieval in the database for a given Repo's ID whose files haven't
+// been prepared yet. It returns nil, nil if there isn't one.
+func (db *DB) GetRepoRetrievalLatestUnprepared(repoID int) (*RepoRetrieval, error) {
+	stmt, err := db.getStatement(stmtRepoRetrievalGetLatestUnprepared)
 	if err != nil {
 		return nil, err
 	}
 
-	var repoRetrieval RepoRetrieval
-	err = stmt.QueryRow(repoID).Scan(&repoRetrieval.ID, &repoRetrieval.RepoID,
-		&repoRetrieval.LastRetrieval, &repoRetrieval.CommitHash)
+	repoRetrieval, err := scanRepoRetrieval(stmt.QueryRow(repoID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return repoRetrieval, err
+}
+
+// GetRepoRetrievalByCommit looks up and returns the RepoRetrieval in the
+// database for a given Repo's ID and commit hash. It returns nil, nil if no
+// RepoRetrieval for that commit is found.
+func (db *DB) GetRepoRetrievalByCommit(repoID int, commitHash string) (*RepoRetrieval, error) {
+	stmt, err := db.getStatement(stmtRepoRetrievalGetByCommit)
 	if err != nil {
 		return nil, err
 	}
 
-	return &repoRetrieval, nil
+	repoRetrieval, err := scanRepoRetrieval(stmt.QueryRow(repoID, commitHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return repoRetrieval, err
 }
 
-// InsertRepoRetrieval takes a new repo retrieval's data, creates a new
-// RepoRetrieval struct, adds it to the database, and returns the new struct
-// with its ID from the DB.
-func (db *DB) InsertRepoRetrieval(repoID int, lr time.Time, ch string) (*RepoRetrieval, error) {
-	stmt, err := db.getStatement(stmtRepoRetrievalInsert)
+// GetRepoRetrievalByArchive looks up and returns the RepoRetrieval in the
+// database for a given Repo's ID and source archive SHA256 hash. It returns
+// nil, nil if no RepoRetrieval for that archive is found.
+func (db *DB) GetRepoRetrievalByArchive(repoID int, archiveSHA256 string) (*RepoRetrieval, error) {
+	stmt, err := db.getStatement(stmtRepoRetrievalGetByArchive)
 	if err != nil {
 		return nil, err
 	}
 
+	repoRetrieval, err := scanRepoRetrieval(stmt.QueryRow(repoID, archiveSHA256))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return repoRetrieval, err
+}
+
+// InsertRepoRetrieval takes a new repo retrieval's data and the metadata for
+// its commit, creates a new RepoRetrieval struct, adds it to the database,
+// and returns the new struct with its ID from the DB.
+func (db *DB) InsertRepoRetrieval(repoID int, lr time.Time, ci CommitInfo) (*RepoRetrieval, error) {
+	repoRet := &RepoRetrieval{RepoID: repoID, LastRetrieval: lr,
+		CommitInfo: ci, SourceType: SourceGit}
+	return repoRet, db.insertRepoRetrieval(repoRet)
+}
+
+// InsertBackfillRepoRetrieval is like InsertRepoRetrieval, but flags the new
+// RepoRetrieval as a backfill of a historical commit.
+func (db *DB) InsertBackfillRepoRetrieval(repoID int, lr time.Time, ci CommitInfo) (*RepoRetrieval, error) {
+	repoRet := &RepoRetrieval{RepoID: repoID, LastRetrieval: lr,
+		CommitInfo: ci, IsBackfill: true, SourceType: SourceGit}
+	return repoRet, db.insertRepoRetrieval(repoRet)
+}
+
+// InsertArchiveRepoRetrieval takes a new repo retrieval's data and the name
+// and hashes of its source archive, creates a new RepoRetrieval struct, adds
+// it to the database, and returns the new struct with its ID from the DB.
+func (db *DB) InsertArchiveRepoRetrieval(repoID int, lr time.Time, ai ArchiveInfo) (*RepoRetrieval, error) {
+	repoRet := &RepoRetrieval{RepoID: repoID, LastRetrieval: lr,
+		SourceType: SourceArchive, ArchiveInfo: ai}
+	return repoRet, db.insertRepoRetrieval(repoRet)
+}
+
+// InsertPackageRepoRetrieval takes a new repo retrieval's data, the name and
+// hashes of its package artifact, and the package's version and purl,
+// creates a new RepoRetrieval struct, adds it to the database, and returns
+// the new struct with its ID from the DB.
+func (db *DB) InsertPackageRepoRetrieval(repoID int, lr time.Time, ai ArchiveInfo, version string, purl string) (*RepoRetrieval, error) {
+	repoRet := &RepoRetrieval{RepoID: repoID, LastRetrieval: lr,
+		SourceType: SourcePackage, ArchiveInfo: ai, PackageVersion: version,
+		PURL: purl}
+	return repoRet, db.insertRepoRetrieval(repoRet)
+}
+
+// insertRepoRetrieval adds repoRet to the database, and fills in its ID.
+func (db *DB) insertRepoRetrieval(repoRet *RepoRetrieval) error {
+	stmt, err := db.getStatement(stmtRepoRetrievalInsert)
+	if err != nil {
+		return err
+	}
+
 	var id int
-	err = stmt.QueryRow(repoID, lr, ch).Scan(&id)
+	err = stmt.QueryRow(repoRet.RepoID, repoRet.LastRetrieval,
+		repoRet.CommitHash, repoRet.CommitAuthor, repoRet.CommitCommitter,
+		repoRet.CommitTime, repoRet.CommitSubject, repoRet.TreeHash,
+		repoRet.RefName, repoRet.IsBackfill, repoRet.SourceType,
+		repoRet.ArchiveName, repoRet.ArchiveSHA1, repoRet.ArchiveSHA256,
+		repoRet.ArchiveMD5, repoRet.PackageVersion, repoRet.PURL,
+		repoRet.VerificationCode, repoRet.Prepared).Scan(&id)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	repoRet := &RepoRetrieval{ID: id, RepoID: repoID, LastRetrieval: lr, CommitHash: ch}
-	return repoRet, nil
+	repoRet.ID = id
+	return nil
 }
 
-// UpdateRepoRetrieval updates a given RepoRetrieval's data in both the
-// database and its in-memory struct.
-func (db *DB) UpdateRepoRetrieval(repoRetrieval *RepoRetrieval, lr time.Time, ch string) error {
+// UpdateRepoRetrieval updates a given RepoRetrieval's data, including the
+// metadata for its commit, in both the database and its in-memory struct.
+func (db *DB) UpdateRepoRetrieval(repoRetrieval *RepoRetrieval, lr time.Time, ci CommitInfo) error {
 	stmt, err := db.getStatement(stmtRepoRetrievalUpdate)
 	if err != nil {
 		return err
 	}
 
-	res, err := stmt.Exec(lr, ch, repoRetrieval.ID)
+	res, err := stmt.Exec(lr, ci.CommitHash, ci.CommitAuthor, ci.CommitCommitter,
+		ci.CommitTime, ci.CommitSubject, ci.TreeHash, ci.RefName, repoRetrieval.ID)
 	if err != nil {
 		return err
 	}
@@ -111,7 +301,107 @@ func (db *DB) UpdateRepoRetrieval(repoRetrieval *RepoRetrieval, lr time.Time, ch
 
 	// update in-memory copy of repo
 	repoRetrieval.LastRetrieval = lr
-	repoRetrieval.CommitHash = ch
+	repoRetrieval.CommitInfo = ci
+
+	return nil
+}
+
+// UpdateRepoRetrievalVerificationCode sets the SPDX package verification
+// code for the RepoRetrieval with the given ID.
+func (db *DB) UpdateRepoRetrievalVerificationCode(repoRetrievalID int, code string) error {
+	stmt, err := db.getStatement(stmtRepoRetrievalUpdateVerificationCode)
+	if err != nil {
+		return err
+	}
+
+	res, err := stmt.Exec(code, repoRetrievalID)
+	if err != nil {
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowCount != 1 {
+		return fmt.Errorf("UpdateRepoRetrievalVerificationCode for ID %d modified %d rows, should be 1",
+			repoRetrievalID, rowCount)
+	}
 
 	return nil
 }
+
+// UpdateRepoRetrievalPrepared marks the RepoRetrieval with the given ID as
+// prepared. It should only be called once all of the RepoRetrieval's dirs,
+// files and findings have been recorded.
+func (db *DB) UpdateRepoRetrievalPrepared(repoRetrievalID int) error {
+	stmt, err := db.getStatement(stmtRepoRetrievalUpdatePrepared)
+	if err != nil {
+		return err
+	}
+
+	res, err := stmt.Exec(repoRetrievalID)
+	if err != nil {
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowCount != 1 {
+		return fmt.Errorf("UpdateRepoRetrievalPrepared for ID %d modified %d rows, should be 1",
+			repoRetrievalID, rowCount)
+	}
+
+	return nil
+}
+
+// DeleteRepoRetrieval removes the RepoRetrieval with the given ID from the
+// database, together with its Findings, RepoFiles and RepoDirs, wrapped in a
+// single transaction. It is used to clean up a RepoRetrieval whose files
+// could not be fully prepared.
+func (db *DB) DeleteRepoRetrieval(ctx context.Context, repoRetrievalID int) error {
+	tx, err := db.sqldb.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// delete in dependency order; the self-references between repofiles
+	// and between repodirs are all removed together by a single statement
+	for _, sv := range []dbStatementVal{
+		stmtFindingDeleteForRepoRetrieval,
+		stmtRepoFileDeleteForRepoRetrieval,
+		stmtRepoDirDeleteForRepoRetrieval,
+	} {
+		stmt, err := db.getStatement(sv)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Stmt(stmt).Exec(repoRetrievalID)
+		if err != nil {
+			return err
+		}
+	}
+
+	stmt, err := db.getStatement(stmtRepoRetrievalDelete)
+	if err != nil {
+		return err
+	}
+	res, err := tx.Stmt(stmt).Exec(repoRetrievalID)
+	if err != nil {
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowCount != 1 {
+		return fmt.Errorf("DeleteRepoRetrieval for ID %d deleted %d rows, should be 1",
+			repoRetrievalID, rowCount)
+	}
+
+	return tx.Commit()
+}
@@ -12,12 +12,14 @@ import (
 // in the correct order (e.g., dependent tables dropped before those
 // they depend upon).
 var tables = []string{
+	"findings",
 	"hashfiles",
 	"repofiles",
 	"repodirs",
 	"reporetrievals",
 	"licensenodes",
 	"licenseleafs",
+	"pathrules",
 	"repos",
 }
 
@@ -62,6 +64,11 @@ func (db *DB) createDBTablesIfNotExists() error {
 		return err
 	}
 
+	err = db.createDBPathRulesTableIfNotExists()
+	if err != nil {
+		return err
+	}
+
 	err = db.createDBLicenseLeafsTableIfNotExists()
 	if err != nil {
 		return err
@@ -92,6 +99,11 @@ func (db *DB) createDBTablesIfNotExists() error {
 		return err
 	}
 
+	err = db.createDBFindingsTableIfNotExists()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -102,11 +114,15 @@ const (
 	stmtRepoGet dbStatementVal = iota
 	stmtRepoGetByCoords
 	stmtRepoInsert
+	stmtPathRuleGetForRepo
+	stmtPathRuleInsert
+	stmtPathRuleDelete
 	stmtLicenseLeafGetAll
 	stmtLicenseLeafGetByID
 	stmtLicenseLeafGetByIdentifier
 	stmtLicenseLeafSearchByName
 	stmtLicenseLeafInsert
+	stmtLicenseLeafUpdateExtractedText
 	stmtLicenseNodeGetAll
 	stmtLicenseNodeGetByID
 	stmtLicenseNodeGetAndByContents
@@ -117,17 +133,34 @@ const (
 	stmtLicenseNodeInsert
 	stmtRepoRetrievalGet
 	stmtRepoRetrievalGetLatest
+	stmtRepoRetrievalGetLatestUnprepared
+	stmtRepoRetrievalGetByCommit
+	stmtRepoRetrievalGetByArchive
 	stmtRepoRetrievalInsert
 	stmtRepoRetrievalUpdate
+	stmtRepoRetrievalUpdateVerificationCode
+	stmtRepoRetrievalUpdatePrepared
+	stmtRepoRetrievalDelete
 	stmtRepoFileGet
 	stmtRepoFileGetForRepoRetrieval
+	stmtRepoFileGetForRepoRetrievalByClass
+	stmtRepoFileGetByPath
+	stmtRepoFileGetByHashes
+	stmtRepoFileGetReferencedHashes
 	stmtRepoFileInsert
+	stmtRepoFileDeleteForRepoRetrieval
 	stmtRepoDirGet
 	stmtRepoDirGetForRepoRetrieval
 	stmtRepoDirInsert
+	stmtRepoDirDeleteForRepoRetrieval
 	stmtHashFileGet
+	stmtHashFileGetAll
 	stmtHashFileGetByHashes
+	stmtHashFileGetByAnyHash
 	stmtHashFileInsert
+	stmtFindingGetForRepoRetrieval
+	stmtFindingInsert
+	stmtFindingDeleteForRepoRetrieval
 )
 
 // master prepare function
@@ -138,6 +171,10 @@ func (db *DB) prepareStatements() error {
 	if err != nil {
 		return err
 	}
+	err = db.prepareStatementsPathRules()
+	if err != nil {
+		return err
+	}
 	err = db.prepareStatementsLicenseLeafs()
 	if err != nil {
 		return err
@@ -162,6 +199,10 @@ func (db *DB) prepareStatements() error {
 	if err != nil {
 		return err
 	}
+	err = db.prepareStatementsFindings()
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -173,7 +214,7 @@ func (db *DB) prepareStatementsRepos() error {
 	var err error
 
 	err = db.addStatement(stmtRepoGet, `
-		SELECT id, org_name, repo_name
+		SELECT id, ecosystem, org_name, repo_name
 		FROM repos
 		WHERE id = $1
 	`)
@@ -184,30 +225,70 @@ func (db *DB) prepareStatementsRepos() error {
 	err = db.addStatement(stmtRepoGetByCoords, `
 		SELECT id
 		FROM repos
-		WHERE org_name = $1 AND repo_name = $2
+		WHERE ecosystem = $1 AND org_name = $2 AND repo_name = $3
 	`)
 	if err != nil {
 		return err
 	}
 
 	err = db.addStatement(stmtRepoInsert, `
-		INSERT INTO repos (org_name, repo_name)
-		VALUES ($1, $2)
+		INSERT INTO repos (ecosystem, org_name, repo_name)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// table pathrules
+func (db *DB) prepareStatementsPathRules() error {
+	var err error
+
+	err = db.addStatement(stmtPathRuleGetForRepo, `
+		SELECT id, repo_id, pattern, classification
+		FROM pathrules
+		WHERE repo_id = $1
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+
+	err = db.addStatement(stmtPathRuleInsert, `
+		INSERT INTO pathrules (repo_id, pattern, classification)
+		VALUES ($1, $2, $3)
 		RETURNING id
 	`)
 	if err != nil {
 		return err
 	}
 
+	err = db.addStatement(stmtPathRuleDelete, `
+		DELETE FROM pathrules
+		WHERE id = $1 AND repo_id = $2
+	`)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // table licenseleafs
+
+// licenseLeafColumns lists the columns in the order that scanLicenseLeaf
+// expects them.
+const licenseLeafColumns = `id, identifier, name, is_spdx, type,
+		       extracted_text, cross_refs, comment`
+
 func (db *DB) prepareStatementsLicenseLeafs() error {
 	var err error
 
 	err = db.addStatement(stmtLicenseLeafGetAll, `
-		SELECT id, identifier, name, is_spdx, type
+		SELECT `+licenseLeafColumns+`
 		FROM licenseleafs
 	`)
 	if err != nil {
@@ -215,7 +296,7 @@ func (db *DB) prepareStatementsLicenseLeafs() error {
 	}
 
 	err = db.addStatement(stmtLicenseLeafGetByID, `
-		SELECT id, identifier, name, is_spdx, type
+		SELECT `+licenseLeafColumns+`
 		FROM licenseleafs
 		WHERE id = $1
 	`)
@@ -224,7 +305,7 @@ func (db *DB) prepareStatementsLicenseLeafs() error {
 	}
 
 	err = db.addStatement(stmtLicenseLeafGetByIdentifier, `
-		SELECT id, identifier, name, is_spdx, type
+		SELECT `+licenseLeafColumns+`
 		FROM licenseleafs
 		WHERE identifier = $1
 	`)
@@ -233,7 +314,7 @@ func (db *DB) prepareStatementsLicenseLeafs() error {
 	}
 
 	err = db.addStatement(stmtLicenseLeafSearchByName, `
-		SELECT id, identifier, name, is_spdx, type
+		SELECT `+licenseLeafColumns+`
 		FROM licenseleafs
 		WHERE name LIKE '%$1%'
 	`)
@@ -242,14 +323,24 @@ func (db *DB) prepareStatementsLicenseLeafs() error {
 	}
 
 	err = db.addStatement(stmtLicenseLeafInsert, `
-		INSERT INTO licenseleafs (identifier, name, is_spdx, type)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO licenseleafs (identifier, name, is_spdx, type,
+			extracted_text, cross_refs, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id
 	`)
 	if err != nil {
 		return err
 	}
 
+	err = db.addStatement(stmtLicenseLeafUpdateExtractedText, `
+		UPDATE licenseleafs
+		SET name = $1, extracted_text = $2, cross_refs = $3, comment = $4
+		WHERE id = $5
+	`)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -332,11 +423,20 @@ func (db *DB) prepareStatementsLicenseNodes() error {
 }
 
 // table reporetrievals
+
+// repoRetrievalColumns lists the columns in the order that
+// scanRepoRetrieval expects them.
+const repoRetrievalColumns = `id, repo_id, last_retrieval, commit_hash,
+		       commit_author, commit_committer, commit_time, commit_subject,
+		       tree_hash, ref_name, is_backfill, source_type, archive_name,
+		       archive_sha1, archive_sha256, archive_md5, package_version,
+		       purl, verification_code, prepared`
+
 func (db *DB) prepareStatementsRepoRetrievals() error {
 	var err error
 
 	err = db.addStatement(stmtRepoRetrievalGet, `
-		SELECT id, repo_id, last_retrieval, commit_hash
+		SELECT `+repoRetrievalColumns+`
 		FROM reporetrievals
 		WHERE id = $1
 	`)
@@ -345,9 +445,42 @@ func (db *DB) prepareStatementsRepoRetrievals() error {
 	}
 
 	err = db.addStatement(stmtRepoRetrievalGetLatest, `
-		SELECT id, repo_id, last_retrieval, commit_hash
+		SELECT `+repoRetrievalColumns+`
 		FROM reporetrievals
-		WHERE repo_id = $1
+		WHERE repo_id = $1 AND NOT is_backfill AND prepared
+		ORDER BY last_retrieval DESC
+		LIMIT 1
+	`)
+	if err != nil {
+		return err
+	}
+
+	err = db.addStatement(stmtRepoRetrievalGetLatestUnprepared, `
+		SELECT `+repoRetrievalColumns+`
+		FROM reporetrievals
+		WHERE repo_id = $1 AND NOT is_backfill AND NOT prepared
+		ORDER BY last_retrieval DESC
+		LIMIT 1
+	`)
+	if err != nil {
+		return err
+	}
+
+	err = db.addStatement(stmtRepoRetrievalGetByCommit, `
+		SELECT `+repoRetrievalColumns+`
+		FROM reporetrievals
+		WHERE repo_id = $1 AND commit_hash = $2
+		ORDER BY last_retrieval DESC
+		LIMIT 1
+	`)
+	if err != nil {
+		return err
+	}
+
+	err = db.addStatement(stmtRepoRetrievalGetByArchive, `
+		SELECT `+repoRetrievalColumns+`
+		FROM reporetrievals
+		WHERE repo_id = $1 AND archive_sha256 = $2
 		ORDER BY last_retrieval DESC
 		LIMIT 1
 	`)
@@ -356,8 +489,13 @@ func (db *DB) prepareStatementsRepoRetrievals() error {
 	}
 
 	err = db.addStatement(stmtRepoRetrievalInsert, `
-		INSERT INTO reporetrievals (repo_id, last_retrieval, commit_hash)
-		VALUES ($1, $2, $3)
+		INSERT INTO reporetrievals (repo_id, last_retrieval, commit_hash,
+			commit_author, commit_committer, commit_time, commit_subject,
+			tree_hash, ref_name, is_backfill, source_type, archive_name,
+			archive_sha1, archive_sha256, archive_md5, package_version, purl,
+			verification_code, prepared)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
+			$15, $16, $17, $18, $19)
 		RETURNING id
 	`)
 	if err != nil {
@@ -366,8 +504,36 @@ func (db *DB) prepareStatementsRepoRetrievals() error {
 
 	err = db.addStatement(stmtRepoRetrievalUpdate, `
 		UPDATE reporetrievals
-		SET last_retrieval = $1, commit_hash = $2
-		WHERE id = $3
+		SET last_retrieval = $1, commit_hash = $2, commit_author = $3,
+			commit_committer = $4, commit_time = $5, commit_subject = $6,
+			tree_hash = $7, ref_name = $8
+		WHERE id = $9
+	`)
+	if err != nil {
+		return err
+	}
+
+	err = db.addStatement(stmtRepoRetrievalUpdateVerificationCode, `
+		UPDATE reporetrievals
+		SET verification_code = $1
+		WHERE id = $2
+	`)
+	if err != nil {
+		return err
+	}
+
+	err = db.addStatement(stmtRepoRetrievalUpdatePrepared, `
+		UPDATE reporetrievals
+		SET prepared = TRUE
+		WHERE id = $1
+	`)
+	if err != nil {
+		return err
+	}
+
+	err = db.addStatement(stmtRepoRetrievalDelete, `
+		DELETE FROM reporetrievals
+		WHERE id = $1
 	`)
 	if err != nil {
 		return err
@@ -407,16 +573,31 @@ func (db *DB) prepareStatementsRepoDirs() error {
 		return err
 	}
 
+	err = db.addStatement(stmtRepoDirDeleteForRepoRetrieval, `
+		DELETE FROM repodirs
+		WHERE reporetrieval_id = $1
+	`)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // table repofiles
+
+// repoFileColumns lists the columns in the order that scanRepoFile
+// expects them.
+const repoFileColumns = `id, reporetrieval_id, dir_parent_id, nextfile_id,
+		       prevfile_id, path, hash_sha1, hash_sha256, hash_md5,
+		       hash_sha512, hash_gitoid, file_type, size, is_executable, symlink_target, is_binary, mime_type,
+		       language, classification`
+
 func (db *DB) prepareStatementsRepoFiles() error {
 	var err error
 
 	err = db.addStatement(stmtRepoFileGet, `
-		SELECT id, reporetrieval_id, dir_parent_id, nextfile_id, prevfile_id,
-		       path, hash_sha1, hash_sha256, hash_md5
+		SELECT `+repoFileColumns+`
 		FROM repofiles
 		WHERE id = $1
 	`)
@@ -425,8 +606,7 @@ func (db *DB) prepareStatementsRepoFiles() error {
 	}
 
 	err = db.addStatement(stmtRepoFileGetForRepoRetrieval, `
-		SELECT id, reporetrieval_id, dir_parent_id, nextfile_id, prevfile_id,
-		       path, hash_sha1, hash_sha256, hash_md5
+		SELECT `+repoFileColumns+`
 		FROM repofiles
 		WHERE reporetrieval_id = $1
 		ORDER BY path
@@ -435,16 +615,64 @@ func (db *DB) prepareStatementsRepoFiles() error {
 		return err
 	}
 
+	err = db.addStatement(stmtRepoFileGetForRepoRetrievalByClass, `
+		SELECT `+repoFileColumns+`
+		FROM repofiles
+		WHERE reporetrieval_id = $1 AND classification = $2
+		ORDER BY path
+	`)
+	if err != nil {
+		return err
+	}
+
+	err = db.addStatement(stmtRepoFileGetByPath, `
+		SELECT `+repoFileColumns+`
+		FROM repofiles
+		WHERE reporetrieval_id = $1 AND path = $2
+	`)
+	if err != nil {
+		return err
+	}
+
+	err = db.addStatement(stmtRepoFileGetByHashes, `
+		SELECT `+repoFileColumns+`
+		FROM repofiles
+		WHERE hash_sha1 = $1 OR hash_sha256 = $2
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+
+	err = db.addStatement(stmtRepoFileGetReferencedHashes, `
+		SELECT DISTINCT hash_sha1, hash_sha256
+		FROM repofiles
+	`)
+	if err != nil {
+		return err
+	}
+
 	err = db.addStatement(stmtRepoFileInsert, `
 		INSERT INTO repofiles (reporetrieval_id, dir_parent_id,
-			nextfile_id, prevfile_id, path, hash_sha1, hash_sha256, hash_md5)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			nextfile_id, prevfile_id, path, hash_sha1, hash_sha256, hash_md5,
+			hash_sha512, hash_gitoid, file_type, size, is_executable,
+			symlink_target, is_binary, mime_type, language, classification)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16,
+			$17, $18)
 		RETURNING id
 	`)
 	if err != nil {
 		return err
 	}
 
+	err = db.addStatement(stmtRepoFileDeleteForRepoRetrieval, `
+		DELETE FROM repofiles
+		WHERE reporetrieval_id = $1
+	`)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -452,8 +680,17 @@ func (db *DB) prepareStatementsRepoFiles() error {
 func (db *DB) prepareStatementsHashFiles() error {
 	var err error
 
+	err = db.addStatement(stmtHashFileGetAll, `
+		SELECT id, hash_sha1, hash_sha256, hash_md5, hash_sha512, hash_gitoid
+		FROM hashfiles
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+
 	err = db.addStatement(stmtHashFileGet, `
-		SELECT id, hash_sha1, hash_sha256, hash_md5
+		SELECT id, hash_sha1, hash_sha256, hash_md5, hash_sha512, hash_gitoid
 		FROM hashfiles
 		WHERE id = $1
 	`)
@@ -462,7 +699,7 @@ func (db *DB) prepareStatementsHashFiles() error {
 	}
 
 	err = db.addStatement(stmtHashFileGetByHashes, `
-		SELECT id, hash_sha1, hash_sha256, hash_md5
+		SELECT id, hash_sha1, hash_sha256, hash_md5, hash_sha512, hash_gitoid
 		FROM hashfiles
 		WHERE hash_sha1 = $1 AND hash_sha256 = $2
 	`)
@@ -470,14 +707,62 @@ func (db *DB) prepareStatementsHashFiles() error {
 		return err
 	}
 
+	err = db.addStatement(stmtHashFileGetByAnyHash, `
+		SELECT id, hash_sha1, hash_sha256, hash_md5, hash_sha512, hash_gitoid
+		FROM hashfiles
+		WHERE hash_sha1 = $1 OR hash_sha256 = $1 OR hash_md5 = $1
+		   OR hash_sha512 = $1 OR hash_gitoid = $1
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+
 	err = db.addStatement(stmtHashFileInsert, `
-		INSERT INTO hashfiles (hash_sha1, hash_sha256, hash_md5)
-		VALUES ($1, $2, $3)
+		INSERT INTO hashfiles (hash_sha1, hash_sha256, hash_md5, hash_sha512,
+			hash_gitoid)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// table findings
+func (db *DB) prepareStatementsFindings() error {
+	var err error
+
+	err = db.addStatement(stmtFindingGetForRepoRetrieval, `
+		SELECT id, reporetrieval_id, repofile_id, type, expression,
+		       licensenode_id, source
+		FROM findings
+		WHERE reporetrieval_id = $1
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+
+	err = db.addStatement(stmtFindingInsert, `
+		INSERT INTO findings (reporetrieval_id, repofile_id, type, expression,
+			licensenode_id, source)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id
 	`)
 	if err != nil {
 		return err
 	}
 
+	err = db.addStatement(stmtFindingDeleteForRepoRetrieval, `
+		DELETE FROM findings
+		WHERE reporetrieval_id = $1
+	`)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
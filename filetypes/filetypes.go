@@ -0,0 +1,146 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package filetypes
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/swinslow/peridot/database"
+)
+
+// HeadSize is the number of bytes from the start of a file that are used to
+// classify it. As in git, a file is treated as binary if there is a NUL
+// byte anywhere within them.
+const HeadSize = 8000
+
+// ReadHead reads and returns up to the first HeadSize bytes from r.
+func ReadHead(r io.Reader) ([]byte, error) {
+	head, err := io.ReadAll(io.LimitReader(r, HeadSize))
+	if err != nil {
+		return nil, err
+	}
+	return head, nil
+}
+
+// NewFileDetails takes a file's path (slash-separated, relative to its
+// retrieval), size, executable bit and symlink target, and the head of its
+// contents as returned by ReadHead, and returns its FileDetails. Pass an
+// empty symlinkTarget for regular files.
+func NewFileDetails(filePath string, size int64, isExecutable bool, symlinkTarget string, head []byte) database.FileDetails {
+	if symlinkTarget != "" {
+		return database.FileDetails{
+			FileType:      database.FileTypeSymlink,
+			Size:          size,
+			SymlinkTarget: symlinkTarget,
+			MIMEType:      "inode/symlink",
+		}
+	}
+
+	isBinary := IsBinary(head)
+	fd := database.FileDetails{
+		FileType:     database.FileTypeRegular,
+		Size:         size,
+		IsExecutable: isExecutable,
+		IsBinary:     isBinary,
+		MIMEType:     GetMIMEType(filePath, head, isBinary),
+	}
+	if !isBinary {
+		fd.Language = GetLanguage(filePath)
+	}
+	return fd
+}
+
+// IsBinary returns true if the head of a file's contents contains a NUL
+// byte.
+func IsBinary(head []byte) bool {
+	return bytes.IndexByte(head, 0) >= 0
+}
+
+// GetMIMEType returns the MIME type for a file, without any parameters. The
+// type is sniffed from its contents; text files are then refined by their
+// extension, since sniffing alone reports nearly all of them as text/plain.
+func GetMIMEType(filePath string, head []byte, isBinary bool) string {
+	mimeType := http.DetectContentType(head)
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+
+	if isBinary {
+		// http.DetectContentType can report text types for files that
+		// merely start out looking like text
+		if strings.HasPrefix(mimeType, "text/") {
+			return "application/octet-stream"
+		}
+		return mimeType
+	}
+
+	if mimeType == "text/plain" {
+		ext := strings.ToLower(path.Ext(filePath))
+		if t, ok := textMIMETypes[ext]; ok {
+			return t
+		}
+	}
+	return mimeType
+}
+
+// GetLanguage returns the programming language for a file based on its name
+// or extension, or an empty string if it is not recognized.
+func GetLanguage(filePath string) string {
+	base := path.Base(filePath)
+	if lang, ok := languageFilenames[base]; ok {
+		return lang
+	}
+	return languageExtensions[strings.ToLower(path.Ext(base))]
+}
+
+// GetDiskFileDetails returns the FileDetails for the file at path (slash-
+// separated) within pathRoot on disk. Symlinks are never followed; their
+// target is recorded instead.
+func GetDiskFileDetails(pathRoot string, filePath string) (database.FileDetails, error) {
+	fullPath := filepath.Join(pathRoot, filepath.FromSlash(filePath))
+	fi, err := os.Lstat(fullPath)
+	if err != nil {
+		return database.FileDetails{}, err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			return database.FileDetails{}, err
+		}
+		return NewFileDetails(filePath, int64(len(target)), false, target, nil), nil
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return database.FileDetails{}, err
+	}
+	defer f.Close()
+
+	head, err := ReadHead(f)
+	if err != nil {
+		return database.FileDetails{}, err
+	}
+	return NewFileDetails(filePath, fi.Size(), fi.Mode()&0111 != 0, "", head), nil
+}
+
+// GetAllDiskFileDetails is like GetDiskFileDetails, but returns a map from
+// each path in allPaths to its FileDetails.
+func GetAllDiskFileDetails(pathRoot string, allPaths []string) (map[string]database.FileDetails, error) {
+	pathsToDetails := make(map[string]database.FileDetails)
+	for _, filePath := range allPaths {
+		fd, err := GetDiskFileDetails(pathRoot, filePath)
+		if err != nil {
+			return nil, err
+		}
+		pathsToDetails[filePath] = fd
+	}
+	return pathsToDetails, nil
+}
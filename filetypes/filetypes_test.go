@@ -0,0 +1,98 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package filetypes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/swinslow/peridot/database"
+)
+
+func TestIsBinary(t *testing.T) {
+	if IsBinary([]byte("package main\n")) {
+		t.Errorf("expected text to not be binary")
+	}
+	if !IsBinary([]byte("\x7fELF\x02\x01\x01\x00")) {
+		t.Errorf("expected contents with NUL byte to be binary")
+	}
+	if IsBinary(nil) {
+		t.Errorf("expected empty file to not be binary")
+	}
+}
+
+func TestGetMIMEType(t *testing.T) {
+	tests := []struct {
+		path     string
+		head     []byte
+		mimeType string
+	}{
+		{"src/main.go", []byte("package main\n"), "text/x-go"},
+		{"README", []byte("hello\n"), "text/plain"},
+		{"docs/logo.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), "image/png"},
+		{"data.bin", []byte("abc\x00def"), "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		got := GetMIMEType(tt.path, tt.head, IsBinary(tt.head))
+		if got != tt.mimeType {
+			t.Errorf("expected %s for %s, got %s", tt.mimeType, tt.path, got)
+		}
+	}
+}
+
+func TestGetLanguage(t *testing.T) {
+	tests := map[string]string{
+		"src/main.go":          "Go",
+		"lib/Foo.JAVA":         "Java",
+		"build/Makefile":       "Makefile",
+		"tools/CMakeLists.txt": "CMake",
+		"LICENSE":              "",
+		"notes.txt":            "",
+	}
+	for path, want := range tests {
+		got := GetLanguage(path)
+		if got != want {
+			t.Errorf("expected %q for %s, got %q", want, path, got)
+		}
+	}
+}
+
+func TestNewFileDetailsForBinaryHasNoLanguage(t *testing.T) {
+	fd := NewFileDetails("lib/blob.c", 8, false, "", []byte("abc\x00def"))
+	if !fd.IsBinary || fd.Language != "" {
+		t.Errorf("expected binary file with no language, got %+v", fd)
+	}
+}
+
+func TestGetDiskFileDetails(t *testing.T) {
+	root := t.TempDir()
+	err := os.WriteFile(filepath.Join(root, "run.sh"), []byte("#!/bin/sh\necho hi\n"), 0755)
+	if err != nil {
+		t.Fatalf("couldn't write test file: %v", err)
+	}
+	err = os.Symlink("does-not-exist", filepath.Join(root, "dangling"))
+	if err != nil {
+		t.Fatalf("couldn't create test symlink: %v", err)
+	}
+
+	fd, err := GetDiskFileDetails(root, "run.sh")
+	if err != nil {
+		t.Fatalf("got error when calling GetDiskFileDetails: %v", err)
+	}
+	if fd.FileType != database.FileTypeRegular || fd.Size != 18 || !fd.IsExecutable ||
+		fd.IsBinary || fd.MIMEType != "text/x-shellscript" || fd.Language != "Shell" {
+		t.Errorf("unexpected details for run.sh: %+v", fd)
+	}
+
+	// symlinks are recorded without being followed, even if dangling
+	fd, err = GetDiskFileDetails(root, "dangling")
+	if err != nil {
+		t.Fatalf("got error when calling GetDiskFileDetails: %v", err)
+	}
+	if fd.FileType != database.FileTypeSymlink || fd.SymlinkTarget != "does-not-exist" ||
+		fd.Size != int64(len("does-not-exist")) {
+		t.Errorf("unexpected details for dangling symlink: %+v", fd)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package filetypes
+
+// textMIMETypes maps lowercased file extensions to the MIME types used for
+// text files that http.DetectContentType reports as text/plain.
+var textMIMETypes = map[string]string{
+	".c":        "text/x-c",
+	".cc":       "text/x-c++",
+	".cpp":      "text/x-c++",
+	".css":      "text/css",
+	".csv":      "text/csv",
+	".go":       "text/x-go",
+	".h":        "text/x-c",
+	".hpp":      "text/x-c++",
+	".htm":      "text/html",
+	".html":     "text/html",
+	".java":     "text/x-java",
+	".js":       "text/javascript",
+	".json":     "application/json",
+	".markdown": "text/markdown",
+	".md":       "text/markdown",
+	".mjs":      "text/javascript",
+	".py":       "text/x-python",
+	".rs":       "text/x-rust",
+	".rst":      "text/x-rst",
+	".sh":       "text/x-shellscript",
+	".svg":      "image/svg+xml",
+	".toml":     "application/toml",
+	".ts":       "text/x-typescript",
+	".xml":      "text/xml",
+	".yaml":     "application/yaml",
+	".yml":      "application/yaml",
+}
+
+// languageFilenames maps file names that identify a language regardless of
+// their extension.
+var languageFilenames = map[string]string{
+	"BUILD":          "Starlark",
+	"BUILD.bazel":    "Starlark",
+	"CMakeLists.txt": "CMake",
+	"Dockerfile":     "Dockerfile",
+	"GNUmakefile":    "Makefile",
+	"Gemfile":        "Ruby",
+	"Makefile":       "Makefile",
+	"Rakefile":       "Ruby",
+	"makefile":       "Makefile",
+}
+
+// languageExtensions maps lowercased file extensions to languages.
+var languageExtensions = map[string]string{
+	".asm":   "Assembly",
+	".bash":  "Shell",
+	".bzl":   "Starlark",
+	".c":     "C",
+	".cc":    "C++",
+	".cmake": "CMake",
+	".cpp":   "C++",
+	".cs":    "C#",
+	".css":   "CSS",
+	".cxx":   "C++",
+	".dart":  "Dart",
+	".erl":   "Erlang",
+	".ex":    "Elixir",
+	".exs":   "Elixir",
+	".go":    "Go",
+	".h":     "C",
+	".hh":    "C++",
+	".hpp":   "C++",
+	".hs":    "Haskell",
+	".htm":   "HTML",
+	".html":  "HTML",
+	".java":  "Java",
+	".js":    "JavaScript",
+	".jsx":   "JavaScript",
+	".kt":    "Kotlin",
+	".lua":   "Lua",
+	".m":     "Objective-C",
+	".mjs":   "JavaScript",
+	".mk":    "Makefile",
+	".php":   "PHP",
+	".pl":    "Perl",
+	".pm":    "Perl",
+	".proto": "Protocol Buffers",
+	".py":    "Python",
+	".r":     "R",
+	".rb":    "Ruby",
+	".rs":    "Rust",
+	".s":     "Assembly",
+	".scala": "Scala",
+	".sh":    "Shell",
+	".sql":   "SQL",
+	".swift": "Swift",
+	".ts":    "TypeScript",
+	".tsx":   "TypeScript",
+	".vue":   "Vue",
+	".zsh":   "Shell",
+}
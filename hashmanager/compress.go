@@ -0,0 +1,223 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package hashmanager
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// CompressionNone stores blobs as raw copies
+	CompressionNone = "none"
+
+	// CompressionGzip stores blobs gzip-compressed, with a ".gz" suffix
+	CompressionGzip = "gzip"
+
+	// CompressionZstd stores blobs zstd-compressed, with a ".zst" suffix
+	CompressionZstd = "zstd"
+)
+
+// compressionSuffixes maps each compression to the suffix added to the
+// names of blobs stored with it. A blob's hashes are always those of its
+// original content, so the suffix is the only way to tell how to read it.
+var compressionSuffixes = map[string]string{
+	CompressionNone: "",
+	CompressionGzip: ".gz",
+	CompressionZstd: ".zst",
+}
+
+// IsValidCompression returns true if c is one of the Compression*
+// constants.
+func IsValidCompression(c string) bool {
+	_, ok := compressionSuffixes[c]
+	return ok
+}
+
+// splitCompressionSuffix takes a blob's file name, and returns it without
+// any compression suffix along with the compression that suffix indicates.
+func splitCompressionSuffix(name string) (string, string) {
+	for c, suffix := range compressionSuffixes {
+		if suffix != "" && strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix), c
+		}
+	}
+	return name, CompressionNone
+}
+
+// findBlob returns the key of the stored blob with the given hashes, and
+// the compression it is stored with, whether or not that is the store's
+// current compression. It returns an error satisfying os.IsNotExist if
+// there is no such blob.
+func (hm *HashManager) findBlob(hSHA1 string, hSHA256 string, hMD5 string) (string, string, error) {
+	key := GetKeyForHash(hSHA1, hSHA256, hMD5)
+
+	// check the current compression first, since that is most likely
+	order := []string{hm.compression(), CompressionNone, CompressionGzip, CompressionZstd}
+	for _, c := range order {
+		_, err := hm.Store.Stat(key + compressionSuffixes[c])
+		if err == nil {
+			return key + compressionSuffixes[c], c, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", "", fmt.Errorf("couldn't check hash location: %v", err)
+		}
+	}
+	return "", "", &os.PathError{Op: "find", Path: hm.Store.Location(key), Err: os.ErrNotExist}
+}
+
+// compression returns the compression used for new blobs.
+func (hm *HashManager) compression() string {
+	if hm.Compression == "" {
+		return CompressionNone
+	}
+	return hm.Compression
+}
+
+// OpenBlob opens the stored blob with the given hashes for reading, and
+// decompresses it if needed, so that the returned contents are always the
+// original file's.
+func (hm *HashManager) OpenBlob(hSHA1 string, hSHA256 string, hMD5 string) (io.ReadCloser, error) {
+	key, c, err := hm.findBlob(hSHA1, hSHA256, hMD5)
+	if err != nil {
+		return nil, err
+	}
+	return hm.openCompressed(key, c)
+}
+
+// decompressReader reads decompressed contents, and closes both the
+// decompressor and the underlying blob.
+type decompressReader struct {
+	io.Reader
+	closeFn func()
+	rc      io.ReadCloser
+}
+
+func (dr *decompressReader) Close() error {
+	if dr.closeFn != nil {
+		dr.closeFn()
+	}
+	return dr.rc.Close()
+}
+
+// openCompressed opens the blob at key, and decompresses it as c.
+func (hm *HashManager) openCompressed(key string, c string) (io.ReadCloser, error) {
+	rc, err := hm.Store.Open(key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c {
+	case CompressionGzip:
+		zr, err := gzip.NewReader(rc)
+		if err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("couldn't read gzip blob %s: %v", key, err)
+		}
+		return &decompressReader{Reader: zr, closeFn: func() { zr.Close() }, rc: rc}, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(rc, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("couldn't read zstd blob %s: %v", key, err)
+		}
+		return &decompressReader{Reader: zr, closeFn: zr.Close, rc: rc}, nil
+	default:
+		return rc, nil
+	}
+}
+
+// writeCompressed copies src to dst, compressed as c.
+func writeCompressed(dst io.Writer, src io.Reader, c string) error {
+	var zw io.WriteCloser
+	var err error
+	switch c {
+	case CompressionGzip:
+		zw = gzip.NewWriter(dst)
+	case CompressionZstd:
+		zw, err = zstd.NewWriter(dst, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return err
+		}
+	default:
+		_, err = io.Copy(dst, src)
+		return err
+	}
+
+	_, err = io.Copy(zw, src)
+	if err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// compressTo compresses src as c, and passes the result to put as a
+// reader. If check is non-nil, it is called once src has been read in full,
+// and an error from it is returned to put as a read error, so that put
+// stores nothing.
+func compressTo(src io.Reader, c string, check func() error, put func(io.Reader) error) error {
+	pr, pw := io.Pipe()
+	go func() {
+		err := writeCompressed(pw, src, c)
+		if err == nil && check != nil {
+			err = check()
+		}
+		pw.CloseWithError(err)
+	}()
+	err := put(pr)
+	// unblock the writer if put stopped reading early
+	pr.CloseWithError(err)
+	return err
+}
+
+// RecompressBlob rewrites the blob b so that it is stored with compression
+// c, and returns its new key along with its size before and after. If b is
+// already stored that way, it is left alone. The new copy is complete
+// before the old one is removed, so an interruption never loses a blob.
+func (hm *HashManager) RecompressBlob(b Blob, c string) (string, int64, int64, error) {
+	if !IsValidCompression(c) {
+		return "", 0, 0, fmt.Errorf("invalid compression %s", c)
+	}
+	if b.Compression == c {
+		return b.Key, b.Size, b.Size, nil
+	}
+
+	base, _ := splitCompressionSuffix(b.Key)
+	newKey := base + compressionSuffixes[c]
+
+	// an earlier, interrupted run may have already written the new copy
+	if info, err := hm.Store.Stat(newKey); err == nil {
+		err = hm.Store.Remove(b.Key)
+		return newKey, b.Size, info.Size, err
+	}
+
+	src, err := hm.openCompressed(b.Key, b.Compression)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	defer src.Close()
+
+	// don't carry a corrupt blob over into its new form
+	hr := newHashingReader(src)
+	check := func() error { return hr.check(b.HashSHA1, b.HashSHA256, "") }
+	err = compressTo(hr, c, check, func(r io.Reader) error {
+		return hm.Store.Put(newKey, r)
+	})
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("couldn't write %s: %v", hm.Store.Location(newKey), err)
+	}
+	info, err := hm.Store.Stat(newKey)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	err = hm.Store.Remove(b.Key)
+	return newKey, b.Size, info.Size, err
+}
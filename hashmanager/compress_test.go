@@ -0,0 +1,93 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package hashmanager
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompressedBlobsReadBack(t *testing.T) {
+	contents := strings.Repeat("hello world\n", 100)
+	for _, c := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		hm := &HashManager{Store: &LocalStore{Root: t.TempDir()}, Compression: c}
+		hashes := storeBlob(t, hm, contents)
+
+		path := hm.GetPathToHash(hashes.SHA1, hashes.SHA256, hashes.MD5) + compressionSuffixes[c]
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("%s: expected blob at %s, got %v", c, path, err)
+		}
+		if c != CompressionNone && fi.Size() >= int64(len(contents)) {
+			t.Errorf("%s: expected blob to be compressed, got %d bytes", c, fi.Size())
+		}
+
+		r, err := hm.OpenBlob(hashes.SHA1, hashes.SHA256, hashes.MD5)
+		if err != nil {
+			t.Fatalf("%s: got error when calling OpenBlob: %v", c, err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || string(got) != contents {
+			t.Errorf("%s: expected original contents back, got %d bytes (%v)", c, len(got), err)
+		}
+
+		blobs, invalid, err := hm.ListBlobs()
+		if err != nil || len(blobs) != 1 || len(invalid) != 0 {
+			t.Fatalf("%s: expected 1 blob, got %v, %v (%v)", c, blobs, invalid, err)
+		}
+		if blobs[0].Compression != c || blobs[0].HashSHA256 != hashes.SHA256 {
+			t.Errorf("%s: expected blob to be parsed with its compression, got %+v", c, blobs[0])
+		}
+		detail, err := hm.VerifyBlob(blobs[0], hashes.MD5)
+		if detail != "" || err != nil {
+			t.Errorf("%s: expected blob to verify, got %s (%v)", c, detail, err)
+		}
+	}
+}
+
+func TestCopyFindsBlobWithOtherCompression(t *testing.T) {
+	hm := &HashManager{Store: &LocalStore{Root: t.TempDir()}, Compression: CompressionGzip}
+	hashes := storeBlob(t, hm, "hello")
+
+	hm.Compression = CompressionZstd
+	copied, err := hm.CopyReaderToHash(strings.NewReader("hello"), hashes.SHA1, hashes.SHA256, hashes.MD5)
+	if err != nil {
+		t.Fatalf("got error when calling CopyReaderToHash: %v", err)
+	}
+	if copied {
+		t.Errorf("expected existing gzip blob to be found, but it was copied again")
+	}
+}
+
+func TestRecompressBlob(t *testing.T) {
+	hm := &HashManager{Store: &LocalStore{Root: t.TempDir()}}
+	hashes := storeBlob(t, hm, "hello")
+
+	for _, c := range []string{CompressionZstd, CompressionGzip, CompressionNone} {
+		blobs, _, err := hm.ListBlobs()
+		if err != nil || len(blobs) != 1 {
+			t.Fatalf("expected 1 blob, got %v (%v)", blobs, err)
+		}
+		newPath, _, _, err := hm.RecompressBlob(blobs[0], c)
+		if err != nil {
+			t.Fatalf("got error when calling RecompressBlob to %s: %v", c, err)
+		}
+		if _, err := hm.Store.Stat(blobs[0].Key); !os.IsNotExist(err) {
+			t.Errorf("expected old blob %s to be removed", blobs[0].Key)
+		}
+
+		r, err := hm.OpenBlob(hashes.SHA1, hashes.SHA256, hashes.MD5)
+		if err != nil {
+			t.Fatalf("got error when calling OpenBlob after converting to %s: %v", c, err)
+		}
+		got, _ := io.ReadAll(r)
+		r.Close()
+		if string(got) != "hello" {
+			t.Errorf("expected hello from %s, got %q", newPath, got)
+		}
+	}
+}
@@ -4,26 +4,37 @@
 package hashmanager
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
-
-	"golang.org/x/sys/unix"
+	"strings"
 
 	"github.com/swinslow/peridot/config"
 	"github.com/swinslow/peridot/database"
+	"github.com/swinslow/peridot/progress"
 )
 
 // HashManager holds the data objects needed to manage copies of files
-// scanned by peridot that are stored on disk by their hash values.
+// scanned by peridot that are stored in a BlobStore by their hash values.
+// Compression is one of the Compression* constants, and is used for new
+// blobs; blobs stored with any compression can be read. Link is one of the
+// Link* constants.
 type HashManager struct {
-	HashesPath string
-	db         *database.DB
+	Store       BlobStore
+	Compression string
+	Link        string
+	db          *database.DB
 }
 
 // PrepareHM is called with existing Config and Database objects and
-// initializes the hash manager's on-disk storage location.
+// initializes the hash manager's BlobStore, as selected by the Config.
 func (hm *HashManager) PrepareHM(cfg *config.Config, db *database.DB) error {
 	if hm == nil {
 		return fmt.Errorf("must pass non-nil HashManager")
@@ -35,104 +46,269 @@ func (hm *HashManager) PrepareHM(cfg *config.Config, db *database.DB) error {
 		return fmt.Errorf("must prepare and pass database")
 	}
 
-	err := hm.setHashesLocation(cfg.HashesLocation)
+	store, err := NewBlobStore(cfg)
 	if err != nil {
 		return err
 	}
+	hm.Store = store
 
-	hm.db = db
-	return nil
-}
+	if cfg.HashesCompression != "" && !IsValidCompression(cfg.HashesCompression) {
+		return fmt.Errorf("invalid hashes compression %s", cfg.HashesCompression)
+	}
+	hm.Compression = cfg.HashesCompression
 
-func (hm *HashManager) setHashesLocation(path string) error {
-	// check whether path exists in filesystem
-	fi, err := os.Stat(path)
+	err = checkLinkMode(cfg)
 	if err != nil {
 		return err
 	}
+	hm.Link = cfg.HashesLink
+
+	hm.db = db
+	return nil
+}
 
-	// check whether path is a directory
-	if !fi.IsDir() {
-		return fmt.Errorf("%s is not a directory", path)
+// GetReaderHashes reads all of src and returns its Hashes. size is the
+// number of bytes that src will return, which git includes in a blob's
+// object ID; if size is negative, the GitOID is left empty. It returns an
+// error if src doesn't return exactly size bytes.
+func GetReaderHashes(src io.Reader, size int64) (database.Hashes, error) {
+	var hashes database.Hashes
+	hSHA1 := sha1.New()
+	hSHA256 := sha256.New()
+	hMD5 := md5.New()
+	hSHA512 := sha512.New()
+	hGit := sha1.New()
+	writers := []io.Writer{hSHA1, hSHA256, hMD5, hSHA512}
+	if size >= 0 {
+		fmt.Fprintf(hGit, "blob %d\x00", size)
+		writers = append(writers, hGit)
 	}
 
-	// check whether path is writable
-	if unix.Access(path, unix.W_OK) != nil {
-		return fmt.Errorf("%s is not writable", path)
+	n, err := io.Copy(io.MultiWriter(writers...), src)
+	if err != nil {
+		return hashes, err
+	}
+	if size >= 0 && n != size {
+		return hashes, fmt.Errorf("read %d bytes, expected %d", n, size)
+	}
+	hashes.SHA1 = fmt.Sprintf("%x", hSHA1.Sum(nil))
+	hashes.SHA256 = fmt.Sprintf("%x", hSHA256.Sum(nil))
+	hashes.MD5 = fmt.Sprintf("%x", hMD5.Sum(nil))
+	hashes.SHA512 = fmt.Sprintf("%x", hSHA512.Sum(nil))
+	if size >= 0 {
+		hashes.GitOID = fmt.Sprintf("%x", hGit.Sum(nil))
 	}
 
-	// we're good
-	hm.HashesPath = path
-	return nil
+	return hashes, nil
 }
 
-// GetPathToHash takes a file's hash values, and returns the full on-disk
-// pathname to locate that file.
-func (hm *HashManager) GetPathToHash(hSHA1 string, hSHA256 string, hMD5 string) string {
+// OpenNoFollow opens the file at path for reading. If it is a symlink, the
+// link is not followed; instead, the returned contents are the link's target
+// string, matching how git stores symlinks.
+func OpenNoFollow(path string) (io.ReadCloser, error) {
+	r, _, err := OpenNoFollowWithSize(path)
+	return r, err
+}
+
+// OpenNoFollowWithSize is like OpenNoFollow, but also returns the number of
+// bytes of contents, for passing to GetReaderHashes.
+func OpenNoFollowWithSize(path string) (io.ReadCloser, int64, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		return io.NopCloser(strings.NewReader(target)), int64(len(target)), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	return f, fi.Size(), nil
+}
+
+// GetKeyForHash takes a file's hash values, and returns its key in the
+// BlobStore, without any compression suffix.
+func GetKeyForHash(hSHA1 string, hSHA256 string, hMD5 string) string {
 	// uses all three hashes for dirs
 	// uses just SHA1 and SHA256 for filename
-	return filepath.Join(hm.HashesPath, hSHA1[:2], hSHA256[:2], hMD5[:2],
-		fmt.Sprintf("%s.%s", hSHA1, hSHA256))
+	return fmt.Sprintf("%s/%s/%s/%s.%s", hSHA1[:2], hSHA256[:2], hMD5[:2],
+		hSHA1, hSHA256)
 }
 
-// CopyFileToHash copies a file into its corresponding on-disk "hash location"
-// based on its hash values. It returns (false, nil) if a file already exists
-// in the hash location.
+// GetPathToHash takes a file's hash values, and returns the location of
+// that file in the BlobStore, without any compression suffix. For a local
+// store, this is its full on-disk pathname.
+func (hm *HashManager) GetPathToHash(hSHA1 string, hSHA256 string, hMD5 string) string {
+	return hm.Store.Location(GetKeyForHash(hSHA1, hSHA256, hMD5))
+}
+
+// CopyFileToHash copies a file into its corresponding "hash location" in
+// the BlobStore based on its hash values, compressing it if the HashManager
+// is configured to. It returns (false, nil) if a file already exists in the
+// hash location, however it is compressed.
 func (hm *HashManager) CopyFileToHash(srcPath string, hSHA1 string, hSHA256 string, hMD5 string) (bool, error) {
-	// first check if there's already a file in the dst path
-	dstPath := hm.GetPathToHash(hSHA1, hSHA256, hMD5)
-	_, err := os.Stat(dstPath)
+	// first check if there's already a file in the hash location
+	_, _, err := hm.findBlob(hSHA1, hSHA256, hMD5)
 	if err == nil {
-		// no error from stat means a file exists at dstPath
+		// no error from findBlob means a file exists there
 		return false, nil
 	}
 
 	if !(os.IsNotExist(err)) {
-		return false, fmt.Errorf("couldn't check hash location: %v", err)
-	}
-
-	// dstPath is available
-	// make sure the directory exists
-	err = os.MkdirAll(filepath.Dir(dstPath), 0700)
-	if err != nil {
-		return false, fmt.Errorf("couldn't create hash subdir: %v", err)
+		return false, err
 	}
 
-	// and copy the file there
-	srcFile, err := os.Open(srcPath)
+	// the hash location is available, so copy the file there
+	srcFile, err := OpenNoFollow(srcPath)
 	if err != nil {
 		return false, fmt.Errorf("couldn't open src file for copying: %v", err)
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.Create(dstPath)
-	if err != nil {
-		return false, fmt.Errorf("couldn't open dst file for copying: %v", err)
+	return hm.putBlob(srcFile, hSHA1, hSHA256, hMD5)
+}
+
+// CopyReaderToHash is like CopyFileToHash, but reads the file's contents
+// from src rather than from a path on disk. It returns (false, nil) if a
+// file already exists in the hash location, without reading from src.
+func (hm *HashManager) CopyReaderToHash(src io.Reader, hSHA1 string, hSHA256 string, hMD5 string) (bool, error) {
+	_, _, err := hm.findBlob(hSHA1, hSHA256, hMD5)
+	if err == nil {
+		return false, nil
 	}
 
-	_, err = io.Copy(dstFile, srcFile)
-	if err != nil {
-		dstFile.Close()
-		return false, fmt.Errorf("error copying file: %v", err)
+	if !(os.IsNotExist(err)) {
+		return false, err
 	}
 
-	err = dstFile.Close()
+	return hm.putBlob(src, hSHA1, hSHA256, hMD5)
+}
+
+// putBlob compresses src and stores it in its hash location, plus the
+// suffix for the HashManager's compression. Nothing is stored unless src's
+// contents match the given hashes. It returns false if another writer
+// stored the same blob first.
+func (hm *HashManager) putBlob(src io.Reader, hSHA1 string, hSHA256 string, hMD5 string) (bool, error) {
+	c := hm.compression()
+	key := GetKeyForHash(hSHA1, hSHA256, hMD5) + compressionSuffixes[c]
+
+	hr := newHashingReader(src)
+	check := func() error { return hr.check(hSHA1, hSHA256, hMD5) }
+	var created bool
+	err := compressTo(hr, c, check, func(r io.Reader) error {
+		var err error
+		created, err = hm.Store.PutIfAbsent(key, r)
+		return err
+	})
 	if err != nil {
-		return false, fmt.Errorf("error closing file: %v", err)
+		return false, fmt.Errorf("couldn't store blob: %v", err)
 	}
+	return created, nil
+}
+
+// hashingReader hashes everything read through it, so that a copy can be
+// checked against its expected hashes without reading the source twice.
+type hashingReader struct {
+	r       io.Reader
+	hSHA1   hash.Hash
+	hSHA256 hash.Hash
+	hMD5    hash.Hash
+}
+
+func newHashingReader(r io.Reader) *hashingReader {
+	return &hashingReader{r: r, hSHA1: sha1.New(), hSHA256: sha256.New(), hMD5: md5.New()}
+}
+
+func (hr *hashingReader) Read(p []byte) (int, error) {
+	n, err := hr.r.Read(p)
+	hr.hSHA1.Write(p[:n])
+	hr.hSHA256.Write(p[:n])
+	hr.hMD5.Write(p[:n])
+	return n, err
+}
 
-	return true, nil
+// check returns an error if the contents read so far don't match the given
+// hashes. An empty hMD5 isn't checked.
+func (hr *hashingReader) check(hSHA1 string, hSHA256 string, hMD5 string) error {
+	gotSHA1 := fmt.Sprintf("%x", hr.hSHA1.Sum(nil))
+	gotSHA256 := fmt.Sprintf("%x", hr.hSHA256.Sum(nil))
+	gotMD5 := fmt.Sprintf("%x", hr.hMD5.Sum(nil))
+	if gotSHA1 != hSHA1 || gotSHA256 != hSHA256 || (hMD5 != "" && gotMD5 != hMD5) {
+		return fmt.Errorf("contents don't match expected hashes: SHA1 is %s, expected %s", gotSHA1, hSHA1)
+	}
+	return nil
 }
 
 // CopyAllFilesToHash copies each file to its corresponding on-disk "hash
-// location" based on its hash values. It returns a new map of path => array
-// of hashes for only the newly-copied files that weren't already present.
-func (hm *HashManager) CopyAllFilesToHash(pathRoot string, pathsToHashes map[string][3]string) (map[string][3]string, error) {
-	copiedFiles := make(map[string][3]string)
+// location" based on its hash values. It returns a new map of path => Hashes
+// for only the newly-copied files that weren't already present. If the
+// HashManager's Link mode allows it, regular files are linked into the hash
+// store rather than copied.
+func (hm *HashManager) CopyAllFilesToHash(ctx context.Context, pathRoot string, pathsToHashes map[string]database.Hashes) (map[string]database.Hashes, error) {
+	open := func(path string) (io.ReadCloser, error) {
+		return OpenNoFollow(filepath.Join(pathRoot, path))
+	}
+	return hm.copyAllFiles(ctx, open, pathRoot, pathsToHashes)
+}
+
+// FileOpener opens a file by its path within a repo, for reading its
+// contents into the hash store.
+type FileOpener func(path string) (io.ReadCloser, error)
+
+// CopyAllFilesToHashWithOpener is like CopyAllFilesToHash, but opens each
+// file's contents using open rather than reading from a directory on disk.
+// Files already present in the hash store are not opened. If ctx is
+// cancelled, it stops before the next file; files already copied are kept,
+// since each one is complete.
+func (hm *HashManager) CopyAllFilesToHashWithOpener(ctx context.Context, open FileOpener, pathsToHashes map[string]database.Hashes) (map[string]database.Hashes, error) {
+	return hm.copyAllFiles(ctx, open, "", pathsToHashes)
+}
+
+// copyAllFiles does the work for CopyAllFilesToHash and
+// CopyAllFilesToHashWithOpener. If pathRoot is non-empty, it tries linking
+// each file from there before falling back to open.
+func (hm *HashManager) copyAllFiles(ctx context.Context, open FileOpener, pathRoot string, pathsToHashes map[string]database.Hashes) (map[string]database.Hashes, error) {
+	copiedFiles := make(map[string]database.Hashes)
+	t := progress.Start(ctx, progress.PhaseCopy, len(pathsToHashes))
 
 	for path, hashes := range pathsToHashes {
-		fullPath := filepath.Join(pathRoot, path)
-		copied, err := hm.CopyFileToHash(fullPath, hashes[0], hashes[1], hashes[2])
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// check first, so we don't open files that we won't need
+		_, _, err := hm.findBlob(hashes.SHA1, hashes.SHA256, hashes.MD5)
+		if err == nil {
+			t.Add(1, 0)
+			continue
+		}
+
+		if pathRoot != "" {
+			copied, n, linked, err := hm.linkToHash(filepath.Join(pathRoot, path), hashes)
+			if err != nil {
+				return nil, fmt.Errorf("error linking all files to hashes: %v", err)
+			}
+			if linked {
+				if copied {
+					copiedFiles[path] = hashes
+				}
+				t.Add(1, n)
+				continue
+			}
+		}
+
+		src, err := open(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening %s to copy to hashes: %v", path, err)
+		}
+		cr := &progress.CountingReader{R: src}
+		copied, err := hm.CopyReaderToHash(cr, hashes.SHA1, hashes.SHA256, hashes.MD5)
+		src.Close()
 		if err != nil {
 			return nil, fmt.Errorf("error copying all files to hashes: %v", err)
 		}
@@ -140,6 +316,7 @@ func (hm *HashManager) CopyAllFilesToHash(pathRoot string, pathsToHashes map[str
 		if copied {
 			copiedFiles[path] = hashes
 		}
+		t.Add(1, cr.N)
 	}
 	return copiedFiles, nil
 }
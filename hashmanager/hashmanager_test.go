@@ -0,0 +1,117 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package hashmanager
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/swinslow/peridot/database"
+)
+
+func TestCopyReaderToHashRejectsWrongContents(t *testing.T) {
+	hm := &HashManager{Store: &LocalStore{Root: t.TempDir()}}
+	hashes, _ := GetReaderHashes(strings.NewReader("hello"), 5)
+
+	copied, err := hm.CopyReaderToHash(strings.NewReader("hellp"), hashes.SHA1, hashes.SHA256, hashes.MD5)
+	if err == nil || copied {
+		t.Errorf("expected mismatched contents to be rejected, got %v (%v)", copied, err)
+	}
+
+	// neither the blob nor its temporary file is left behind
+	entries, err := os.ReadDir(hm.Store.Location(GetKeyForHash(hashes.SHA1, hashes.SHA256, hashes.MD5) + "/.."))
+	if err != nil {
+		t.Fatalf("couldn't read hash subdir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected empty hash subdir, got %v", entries)
+	}
+}
+
+func TestConcurrentCopiesOfSameContent(t *testing.T) {
+	contents := strings.Repeat("hello world\n", 10000)
+	for _, c := range []string{CompressionNone, CompressionZstd} {
+		hm := &HashManager{Store: &LocalStore{Root: t.TempDir()}, Compression: c}
+		hashes, _ := GetReaderHashes(strings.NewReader(contents), int64(len(contents)))
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		created := 0
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				copied, err := hm.CopyReaderToHash(strings.NewReader(contents), hashes.SHA1, hashes.SHA256, hashes.MD5)
+				if err != nil {
+					t.Errorf("%s: got error when calling CopyReaderToHash: %v", c, err)
+				}
+				if copied {
+					mu.Lock()
+					created++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if created != 1 {
+			t.Errorf("%s: expected exactly 1 copy to be reported as new, got %d", c, created)
+		}
+		blobs, invalid, err := hm.ListBlobs()
+		if err != nil || len(blobs) != 1 || len(invalid) != 0 {
+			t.Fatalf("%s: expected 1 blob, got %v, %v (%v)", c, blobs, invalid, err)
+		}
+		detail, err := hm.VerifyBlob(blobs[0], hashes.MD5)
+		if detail != "" || err != nil {
+			t.Errorf("%s: expected blob to verify, got %s (%v)", c, detail, err)
+		}
+	}
+}
+
+func TestHashKind(t *testing.T) {
+	hashes, _ := GetReaderHashes(strings.NewReader("hello"), 5)
+	for h, want := range map[string]string{hashes.SHA1: "SHA1", hashes.SHA256: "SHA256",
+		hashes.MD5: "MD5", hashes.SHA512: "SHA512", hashes.GitOID: "SHA1"} {
+		if got := hashKind(h); got != want {
+			t.Errorf("expected %s, got %s", want, got)
+		}
+	}
+	for _, h := range []string{"", "abc", strings.ToUpper(hashes.SHA1), hashes.SHA1 + "0"} {
+		if got := hashKind(h); got != "" {
+			t.Errorf("expected %q to be no kind of hash, got %s", h, got)
+		}
+	}
+}
+
+func TestGetReaderHashesGitOID(t *testing.T) {
+	// known values from "git hash-object"
+	for contents, want := range map[string]string{
+		"":      "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391",
+		"hello": "b6fc4c620b67d95f953a5c1c1230aaab5db5a1b0",
+	} {
+		hashes, err := GetReaderHashes(strings.NewReader(contents), int64(len(contents)))
+		if err != nil {
+			t.Fatalf("couldn't hash %q: %v", contents, err)
+		}
+		if hashes.GitOID != want {
+			t.Errorf("%q: expected git OID %s, got %s", contents, want, hashes.GitOID)
+		}
+		if hashes.SWHID() != "swh:1:cnt:"+want {
+			t.Errorf("%q: expected SWHID for %s, got %s", contents, want, hashes.SWHID())
+		}
+		if oid, ok := database.GitOIDFromSWHID(hashes.SWHID()); !ok || oid != want {
+			t.Errorf("%q: expected to parse git OID %s from SWHID, got %s", contents, want, oid)
+		}
+	}
+
+	hashes, err := GetReaderHashes(strings.NewReader("hello"), -1)
+	if err != nil || hashes.GitOID != "" || hashes.SHA1 == "" {
+		t.Errorf("expected no git OID without size, got %+v (%v)", hashes, err)
+	}
+	if _, err := GetReaderHashes(strings.NewReader("hello"), 4); err == nil {
+		t.Errorf("expected error for wrong size, got nil")
+	}
+}
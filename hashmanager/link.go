@@ -0,0 +1,89 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package hashmanager
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/swinslow/peridot/config"
+	"github.com/swinslow/peridot/database"
+)
+
+const (
+	// LinkNone copies every file into the hash store
+	LinkNone = "none"
+
+	// LinkHardlink hardlinks files from a clone into the hash store, so
+	// that they share an inode
+	LinkHardlink = "hardlink"
+
+	// LinkReflink makes copy-on-write clones of files in the hash store,
+	// on filesystems that support them, such as Btrfs and XFS
+	LinkReflink = "reflink"
+)
+
+// errLinkUnsupported is returned when a file can't be linked into the hash
+// store, such as when it is on another filesystem, and should be copied
+// instead.
+var errLinkUnsupported = fmt.Errorf("linking not supported")
+
+// checkLinkMode returns an error if cfg.HashesLink is invalid, or can't be
+// used with the rest of cfg. A stored blob shares its bytes with the file
+// it was linked from, so it can't be compressed, and it must be on the
+// local filesystem.
+func checkLinkMode(cfg *config.Config) error {
+	switch cfg.HashesLink {
+	case "", LinkNone:
+		return nil
+	case LinkHardlink, LinkReflink:
+	default:
+		return fmt.Errorf("invalid hashes link mode %s", cfg.HashesLink)
+	}
+
+	if cfg.HashesCompression != "" && cfg.HashesCompression != CompressionNone {
+		return fmt.Errorf("hashes link mode %s needs hashes compression %s", cfg.HashesLink, CompressionNone)
+	}
+	if cfg.HashesBackend != "" && cfg.HashesBackend != BackendLocal {
+		return fmt.Errorf("hashes link mode %s needs hashes backend %s", cfg.HashesLink, BackendLocal)
+	}
+	return nil
+}
+
+// linkToHash stores the file at srcPath in its hash location by linking
+// it, if the HashManager's Link mode and store allow it. It returns
+// linked == false, without an error, if the file should be copied instead.
+// Otherwise, it returns whether the blob was newly created, and its size.
+func (hm *HashManager) linkToHash(srcPath string, hashes database.Hashes) (created bool, size int64, linked bool, err error) {
+	if hm.Link == "" || hm.Link == LinkNone || hm.compression() != CompressionNone {
+		return false, 0, false, nil
+	}
+	ls, ok := hm.Store.(*LocalStore)
+	if !ok {
+		return false, 0, false, nil
+	}
+
+	// symlinks are stored as their target string, so they can't be linked
+	fi, err := os.Lstat(srcPath)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false, 0, false, nil
+	}
+
+	key := GetKeyForHash(hashes.SHA1, hashes.SHA256, hashes.MD5)
+	created, err = ls.LinkIfAbsent(key, srcPath, hm.Link, func(r io.Reader) error {
+		hr := newHashingReader(r)
+		if _, err := io.Copy(io.Discard, hr); err != nil {
+			return err
+		}
+		return hr.check(hashes.SHA1, hashes.SHA256, hashes.MD5)
+	})
+	if err == errLinkUnsupported {
+		return false, 0, false, nil
+	}
+	if err != nil {
+		return false, 0, false, fmt.Errorf("couldn't link blob: %v", err)
+	}
+	return created, fi.Size(), true, nil
+}
@@ -0,0 +1,141 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package hashmanager
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swinslow/peridot/database"
+)
+
+// writeRepoFile writes contents to path within root, and returns its hashes.
+func writeRepoFile(t *testing.T, root string, path string, contents string) database.Hashes {
+	err := os.WriteFile(filepath.Join(root, path), []byte(contents), 0755)
+	if err != nil {
+		t.Fatalf("couldn't write %s: %v", path, err)
+	}
+	hashes, err := GetReaderHashes(strings.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		t.Fatalf("couldn't hash %s: %v", path, err)
+	}
+	return hashes
+}
+
+func TestCopyAllFilesToHashHardlink(t *testing.T) {
+	root := t.TempDir()
+	hm := &HashManager{Store: &LocalStore{Root: filepath.Join(root, "hashes")}, Link: LinkHardlink}
+	if err := os.Mkdir(filepath.Join(root, "hashes"), 0700); err != nil {
+		t.Fatalf("couldn't create hash store: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "repo"), 0700); err != nil {
+		t.Fatalf("couldn't create repo: %v", err)
+	}
+	hashes := writeRepoFile(t, filepath.Join(root, "repo"), "a.sh", "echo hi\n")
+
+	copied, err := hm.CopyAllFilesToHash(context.Background(), filepath.Join(root, "repo"),
+		map[string]database.Hashes{"a.sh": hashes})
+	if err != nil || len(copied) != 1 {
+		t.Fatalf("expected 1 file stored, got %v (%v)", copied, err)
+	}
+
+	srcFI, err := os.Stat(filepath.Join(root, "repo", "a.sh"))
+	if err != nil {
+		t.Fatalf("couldn't stat source: %v", err)
+	}
+	blobFI, err := os.Stat(hm.GetPathToHash(hashes.SHA1, hashes.SHA256, hashes.MD5))
+	if err != nil {
+		t.Fatalf("couldn't stat blob: %v", err)
+	}
+	if !os.SameFile(srcFI, blobFI) {
+		t.Errorf("expected blob to be hardlinked to source")
+	}
+	if perm := blobFI.Mode().Perm(); perm != 0555 {
+		t.Errorf("expected blob to be read-only and keep its executable bits, got %v", perm)
+	}
+}
+
+func TestCopyAllFilesToHashHardlinkResetsModTime(t *testing.T) {
+	root := t.TempDir()
+	repo := t.TempDir()
+	hm := &HashManager{Store: &LocalStore{Root: root}, Link: LinkHardlink}
+	hashes := writeRepoFile(t, repo, "a.txt", "hello")
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	if err := os.Chtimes(filepath.Join(repo, "a.txt"), old, old); err != nil {
+		t.Fatalf("couldn't set source modification time: %v", err)
+	}
+
+	before := time.Now().Add(-time.Minute)
+	_, err := hm.CopyAllFilesToHash(context.Background(), repo,
+		map[string]database.Hashes{"a.txt": hashes})
+	if err != nil {
+		t.Fatalf("got error when calling CopyAllFilesToHash: %v", err)
+	}
+
+	// a new blob must look new to garbage collection's grace period
+	blobFI, err := os.Stat(hm.GetPathToHash(hashes.SHA1, hashes.SHA256, hashes.MD5))
+	if err != nil {
+		t.Fatalf("couldn't stat blob: %v", err)
+	}
+	if blobFI.ModTime().Before(before) {
+		t.Errorf("expected blob modification time to be reset, got %v", blobFI.ModTime())
+	}
+}
+
+func TestCopyAllFilesToHashLinkFallsBack(t *testing.T) {
+	for _, mode := range []string{LinkHardlink, LinkReflink} {
+		root := t.TempDir()
+		repo := t.TempDir()
+		hm := &HashManager{Store: &LocalStore{Root: root}, Link: mode}
+		hashes := writeRepoFile(t, repo, "a.txt", "hello")
+		if err := os.Symlink("a.txt", filepath.Join(repo, "b.txt")); err != nil {
+			t.Fatalf("couldn't create symlink: %v", err)
+		}
+		linkHashes, _ := GetReaderHashes(strings.NewReader("a.txt"), 5)
+
+		// reflinks are unsupported on most test filesystems, and symlinks
+		// are never linked, so these are copied; either way, the blobs
+		// must have the right contents and be read-only
+		copied, err := hm.CopyAllFilesToHash(context.Background(), repo,
+			map[string]database.Hashes{"a.txt": hashes, "b.txt": linkHashes})
+		if err != nil || len(copied) != 2 {
+			t.Fatalf("%s: expected 2 files stored, got %v (%v)", mode, copied, err)
+		}
+		for contents, h := range map[string]database.Hashes{"hello": hashes, "a.txt": linkHashes} {
+			r, err := hm.OpenBlob(h.SHA1, h.SHA256, h.MD5)
+			if err != nil {
+				t.Fatalf("%s: couldn't open blob: %v", mode, err)
+			}
+			got, _ := io.ReadAll(r)
+			r.Close()
+			if string(got) != contents {
+				t.Errorf("%s: expected %q, got %q", mode, contents, got)
+			}
+			fi, err := os.Stat(hm.GetPathToHash(h.SHA1, h.SHA256, h.MD5))
+			if err != nil || fi.Mode().Perm()&0222 != 0 {
+				t.Errorf("%s: expected read-only blob, got %v (%v)", mode, fi.Mode(), err)
+			}
+		}
+	}
+}
+
+func TestCopyAllFilesToHashLinkRejectsWrongContents(t *testing.T) {
+	repo := t.TempDir()
+	hm := &HashManager{Store: &LocalStore{Root: t.TempDir()}, Link: LinkHardlink}
+	hashes := writeRepoFile(t, repo, "a.txt", "hello")
+	writeRepoFile(t, repo, "a.txt", "hellp")
+
+	_, err := hm.CopyAllFilesToHash(context.Background(), repo, map[string]database.Hashes{"a.txt": hashes})
+	if err == nil {
+		t.Fatalf("expected error for changed contents, got nil")
+	}
+	if _, err := hm.Store.Stat(GetKeyForHash(hashes.SHA1, hashes.SHA256, hashes.MD5)); !os.IsNotExist(err) {
+		t.Errorf("expected nothing stored, got %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package hashmanager
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile is locked shared by anything that adds blobs to a LocalStore,
+// and exclusive by anything that removes them.
+const lockFile = ".lock"
+
+// LocalStore is a BlobStore in a directory on the local filesystem.
+type LocalStore struct {
+	Root string
+}
+
+// NewLocalStore returns a LocalStore for the directory at path, which must
+// already exist and be writable.
+func NewLocalStore(path string) (*LocalStore, error) {
+	// check whether path exists in filesystem
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// check whether path is a directory
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", path)
+	}
+
+	// check whether path is writable
+	if unix.Access(path, unix.W_OK) != nil {
+		return nil, fmt.Errorf("%s is not writable", path)
+	}
+
+	// we're good
+	return &LocalStore{Root: path}, nil
+}
+
+// Location returns the path on disk for key.
+func (ls *LocalStore) Location(key string) string {
+	return filepath.Join(ls.Root, filepath.FromSlash(key))
+}
+
+// Stat returns details of the file at key.
+func (ls *LocalStore) Stat(key string) (BlobInfo, error) {
+	fi, err := os.Lstat(ls.Location(key))
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	return BlobInfo{Key: key, Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+// Open opens the file at key for reading.
+func (ls *LocalStore) Open(key string) (io.ReadCloser, error) {
+	return os.Open(ls.Location(key))
+}
+
+// blobMode is the permissions for blobs, which are read-only so that
+// nothing writes to them in place once they are stored.
+const blobMode = 0444
+
+// writeTemp writes all of src to a new, uniquely-named, read-only temporary
+// file next to path, and syncs it to disk. The temporary file is dot-prefixed so that
+// it is never listed as a blob, even if a crash leaves it behind.
+func writeTemp(path string, src io.Reader) (string, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return "", fmt.Errorf("couldn't create hash subdir: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("couldn't open dst file for copying: %v", err)
+	}
+	_, err = io.Copy(tmp, src)
+	if err == nil {
+		err = tmp.Chmod(blobMode)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("error copying file: %v", err)
+	}
+	return tmp.Name(), nil
+}
+
+// syncDir syncs the directory at path, so that a rename or link within it
+// survives a crash.
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Put writes src to a temporary file next to key's location, and then
+// renames it into place.
+func (ls *LocalStore) Put(key string, src io.Reader) error {
+	path := ls.Location(key)
+	tmpPath, err := writeTemp(path, src)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// PutIfAbsent writes src to a temporary file next to key's location, and
+// then hard-links it into place, which fails if another writer got there
+// first.
+func (ls *LocalStore) PutIfAbsent(key string, src io.Reader) (bool, error) {
+	path := ls.Location(key)
+	tmpPath, err := writeTemp(path, src)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmpPath)
+
+	return linkIntoPlace(tmpPath, path)
+}
+
+// linkIntoPlace hard-links the temporary file at tmpPath to path, and
+// returns false if something is already there.
+func linkIntoPlace(tmpPath string, path string) (bool, error) {
+	err := os.Link(tmpPath, path)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, syncDir(filepath.Dir(path))
+}
+
+// LinkIfAbsent is like PutIfAbsent, but stores the file at srcPath by
+// sharing its bytes on disk rather than copying them, with a hardlink or a
+// reflink as mode says. Before the blob is put in place, check is called
+// with its contents, and nothing is stored if it returns an error. It
+// returns errLinkUnsupported if srcPath can't be linked from here, such as
+// when it is on another filesystem.
+//
+// A hardlinked blob shares its inode with srcPath, so the write permissions
+// that LinkIfAbsent removes are also removed from srcPath. Git replaces
+// files rather than writing to them in place, so this doesn't stop later
+// checkouts, but it does keep anything else from changing the blob.
+//
+// A linked blob would otherwise keep srcPath's modification time, which may
+// be long before the grace period that keeps garbage collection from
+// removing blobs that aren't referenced yet, so it is set to now. For a
+// hardlink, this also touches srcPath.
+func (ls *LocalStore) LinkIfAbsent(key string, srcPath string, mode string, check func(io.Reader) error) (bool, error) {
+	path := ls.Location(key)
+	var tmpPath string
+	var err error
+	switch mode {
+	case LinkHardlink:
+		tmpPath, err = hardlinkTemp(path, srcPath)
+	case LinkReflink:
+		tmpPath, err = reflinkTemp(path, srcPath)
+	default:
+		return false, errLinkUnsupported
+	}
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmpPath)
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return false, err
+	}
+	err = check(f)
+	f.Close()
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	err = os.Chtimes(tmpPath, now, now)
+	if err != nil {
+		return false, err
+	}
+
+	return linkIntoPlace(tmpPath, path)
+}
+
+// hardlinkTemp hard-links srcPath to a new, uniquely-named temporary file
+// next to path, and removes its write permissions.
+func hardlinkTemp(path string, srcPath string) (string, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return "", fmt.Errorf("couldn't create hash subdir: %v", err)
+	}
+
+	// os.Link can't pick a unique name itself, so reserve one first
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("couldn't reserve temporary name for link: %v", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpPath)
+
+	err = os.Link(srcPath, tmpPath)
+	if err != nil {
+		if linkErr, ok := err.(*os.LinkError); ok && isLinkUnsupported(linkErr.Err) {
+			return "", errLinkUnsupported
+		}
+		return "", err
+	}
+
+	fi, err := os.Stat(tmpPath)
+	if err == nil {
+		err = os.Chmod(tmpPath, fi.Mode().Perm()&^0222)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return tmpPath, nil
+}
+
+// isLinkUnsupported returns true if err from a hardlink means that the
+// filesystem can't link the file, rather than that something went wrong.
+func isLinkUnsupported(err error) bool {
+	return err == unix.EXDEV || err == unix.EPERM || err == unix.EMLINK || err == unix.EOPNOTSUPP
+}
+
+// reflinkTemp makes a read-only, copy-on-write clone of srcPath in a new,
+// uniquely-named temporary file next to path, and syncs it to disk.
+func reflinkTemp(path string, srcPath string) (string, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return "", fmt.Errorf("couldn't create hash subdir: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("couldn't open dst file for reflink: %v", err)
+	}
+	err = reflink(tmp, src)
+	if err == nil {
+		err = tmp.Chmod(blobMode)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// Remove deletes the file at key, along with any of its parent directories
+// that are left empty.
+func (ls *LocalStore) Remove(key string) error {
+	path := ls.Location(key)
+	err := os.Remove(path)
+	if err != nil {
+		return err
+	}
+
+	// removing a non-empty directory fails, which is where we stop
+	for dir := filepath.Dir(path); dir != ls.Root && strings.HasPrefix(dir, ls.Root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// List walks the directory, skipping bookkeeping files and directories.
+func (ls *LocalStore) List(fn func(BlobInfo) error) error {
+	return filepath.Walk(ls.Root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != ls.Root && strings.HasPrefix(fi.Name(), ".") {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(ls.Root, path)
+		if err != nil {
+			return err
+		}
+		return fn(BlobInfo{Key: filepath.ToSlash(rel), Size: fi.Size(), ModTime: fi.ModTime()})
+	})
+}
+
+// Lock takes a lock on a file in the directory, which only excludes other
+// processes on the same machine.
+func (ls *LocalStore) Lock(exclusive bool) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(ls.Root, lockFile), os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open hash store lock: %v", err)
+	}
+
+	how := unix.LOCK_SH
+	if exclusive {
+		how = unix.LOCK_EX | unix.LOCK_NB
+	}
+	err = unix.Flock(int(f.Fd()), how)
+	if err != nil {
+		f.Close()
+		if err == unix.EWOULDBLOCK {
+			return nil, ErrStoreBusy
+		}
+		return nil, fmt.Errorf("couldn't lock hash store: %v", err)
+	}
+
+	return func() {
+		unix.Flock(int(f.Fd()), unix.LOCK_UN)
+		f.Close()
+	}, nil
+}
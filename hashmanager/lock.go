@@ -0,0 +1,33 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package hashmanager
+
+// LockShared waits until no garbage collection is running on the hash
+// store, then locks it so that none can start until the returned function
+// is called. Any number of shared locks can be held at once.
+func (hm *HashManager) LockShared() (func(), error) {
+	return hm.Store.Lock(false)
+}
+
+// TryLockExclusive locks the hash store for garbage collection, until the
+// returned function is called. It returns ErrStoreBusy immediately, rather
+// than waiting, if anything else holds a lock.
+func (hm *HashManager) TryLockExclusive() (func(), error) {
+	return hm.Store.Lock(true)
+}
+
+// RemoveBlob deletes the blob at key from the hash store, and returns its
+// size.
+func (hm *HashManager) RemoveBlob(key string) (int64, error) {
+	info, err := hm.Store.Stat(key)
+	if err != nil {
+		return 0, err
+	}
+
+	err = hm.Store.Remove(key)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
@@ -0,0 +1,58 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package hashmanager
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLockExcludesGC(t *testing.T) {
+	hm := &HashManager{Store: &LocalStore{Root: t.TempDir()}}
+
+	unlock1, err := hm.LockShared()
+	if err != nil {
+		t.Fatalf("got error when calling LockShared: %v", err)
+	}
+	unlock2, err := hm.LockShared()
+	if err != nil {
+		t.Fatalf("expected second shared lock to succeed, got %v", err)
+	}
+
+	if _, err := hm.TryLockExclusive(); err == nil {
+		t.Errorf("expected exclusive lock to fail while shared locks are held")
+	}
+
+	unlock1()
+	unlock2()
+	unlock, err := hm.TryLockExclusive()
+	if err != nil {
+		t.Fatalf("expected exclusive lock to succeed, got %v", err)
+	}
+	unlock()
+}
+
+func TestRemoveBlob(t *testing.T) {
+	hm := &HashManager{Store: &LocalStore{Root: t.TempDir()}}
+	hashes := storeBlob(t, hm, "hello")
+	other := storeBlob(t, hm, "world")
+	size, err := hm.RemoveBlob(GetKeyForHash(hashes.SHA1, hashes.SHA256, hashes.MD5))
+	if err != nil {
+		t.Fatalf("got error when calling RemoveBlob: %v", err)
+	}
+	if size != 5 {
+		t.Errorf("expected size 5, got %d", size)
+	}
+
+	// only the blob's own, now empty, directories are removed
+	if _, err := os.Stat(hm.Store.Location(hashes.SHA1[:2])); !os.IsNotExist(err) {
+		t.Errorf("expected empty directories to be removed")
+	}
+	if _, err := os.Stat(hm.Store.Location("")); err != nil {
+		t.Errorf("expected hash store root to remain, got %v", err)
+	}
+	if _, err := os.Stat(hm.GetPathToHash(other.SHA1, other.SHA256, other.MD5)); err != nil {
+		t.Errorf("expected other blob to remain, got %v", err)
+	}
+}
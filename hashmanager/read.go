@@ -0,0 +1,76 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package hashmanager
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/swinslow/peridot/database"
+)
+
+// hashKind returns "SHA1", "SHA256", "MD5" or "SHA512" according to the
+// length of h, which must be a lowercase hex string, or "" if it can't be
+// any of them. A git blob object ID has the same length as a SHA1.
+func hashKind(h string) string {
+	switch {
+	case isHex(h, 40):
+		return "SHA1"
+	case isHex(h, 64):
+		return "SHA256"
+	case isHex(h, 32):
+		return "MD5"
+	case isHex(h, 128):
+		return "SHA512"
+	}
+	return ""
+}
+
+// OpenBlobByHash opens the stored blob with h as any one of its SHA1,
+// SHA256, MD5 or SHA512 hashes, its git blob object ID or its SWHID,
+// looking up the others in the database, and decompresses it if needed. It
+// returns an error if h matches more than one distinct file, which can
+// only happen for MD5.
+func (hm *HashManager) OpenBlobByHash(h string) (io.ReadCloser, error) {
+	h = strings.ToLower(h)
+	if oid, ok := database.GitOIDFromSWHID(h); ok {
+		h = oid
+	}
+	if hashKind(h) == "" {
+		return nil, fmt.Errorf("%s is not a SHA1, SHA256, MD5 or SHA512 hash, git object ID or SWHID", h)
+	}
+
+	hashFiles, err := hm.db.GetHashFilesByHash(h)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't look up hash in DB: %v", err)
+	}
+	if len(hashFiles) == 0 {
+		return nil, &os.PathError{Op: "open", Path: h, Err: os.ErrNotExist}
+	}
+	hf := hashFiles[0]
+	for _, other := range hashFiles[1:] {
+		if other.HashSHA1 != hf.HashSHA1 || other.HashSHA256 != hf.HashSHA256 {
+			return nil, fmt.Errorf("%s %s matches more than one file", hashKind(h), h)
+		}
+	}
+
+	return hm.OpenBlob(hf.HashSHA1, hf.HashSHA256, hf.HashMD5)
+}
+
+// OpenBlobForRepoFile opens the stored blob with the contents of the
+// RepoFile with the given ID, and decompresses it if needed. Files that
+// were excluded by a path rule were never stored, so they can't be opened.
+func (hm *HashManager) OpenBlobForRepoFile(repoFileID int) (io.ReadCloser, error) {
+	rf, err := hm.db.GetRepoFileByID(repoFileID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get repo file from DB: %v", err)
+	}
+	if rf.Classification == database.ClassExcluded {
+		return nil, fmt.Errorf("%s was excluded by a path rule, so its contents weren't stored", rf.Path)
+	}
+
+	return hm.OpenBlob(rf.HashSHA1, rf.HashSHA256, rf.HashMD5)
+}
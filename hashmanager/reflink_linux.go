@@ -0,0 +1,29 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build linux
+// +build linux
+
+package hashmanager
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// ficlone is the FICLONE ioctl request, which makes one file share all of
+// another's extents.
+const ficlone = 0x40049409
+
+// reflink makes dst a copy-on-write clone of src.
+func reflink(dst *os.File, src *os.File) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, dst.Fd(), ficlone, src.Fd())
+	if errno != 0 {
+		if errno == unix.EXDEV || errno == unix.EOPNOTSUPP || errno == unix.EINVAL || errno == unix.ENOTTY {
+			return errLinkUnsupported
+		}
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+// +build !linux
+
+package hashmanager
+
+import (
+	"os"
+)
+
+// reflink isn't supported here, so files are always copied instead.
+func reflink(dst *os.File, src *os.File) error {
+	return errLinkUnsupported
+}
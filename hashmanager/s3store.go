@@ -0,0 +1,428 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package hashmanager
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/swinslow/peridot/config"
+)
+
+// Lease objects are how S3Store locks the store across workers. Each
+// shared lock is an object under leaseSharedPrefix, and the exclusive lock
+// is leaseExclusive. A worker that crashes while holding a lock leaves its
+// lease behind, and it must be deleted by hand.
+const (
+	leaseSharedPrefix = ".locks/shared/"
+	leaseExclusive    = ".locks/exclusive"
+)
+
+// emptySHA256 is the hex SHA256 of an empty request body.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Store is a BlobStore in a bucket of an S3-compatible object store. It
+// uses path-style requests, so that it works with stand-ins such as MinIO
+// as well as with S3 itself.
+type S3Store struct {
+	cfg      config.S3Config
+	endpoint *url.URL
+	client   *http.Client
+
+	// pollInterval is how long Lock waits between checks for an
+	// exclusive lock to be released
+	pollInterval time.Duration
+}
+
+// NewS3Store returns an S3Store for the bucket described by cfg.
+func NewS3Store(cfg config.S3Config) (*S3Store, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("must pass S3 endpoint and bucket")
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %s", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Prefix != "" && !strings.HasSuffix(cfg.Prefix, "/") {
+		cfg.Prefix += "/"
+	}
+
+	return &S3Store{
+		cfg:          cfg,
+		endpoint:     endpoint,
+		client:       &http.Client{},
+		pollInterval: 5 * time.Second,
+	}, nil
+}
+
+// Location returns an s3:// URL for key.
+func (s *S3Store) Location(key string) string {
+	return "s3://" + s.cfg.Bucket + "/" + s.cfg.Prefix + key
+}
+
+// s3Escape URI-encodes s as S3 expects, leaving only unreserved characters
+// as they are. If path is true, "/" is left as it is too.
+func s3Escape(s string, path bool) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', path && b == '/':
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+// newRequest builds a request for the object at key, or for the bucket
+// itself if key is empty.
+func (s *S3Store) newRequest(method string, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	path := "/" + s.cfg.Bucket + "/"
+	if key != "" {
+		path += s.cfg.Prefix + key
+	}
+
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawPath = s3Escape(u.Path, true)
+	u.RawQuery = canonicalQuery(query)
+	return http.NewRequest(method, u.String(), body)
+}
+
+// canonicalQuery encodes query sorted by key, as SigV4 requires.
+func canonicalQuery(query url.Values) string {
+	var keys []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// signRequest adds AWS Signature Version 4 headers to req, for a body with
+// the given hex SHA256. Requests are sent unsigned if no access key is
+// configured.
+func signRequest(req *http.Request, cfg config.S3Config, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if cfg.AccessKeyID == "" {
+		return
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "content-type" ||
+			lk == "content-md5" || lk == "range" || lk == "if-none-match" {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	var names []string
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := amzDate[:8] + "/" + cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" +
+		hex.EncodeToString(hashedRequest[:])
+
+	key := hmacSHA256([]byte("AWS4"+cfg.SecretAccessKey), amzDate[:8])
+	key = hmacSHA256(key, cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// do signs and sends req, and returns its response if it succeeded. A 404
+// is returned as an error satisfying os.IsNotExist.
+func (s *S3Store) do(req *http.Request, key string, payloadHash string) (*http.Response, error) {
+	signRequest(req, s.cfg, payloadHash, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &os.PathError{Op: strings.ToLower(req.Method), Path: s.Location(key), Err: os.ErrNotExist}
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return resp, fmt.Errorf("S3 %s %s failed: %s %s", req.Method, s.Location(key), resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// Stat sends a HEAD request for key.
+func (s *S3Store) Stat(key string) (BlobInfo, error) {
+	req, err := s.newRequest(http.MethodHead, key, nil, nil)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	resp, err := s.do(req, key, emptySHA256)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	resp.Body.Close()
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return BlobInfo{Key: key, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+// Open sends a GET request for key, and returns the response body.
+func (s *S3Store) Open(key string) (io.ReadCloser, error) {
+	req, err := s.newRequest(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, key, emptySHA256)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Put spools src to a temporary file, to find its length and hash for
+// signing, and then uploads it to key in a single PUT request.
+func (s *S3Store) Put(key string, src io.Reader) error {
+	_, err := s.put(key, src, false)
+	return err
+}
+
+// PutIfAbsent is like Put, but makes the upload conditional on there being
+// no object at key already.
+func (s *S3Store) PutIfAbsent(key string, src io.Reader) (bool, error) {
+	return s.put(key, src, true)
+}
+
+// put uploads src to key. If ifAbsent is true, it returns false rather
+// than replacing an existing object.
+func (s *S3Store) put(key string, src io.Reader, ifAbsent bool) (bool, error) {
+	tmp, err := os.CreateTemp("", "peridot-s3-")
+	if err != nil {
+		return false, fmt.Errorf("couldn't create temporary file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), src)
+	if err != nil {
+		return false, fmt.Errorf("error copying file: %v", err)
+	}
+	_, err = tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := s.newRequest(http.MethodPut, key, nil, tmp)
+	if err != nil {
+		return false, err
+	}
+	req.ContentLength = size
+	if ifAbsent {
+		req.Header.Set("If-None-Match", "*")
+	}
+	resp, err := s.do(req, key, hex.EncodeToString(h.Sum(nil)))
+	if resp != nil && resp.StatusCode == http.StatusPreconditionFailed {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// Remove sends a DELETE request for key.
+func (s *S3Store) Remove(key string) error {
+	req, err := s.newRequest(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, key, emptySHA256)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listBucketResult is the response to a ListObjectsV2 request.
+type listBucketResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+}
+
+// List lists every object under the configured prefix, a page at a time.
+func (s *S3Store) List(fn func(BlobInfo) error) error {
+	return s.list("", func(info BlobInfo) error {
+		if isBookkeeping(info.Key) {
+			return nil
+		}
+		return fn(info)
+	})
+}
+
+// list calls fn for each object whose key starts with prefix, including
+// bookkeeping objects.
+func (s *S3Store) list(prefix string, fn func(BlobInfo) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.cfg.Prefix + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := s.newRequest(http.MethodGet, "", query, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := s.do(req, "", emptySHA256)
+		if err != nil {
+			return err
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("couldn't parse S3 listing: %v", err)
+		}
+
+		for _, obj := range result.Contents {
+			info := BlobInfo{
+				Key:     strings.TrimPrefix(obj.Key, s.cfg.Prefix),
+				Size:    obj.Size,
+				ModTime: obj.LastModified,
+			}
+			err = fn(info)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Lock uses lease objects in the bucket, so that it excludes workers on
+// other machines. Each side writes its own lease before checking for the
+// other's, so that at least one of them always sees the other.
+func (s *S3Store) Lock(exclusive bool) (func(), error) {
+	if exclusive {
+		return s.lockExclusive()
+	}
+	return s.lockShared()
+}
+
+func (s *S3Store) lockShared() (func(), error) {
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	key := fmt.Sprintf("%s%s-%d-%x", leaseSharedPrefix, hostname, os.Getpid(), id)
+
+	for {
+		err = s.Put(key, strings.NewReader(""))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't lock hash store: %v", err)
+		}
+
+		_, err = s.Stat(leaseExclusive)
+		if os.IsNotExist(err) {
+			return func() { s.Remove(key) }, nil
+		}
+		s.Remove(key)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't lock hash store: %v", err)
+		}
+		time.Sleep(s.pollInterval)
+	}
+}
+
+func (s *S3Store) lockExclusive() (func(), error) {
+	ok, err := s.put(leaseExclusive, strings.NewReader(""), true)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't lock hash store: %v", err)
+	}
+	if !ok {
+		return nil, ErrStoreBusy
+	}
+	unlock := func() { s.Remove(leaseExclusive) }
+
+	busy := false
+	err = s.list(leaseSharedPrefix, func(info BlobInfo) error {
+		busy = true
+		return nil
+	})
+	if err != nil || busy {
+		unlock()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't lock hash store: %v", err)
+		}
+		return nil, ErrStoreBusy
+	}
+	return unlock, nil
+}
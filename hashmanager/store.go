@@ -0,0 +1,97 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package hashmanager
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/swinslow/peridot/config"
+)
+
+const (
+	// BackendLocal keeps blobs in a directory on the local filesystem
+	BackendLocal = "local"
+
+	// BackendS3 keeps blobs in a bucket in an S3-compatible object store,
+	// so that several peridot workers can share them
+	BackendS3 = "s3"
+)
+
+// BlobStore is where the hash store keeps its blobs. Keys are
+// slash-separated paths relative to the root of the store. Keys with any
+// part starting with "." are peridot's own bookkeeping, and are never
+// listed as blobs.
+type BlobStore interface {
+	// Stat returns details of the blob at key, or an error satisfying
+	// os.IsNotExist if there is none.
+	Stat(key string) (BlobInfo, error)
+
+	// Open opens the blob at key for reading, or returns an error
+	// satisfying os.IsNotExist if there is none.
+	Open(key string) (io.ReadCloser, error)
+
+	// Put stores all of src at key, replacing anything already there.
+	// Readers never see a partially-written blob, and nothing is stored
+	// if reading from src fails.
+	Put(key string, src io.Reader) error
+
+	// PutIfAbsent is like Put, but leaves anything already at key alone
+	// and returns false. When several writers race to store the same key,
+	// exactly one of them returns true.
+	PutIfAbsent(key string, src io.Reader) (bool, error)
+
+	// Remove deletes the blob at key.
+	Remove(key string) error
+
+	// List calls fn for every key in the store other than bookkeeping,
+	// in no particular order.
+	List(fn func(BlobInfo) error) error
+
+	// Lock locks the store, and returns a function to unlock it. Any
+	// number of shared locks can be held at once, and Lock waits until
+	// no exclusive lock is held before taking one. An exclusive lock
+	// excludes all others, and Lock returns ErrStoreBusy straight away
+	// if it can't take one.
+	Lock(exclusive bool) (func(), error)
+
+	// Location returns a human-readable location for key, such as its
+	// path on disk.
+	Location(key string) string
+}
+
+// BlobInfo describes an entry in a BlobStore.
+type BlobInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// ErrStoreBusy is returned when trying to lock a BlobStore exclusively
+// while anything else holds a lock.
+var ErrStoreBusy = fmt.Errorf("hash store is in use by another operation; try again later")
+
+// NewBlobStore returns the BlobStore selected by cfg.HashesBackend.
+func NewBlobStore(cfg *config.Config) (BlobStore, error) {
+	switch cfg.HashesBackend {
+	case "", BackendLocal:
+		return NewLocalStore(cfg.HashesLocation)
+	case BackendS3:
+		return NewS3Store(cfg.HashesS3)
+	default:
+		return nil, fmt.Errorf("invalid hashes backend %s", cfg.HashesBackend)
+	}
+}
+
+// isBookkeeping returns true if any part of key starts with ".".
+func isBookkeeping(key string) bool {
+	for _, part := range strings.Split(key, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
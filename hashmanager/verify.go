@@ -0,0 +1,218 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package hashmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Keys in the hash store with any part starting with "." are peridot's own
+// bookkeeping, and are never treated as blobs.
+const (
+	quarantineDir   = ".quarantine"
+	verifyStateFile = ".verify-state.json"
+)
+
+// Blob describes a file found in the hash store. Its SHA1 and SHA256 hashes
+// come from its file name; only the first two characters of its MD5 hash
+// are known from its key. Compression comes from its file name's suffix.
+// Size is its stored, possibly compressed, size.
+type Blob struct {
+	Key         string
+	HashSHA1    string
+	HashSHA256  string
+	MD5Prefix   string
+	Compression string
+	Size        int64
+	ModTime     time.Time
+}
+
+const (
+	// IssueMissing indicates a hashfile in the database with no blob
+	IssueMissing = "missing"
+
+	// IssueCorrupt indicates a blob whose contents don't match its hashes
+	IssueCorrupt = "corrupt"
+
+	// IssueOrphaned indicates a blob with no hashfile in the database, or a
+	// file in the hash store that isn't named like a blob
+	IssueOrphaned = "orphaned"
+)
+
+// VerifyIssue describes a problem found when verifying the hash store. Kind
+// is one of the Issue* constants. Path is the blob's location, such as its
+// path on disk, for display.
+type VerifyIssue struct {
+	Kind        string `json:"kind"`
+	Key         string `json:"key"`
+	Path        string `json:"path"`
+	HashSHA1    string `json:"sha1"`
+	HashSHA256  string `json:"sha256"`
+	Detail      string `json:"detail"`
+	Quarantined bool   `json:"quarantined"`
+}
+
+// VerifyState records how far a verification run got, so that an
+// interrupted run can be resumed. All blobs with keys up to and including
+// LastKey, in sorted order, have been checked.
+type VerifyState struct {
+	LastKey string         `json:"lastKey"`
+	Issues  []*VerifyIssue `json:"issues"`
+}
+
+// isHex returns true if s is a lowercase hex string of length n.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseBlobKey takes a key in the hash store and returns the Blob it names,
+// or false if it isn't laid out as GetKeyForHash would.
+func parseBlobKey(key string) (Blob, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 {
+		return Blob{}, false
+	}
+	base, c := splitCompressionSuffix(parts[3])
+	name := strings.SplitN(base, ".", 2)
+	if len(name) != 2 || !isHex(name[0], 40) || !isHex(name[1], 64) || !isHex(parts[2], 2) {
+		return Blob{}, false
+	}
+	if parts[0] != name[0][:2] || parts[1] != name[1][:2] {
+		return Blob{}, false
+	}
+	return Blob{Key: key, HashSHA1: name[0], HashSHA256: name[1], MD5Prefix: parts[2], Compression: c}, true
+}
+
+// ListBlobs lists the hash store and returns all blobs found, sorted by
+// key, along with the keys of any other files that aren't named like
+// blobs.
+func (hm *HashManager) ListBlobs() ([]Blob, []string, error) {
+	var blobs []Blob
+	var invalid []string
+	err := hm.Store.List(func(info BlobInfo) error {
+		b, ok := parseBlobKey(info.Key)
+		if !ok {
+			invalid = append(invalid, info.Key)
+			return nil
+		}
+		b.Size = info.Size
+		b.ModTime = info.ModTime
+		blobs = append(blobs, b)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Key < blobs[j].Key })
+	sort.Strings(invalid)
+	return blobs, invalid, nil
+}
+
+// VerifyBlob re-hashes a blob's decompressed contents. It returns an empty
+// string if they match the hashes in its path, and hMD5 if that is
+// non-empty; or else a description of the mismatch.
+func (hm *HashManager) VerifyBlob(b Blob, hMD5 string) (string, error) {
+	f, err := hm.openCompressed(b.Key, b.Compression)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hashes, err := GetReaderHashes(f, -1)
+	if err != nil {
+		// a compressed blob that can't be decompressed is corrupt too
+		if b.Compression != CompressionNone {
+			return fmt.Sprintf("couldn't decompress: %v", err), nil
+		}
+		return "", err
+	}
+
+	switch {
+	case hashes.SHA1 != b.HashSHA1:
+		return fmt.Sprintf("SHA1 is %s", hashes.SHA1), nil
+	case hashes.SHA256 != b.HashSHA256:
+		return fmt.Sprintf("SHA256 is %s", hashes.SHA256), nil
+	case !strings.HasPrefix(hashes.MD5, b.MD5Prefix):
+		return fmt.Sprintf("MD5 is %s, but blob is in directory %s", hashes.MD5, b.MD5Prefix), nil
+	case hMD5 != "" && hashes.MD5 != hMD5:
+		return fmt.Sprintf("MD5 is %s, but database has %s", hashes.MD5, hMD5), nil
+	}
+	return "", nil
+}
+
+// QuarantineBlob moves the blob at key to the same key under the store's
+// quarantine directory, and returns its new location.
+func (hm *HashManager) QuarantineBlob(key string) (string, error) {
+	src, err := hm.Store.Open(key)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dstKey := quarantineDir + "/" + key
+	err = hm.Store.Put(dstKey, src)
+	if err != nil {
+		return "", fmt.Errorf("couldn't move %s to quarantine: %v", hm.Store.Location(key), err)
+	}
+	err = hm.Store.Remove(key)
+	if err != nil {
+		return "", fmt.Errorf("couldn't remove %s after quarantine: %v", hm.Store.Location(key), err)
+	}
+	return hm.Store.Location(dstKey), nil
+}
+
+// LoadVerifyState returns the VerifyState saved by an interrupted
+// verification run, or nil if there is none.
+func (hm *HashManager) LoadVerifyState() (*VerifyState, error) {
+	rc, err := hm.Store.Open(verifyStateFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	state := &VerifyState{}
+	err = json.NewDecoder(rc).Decode(state)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse saved verify state: %v", err)
+	}
+	return state, nil
+}
+
+// SaveVerifyState saves state so that a later run can resume from it.
+func (hm *HashManager) SaveVerifyState(state *VerifyState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	// Put never leaves a partial state file, even after a crash
+	return hm.Store.Put(verifyStateFile, bytes.NewReader(b))
+}
+
+// ClearVerifyState removes any saved VerifyState.
+func (hm *HashManager) ClearVerifyState() error {
+	err := hm.Store.Remove(verifyStateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
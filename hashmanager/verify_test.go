@@ -0,0 +1,130 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package hashmanager
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/swinslow/peridot/database"
+)
+
+// storeBlob writes contents into hm's store at the path for its hashes,
+// and returns those hashes.
+func storeBlob(t *testing.T, hm *HashManager, contents string) database.Hashes {
+	hashes, err := GetReaderHashes(strings.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		t.Fatalf("couldn't hash contents: %v", err)
+	}
+	_, err = hm.CopyReaderToHash(strings.NewReader(contents), hashes.SHA1, hashes.SHA256, hashes.MD5)
+	if err != nil {
+		t.Fatalf("couldn't store blob: %v", err)
+	}
+	return hashes
+}
+
+func TestParseBlobKey(t *testing.T) {
+	sha1 := strings.Repeat("a", 40)
+	sha256 := strings.Repeat("b", 64)
+	b, ok := parseBlobKey("aa/bb/cc/" + sha1 + "." + sha256)
+	if !ok || b.HashSHA1 != sha1 || b.HashSHA256 != sha256 || b.MD5Prefix != "cc" {
+		t.Errorf("expected valid blob, got %+v (%v)", b, ok)
+	}
+
+	for _, key := range []string{
+		"ab/bb/cc/" + sha1 + "." + sha256,
+		"aa/bb/" + sha1 + "." + sha256,
+		"aa/bb/cc/" + sha1,
+		"aa/bb/cc/" + strings.ToUpper(sha1) + "." + sha256,
+	} {
+		if _, ok := parseBlobKey(key); ok {
+			t.Errorf("expected %s to be invalid", key)
+		}
+	}
+}
+
+func TestListAndVerifyBlobs(t *testing.T) {
+	hm := &HashManager{Store: &LocalStore{Root: t.TempDir()}}
+	good := storeBlob(t, hm, "hello")
+	bad := storeBlob(t, hm, "world")
+
+	// corrupt one blob, and add a stray file and a bookkeeping file
+	badPath := hm.GetPathToHash(bad.SHA1, bad.SHA256, bad.MD5)
+	err := os.Chmod(badPath, 0600)
+	if err == nil {
+		err = os.WriteFile(badPath, []byte("w0rld"), 0600)
+	}
+	if err != nil {
+		t.Fatalf("couldn't corrupt blob: %v", err)
+	}
+	err = os.WriteFile(hm.Store.Location("stray.txt"), []byte("x"), 0600)
+	if err != nil {
+		t.Fatalf("couldn't write stray file: %v", err)
+	}
+	err = hm.SaveVerifyState(&VerifyState{LastKey: "x"})
+	if err != nil {
+		t.Fatalf("couldn't save verify state: %v", err)
+	}
+
+	blobs, invalid, err := hm.ListBlobs()
+	if err != nil {
+		t.Fatalf("got error when calling ListBlobs: %v", err)
+	}
+	if len(blobs) != 2 {
+		t.Fatalf("expected 2 blobs, got %d", len(blobs))
+	}
+	if len(invalid) != 1 || invalid[0] != "stray.txt" {
+		t.Errorf("expected only stray.txt to be invalid, got %v", invalid)
+	}
+
+	for _, b := range blobs {
+		detail, err := hm.VerifyBlob(b, "")
+		if err != nil {
+			t.Fatalf("got error when calling VerifyBlob: %v", err)
+		}
+		switch b.HashSHA1 {
+		case good.SHA1:
+			if detail != "" {
+				t.Errorf("expected good blob to verify, got %s", detail)
+			}
+		case bad.SHA1:
+			if detail == "" {
+				t.Errorf("expected corrupt blob to fail verification")
+			}
+		}
+	}
+
+	// a wrong MD5 in the database is also a mismatch
+	for _, b := range blobs {
+		if b.HashSHA1 == good.SHA1 {
+			detail, _ := hm.VerifyBlob(b, strings.Repeat("0", 32))
+			if detail == "" {
+				t.Errorf("expected MD5 mismatch with database to fail verification")
+			}
+		}
+	}
+}
+
+func TestQuarantineBlob(t *testing.T) {
+	hm := &HashManager{Store: &LocalStore{Root: t.TempDir()}}
+	hashes := storeBlob(t, hm, "hello")
+	path := hm.GetPathToHash(hashes.SHA1, hashes.SHA256, hashes.MD5)
+
+	newPath, err := hm.QuarantineBlob(GetKeyForHash(hashes.SHA1, hashes.SHA256, hashes.MD5))
+	if err != nil {
+		t.Fatalf("got error when calling QuarantineBlob: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected blob to be moved out of store")
+	}
+	if _, err := os.Stat(newPath); err != nil {
+		t.Errorf("expected blob at %s, got %v", newPath, err)
+	}
+
+	blobs, _, err := hm.ListBlobs()
+	if err != nil || len(blobs) != 0 {
+		t.Errorf("expected quarantined blob to not be listed, got %v (%v)", blobs, err)
+	}
+}
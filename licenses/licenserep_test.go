@@ -26,3 +26,12 @@ func TestCanGetNodesForExpression(t *testing.T) {
 
 	//printNodeExpression(t, nodeTree)
 }
+
+func TestGetNodesForIncompleteExpressionFails(t *testing.T) {
+	for _, expr := range []string{"MIT AND", "(MIT OR Apache-2.0", "MIT OR (Apache-2.0 AND"} {
+		_, err := GetNodesForExpression(expr)
+		if err == nil {
+			t.Errorf("expected error for incomplete expression %q, got nil", expr)
+		}
+	}
+}
@@ -250,6 +250,9 @@ func parseTokens(exprTokens []exprToken) (*licenseNode, error) {
 		// fmt.Printf("\n")
 	}
 
-	// FIXME check whether tree is full
+	// an expression that ends with an operator or an open paren is incomplete
+	if !nodeTreeIsFull(curParentNode) {
+		return curParentNode, fmt.Errorf("invalid parse tree: incomplete expression %v; full tree: %v", exprTokens, curParentNode)
+	}
 	return curParentNode, nil
 }
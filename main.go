@@ -4,8 +4,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 
 	"github.com/swinslow/peridot/cli"
 	"github.com/swinslow/peridot/config"
@@ -16,12 +18,27 @@ import (
 func main() {
 	var err error
 
+	// cancel long-running operations cleanly on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ctx, finishProgress, err := cli.SetupProgress(ctx)
+	defer finishProgress()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
 	co := &coordinator.Coordinator{}
 
 	cfg := &config.Config{}
 	cfg.SetDBConnectString("steve", "", "peridot", false)
 	cfg.ReposLocation = "/Users/steve/programming/scanning/peridot-repos"
 	cfg.HashesLocation = "/Users/steve/programming/scanning/peridot-hashes"
+	cfg.HashesCompression = "none"
+	cfg.HashesBackend = "local"
+	cfg.HashesLink = "none"
+	cfg.ArchivesLocation = "/Users/steve/programming/scanning/peridot-archives"
 	cfg.SPDXLLJSONLocation = "/Users/steve/programming/GitHub/license-list-data/json"
 
 	db := database.InitDB()
@@ -45,7 +62,15 @@ func main() {
 	command := os.Args[1]
 	switch command {
 	case "repo":
-		cli.CmdRepo(co, db, cfg)
+		cli.CmdRepo(ctx, co, db, cfg)
+	case "hashes":
+		cli.CmdHashes(ctx, co, db, cfg)
+	case "cat":
+		cli.CmdCat(ctx, co, db, cfg)
+	case "package":
+		cli.CmdPackage(ctx, co, db, cfg)
+	case "spdx":
+		cli.CmdSPDX(ctx, co, db, cfg)
 	case "reset":
 		cli.CmdReset(co, db, cfg)
 	default:
@@ -55,7 +80,11 @@ func main() {
 }
 
 func printCommands() {
+	fmt.Printf("  cat\n")
+	fmt.Printf("  hashes\n")
+	fmt.Printf("  package\n")
 	fmt.Printf("  repo\n")
 	fmt.Printf("  reset\n")
+	fmt.Printf("  spdx\n")
 	fmt.Printf("\n")
 }
@@ -0,0 +1,107 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package pathrules
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/swinslow/peridot/database"
+)
+
+// ValidatePattern returns an error if pattern is not a usable
+// gitignore-style glob. Negated patterns ("!...") are not supported;
+// instead, a later rule with a different classification overrides an
+// earlier one.
+func ValidatePattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("empty pattern")
+	}
+	if strings.HasPrefix(pattern, "!") {
+		return fmt.Errorf("negated patterns are not supported: %s", pattern)
+	}
+	if strings.HasPrefix(pattern, "#") {
+		return fmt.Errorf("pattern looks like a comment: %s", pattern)
+	}
+
+	for _, seg := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		if seg == "**" {
+			continue
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return fmt.Errorf("invalid pattern %s: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Match returns true if the slash-separated file path matches pattern,
+// following gitignore's rules:
+//
+//   - a pattern with no slash, other than a trailing one, matches at any
+//     depth; otherwise it is relative to the root
+//   - a pattern with a trailing slash only matches directories
+//   - "*" and "?" don't match "/"; "**" matches any number of directories
+//   - a pattern that matches a directory matches everything beneath it
+func Match(pattern string, filePath string) bool {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	// unanchored patterns can start in any directory
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	patSegs := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	pathSegs := strings.Split(filePath, "/")
+
+	// check the file itself, and each of the directories containing it
+	for i := 1; i <= len(pathSegs); i++ {
+		isDir := i < len(pathSegs)
+		if dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(patSegs, pathSegs[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(patSegs []string, pathSegs []string) bool {
+	for len(patSegs) > 0 {
+		if patSegs[0] == "**" {
+			// try matching the rest at each remaining position
+			for i := 0; i <= len(pathSegs); i++ {
+				if matchSegments(patSegs[1:], pathSegs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(pathSegs) == 0 {
+			return false
+		}
+		ok, err := path.Match(patSegs[0], pathSegs[0])
+		if err != nil || !ok {
+			return false
+		}
+		patSegs = patSegs[1:]
+		pathSegs = pathSegs[1:]
+	}
+	return len(pathSegs) == 0
+}
+
+// Classify returns the classification for the slash-separated file path
+// from the last of rules that matches it, or an empty string if none do.
+func Classify(rules []*database.PathRule, filePath string) string {
+	classification := ""
+	for _, rule := range rules {
+		if Match(rule.Pattern, filePath) {
+			classification = rule.Classification
+		}
+	}
+	return classification
+}
@@ -0,0 +1,80 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package pathrules
+
+import (
+	"testing"
+
+	"github.com/swinslow/peridot/database"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		// unanchored patterns match at any depth
+		{"*.png", "logo.png", true},
+		{"*.png", "docs/img/logo.png", true},
+		{"*.png", "logo.pngx", false},
+		{"testdata", "pkg/testdata/input.json", true},
+		// anchored patterns only match from the root
+		{"/build", "build/out.o", true},
+		{"/build", "src/build/out.o", false},
+		{"docs/*.md", "docs/intro.md", true},
+		{"docs/*.md", "docs/api/intro.md", false},
+		{"docs/*.md", "src/docs/intro.md", false},
+		// trailing slash only matches directories
+		{"vendor/", "vendor/github.com/pkg/errors/errors.go", true},
+		{"vendor/", "vendor", false},
+		{"vendor/", "src/vendor/lib.c", true},
+		// double-star patterns
+		{"**/fixtures/**", "a/b/fixtures/c/d.txt", true},
+		{"src/**/*_test.go", "src/main_test.go", true},
+		{"src/**/*_test.go", "src/pkg/sub/x_test.go", true},
+		{"src/**/*_test.go", "lib/x_test.go", false},
+	}
+	for _, tt := range tests {
+		got := Match(tt.pattern, tt.path)
+		if got != tt.want {
+			t.Errorf("expected %v for Match(%s, %s), got %v", tt.want, tt.pattern, tt.path, got)
+		}
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	for _, p := range []string{"*.png", "/build/", "src/**/*_test.go", "[ab]*.c"} {
+		if err := ValidatePattern(p); err != nil {
+			t.Errorf("expected %s to be valid, got %v", p, err)
+		}
+	}
+	for _, p := range []string{"", "  ", "!keep.txt", "# comment", "[unclosed"} {
+		if err := ValidatePattern(p); err == nil {
+			t.Errorf("expected %q to be invalid, got nil", p)
+		}
+	}
+}
+
+func TestClassifyUsesLastMatchingRule(t *testing.T) {
+	rules := []*database.PathRule{
+		{ID: 1, Pattern: "vendor/", Classification: database.ClassVendored},
+		{ID: 2, Pattern: "*.md", Classification: database.ClassDocumentation},
+		{ID: 3, Pattern: "vendor/**/testdata/", Classification: database.ClassExcluded},
+	}
+	tests := map[string]string{
+		"main.go":                           "",
+		"README.md":                         database.ClassDocumentation,
+		"vendor/lib/lib.go":                 database.ClassVendored,
+		"vendor/lib/README.md":              database.ClassDocumentation,
+		"vendor/lib/testdata/big.bin":       database.ClassExcluded,
+		"vendor/lib/testdata/sub/README.md": database.ClassExcluded,
+	}
+	for path, want := range tests {
+		got := Classify(rules, path)
+		if got != want {
+			t.Errorf("expected %q for %s, got %q", want, path, got)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package progress
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+)
+
+const (
+	// PhaseClone is the phase for cloning a repo
+	PhaseClone = "clone"
+
+	// PhaseFetch is the phase for pulling updates to a repo
+	PhaseFetch = "fetch"
+
+	// PhaseUnpack is the phase for unpacking an archive
+	PhaseUnpack = "unpack"
+
+	// PhaseHash is the phase for calculating file hashes
+	PhaseHash = "hash"
+
+	// PhaseInsert is the phase for adding dirs and files to the database
+	PhaseInsert = "insert"
+
+	// PhaseCopy is the phase for copying files into the hash store
+	PhaseCopy = "copy"
+
+	// PhaseBackfill is the phase for backfilling historical commits
+	PhaseBackfill = "backfill"
+
+	// PhaseVerify is the phase for verifying blobs in the hash store
+	PhaseVerify = "verify"
+
+	// PhaseGC is the phase for removing unreferenced blobs
+	PhaseGC = "gc"
+
+	// PhaseCompress is the phase for recompressing blobs in the hash store
+	PhaseCompress = "compress"
+)
+
+// Update describes progress within a phase of a long-running operation.
+// Total is 0 if the number of items isn't known in advance. Bytes is the
+// number of bytes processed so far in this phase. Message is free text,
+// such as progress lines from a git remote, and is otherwise empty.
+type Update struct {
+	Phase   string `json:"phase"`
+	Done    int    `json:"done"`
+	Total   int    `json:"total"`
+	Bytes   int64  `json:"bytes"`
+	Message string `json:"message,omitempty"`
+}
+
+// Reporter receives progress Updates.
+type Reporter interface {
+	Report(u Update)
+}
+
+type nopReporter struct{}
+
+func (nopReporter) Report(u Update) {}
+
+type reporterKey struct{}
+
+// NewContext returns a copy of ctx that carries r, so that it can be passed
+// down through the managers along with cancellation.
+func NewContext(ctx context.Context, r Reporter) context.Context {
+	return context.WithValue(ctx, reporterKey{}, r)
+}
+
+// FromContext returns the Reporter carried by ctx, or a Reporter that
+// discards all Updates if there is none.
+func FromContext(ctx context.Context) Reporter {
+	if r, ok := ctx.Value(reporterKey{}).(Reporter); ok && r != nil {
+		return r
+	}
+	return nopReporter{}
+}
+
+// Tracker keeps the running totals for one phase and reports each change.
+type Tracker struct {
+	r Reporter
+	u Update
+}
+
+// Start reports the beginning of a phase with the given number of items
+// (or 0 if unknown) to ctx's Reporter, and returns a Tracker for it.
+func Start(ctx context.Context, phase string, total int) *Tracker {
+	t := &Tracker{r: FromContext(ctx), u: Update{Phase: phase, Total: total}}
+	t.r.Report(t.u)
+	return t
+}
+
+// Add records that n more items and size more bytes have been processed.
+func (t *Tracker) Add(n int, size int64) {
+	t.u.Done += n
+	t.u.Bytes += size
+	t.r.Report(t.u)
+}
+
+// Message reports a line of free text for this phase.
+func (t *Tracker) Message(msg string) {
+	u := t.u
+	u.Message = msg
+	t.r.Report(u)
+}
+
+// CountingReader wraps R and counts the bytes read from it in N.
+type CountingReader struct {
+	R io.Reader
+	N int64
+}
+
+func (cr *CountingReader) Read(p []byte) (int, error) {
+	n, err := cr.R.Read(p)
+	cr.N += int64(n)
+	return n, err
+}
+
+// messageWriter reports each line written to it as a Message.
+type messageWriter struct {
+	t   *Tracker
+	buf bytes.Buffer
+}
+
+// NewMessageWriter starts a phase with an unknown number of items, and
+// returns an io.Writer that reports each line written to it as a Message
+// in that phase. Lines may end in either "\n" or "\r", as git remotes
+// use "\r" to redraw their own progress counters.
+func NewMessageWriter(ctx context.Context, phase string) io.Writer {
+	return &messageWriter{t: Start(ctx, phase, 0)}
+}
+
+func (mw *messageWriter) Write(p []byte) (int, error) {
+	mw.buf.Write(p)
+	for {
+		b := mw.buf.Bytes()
+		i := bytes.IndexAny(b, "\r\n")
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSpace(string(b[:i]))
+		mw.buf.Next(i + 1)
+		if line != "" {
+			mw.t.Message(line)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,143 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package progress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingReporter struct {
+	updates []Update
+}
+
+func (rr *recordingReporter) Report(u Update) {
+	rr.updates = append(rr.updates, u)
+}
+
+func TestFromContextWithoutReporterDiscards(t *testing.T) {
+	r := FromContext(context.Background())
+	if r == nil {
+		t.Fatalf("expected non-nil Reporter, got nil")
+	}
+	// must not panic
+	r.Report(Update{Phase: PhaseHash})
+}
+
+func TestTrackerReportsRunningTotals(t *testing.T) {
+	rr := &recordingReporter{}
+	ctx := NewContext(context.Background(), rr)
+
+	tr := Start(ctx, PhaseHash, 2)
+	tr.Add(1, 100)
+	tr.Add(1, 50)
+
+	want := []Update{
+		{Phase: PhaseHash, Total: 2},
+		{Phase: PhaseHash, Done: 1, Total: 2, Bytes: 100},
+		{Phase: PhaseHash, Done: 2, Total: 2, Bytes: 150},
+	}
+	if fmt.Sprint(rr.updates) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, rr.updates)
+	}
+}
+
+func TestMessageWriterSplitsLines(t *testing.T) {
+	rr := &recordingReporter{}
+	ctx := NewContext(context.Background(), rr)
+
+	w := NewMessageWriter(ctx, PhaseClone)
+	fmt.Fprintf(w, "Counting objects: 1\rCounting objects: 2\r")
+	fmt.Fprintf(w, "Total 2 (delta 0)")
+	fmt.Fprintf(w, "\n")
+
+	var msgs []string
+	for _, u := range rr.updates {
+		if u.Message != "" {
+			msgs = append(msgs, u.Message)
+		}
+	}
+	want := []string{"Counting objects: 1", "Counting objects: 2", "Total 2 (delta 0)"}
+	if strings.Join(msgs, "|") != strings.Join(want, "|") {
+		t.Errorf("expected %v, got %v", want, msgs)
+	}
+}
+
+func TestFormatBar(t *testing.T) {
+	got := FormatBar(Update{Phase: PhaseCopy, Done: 5, Total: 10, Bytes: 2048})
+	want := "copy     [===============               ] 5/10 (2.0 KiB)"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	got = FormatBar(Update{Phase: PhaseUnpack, Done: 7})
+	want = "unpack   7"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:       "0 B",
+		1023:    "1023 B",
+		1536:    "1.5 KiB",
+		5 << 20: "5.0 MiB",
+		3 << 30: "3.0 GiB",
+	}
+	for n, want := range tests {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("expected %s for %d, got %s", want, n, got)
+		}
+	}
+}
+
+func TestThrottleSkipsIntermediateUpdates(t *testing.T) {
+	th := &throttle{}
+	now := time.Now()
+
+	if !th.allow(Update{Phase: PhaseHash, Total: 3}, now) {
+		t.Errorf("expected first update of phase to be allowed")
+	}
+	if th.allow(Update{Phase: PhaseHash, Done: 1, Total: 3}, now) {
+		t.Errorf("expected update within redraw interval to be skipped")
+	}
+	if u, ok := th.flush(); !ok || u.Done != 1 {
+		t.Errorf("expected skipped update to be pending, got %v, %v", u, ok)
+	}
+	if !th.allow(Update{Phase: PhaseHash, Done: 3, Total: 3}, now) {
+		t.Errorf("expected final update of phase to be allowed")
+	}
+	// the same phase starting again is a new phase
+	if !th.allow(Update{Phase: PhaseHash, Total: 3}, now) {
+		t.Errorf("expected restarted phase to be allowed")
+	}
+}
+
+func TestJSONReporterWritesOneObjectPerLine(t *testing.T) {
+	buf := &bytes.Buffer{}
+	jr := NewJSONReporter(buf)
+	ctx := NewContext(context.Background(), jr)
+
+	tr := Start(ctx, PhaseCopy, 1)
+	tr.Add(1, 10)
+	jr.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+	var u Update
+	if err := json.Unmarshal([]byte(lines[1]), &u); err != nil {
+		t.Fatalf("couldn't parse JSON line %q: %v", lines[1], err)
+	}
+	if u != (Update{Phase: PhaseCopy, Done: 1, Total: 1, Bytes: 10}) {
+		t.Errorf("unexpected final update %+v", u)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redrawInterval limits how often counters are rendered, since a phase can
+// report an Update for every one of many thousands of files.
+const redrawInterval = 100 * time.Millisecond
+
+// throttle decides which Updates are rendered. Messages, the first and last
+// Updates of each phase, and at most one Update per redrawInterval get
+// through; a skipped Update is kept as pending, so that it can be flushed
+// when its phase ends. A phase with a known total ends when it is complete,
+// so that the same phase can start again (e.g., hashing for each of several
+// backfilled commits).
+type throttle struct {
+	phase      string
+	last       time.Time
+	pending    Update
+	hasPending bool
+}
+
+func (th *throttle) allow(u Update, now time.Time) bool {
+	if u.Message != "" {
+		return true
+	}
+	complete := isComplete(u)
+	if u.Phase == th.phase && !complete && now.Sub(th.last) < redrawInterval {
+		th.pending = u
+		th.hasPending = true
+		return false
+	}
+	th.phase = u.Phase
+	if complete {
+		th.phase = ""
+	}
+	th.last = now
+	th.hasPending = false
+	return true
+}
+
+func isComplete(u Update) bool {
+	return u.Total > 0 && u.Done >= u.Total
+}
+
+// flush returns the pending Update, if any, and clears it.
+func (th *throttle) flush() (Update, bool) {
+	u, ok := th.pending, th.hasPending
+	th.hasPending = false
+	return u, ok
+}
+
+// BarReporter renders Updates as a progress bar for each phase, redrawn in
+// place on a terminal.
+type BarReporter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	th    throttle
+	drawn bool
+}
+
+// NewBarReporter returns a BarReporter that writes to w.
+func NewBarReporter(w io.Writer) *BarReporter {
+	return &BarReporter{w: w}
+}
+
+// Report renders u.
+func (br *BarReporter) Report(u Update) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	if u.Phase != br.th.phase {
+		br.endPhase()
+	}
+	if !br.th.allow(u, time.Now()) {
+		return
+	}
+
+	if u.Message != "" {
+		// print messages on their own line, then redraw the bar below
+		fmt.Fprintf(br.w, "\r\033[K%s: %s\n", u.Phase, u.Message)
+		br.drawn = false
+		return
+	}
+	fmt.Fprintf(br.w, "\r\033[K%s", FormatBar(u))
+	br.drawn = true
+	if isComplete(u) {
+		fmt.Fprintf(br.w, "\n")
+		br.drawn = false
+	}
+}
+
+// Close draws the final state of the current phase and ends its line.
+func (br *BarReporter) Close() error {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.endPhase()
+	return nil
+}
+
+func (br *BarReporter) endPhase() {
+	if u, ok := br.th.flush(); ok {
+		fmt.Fprintf(br.w, "\r\033[K%s", FormatBar(u))
+		br.drawn = true
+	}
+	if br.drawn {
+		fmt.Fprintf(br.w, "\n")
+		br.drawn = false
+	}
+}
+
+// FormatBar returns a single line showing the progress in u, with a bar if
+// its total is known.
+func FormatBar(u Update) string {
+	var s string
+	if u.Total > 0 {
+		const width = 30
+		filled := width * u.Done / u.Total
+		if filled > width {
+			filled = width
+		}
+		bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+		s = fmt.Sprintf("%-8s [%s] %d/%d", u.Phase, bar, u.Done, u.Total)
+	} else {
+		s = fmt.Sprintf("%-8s %d", u.Phase, u.Done)
+	}
+	if u.Bytes > 0 {
+		s += " (" + FormatBytes(u.Bytes) + ")"
+	}
+	return s
+}
+
+// FormatBytes returns n as a human-readable size, such as "1.5 MiB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// JSONReporter writes Updates as a stream of JSON objects, one per line,
+// for consumption by other programs.
+type JSONReporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	th  throttle
+}
+
+// NewJSONReporter returns a JSONReporter that writes to w.
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{enc: json.NewEncoder(w)}
+}
+
+// Report writes u.
+func (jr *JSONReporter) Report(u Update) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+
+	if u.Phase != jr.th.phase {
+		if pending, ok := jr.th.flush(); ok {
+			jr.enc.Encode(pending)
+		}
+	}
+	if jr.th.allow(u, time.Now()) {
+		jr.enc.Encode(u)
+	}
+}
+
+// Close writes the final Update of the current phase, if it was skipped.
+func (jr *JSONReporter) Close() error {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	if pending, ok := jr.th.flush(); ok {
+		return jr.enc.Encode(pending)
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package repomanager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	gitObject "gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/swinslow/peridot/database"
+	"github.com/swinslow/peridot/filetypes"
+	"github.com/swinslow/peridot/hashmanager"
+	"github.com/swinslow/peridot/progress"
+)
+
+// GetTagCommits takes a Repo and returns the metadata for each commit that
+// is pointed to by one of its tags, oldest first. Tags that do not point to
+// a commit are skipped. If more than one tag points to the same commit, only
+// the first tag name (in sorted order) is used.
+func (rm *RepoManager) GetTagCommits(repo *database.Repo) ([]*database.CommitInfo, error) {
+	r, err := git.PlainOpen(rm.GetPathToRepo(repo))
+	if err != nil {
+		return nil, err
+	}
+
+	tagRefs, err := r.Tags()
+	if err != nil {
+		return nil, err
+	}
+	defer tagRefs.Close()
+
+	var refs []*plumbing.Reference
+	err = tagRefs.ForEach(func(ref *plumbing.Reference) error {
+		refs = append(refs, ref)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Name().String() < refs[j].Name().String()
+	})
+
+	seen := make(map[plumbing.Hash]struct{})
+	var cis []*database.CommitInfo
+	for _, ref := range refs {
+		commit, err := getTagRefCommit(r, ref)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get commit for tag %s: %v", ref.Name(), err)
+		}
+		if commit == nil {
+			continue
+		}
+		if _, ok := seen[commit.Hash]; ok {
+			continue
+		}
+		seen[commit.Hash] = struct{}{}
+		cis = append(cis, getCommitInfo(commit, ref.Name().String()))
+	}
+
+	sortCommitInfosByTime(cis)
+	return cis, nil
+}
+
+// getTagRefCommit returns the commit pointed to by a tag reference, for
+// both annotated and lightweight tags. It returns nil, nil if the tag points
+// to some other kind of object.
+func getTagRefCommit(r *git.Repository, ref *plumbing.Reference) (*gitObject.Commit, error) {
+	// annotated tags point to a tag object
+	tag, err := r.TagObject(ref.Hash())
+	switch err {
+	case nil:
+		if tag.TargetType != plumbing.CommitObject {
+			return nil, nil
+		}
+		return tag.Commit()
+	case plumbing.ErrObjectNotFound:
+		// lightweight tags point directly to a commit
+		commit, err := r.CommitObject(ref.Hash())
+		if err == plumbing.ErrObjectNotFound {
+			return nil, nil
+		}
+		return commit, err
+	default:
+		return nil, err
+	}
+}
+
+// GetCommitRange takes a Repo and two revisions, and returns the metadata for
+// each commit that is reachable from to but not from from, oldest first. This
+// matches the meaning of "from..to" in git. If from is empty, all commits
+// reachable from to are returned.
+func (rm *RepoManager) GetCommitRange(repo *database.Repo, from string, to string) ([]*database.CommitInfo, error) {
+	r, err := git.PlainOpen(rm.GetPathToRepo(repo))
+	if err != nil {
+		return nil, err
+	}
+
+	toHash, err := r.ResolveRevision(plumbing.Revision(to))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't resolve revision %s: %v", to, err)
+	}
+
+	// collect everything reachable from "from", so we can exclude it
+	excluded := make(map[plumbing.Hash]struct{})
+	if from != "" {
+		fromHash, err := r.ResolveRevision(plumbing.Revision(from))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't resolve revision %s: %v", from, err)
+		}
+		fromIter, err := r.Log(&git.LogOptions{From: *fromHash})
+		if err != nil {
+			return nil, err
+		}
+		err = fromIter.ForEach(func(c *gitObject.Commit) error {
+			excluded[c.Hash] = struct{}{}
+			return nil
+		})
+		fromIter.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	toIter, err := r.Log(&git.LogOptions{From: *toHash})
+	if err != nil {
+		return nil, err
+	}
+	defer toIter.Close()
+
+	var cis []*database.CommitInfo
+	err = toIter.ForEach(func(c *gitObject.Commit) error {
+		if _, ok := excluded[c.Hash]; !ok {
+			cis = append(cis, getCommitInfo(c, ""))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// log is newest first, so reverse it
+	for i, j := 0, len(cis)-1; i < j; i, j = i+1, j-1 {
+		cis[i], cis[j] = cis[j], cis[i]
+	}
+	return cis, nil
+}
+
+func sortCommitInfosByTime(cis []*database.CommitInfo) {
+	sort.SliceStable(cis, func(i, j int) bool {
+		return cis[i].CommitTime.Before(cis[j].CommitTime)
+	})
+}
+
+// getCommitTree opens a Repo and returns the tree for the given commit.
+func (rm *RepoManager) getCommitTree(repo *database.Repo, commitHash string) (*gitObject.Tree, error) {
+	r, err := git.PlainOpen(rm.GetPathToRepo(repo))
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := r.CommitObject(plumbing.NewHash(commitHash))
+	if err != nil {
+		return nil, err
+	}
+
+	return commit.Tree()
+}
+
+// GetAllFilepathsForCommit takes a Repo and a commit hash, and returns a
+// string slice containing the paths for all files in that commit's tree.
+// Unlike GetAllFilepaths, it does not depend on what is checked out on disk.
+func (rm *RepoManager) GetAllFilepathsForCommit(repo *database.Repo, commitHash string) ([]string, error) {
+	tree, err := rm.getCommitTree(repo, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var filePaths []string
+	err = tree.Files().ForEach(func(f *gitObject.File) error {
+		filePaths = append(filePaths, f.Name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return filePaths, nil
+}
+
+// GetFileHashesForCommit takes a Repo and a commit hash, and returns a map of
+// strings from path (for all files in that commit's tree) to that file's
+// Hashes. The contents are read from the git object store, not from the
+// worktree.
+func (rm *RepoManager) GetFileHashesForCommit(ctx context.Context, repo *database.Repo, commitHash string) (map[string]database.Hashes, error) {
+	tree, err := rm.getCommitTree(repo, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	pathsToHashes := make(map[string]database.Hashes)
+	t := progress.Start(ctx, progress.PhaseHash, 0)
+	err = tree.Files().ForEach(func(f *gitObject.File) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rdr, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		hashes, err := hashmanager.GetReaderHashes(rdr, f.Size)
+		rdr.Close()
+		if err != nil {
+			return err
+		}
+		if hashes.GitOID != f.Hash.String() {
+			return fmt.Errorf("contents of %s don't match git object %s", f.Name, f.Hash)
+		}
+		pathsToHashes[f.Name] = hashes
+		t.Add(1, f.Size)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pathsToHashes, nil
+}
+
+// GetFileDetailsForCommit takes a Repo and a commit hash, and returns a map
+// from path (for all files in that commit's tree) to that file's
+// FileDetails, using the file modes recorded in the tree.
+func (rm *RepoManager) GetFileDetailsForCommit(repo *database.Repo, commitHash string) (map[string]database.FileDetails, error) {
+	tree, err := rm.getCommitTree(repo, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	pathsToDetails := make(map[string]database.FileDetails)
+	err = tree.Files().ForEach(func(f *gitObject.File) error {
+		if f.Mode == filemode.Symlink {
+			// a symlink's blob holds its target
+			target, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			pathsToDetails[f.Name] = filetypes.NewFileDetails(f.Name, f.Size, false, target, nil)
+			return nil
+		}
+
+		rdr, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		head, err := filetypes.ReadHead(rdr)
+		rdr.Close()
+		if err != nil {
+			return err
+		}
+		pathsToDetails[f.Name] = filetypes.NewFileDetails(f.Name, f.Size,
+			f.Mode == filemode.Executable, "", head)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pathsToDetails, nil
+}
+
+// GetCommitFileOpener takes a Repo and a commit hash, and returns a function
+// that opens files by path from that commit's tree.
+func (rm *RepoManager) GetCommitFileOpener(repo *database.Repo, commitHash string) (func(path string) (io.ReadCloser, error), error) {
+	tree, err := rm.getCommitTree(repo, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(path string) (io.ReadCloser, error) {
+		f, err := tree.File(path)
+		if err != nil {
+			return nil, err
+		}
+		return f.Reader()
+	}, nil
+}
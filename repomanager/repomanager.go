@@ -4,13 +4,11 @@
 package repomanager
 
 import (
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
+	"context"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"golang.org/x/sys/unix"
@@ -19,6 +17,9 @@ import (
 
 	"github.com/swinslow/peridot/config"
 	"github.com/swinslow/peridot/database"
+	"github.com/swinslow/peridot/filetypes"
+	"github.com/swinslow/peridot/hashmanager"
+	"github.com/swinslow/peridot/progress"
 )
 
 // RepoManager holds the data objects needed to manage copies of files
@@ -90,48 +91,72 @@ func (rm *RepoManager) GetURLToRepo(repo *database.Repo) string {
 // CloneRepo takes a Repo that is already in the database, and makes an
 // initial clone of its contents onto disk, creating and adding a first
 // RepoRetrieval to the database.
-func (rm *RepoManager) CloneRepo(repo *database.Repo) error {
+func (rm *RepoManager) CloneRepo(ctx context.Context, repo *database.Repo) error {
 	dstPath := rm.GetPathToRepo(repo)
 	srcURL := rm.GetURLToRepo(repo)
-	r, err := git.PlainClone(dstPath, false, &git.CloneOptions{
+	r, err := git.PlainCloneContext(ctx, dstPath, false, &git.CloneOptions{
 		URL:               srcURL,
 		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
-		Progress:          os.Stdout,
+		Progress:          progress.NewMessageWriter(ctx, progress.PhaseClone),
 	})
 	if err != nil {
 		return err
 	}
 
-	// get HEAD reference
-	ref, err := r.Head()
+	ci, err := getHeadCommitInfo(r)
 	if err != nil {
 		return err
 	}
 
-	// get commit history
-	ci, err := r.Log(&git.LogOptions{
-		From:  ref.Hash(),
-		Order: git.LogOrderCommitterTime,
-	})
+	// and insert time and metadata for commit
+	_, err = rm.db.InsertRepoRetrieval(repo.ID, time.Now(), *ci)
 	if err != nil {
 		return err
 	}
-	defer ci.Close()
 
-	// get first commit from iter
-	commit, err := ci.Next()
+	return nil
+}
+
+// getHeadCommitInfo takes an opened git repository and returns the metadata
+// for the commit currently at HEAD.
+func getHeadCommitInfo(r *git.Repository) (*database.CommitInfo, error) {
+	// get HEAD reference
+	ref, err := r.Head()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// and insert time and hash for commit
-	// FIXME determine whether we need to do all this or can just use ref.Hash()
-	_, err = rm.db.InsertRepoRetrieval(repo.ID, time.Now(), commit.Hash.String())
+	// get commit object
+	commit, err := r.CommitObject(ref.Hash())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return getCommitInfo(commit, ref.Name().String()), nil
+}
+
+// getCommitInfo takes a commit and the name of the ref it was resolved from,
+// and returns the metadata for that commit.
+func getCommitInfo(commit *gitObject.Commit, refName string) *database.CommitInfo {
+	return &database.CommitInfo{
+		CommitHash:      commit.Hash.String(),
+		CommitAuthor:    formatSignature(commit.Author),
+		CommitCommitter: formatSignature(commit.Committer),
+		CommitTime:      commit.Committer.When,
+		CommitSubject:   getCommitSubject(commit.Message),
+		TreeHash:        commit.TreeHash.String(),
+		RefName:         refName,
+	}
+}
+
+// formatSignature returns a git signature as "Name <email>".
+func formatSignature(sig gitObject.Signature) string {
+	return fmt.Sprintf("%s <%s>", sig.Name, sig.Email)
+}
+
+// getCommitSubject returns the first line of a commit message.
+func getCommitSubject(msg string) string {
+	return strings.TrimSpace(strings.SplitN(msg, "\n", 2)[0])
 }
 
 // GetRepoLatestCommit takes a Repo and returns a reference to the most recent
@@ -174,7 +199,7 @@ func (rm *RepoManager) GetRepoLatestCommit(repo *database.Repo) (*gitObject.Comm
 // RepoRetrieval in the database. If there aren't, it updates the most
 // recent RepoRetrieval in the database to flag that it is still current as
 // of the present time.
-func (rm *RepoManager) UpdateRepo(repo *database.Repo) error {
+func (rm *RepoManager) UpdateRepo(ctx context.Context, repo *database.Repo) error {
 	repoPath := rm.GetPathToRepo(repo)
 	r, err := git.PlainOpen(repoPath)
 	if err != nil {
@@ -187,43 +212,26 @@ func (rm *RepoManager) UpdateRepo(repo *database.Repo) error {
 	}
 
 	// pull an update
-	err = w.Pull(&git.PullOptions{RemoteName: "origin"})
-	if err != nil && err != git.NoErrAlreadyUpToDate {
-		return err
-	}
-
-	// get HEAD reference
-	ref, err := r.Head()
-	if err != nil {
-		return err
-	}
-
-	// get commit history
-	ci, err := r.Log(&git.LogOptions{
-		From:  ref.Hash(),
-		Order: git.LogOrderCommitterTime,
+	err = w.PullContext(ctx, &git.PullOptions{
+		RemoteName: "origin",
+		Progress:   progress.NewMessageWriter(ctx, progress.PhaseFetch),
 	})
-	if err != nil {
+	if err != nil && err != git.NoErrAlreadyUpToDate {
 		return err
 	}
-	defer ci.Close()
 
-	// get first commit from iter
-	commit, err := ci.Next()
+	ci, err := getHeadCommitInfo(r)
 	if err != nil {
 		return err
 	}
 
-	// FIXME determine whether we need to do all this or can just use ref.Hash()
-
 	// get the most current RepoRetrieval so we can decide whether to
 	// update it (if commit is the same) or to insert a new one (otherwise)
 	repoRet, err := rm.db.GetRepoRetrievalLatest(repo.ID)
-	commitHash := commit.Hash.String()
-	if err != nil || commitHash != repoRet.CommitHash {
-		_, err = rm.db.InsertRepoRetrieval(repo.ID, time.Now(), commitHash)
+	if err != nil || ci.CommitHash != repoRet.CommitHash {
+		_, err = rm.db.InsertRepoRetrieval(repo.ID, time.Now(), *ci)
 	} else {
-		err = rm.db.UpdateRepoRetrieval(repoRet, time.Now(), commitHash)
+		err = rm.db.UpdateRepoRetrieval(repoRet, time.Now(), *ci)
 	}
 
 	// return back whatever err (or nil) we got from the insert / update call
@@ -311,43 +319,52 @@ func (rm *RepoManager) GetAllFilepaths(repo *database.Repo) ([]string, error) {
 }
 
 // GetFileHashes takes a Repo and returns a map of strings from path (for
-// all files in that repo as currently found on disk) to a 3-element string
-// array, with that file's hashes in order: SHA1, SHA256, MD5.
-func (rm *RepoManager) GetFileHashes(repo *database.Repo) (map[string][3]string, error) {
+// all files in that repo as currently found on disk) to that file's Hashes.
+// Symlinks are not followed; their hashes are those of the link target
+// string.
+func (rm *RepoManager) GetFileHashes(ctx context.Context, repo *database.Repo) (map[string]database.Hashes, error) {
 	allPaths, err := rm.GetAllFilepaths(repo)
 	if err != nil {
 		return nil, err
 	}
 
-	pathsToHashes := make(map[string][3]string)
+	pathsToHashes := make(map[string]database.Hashes)
 	pathRoot := rm.GetPathToRepo(repo)
+	t := progress.Start(ctx, progress.PhaseHash, len(allPaths))
 
 	for _, path := range allPaths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		fullPath := filepath.Join(pathRoot, path)
-		f, err := os.Open(fullPath)
+		f, size, err := hashmanager.OpenNoFollowWithSize(fullPath)
 		if err != nil {
 			return nil, err
 		}
 		// don't defer f.Close() here, b/c we're in a loop
 
-		var hashes [3]string
-		hSHA1 := sha1.New()
-		hSHA256 := sha256.New()
-		hMD5 := md5.New()
-		hMulti := io.MultiWriter(hSHA1, hSHA256, hMD5)
-
-		if _, err := io.Copy(hMulti, f); err != nil {
-			f.Close()
+		cr := &progress.CountingReader{R: f}
+		hashes, err := hashmanager.GetReaderHashes(cr, size)
+		f.Close()
+		if err != nil {
 			return nil, err
 		}
-		hashes[0] = fmt.Sprintf("%x", hSHA1.Sum(nil))
-		hashes[1] = fmt.Sprintf("%x", hSHA256.Sum(nil))
-		hashes[2] = fmt.Sprintf("%x", hMD5.Sum(nil))
 
 		pathsToHashes[path] = hashes
-
-		f.Close()
+		t.Add(1, cr.N)
 	}
 
 	return pathsToHashes, nil
 }
+
+// GetFileDetails takes a Repo and returns a map from path (for all files in
+// that repo as currently found on disk) to that file's FileDetails.
+func (rm *RepoManager) GetFileDetails(repo *database.Repo) (map[string]database.FileDetails, error) {
+	allPaths, err := rm.GetAllFilepaths(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return filetypes.GetAllDiskFileDetails(rm.GetPathToRepo(repo), allPaths)
+}
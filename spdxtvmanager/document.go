@@ -0,0 +1,143 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseError is an error in an SPDX document, with the number of the line
+// where it was found, starting from 1.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Parse reads an SPDX tag-value document from r. Any error in the
+// document itself is returned as a *ParseError.
+func Parse(r io.Reader) (*Document, error) {
+	doc, _, err := ParseWithPositions(r)
+	return doc, err
+}
+
+// ParseWithPositions is like Parse, but also returns the Positions of the
+// document's tags, for reporting problems with the document.
+func ParseWithPositions(r io.Reader) (*Document, *Positions, error) {
+	tvList, err := readTagValues(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parser := &spdxTVParser{}
+	pos := &Positions{lines: map[positionKey]int{}}
+	for _, tv := range tvList {
+		err = parser.parseNextPair(tv.tag, tv.value)
+		if err != nil {
+			return nil, nil, &ParseError{Line: tv.line, Err: err}
+		}
+		pos.record(parser.elementForTag(tv.tag), tv.tag, tv.line)
+	}
+	doc, err := parser.finalize()
+	return doc, pos, err
+}
+
+// Positions records the lines where the tags of a tag-value document were
+// found. Elements are identified by pointer: a *Package, *File, *Snippet,
+// *Relationship, *Annotation or *Review, or the document's *CreationInfo.
+// Its methods return 0 for a nil Positions, such as for documents in other
+// formats.
+type Positions struct {
+	lines map[positionKey]int
+}
+
+type positionKey struct {
+	elem  interface{}
+	tag   string
+	index int
+}
+
+func (p *Positions) record(elem interface{}, tag string, line int) {
+	if _, ok := p.lines[positionKey{elem: elem}]; !ok {
+		p.lines[positionKey{elem: elem}] = line
+	}
+	k := positionKey{elem: elem, tag: tag}
+	for {
+		if _, ok := p.lines[k]; !ok {
+			break
+		}
+		k.index++
+	}
+	p.lines[k] = line
+}
+
+// Line returns the line of the first tag for elem, such as "PackageName"
+// for a *Package, or of elem's first tag of any kind if tag is "". It
+// returns 0 if there is no such tag.
+func (p *Positions) Line(elem interface{}, tag string) int {
+	return p.ValueLine(elem, tag, 0)
+}
+
+// ValueLine returns the line of the i'th tag for elem, counting from 0, for
+// tags that can appear more than once, such as "LicenseInfoInFile".
+func (p *Positions) ValueLine(elem interface{}, tag string, i int) int {
+	if p == nil {
+		return 0
+	}
+	return p.lines[positionKey{elem: elem, tag: tag, index: i}]
+}
+
+// readTagValues reads all of r's lines, and returns its tag-value pairs.
+func readTagValues(r io.Reader) ([]tagvalue, error) {
+	var tvList []tagvalue
+	err := streamTagValues(r, func(tv tagvalue) error {
+		tvList = append(tvList, tv)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tvList, nil
+}
+
+// streamTagValues reads r's lines, and calls fn with each of its tag-value
+// pairs as soon as the pair is complete, so that only one is held in
+// memory at a time. Errors returned by fn are returned as is.
+func streamTagValues(r io.Reader, fn func(tv tagvalue) error) error {
+	reader := &spdxTVReader{}
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line != "" || err == nil {
+			line = strings.TrimRight(line, "\r\n")
+			if lineErr := reader.readNextLine(line); lineErr != nil {
+				return &ParseError{Line: reader.currentLine, Err: lineErr}
+			}
+		}
+		for _, tv := range reader.tvList {
+			if fnErr := fn(tv); fnErr != nil {
+				return fnErr
+			}
+		}
+		reader.tvList = reader.tvList[:0]
+		if err == io.EOF {
+			break
+		}
+	}
+
+	_, err := reader.finalize()
+	if err != nil {
+		return &ParseError{Line: reader.tagLine, Err: fmt.Errorf("<text> is never closed")}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDocument = `SPDXVersion: SPDX-2.1
+DataLicense: CC0-1.0
+
+FileName: ./src/main.c
+FileChecksum: SHA1: 85ED0817AF83A24AD8DA68C2B5094DE69833983C
+FileChecksum: MD5: 624c1abb3664f4b35547e7c73864ad24
+LicenseConcluded: MIT
+LicenseInfoInFile: MIT
+LicenseInfoInFile: Apache-2.0
+FileCopyrightText: <text>Copyright (c) Jane Doe
+Copyright (c) John Doe</text>
+
+FileName: ./README
+LicenseConcluded: NOASSERTION
+FileCopyrightText: NONE
+`
+
+func TestParseDocument(t *testing.T) {
+	doc, err := Parse(strings.NewReader(testDocument))
+	if err != nil {
+		t.Fatalf("got error when calling Parse: %v", err)
+	}
+	if len(doc.Files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(doc.Files))
+	}
+
+	f := doc.Files[0]
+	if f.Path != "./src/main.c" {
+		t.Errorf("expected path ./src/main.c, got %s", f.Path)
+	}
+	if f.Checksums["SHA1"] != "85ed0817af83a24ad8da68c2b5094de69833983c" || f.Checksums["MD5"] != "624c1abb3664f4b35547e7c73864ad24" || len(f.Checksums) != 2 {
+		t.Errorf("got wrong checksums: %v", f.Checksums)
+	}
+	if f.LicenseConcluded != "MIT" {
+		t.Errorf("expected concluded license MIT, got %s", f.LicenseConcluded)
+	}
+	if len(f.LicenseInfoInFile) != 2 || f.LicenseInfoInFile[1] != "Apache-2.0" {
+		t.Errorf("got wrong licenses in file: %v", f.LicenseInfoInFile)
+	}
+	if f.CopyrightText != "Copyright (c) Jane Doe\nCopyright (c) John Doe" {
+		t.Errorf("got wrong copyright text: %q", f.CopyrightText)
+	}
+
+	f = doc.Files[1]
+	if f.Path != "./README" || f.LicenseConcluded != "NOASSERTION" || f.CopyrightText != "NONE" {
+		t.Errorf("got wrong second file: %+v", f)
+	}
+}
+
+func TestParseReportsLineNumbers(t *testing.T) {
+	for doc, wantLine := range map[string]int{
+		"SPDXVersion: SPDX-2.1\n\nno colon here\n":                3,
+		"FileName: a\n# comment\nFileChecksum: SHA3: abc\n":       3,
+		"FileName: a\nFileCopyrightText: <text>never\nclosed\n\n": 2,
+	} {
+		_, err := Parse(strings.NewReader(doc))
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("expected *ParseError for %q, got %v", doc, err)
+			continue
+		}
+		if perr.Line != wantLine {
+			t.Errorf("expected error on line %d for %q, got %v", wantLine, doc, perr)
+		}
+	}
+}
+
+const testFullDocument = `SPDXVersion: SPDX-2.2
+DataLicense: CC0-1.0
+SPDXID: SPDXRef-DOCUMENT
+DocumentName: hello
+DocumentNamespace: https://example.com/spdx/hello-1.0
+ExternalDocumentRef: DocumentRef-other https://example.com/other SHA1: D6A770BA38583ED4BB4525BD96E50461655D2759
+LicenseListVersion: 3.9
+Creator: Person: Jane Doe (jane@example.com)
+Creator: Tool: peridot
+Created: 2020-01-02T03:04:05Z
+DocumentComment: <text>A test
+document</text>
+
+Relationship: SPDXRef-DOCUMENT DESCRIBES SPDXRef-Package
+RelationshipComment: the only package
+
+Reviewer: Person: Joe Reviewer
+ReviewDate: 2020-02-03T04:05:06Z
+ReviewComment: looks fine
+
+PackageName: hello
+SPDXID: SPDXRef-Package
+PackageVersion: 1.0
+PackageSupplier: Organization: Acme
+PackageDownloadLocation: git+https://example.com/hello.git
+FilesAnalyzed: true
+PackageVerificationCode: D6A770BA38583ED4BB4525BD96E50461655D2758 (excludes: ./package.spdx)
+PackageChecksum: SHA256: 11b6d3ee554eedf79299905a98f9b9a04e498210b59f15094c916c91d150efcd
+PackageLicenseConcluded: MIT
+PackageLicenseInfoFromFiles: MIT
+PackageLicenseInfoFromFiles: Apache-2.0
+PackageLicenseDeclared: MIT
+PackageCopyrightText: Copyright Acme
+ExternalRef: PACKAGE-MANAGER purl pkg:generic/hello@1.0
+ExternalRefComment: a purl
+
+FileName: ./hello.c
+SPDXID: SPDXRef-File
+FileType: SOURCE
+FileChecksum: SHA1: 85ed0817af83a24ad8da68c2b5094de69833983c
+LicenseConcluded: MIT
+LicenseInfoInFile: MIT
+FileCopyrightText: Copyright Acme
+FileNotice: NOTICE text
+FileContributor: Jane Doe
+FileContributor: John Doe
+FileComment: the main file
+
+SnippetSPDXID: SPDXRef-Snippet
+SnippetFromFileSPDXID: SPDXRef-File
+SnippetByteRange: 310:420
+SnippetLineRange: 5:23
+SnippetLicenseConcluded: Apache-2.0
+LicenseInfoInSnippet: Apache-2.0
+SnippetCopyrightText: NOASSERTION
+
+FileName: ./README
+SPDXID: SPDXRef-File2
+FileType: DOCUMENTATION
+FileType: TEXT
+
+Annotator: Tool: peridot
+AnnotationDate: 2020-03-04T05:06:07Z
+AnnotationType: OTHER
+SPDXREF: SPDXRef-File2
+AnnotationComment: no licenses found
+
+LicenseID: LicenseRef-Custom
+ExtractedText: <text>Permission is granted
+to do anything.</text>
+LicenseName: Custom License
+LicenseCrossReference: https://example.com/custom
+LicenseCrossReference: https://example.com/custom-2
+LicenseComment: found in hello.c
+`
+
+func TestParseFullDocument(t *testing.T) {
+	doc, err := Parse(strings.NewReader(testFullDocument))
+	if err != nil {
+		t.Fatalf("got error when calling Parse: %v", err)
+	}
+
+	ci := doc.CreationInfo
+	if ci.SPDXVersion != "SPDX-2.2" || ci.DataLicense != "CC0-1.0" || ci.SPDXIdentifier != "SPDXRef-DOCUMENT" ||
+		ci.DocumentName != "hello" || ci.DocumentNamespace != "https://example.com/spdx/hello-1.0" ||
+		ci.LicenseListVersion != "3.9" || ci.Created != "2020-01-02T03:04:05Z" || ci.DocumentComment != "A test\ndocument" {
+		t.Errorf("got wrong creation info: %+v", ci)
+	}
+	if len(ci.ExternalDocumentRefs) != 1 || ci.ExternalDocumentRefs[0] != (ExternalDocumentRef{
+		ID: "DocumentRef-other", URI: "https://example.com/other",
+		ChecksumAlgorithm: "SHA1", Checksum: "d6a770ba38583ed4bb4525bd96e50461655d2759"}) {
+		t.Errorf("got wrong external document refs: %+v", ci.ExternalDocumentRefs)
+	}
+	if len(ci.Creators) != 2 || ci.Creators[0] != (Creator{Type: "Person", Name: "Jane Doe (jane@example.com)"}) ||
+		ci.Creators[1].String() != "Tool: peridot" {
+		t.Errorf("got wrong creators: %+v", ci.Creators)
+	}
+
+	if len(doc.Packages) != 1 {
+		t.Fatalf("expected 1 package, got %d", len(doc.Packages))
+	}
+	pkg := doc.Packages[0]
+	if pkg.Name != "hello" || pkg.SPDXIdentifier != "SPDXRef-Package" || pkg.Version != "1.0" ||
+		pkg.Supplier != "Organization: Acme" || pkg.DownloadLocation != "git+https://example.com/hello.git" ||
+		!pkg.FilesAnalyzed || pkg.LicenseConcluded != "MIT" || pkg.LicenseDeclared != "MIT" ||
+		pkg.CopyrightText != "Copyright Acme" || len(pkg.LicenseInfoFromFiles) != 2 {
+		t.Errorf("got wrong package: %+v", pkg)
+	}
+	if pkg.VerificationCode.Value != "d6a770ba38583ed4bb4525bd96e50461655d2758" ||
+		len(pkg.VerificationCode.ExcludedFiles) != 1 || pkg.VerificationCode.ExcludedFiles[0] != "./package.spdx" {
+		t.Errorf("got wrong verification code: %+v", pkg.VerificationCode)
+	}
+	if pkg.Checksums["SHA256"] != "11b6d3ee554eedf79299905a98f9b9a04e498210b59f15094c916c91d150efcd" {
+		t.Errorf("got wrong package checksums: %v", pkg.Checksums)
+	}
+	if len(pkg.ExternalRefs) != 1 || *pkg.ExternalRefs[0] != (ExternalRef{Category: "PACKAGE-MANAGER",
+		Type: "purl", Locator: "pkg:generic/hello@1.0", Comment: "a purl"}) {
+		t.Errorf("got wrong external refs: %+v", pkg.ExternalRefs)
+	}
+
+	if len(doc.Files) != 2 || len(pkg.Files) != 2 || pkg.Files[1] != doc.Files[1] {
+		t.Fatalf("expected 2 files in package and document, got %d and %d", len(pkg.Files), len(doc.Files))
+	}
+	f := doc.Files[0]
+	if f.Path != "./hello.c" || f.SPDXIdentifier != "SPDXRef-File" || len(f.FileTypes) != 1 ||
+		f.Notice != "NOTICE text" || len(f.Contributors) != 2 || f.Comment != "the main file" {
+		t.Errorf("got wrong file: %+v", f)
+	}
+	if len(doc.Files[1].FileTypes) != 2 {
+		t.Errorf("expected 2 file types, got %v", doc.Files[1].FileTypes)
+	}
+
+	if len(doc.Snippets) != 1 {
+		t.Fatalf("expected 1 snippet, got %d", len(doc.Snippets))
+	}
+	sn := doc.Snippets[0]
+	if sn.SPDXIdentifier != "SPDXRef-Snippet" || sn.FromFile != "SPDXRef-File" ||
+		sn.ByteRange != (Range{310, 420}) || sn.LineRange != (Range{5, 23}) ||
+		sn.LicenseConcluded != "Apache-2.0" || len(sn.LicenseInfoInSnippet) != 1 || sn.CopyrightText != "NOASSERTION" {
+		t.Errorf("got wrong snippet: %+v", sn)
+	}
+
+	if len(doc.Relationships) != 1 || *doc.Relationships[0] != (Relationship{RefA: "SPDXRef-DOCUMENT",
+		Type: "DESCRIBES", RefB: "SPDXRef-Package", Comment: "the only package"}) {
+		t.Errorf("got wrong relationships: %+v", doc.Relationships)
+	}
+	if len(doc.Reviews) != 1 || doc.Reviews[0].Reviewer.Name != "Joe Reviewer" ||
+		doc.Reviews[0].Date != "2020-02-03T04:05:06Z" || doc.Reviews[0].Comment != "looks fine" {
+		t.Errorf("got wrong reviews: %+v", doc.Reviews)
+	}
+	if len(doc.Annotations) != 1 || *doc.Annotations[0] != (Annotation{Annotator: Creator{Type: "Tool", Name: "peridot"},
+		Date: "2020-03-04T05:06:07Z", Type: "OTHER", SPDXIdentifier: "SPDXRef-File2", Comment: "no licenses found"}) {
+		t.Errorf("got wrong annotations: %+v", doc.Annotations)
+	}
+	if len(doc.OtherLicenses) != 1 {
+		t.Fatalf("expected 1 other license, got %d", len(doc.OtherLicenses))
+	}
+	ol := doc.OtherLicenses[0]
+	if ol.LicenseID != "LicenseRef-Custom" || ol.ExtractedText != "Permission is granted\nto do anything." ||
+		ol.Name != "Custom License" || len(ol.CrossReferences) != 2 || ol.Comment != "found in hello.c" {
+		t.Errorf("got wrong other license: %+v", ol)
+	}
+}
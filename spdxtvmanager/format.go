@@ -0,0 +1,97 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is a file format for SPDX documents.
+type Format int
+
+const (
+	// FormatTagValue is the SPDX tag-value format
+	FormatTagValue Format = iota
+
+	// FormatJSON is the SPDX JSON format
+	FormatJSON
+
+	// FormatYAML is the SPDX YAML format
+	FormatYAML
+
+	// FormatRDF is the SPDX RDF/XML format
+	FormatRDF
+
+	// FormatSPDX3 is the SPDX 3.0 JSON-LD format
+	FormatSPDX3
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatJSON:
+		return "json"
+	case FormatYAML:
+		return "yaml"
+	case FormatRDF:
+		return "rdf"
+	case FormatSPDX3:
+		return "spdx3"
+	}
+	return "tag-value"
+}
+
+// FormatForPath returns the format of an SPDX document from its file name,
+// such as "hello.spdx.json". Names ending in ".spdx3.json" or ".jsonld" are
+// SPDX 3.0, and names that don't end in ".json", ".yaml", ".yml", ".rdf"
+// or ".xml" are taken to be tag-value.
+func FormatForPath(p string) Format {
+	p = strings.ToLower(p)
+	switch {
+	case strings.HasSuffix(p, ".spdx3.json"), strings.HasSuffix(p, ".jsonld"):
+		return FormatSPDX3
+	case strings.HasSuffix(p, ".json"):
+		return FormatJSON
+	case strings.HasSuffix(p, ".yaml"), strings.HasSuffix(p, ".yml"):
+		return FormatYAML
+	case strings.HasSuffix(p, ".rdf"), strings.HasSuffix(p, ".xml"):
+		return FormatRDF
+	}
+	return FormatTagValue
+}
+
+// ParseFormat reads an SPDX document in the given format from r.
+func ParseFormat(r io.Reader, f Format) (*Document, error) {
+	switch f {
+	case FormatTagValue:
+		return Parse(r)
+	case FormatJSON:
+		return ParseJSON(r)
+	case FormatYAML:
+		return ParseYAML(r)
+	case FormatRDF:
+		return ParseRDF(r)
+	case FormatSPDX3:
+		return ParseSPDX3(r)
+	}
+	return nil, fmt.Errorf("unknown SPDX format %d", f)
+}
+
+// WriteFormat writes doc to w in the given format.
+func WriteFormat(w io.Writer, doc *Document, f Format) error {
+	switch f {
+	case FormatTagValue:
+		return Write(w, doc)
+	case FormatJSON:
+		return WriteJSON(w, doc)
+	case FormatYAML:
+		return WriteYAML(w, doc)
+	case FormatRDF:
+		return WriteRDF(w, doc)
+	case FormatSPDX3:
+		return WriteSPDX3(w, doc)
+	}
+	return fmt.Errorf("unknown SPDX format %d", f)
+}
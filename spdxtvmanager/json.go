@@ -0,0 +1,633 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The json* types mirror the SPDX 2.2 JSON schema. They are also used for
+// YAML, which has the same structure.
+
+type jsonDocument struct {
+	SPDXVersion          string                    `json:"spdxVersion"`
+	DataLicense          string                    `json:"dataLicense"`
+	SPDXIdentifier       string                    `json:"SPDXID"`
+	Name                 string                    `json:"name"`
+	DocumentNamespace    string                    `json:"documentNamespace"`
+	ExternalDocumentRefs []jsonExternalDocumentRef `json:"externalDocumentRefs,omitempty"`
+	CreationInfo         jsonCreationInfo          `json:"creationInfo"`
+	Comment              string                    `json:"comment,omitempty"`
+	DocumentDescribes    []string                  `json:"documentDescribes,omitempty"`
+	Packages             []*jsonPackage            `json:"packages,omitempty"`
+	Files                []*jsonFile               `json:"files,omitempty"`
+	Snippets             []*jsonSnippet            `json:"snippets,omitempty"`
+	Relationships        []*jsonRelationship       `json:"relationships,omitempty"`
+	Annotations          []*jsonAnnotation         `json:"annotations,omitempty"`
+	Reviews              []*jsonReview             `json:"revieweds,omitempty"`
+	OtherLicenses        []*jsonOtherLicense       `json:"hasExtractedLicensingInfos,omitempty"`
+}
+
+type jsonExternalDocumentRef struct {
+	ID       string       `json:"externalDocumentId"`
+	URI      string       `json:"spdxDocument"`
+	Checksum jsonChecksum `json:"checksum"`
+}
+
+type jsonChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+type jsonCreationInfo struct {
+	LicenseListVersion string   `json:"licenseListVersion,omitempty"`
+	Creators           []string `json:"creators"`
+	Created            string   `json:"created"`
+	Comment            string   `json:"comment,omitempty"`
+}
+
+type jsonPackage struct {
+	Name                 string                `json:"name"`
+	SPDXIdentifier       string                `json:"SPDXID"`
+	Version              string                `json:"versionInfo,omitempty"`
+	FileName             string                `json:"packageFileName,omitempty"`
+	Supplier             string                `json:"supplier,omitempty"`
+	Originator           string                `json:"originator,omitempty"`
+	DownloadLocation     string                `json:"downloadLocation"`
+	FilesAnalyzed        *bool                 `json:"filesAnalyzed,omitempty"`
+	VerificationCode     *jsonVerificationCode `json:"packageVerificationCode,omitempty"`
+	Checksums            []jsonChecksum        `json:"checksums,omitempty"`
+	HomePage             string                `json:"homepage,omitempty"`
+	SourceInfo           string                `json:"sourceInfo,omitempty"`
+	LicenseConcluded     string                `json:"licenseConcluded,omitempty"`
+	LicenseInfoFromFiles []string              `json:"licenseInfoFromFiles,omitempty"`
+	LicenseDeclared      string                `json:"licenseDeclared,omitempty"`
+	LicenseComments      string                `json:"licenseComments,omitempty"`
+	CopyrightText        string                `json:"copyrightText,omitempty"`
+	Summary              string                `json:"summary,omitempty"`
+	Description          string                `json:"description,omitempty"`
+	Comment              string                `json:"comment,omitempty"`
+	ExternalRefs         []*jsonExternalRef    `json:"externalRefs,omitempty"`
+	AttributionTexts     []string              `json:"attributionTexts,omitempty"`
+	HasFiles             []string              `json:"hasFiles,omitempty"`
+	Annotations          []*jsonAnnotation     `json:"annotations,omitempty"`
+}
+
+type jsonVerificationCode struct {
+	Value         string   `json:"packageVerificationCodeValue"`
+	ExcludedFiles []string `json:"packageVerificationCodeExcludedFiles,omitempty"`
+}
+
+type jsonExternalRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type jsonFile struct {
+	Path              string            `json:"fileName"`
+	SPDXIdentifier    string            `json:"SPDXID"`
+	FileTypes         []string          `json:"fileTypes,omitempty"`
+	Checksums         []jsonChecksum    `json:"checksums,omitempty"`
+	LicenseConcluded  string            `json:"licenseConcluded,omitempty"`
+	LicenseInfoInFile []string          `json:"licenseInfoInFiles,omitempty"`
+	LicenseComments   string            `json:"licenseComments,omitempty"`
+	CopyrightText     string            `json:"copyrightText,omitempty"`
+	Notice            string            `json:"noticeText,omitempty"`
+	Contributors      []string          `json:"fileContributors,omitempty"`
+	AttributionTexts  []string          `json:"attributionTexts,omitempty"`
+	Comment           string            `json:"comment,omitempty"`
+	Dependencies      []string          `json:"fileDependencies,omitempty"`
+	ArtifactOf        []*jsonArtifactOf `json:"artifactOfs,omitempty"`
+	Annotations       []*jsonAnnotation `json:"annotations,omitempty"`
+}
+
+type jsonArtifactOf struct {
+	Name     string `json:"name"`
+	HomePage string `json:"homePage,omitempty"`
+	URI      string `json:"projectUri,omitempty"`
+}
+
+type jsonSnippet struct {
+	SPDXIdentifier       string            `json:"SPDXID"`
+	Name                 string            `json:"name,omitempty"`
+	FromFile             string            `json:"snippetFromFile"`
+	Ranges               []jsonRange       `json:"ranges"`
+	LicenseConcluded     string            `json:"licenseConcluded,omitempty"`
+	LicenseInfoInSnippet []string          `json:"licenseInfoInSnippets,omitempty"`
+	LicenseComments      string            `json:"licenseComments,omitempty"`
+	CopyrightText        string            `json:"copyrightText,omitempty"`
+	Comment              string            `json:"comment,omitempty"`
+	AttributionTexts     []string          `json:"attributionTexts,omitempty"`
+	Annotations          []*jsonAnnotation `json:"annotations,omitempty"`
+}
+
+type jsonRange struct {
+	StartPointer jsonPointer `json:"startPointer"`
+	EndPointer   jsonPointer `json:"endPointer"`
+}
+
+// jsonPointer is a position in a file, given by either Offset or
+// LineNumber.
+type jsonPointer struct {
+	Reference  string `json:"reference"`
+	Offset     int    `json:"offset,omitempty"`
+	LineNumber int    `json:"lineNumber,omitempty"`
+}
+
+type jsonRelationship struct {
+	RefA    string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	RefB    string `json:"relatedSpdxElement"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type jsonAnnotation struct {
+	Annotator string `json:"annotator"`
+	Date      string `json:"annotationDate"`
+	Type      string `json:"annotationType"`
+	Comment   string `json:"comment"`
+}
+
+type jsonReview struct {
+	Reviewer string `json:"reviewer"`
+	Date     string `json:"reviewDate"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type jsonOtherLicense struct {
+	LicenseID       string   `json:"licenseId"`
+	ExtractedText   string   `json:"extractedText"`
+	Name            string   `json:"name,omitempty"`
+	CrossReferences []string `json:"seeAlsos,omitempty"`
+	Comment         string   `json:"comment,omitempty"`
+}
+
+// ParseJSON reads an SPDX JSON document from r. Syntax errors in the
+// document are returned as a *ParseError.
+func ParseJSON(r io.Reader) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var jd jsonDocument
+	err = json.Unmarshal(data, &jd)
+	if err != nil {
+		return nil, jsonParseError(data, err)
+	}
+	return jd.toDocument()
+}
+
+// jsonParseError returns err as a *ParseError if it says where in data it
+// happened.
+func jsonParseError(data []byte, err error) error {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return err
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return &ParseError{Line: bytes.Count(data[:offset], []byte("\n")) + 1, Err: err}
+}
+
+// WriteJSON writes doc to w as an SPDX JSON document.
+func WriteJSON(w io.Writer, doc *Document) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newJSONDocument(doc))
+}
+
+func newJSONDocument(doc *Document) *jsonDocument {
+	ci := doc.CreationInfo
+	jd := &jsonDocument{
+		SPDXVersion:       ci.SPDXVersion,
+		DataLicense:       ci.DataLicense,
+		SPDXIdentifier:    ci.SPDXIdentifier,
+		Name:              ci.DocumentName,
+		DocumentNamespace: ci.DocumentNamespace,
+		CreationInfo: jsonCreationInfo{
+			LicenseListVersion: ci.LicenseListVersion,
+			Creators:           []string{},
+			Created:            ci.Created,
+			Comment:            ci.CreatorComment,
+		},
+		Comment: ci.DocumentComment,
+	}
+	for _, edr := range ci.ExternalDocumentRefs {
+		jd.ExternalDocumentRefs = append(jd.ExternalDocumentRefs, jsonExternalDocumentRef{
+			ID:       edr.ID,
+			URI:      edr.URI,
+			Checksum: jsonChecksum{Algorithm: edr.ChecksumAlgorithm, Value: edr.Checksum},
+		})
+	}
+	for _, c := range ci.Creators {
+		jd.CreationInfo.Creators = append(jd.CreationInfo.Creators, c.String())
+	}
+
+	// annotations are listed on the element they annotate, or on the
+	// document if that isn't in it
+	annotations := map[string][]*jsonAnnotation{}
+	for _, ann := range doc.Annotations {
+		annotations[ann.SPDXIdentifier] = append(annotations[ann.SPDXIdentifier], &jsonAnnotation{
+			Annotator: ann.Annotator.String(),
+			Date:      ann.Date,
+			Type:      ann.Type,
+			Comment:   ann.Comment,
+		})
+	}
+	takeAnnotations := func(id string) []*jsonAnnotation {
+		anns := annotations[id]
+		delete(annotations, id)
+		return anns
+	}
+
+	for _, pkg := range doc.Packages {
+		filesAnalyzed := pkg.FilesAnalyzed
+		jp := &jsonPackage{
+			Name:                 pkg.Name,
+			SPDXIdentifier:       pkg.SPDXIdentifier,
+			Version:              pkg.Version,
+			FileName:             pkg.FileName,
+			Supplier:             pkg.Supplier,
+			Originator:           pkg.Originator,
+			DownloadLocation:     pkg.DownloadLocation,
+			FilesAnalyzed:        &filesAnalyzed,
+			Checksums:            newJSONChecksums(pkg.Checksums),
+			HomePage:             pkg.HomePage,
+			SourceInfo:           pkg.SourceInfo,
+			LicenseConcluded:     pkg.LicenseConcluded,
+			LicenseInfoFromFiles: pkg.LicenseInfoFromFiles,
+			LicenseDeclared:      pkg.LicenseDeclared,
+			LicenseComments:      pkg.LicenseComments,
+			CopyrightText:        pkg.CopyrightText,
+			Summary:              pkg.Summary,
+			Description:          pkg.Description,
+			Comment:              pkg.Comment,
+			AttributionTexts:     pkg.AttributionTexts,
+			Annotations:          takeAnnotations(pkg.SPDXIdentifier),
+		}
+		if pkg.VerificationCode.Value != "" {
+			jp.VerificationCode = &jsonVerificationCode{
+				Value:         pkg.VerificationCode.Value,
+				ExcludedFiles: pkg.VerificationCode.ExcludedFiles,
+			}
+		}
+		for _, er := range pkg.ExternalRefs {
+			jp.ExternalRefs = append(jp.ExternalRefs, &jsonExternalRef{
+				Category: er.Category,
+				Type:     er.Type,
+				Locator:  er.Locator,
+				Comment:  er.Comment,
+			})
+		}
+		for _, f := range pkg.Files {
+			jp.HasFiles = append(jp.HasFiles, f.SPDXIdentifier)
+		}
+		jd.Packages = append(jd.Packages, jp)
+	}
+
+	for _, f := range doc.Files {
+		jf := &jsonFile{
+			Path:              f.Path,
+			SPDXIdentifier:    f.SPDXIdentifier,
+			FileTypes:         f.FileTypes,
+			Checksums:         newJSONChecksums(f.Checksums),
+			LicenseConcluded:  f.LicenseConcluded,
+			LicenseInfoInFile: f.LicenseInfoInFile,
+			LicenseComments:   f.LicenseComments,
+			CopyrightText:     f.CopyrightText,
+			Notice:            f.Notice,
+			Contributors:      f.Contributors,
+			AttributionTexts:  f.AttributionTexts,
+			Comment:           f.Comment,
+			Dependencies:      f.Dependencies,
+			Annotations:       takeAnnotations(f.SPDXIdentifier),
+		}
+		for _, aop := range f.ArtifactOf {
+			jf.ArtifactOf = append(jf.ArtifactOf, &jsonArtifactOf{Name: aop.Name, HomePage: aop.HomePage, URI: aop.URI})
+		}
+		jd.Files = append(jd.Files, jf)
+	}
+
+	for _, sn := range doc.Snippets {
+		js := &jsonSnippet{
+			SPDXIdentifier:       sn.SPDXIdentifier,
+			Name:                 sn.Name,
+			FromFile:             sn.FromFile,
+			Ranges:               []jsonRange{},
+			LicenseConcluded:     sn.LicenseConcluded,
+			LicenseInfoInSnippet: sn.LicenseInfoInSnippet,
+			LicenseComments:      sn.LicenseComments,
+			CopyrightText:        sn.CopyrightText,
+			Comment:              sn.Comment,
+			AttributionTexts:     sn.AttributionTexts,
+			Annotations:          takeAnnotations(sn.SPDXIdentifier),
+		}
+		if sn.ByteRange.Start != 0 {
+			js.Ranges = append(js.Ranges, jsonRange{
+				StartPointer: jsonPointer{Reference: sn.FromFile, Offset: sn.ByteRange.Start},
+				EndPointer:   jsonPointer{Reference: sn.FromFile, Offset: sn.ByteRange.End},
+			})
+		}
+		if sn.LineRange.Start != 0 {
+			js.Ranges = append(js.Ranges, jsonRange{
+				StartPointer: jsonPointer{Reference: sn.FromFile, LineNumber: sn.LineRange.Start},
+				EndPointer:   jsonPointer{Reference: sn.FromFile, LineNumber: sn.LineRange.End},
+			})
+		}
+		jd.Snippets = append(jd.Snippets, js)
+	}
+
+	for _, rln := range doc.Relationships {
+		jd.Relationships = append(jd.Relationships, &jsonRelationship{
+			RefA:    rln.RefA,
+			Type:    rln.Type,
+			RefB:    rln.RefB,
+			Comment: rln.Comment,
+		})
+	}
+	for _, ann := range doc.Annotations {
+		jd.Annotations = append(jd.Annotations, takeAnnotations(ann.SPDXIdentifier)...)
+	}
+	for _, rev := range doc.Reviews {
+		jd.Reviews = append(jd.Reviews, &jsonReview{
+			Reviewer: rev.Reviewer.String(),
+			Date:     rev.Date,
+			Comment:  rev.Comment,
+		})
+	}
+	for _, ol := range doc.OtherLicenses {
+		jd.OtherLicenses = append(jd.OtherLicenses, &jsonOtherLicense{
+			LicenseID:       ol.LicenseID,
+			ExtractedText:   ol.ExtractedText,
+			Name:            ol.Name,
+			CrossReferences: ol.CrossReferences,
+			Comment:         ol.Comment,
+		})
+	}
+
+	return jd
+}
+
+func newJSONChecksums(checksums map[string]string) []jsonChecksum {
+	var jcs []jsonChecksum
+	for _, alg := range checksumOrder(checksums) {
+		jcs = append(jcs, jsonChecksum{Algorithm: alg, Value: checksums[alg]})
+	}
+	return jcs
+}
+
+// toDocument converts jd to a Document. Annotations on elements get the
+// element's SPDX identifier, and the identifiers in documentDescribes
+// become DESCRIBES relationships, unless the document already has those.
+func (jd *jsonDocument) toDocument() (*Document, error) {
+	doc := &Document{
+		CreationInfo: CreationInfo{
+			SPDXVersion:        jd.SPDXVersion,
+			DataLicense:        jd.DataLicense,
+			SPDXIdentifier:     jd.SPDXIdentifier,
+			DocumentName:       jd.Name,
+			DocumentNamespace:  jd.DocumentNamespace,
+			LicenseListVersion: jd.CreationInfo.LicenseListVersion,
+			Created:            jd.CreationInfo.Created,
+			CreatorComment:     jd.CreationInfo.Comment,
+			DocumentComment:    jd.Comment,
+		},
+	}
+	ci := &doc.CreationInfo
+	for _, jedr := range jd.ExternalDocumentRefs {
+		alg, sum, err := jsonChecksumValue("externalDocumentRefs", jedr.Checksum)
+		if err != nil {
+			return nil, err
+		}
+		ci.ExternalDocumentRefs = append(ci.ExternalDocumentRefs, ExternalDocumentRef{
+			ID:                jedr.ID,
+			URI:               jedr.URI,
+			ChecksumAlgorithm: alg,
+			Checksum:          sum,
+		})
+	}
+	for _, jc := range jd.CreationInfo.Creators {
+		c, err := parseCreator(jc)
+		if err != nil {
+			return nil, err
+		}
+		ci.Creators = append(ci.Creators, c)
+	}
+
+	addAnnotations := func(id string, janns []*jsonAnnotation) error {
+		for _, jann := range janns {
+			annotator, err := parseCreator(jann.Annotator)
+			if err != nil {
+				return err
+			}
+			doc.Annotations = append(doc.Annotations, &Annotation{
+				Annotator:      annotator,
+				Date:           jann.Date,
+				Type:           jann.Type,
+				SPDXIdentifier: id,
+				Comment:        jann.Comment,
+			})
+		}
+		return nil
+	}
+	err := addAnnotations(jd.SPDXIdentifier, jd.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	filesByID := map[string]*File{}
+	for _, jf := range jd.Files {
+		checksums, err := jsonChecksumMap("files", jf.Checksums)
+		if err != nil {
+			return nil, err
+		}
+		f := &File{
+			Path:              jf.Path,
+			SPDXIdentifier:    jf.SPDXIdentifier,
+			FileTypes:         jf.FileTypes,
+			Checksums:         checksums,
+			LicenseConcluded:  jf.LicenseConcluded,
+			LicenseInfoInFile: jf.LicenseInfoInFile,
+			LicenseComments:   jf.LicenseComments,
+			CopyrightText:     jf.CopyrightText,
+			Notice:            jf.Notice,
+			Contributors:      jf.Contributors,
+			AttributionTexts:  jf.AttributionTexts,
+			Comment:           jf.Comment,
+			Dependencies:      jf.Dependencies,
+		}
+		for _, jaop := range jf.ArtifactOf {
+			f.ArtifactOf = append(f.ArtifactOf, &ArtifactOfProject{Name: jaop.Name, HomePage: jaop.HomePage, URI: jaop.URI})
+		}
+		doc.Files = append(doc.Files, f)
+		if f.SPDXIdentifier != "" {
+			filesByID[f.SPDXIdentifier] = f
+		}
+	}
+
+	for _, jp := range jd.Packages {
+		checksums, err := jsonChecksumMap("packages", jp.Checksums)
+		if err != nil {
+			return nil, err
+		}
+		pkg := &Package{
+			Name:                 jp.Name,
+			SPDXIdentifier:       jp.SPDXIdentifier,
+			Version:              jp.Version,
+			FileName:             jp.FileName,
+			Supplier:             jp.Supplier,
+			Originator:           jp.Originator,
+			DownloadLocation:     jp.DownloadLocation,
+			FilesAnalyzed:        jp.FilesAnalyzed == nil || *jp.FilesAnalyzed,
+			Checksums:            checksums,
+			HomePage:             jp.HomePage,
+			SourceInfo:           jp.SourceInfo,
+			LicenseConcluded:     jp.LicenseConcluded,
+			LicenseInfoFromFiles: jp.LicenseInfoFromFiles,
+			LicenseDeclared:      jp.LicenseDeclared,
+			LicenseComments:      jp.LicenseComments,
+			CopyrightText:        jp.CopyrightText,
+			Summary:              jp.Summary,
+			Description:          jp.Description,
+			Comment:              jp.Comment,
+			AttributionTexts:     jp.AttributionTexts,
+		}
+		if jp.VerificationCode != nil {
+			pkg.VerificationCode = VerificationCode{
+				Value:         strings.ToLower(jp.VerificationCode.Value),
+				ExcludedFiles: jp.VerificationCode.ExcludedFiles,
+			}
+		}
+		for _, jer := range jp.ExternalRefs {
+			pkg.ExternalRefs = append(pkg.ExternalRefs, &ExternalRef{
+				Category: jer.Category,
+				Type:     jer.Type,
+				Locator:  jer.Locator,
+				Comment:  jer.Comment,
+			})
+		}
+		for _, id := range jp.HasFiles {
+			f, ok := filesByID[id]
+			if !ok {
+				return nil, fmt.Errorf("package %s has unknown file %s", jp.SPDXIdentifier, id)
+			}
+			pkg.Files = append(pkg.Files, f)
+		}
+		doc.Packages = append(doc.Packages, pkg)
+		err = addAnnotations(pkg.SPDXIdentifier, jp.Annotations)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, jf := range jd.Files {
+		err = addAnnotations(jf.SPDXIdentifier, jf.Annotations)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, js := range jd.Snippets {
+		sn := &Snippet{
+			SPDXIdentifier:       js.SPDXIdentifier,
+			FromFile:             js.FromFile,
+			LicenseConcluded:     js.LicenseConcluded,
+			LicenseInfoInSnippet: js.LicenseInfoInSnippet,
+			LicenseComments:      js.LicenseComments,
+			CopyrightText:        js.CopyrightText,
+			Comment:              js.Comment,
+			Name:                 js.Name,
+			AttributionTexts:     js.AttributionTexts,
+		}
+		for _, jr := range js.Ranges {
+			if jr.StartPointer.Offset != 0 {
+				sn.ByteRange = Range{Start: jr.StartPointer.Offset, End: jr.EndPointer.Offset}
+			} else {
+				sn.LineRange = Range{Start: jr.StartPointer.LineNumber, End: jr.EndPointer.LineNumber}
+			}
+		}
+		doc.Snippets = append(doc.Snippets, sn)
+		err = addAnnotations(sn.SPDXIdentifier, js.Annotations)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	described := map[string]bool{}
+	for _, jr := range jd.Relationships {
+		doc.Relationships = append(doc.Relationships, &Relationship{
+			RefA:    jr.RefA,
+			Type:    jr.Type,
+			RefB:    jr.RefB,
+			Comment: jr.Comment,
+		})
+		if jr.RefA == jd.SPDXIdentifier && jr.Type == "DESCRIBES" {
+			described[jr.RefB] = true
+		}
+	}
+	for _, id := range jd.DocumentDescribes {
+		if !described[id] {
+			doc.Relationships = append(doc.Relationships, &Relationship{
+				RefA: jd.SPDXIdentifier,
+				Type: "DESCRIBES",
+				RefB: id,
+			})
+		}
+	}
+
+	for _, jrev := range jd.Reviews {
+		reviewer, err := parseCreator(jrev.Reviewer)
+		if err != nil {
+			return nil, err
+		}
+		doc.Reviews = append(doc.Reviews, &Review{Reviewer: reviewer, Date: jrev.Date, Comment: jrev.Comment})
+	}
+	for _, jol := range jd.OtherLicenses {
+		doc.OtherLicenses = append(doc.OtherLicenses, &OtherLicense{
+			LicenseID:       jol.LicenseID,
+			ExtractedText:   jol.ExtractedText,
+			Name:            jol.Name,
+			CrossReferences: jol.CrossReferences,
+			Comment:         jol.Comment,
+		})
+	}
+
+	return doc, nil
+}
+
+// jsonChecksumValue checks the algorithm of a checksum in the given
+// section, and returns it with the lowercased value.
+func jsonChecksumValue(section string, jc jsonChecksum) (string, string, error) {
+	if !checksumAlgorithms[jc.Algorithm] {
+		return "", "", fmt.Errorf("unknown checksum type in %s: %s", section, jc.Algorithm)
+	}
+	return jc.Algorithm, strings.ToLower(strings.TrimSpace(jc.Value)), nil
+}
+
+func jsonChecksumMap(section string, jcs []jsonChecksum) (map[string]string, error) {
+	if len(jcs) == 0 {
+		return nil, nil
+	}
+	checksums := map[string]string{}
+	for _, jc := range jcs {
+		alg, sum, err := jsonChecksumValue(section, jc)
+		if err != nil {
+			return nil, err
+		}
+		checksums[alg] = sum
+	}
+	return checksums, nil
+}
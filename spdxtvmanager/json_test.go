@@ -0,0 +1,73 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roundTrip writes doc in format f, and parses it back.
+func roundTrip(t *testing.T, doc *Document, f Format) *Document {
+	var buf bytes.Buffer
+	err := WriteFormat(&buf, doc, f)
+	if err != nil {
+		t.Fatalf("got error when writing %s document: %v", f, err)
+	}
+	got, err := ParseFormat(&buf, f)
+	if err != nil {
+		t.Fatalf("got error when parsing %s document: %v", f, err)
+	}
+	return got
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	doc, err := Parse(strings.NewReader(testFullDocument))
+	if err != nil {
+		t.Fatalf("got error when parsing document: %v", err)
+	}
+	doc.OtherLicenses = []*OtherLicense{{LicenseID: "LicenseRef-x", ExtractedText: "some\\nterms", Name: "X"}}
+
+	got := roundTrip(t, doc, FormatJSON)
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("expected JSON document to parse the same as the original")
+	}
+
+	// and the tag-value written from each is the same
+	var want, have bytes.Buffer
+	Write(&want, doc)
+	Write(&have, got)
+	if want.String() != have.String() {
+		t.Errorf("expected:\n%s\ngot:\n%s", want.String(), have.String())
+	}
+}
+
+func TestParseJSONDocumentDescribes(t *testing.T) {
+	doc, err := ParseJSON(strings.NewReader(`{
+  "spdxVersion": "SPDX-2.2",
+  "SPDXID": "SPDXRef-DOCUMENT",
+  "creationInfo": {"creators": ["Tool: other"], "created": "2020-01-02T03:04:05Z"},
+  "documentDescribes": ["SPDXRef-Package"],
+  "packages": [{"name": "hello", "SPDXID": "SPDXRef-Package", "downloadLocation": "NONE", "filesAnalyzed": false}]
+}`))
+	if err != nil {
+		t.Fatalf("got error when parsing document: %v", err)
+	}
+	if len(doc.Relationships) != 1 || doc.Relationships[0].Type != "DESCRIBES" || doc.Relationships[0].RefB != "SPDXRef-Package" {
+		t.Errorf("expected documentDescribes to become a relationship, got %+v", doc.Relationships)
+	}
+	if len(doc.Packages) != 1 || doc.Packages[0].FilesAnalyzed {
+		t.Errorf("expected one package with FilesAnalyzed false, got %+v", doc.Packages)
+	}
+}
+
+func TestParseJSONReportsLineNumbers(t *testing.T) {
+	_, err := ParseJSON(strings.NewReader("{\n  \"spdxVersion\": \"SPDX-2.2\",\n  \"packages\": 7\n}"))
+	pe, ok := err.(*ParseError)
+	if !ok || pe.Line != 3 {
+		t.Errorf("expected ParseError on line 3, got %v", err)
+	}
+}
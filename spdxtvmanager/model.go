@@ -0,0 +1,194 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+// Document is an SPDX 2.x document. Files lists every file in the order
+// that the document describes them, including those that are also listed
+// in a Package's Files.
+type Document struct {
+	CreationInfo  CreationInfo
+	Packages      []*Package
+	Files         []*File
+	Snippets      []*Snippet
+	Relationships []*Relationship
+	Annotations   []*Annotation
+	Reviews       []*Review
+	OtherLicenses []*OtherLicense
+}
+
+// CreationInfo holds the document creation information section of an SPDX
+// document.
+type CreationInfo struct {
+	SPDXVersion          string
+	DataLicense          string
+	SPDXIdentifier       string
+	DocumentName         string
+	DocumentNamespace    string
+	ExternalDocumentRefs []ExternalDocumentRef
+	LicenseListVersion   string
+	Creators             []Creator
+	Created              string
+	CreatorComment       string
+	DocumentComment      string
+}
+
+// ExternalDocumentRef is a reference to another SPDX document, whose
+// elements this one can refer to as "ID:SPDXRef-...".
+type ExternalDocumentRef struct {
+	ID                string
+	URI               string
+	ChecksumAlgorithm string
+	Checksum          string
+}
+
+// Creator is a person, organization or tool that created or reviewed an
+// SPDX document, or annotated an element. Type is "Person",
+// "Organization" or "Tool", and Name is the rest of the value, which for a
+// person or organization may include an email address in parentheses.
+type Creator struct {
+	Type string
+	Name string
+}
+
+// String returns the Creator in tag-value form, such as "Tool: peridot".
+func (c Creator) String() string {
+	return c.Type + ": " + c.Name
+}
+
+// Package is a package described in an SPDX document. Supplier and
+// Originator are given as in the document, such as "Organization: Acme" or
+// "NOASSERTION". Checksums maps each algorithm, such as "SHA1", to a
+// lowercase hex string. FilesAnalyzed is true unless the document says
+// otherwise. Files lists the files that the document describes after the
+// package.
+type Package struct {
+	Name                 string
+	SPDXIdentifier       string
+	Version              string
+	FileName             string
+	Supplier             string
+	Originator           string
+	DownloadLocation     string
+	FilesAnalyzed        bool
+	VerificationCode     VerificationCode
+	Checksums            map[string]string
+	HomePage             string
+	SourceInfo           string
+	LicenseConcluded     string
+	LicenseInfoFromFiles []string
+	LicenseDeclared      string
+	LicenseComments      string
+	CopyrightText        string
+	Summary              string
+	Description          string
+	Comment              string
+	ExternalRefs         []*ExternalRef
+	AttributionTexts     []string
+	Files                []*File
+}
+
+// VerificationCode is a package verification code, which is computed from
+// the SHA1s of the package's files other than ExcludedFiles.
+type VerificationCode struct {
+	Value         string
+	ExcludedFiles []string
+}
+
+// ExternalRef is a reference from a package to information outside the
+// SPDX document, such as a CPE or a package URL.
+type ExternalRef struct {
+	Category string
+	Type     string
+	Locator  string
+	Comment  string
+}
+
+// File is a file described in an SPDX document. Checksums maps each
+// algorithm, such as "SHA1", to a lowercase hex string.
+type File struct {
+	Path              string
+	SPDXIdentifier    string
+	FileTypes         []string
+	Checksums         map[string]string
+	LicenseConcluded  string
+	LicenseInfoInFile []string
+	LicenseComments   string
+	CopyrightText     string
+	Notice            string
+	Contributors      []string
+	AttributionTexts  []string
+	Comment           string
+	Dependencies      []string
+	ArtifactOf        []*ArtifactOfProject
+}
+
+// ArtifactOfProject is a project that a file was derived from, as given by
+// the ArtifactOfProject* tags, which are deprecated since SPDX 2.1.
+type ArtifactOfProject struct {
+	Name     string
+	HomePage string
+	URI      string
+}
+
+// Snippet is a part of a file described in an SPDX document. FromFile is
+// the SPDX identifier of the file. A Range with Start 0 wasn't given.
+type Snippet struct {
+	SPDXIdentifier       string
+	FromFile             string
+	ByteRange            Range
+	LineRange            Range
+	LicenseConcluded     string
+	LicenseInfoInSnippet []string
+	LicenseComments      string
+	CopyrightText        string
+	Comment              string
+	Name                 string
+	AttributionTexts     []string
+}
+
+// Range is an inclusive range of byte offsets or line numbers within a
+// file, starting from 1.
+type Range struct {
+	Start int
+	End   int
+}
+
+// Relationship is a relationship between two SPDX elements, such as
+// "SPDXRef-DOCUMENT DESCRIBES SPDXRef-Package". RefB may also be "NONE" or
+// "NOASSERTION".
+type Relationship struct {
+	RefA    string
+	Type    string
+	RefB    string
+	Comment string
+}
+
+// Annotation is a comment on an SPDX element, made by Annotator at Date.
+// Type is "REVIEW" or "OTHER".
+type Annotation struct {
+	Annotator      Creator
+	Date           string
+	Type           string
+	SPDXIdentifier string
+	Comment        string
+}
+
+// Review is a review of an SPDX document, which is deprecated since SPDX
+// 2.0 in favor of annotations.
+type Review struct {
+	Reviewer Creator
+	Date     string
+	Comment  string
+}
+
+// OtherLicense is a license that isn't on the SPDX License List, referred
+// to in the document's license expressions by its LicenseID, which starts
+// with "LicenseRef-".
+type OtherLicense struct {
+	LicenseID       string
+	ExtractedText   string
+	Name            string
+	CrossReferences []string
+	Comment         string
+}
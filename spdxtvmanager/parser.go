@@ -5,113 +5,500 @@ package spdxtvmanager
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
-type filedata struct {
-	path               string
-	licenseFoundInFile []string
-	licenseConcluded   string
-	sha1               string
-	sha256             string
-	md5                string
-}
+// parserState is the section of the document that the parser is in, which
+// decides what tags such as SPDXID apply to.
+type parserState int
+
+const (
+	stateReady parserState = iota
+	statePackage
+	stateFile
+	stateSnippet
+	stateOtherLicense
+)
 
 type spdxTVParser struct {
-	midfile         bool
-	fdList          []*filedata
-	currentFileData *filedata
+	state             parserState
+	doc               *Document
+	currentPackage    *Package
+	currentFile       *File
+	currentSnippet    *Snippet
+	currentLicense    *OtherLicense
+	currentRelation   *Relationship
+	currentAnnotation *Annotation
+	currentReview     *Review
 }
 
-func (parser *spdxTVParser) finalize() ([]*filedata, error) {
-	if parser.fdList == nil && parser.currentFileData == nil {
-		return nil, nil
+func (parser *spdxTVParser) finalize() (*Document, error) {
+	if parser.doc == nil {
+		parser.doc = &Document{}
 	}
-
-	// save current record from currentFileData to fdList
-	parser.fdList = append(parser.fdList, parser.currentFileData)
+	doc := parser.doc
 
 	// and reset other values
-	parser.midfile = false
-	parser.currentFileData = nil
+	parser.state = stateReady
+	parser.doc = nil
+	parser.currentPackage = nil
+	parser.currentFile = nil
+	parser.currentSnippet = nil
+	parser.currentLicense = nil
+	parser.currentRelation = nil
+	parser.currentAnnotation = nil
+	parser.currentReview = nil
 
-	return parser.fdList, nil
+	return doc, nil
 }
 
 func (parser *spdxTVParser) parseNextPair(tag string, value string) error {
-	if parser.midfile {
-		return parser.parseNextPairFromMidfile(tag, value)
+	if parser.doc == nil {
+		parser.doc = &Document{}
 	}
 
+	// these tags start a new section, or can appear in any section
+	switch tag {
+	case "PackageName":
+		parser.currentPackage = &Package{Name: value, FilesAnalyzed: true}
+		parser.doc.Packages = append(parser.doc.Packages, parser.currentPackage)
+		parser.currentFile = nil
+		parser.currentSnippet = nil
+		parser.state = statePackage
+		return nil
+
+	case "FileName":
+		parser.currentFile = &File{Path: value}
+		parser.doc.Files = append(parser.doc.Files, parser.currentFile)
+		if parser.currentPackage != nil {
+			parser.currentPackage.Files = append(parser.currentPackage.Files, parser.currentFile)
+		}
+		parser.currentSnippet = nil
+		parser.state = stateFile
+		return nil
+
+	case "SnippetSPDXID":
+		parser.currentSnippet = &Snippet{SPDXIdentifier: value}
+		parser.doc.Snippets = append(parser.doc.Snippets, parser.currentSnippet)
+		parser.state = stateSnippet
+		return nil
+
+	case "LicenseID":
+		parser.currentLicense = &OtherLicense{LicenseID: value}
+		parser.doc.OtherLicenses = append(parser.doc.OtherLicenses, parser.currentLicense)
+		parser.state = stateOtherLicense
+		return nil
+
+	case "Relationship", "RelationshipComment",
+		"Annotator", "AnnotationDate", "AnnotationType", "SPDXREF", "AnnotationComment",
+		"Reviewer", "ReviewDate", "ReviewComment":
+		return parser.parseNextPairFromAnywhere(tag, value)
+	}
+
+	switch parser.state {
+	case statePackage:
+		return parser.parseNextPairFromPackage(tag, value)
+	case stateFile:
+		return parser.parseNextPairFromFile(tag, value)
+	case stateSnippet:
+		return parser.parseNextPairFromSnippet(tag, value)
+	case stateOtherLicense:
+		return parser.parseNextPairFromOtherLicense(tag, value)
+	}
 	return parser.parseNextPairFromReady(tag, value)
 }
 
+// elementForTag returns the element that the tag-value pair just parsed
+// applies to, for recording its Position.
+func (parser *spdxTVParser) elementForTag(tag string) interface{} {
+	switch tag {
+	case "Relationship", "RelationshipComment":
+		return parser.currentRelation
+	case "Annotator", "AnnotationDate", "AnnotationType", "SPDXREF", "AnnotationComment":
+		return parser.currentAnnotation
+	case "Reviewer", "ReviewDate", "ReviewComment":
+		return parser.currentReview
+	}
+	switch parser.state {
+	case statePackage:
+		return parser.currentPackage
+	case stateFile:
+		return parser.currentFile
+	case stateSnippet:
+		return parser.currentSnippet
+	case stateOtherLicense:
+		return parser.currentLicense
+	}
+	return &parser.doc.CreationInfo
+}
+
+// parseNextPairFromReady handles tags before the first package or file,
+// which describe the document itself. Unknown tags are ignored.
 func (parser *spdxTVParser) parseNextPairFromReady(tag string, value string) error {
+	ci := &parser.doc.CreationInfo
 	switch tag {
-	case "FileName":
-		parser.currentFileData = &filedata{path: value}
-		parser.midfile = true
+	case "SPDXVersion":
+		ci.SPDXVersion = value
+	case "DataLicense":
+		ci.DataLicense = value
+	case "SPDXID":
+		ci.SPDXIdentifier = value
+	case "DocumentName":
+		ci.DocumentName = value
+	case "DocumentNamespace":
+		ci.DocumentNamespace = value
+	case "ExternalDocumentRef":
+		edr, err := parseExternalDocumentRef(value)
+		if err != nil {
+			return err
+		}
+		ci.ExternalDocumentRefs = append(ci.ExternalDocumentRefs, edr)
+	case "LicenseListVersion":
+		ci.LicenseListVersion = value
+	case "Creator":
+		c, err := parseCreator(value)
+		if err != nil {
+			return err
+		}
+		ci.Creators = append(ci.Creators, c)
+	case "Created":
+		ci.Created = value
+	case "CreatorComment":
+		ci.CreatorComment = value
+	case "DocumentComment":
+		ci.DocumentComment = value
 	}
 	return nil
 }
 
-func (parser *spdxTVParser) parseNextPairFromMidfile(tag string, value string) error {
+func (parser *spdxTVParser) parseNextPairFromPackage(tag string, value string) error {
+	pkg := parser.currentPackage
 	switch tag {
-	case "LicenseFoundInFile":
-		if parser.currentFileData.licenseFoundInFile == nil {
-			parser.currentFileData.licenseFoundInFile = make([]string, 1)
-			parser.currentFileData.licenseFoundInFile[0] = value
-		} else {
-			parser.currentFileData.licenseFoundInFile = append(
-				parser.currentFileData.licenseFoundInFile, value)
+	case "SPDXID":
+		pkg.SPDXIdentifier = value
+	case "PackageVersion":
+		pkg.Version = value
+	case "PackageFileName":
+		pkg.FileName = value
+	case "PackageSupplier":
+		pkg.Supplier = value
+	case "PackageOriginator":
+		pkg.Originator = value
+	case "PackageDownloadLocation":
+		pkg.DownloadLocation = value
+	case "FilesAnalyzed":
+		switch value {
+		case "true":
+			pkg.FilesAnalyzed = true
+		case "false":
+			pkg.FilesAnalyzed = false
+		default:
+			return fmt.Errorf("invalid FilesAnalyzed value: %s", value)
 		}
+	case "PackageVerificationCode":
+		pkg.VerificationCode = parseVerificationCode(value)
+	case "PackageChecksum":
+		if pkg.Checksums == nil {
+			pkg.Checksums = make(map[string]string)
+		}
+		return parseChecksum(tag, value, pkg.Checksums)
+	case "PackageHomePage":
+		pkg.HomePage = value
+	case "PackageSourceInfo":
+		pkg.SourceInfo = value
+	case "PackageLicenseConcluded":
+		pkg.LicenseConcluded = value
+	case "PackageLicenseInfoFromFiles":
+		pkg.LicenseInfoFromFiles = append(pkg.LicenseInfoFromFiles, value)
+	case "PackageLicenseDeclared":
+		pkg.LicenseDeclared = value
+	case "PackageLicenseComments":
+		pkg.LicenseComments = value
+	case "PackageCopyrightText":
+		pkg.CopyrightText = value
+	case "PackageSummary":
+		pkg.Summary = value
+	case "PackageDescription":
+		pkg.Description = value
+	case "PackageComment":
+		pkg.Comment = value
+	case "ExternalRef":
+		sp := strings.Fields(value)
+		if len(sp) != 3 {
+			return fmt.Errorf("invalid ExternalRef format: %s", value)
+		}
+		pkg.ExternalRefs = append(pkg.ExternalRefs, &ExternalRef{Category: sp[0], Type: sp[1], Locator: sp[2]})
+	case "ExternalRefComment":
+		if len(pkg.ExternalRefs) == 0 {
+			return fmt.Errorf("ExternalRefComment without preceding ExternalRef")
+		}
+		pkg.ExternalRefs[len(pkg.ExternalRefs)-1].Comment = value
+	case "PackageAttributionText":
+		pkg.AttributionTexts = append(pkg.AttributionTexts, value)
+	}
+	return nil
+}
 
-	case "LicenseConcluded":
-		parser.currentFileData.licenseConcluded = value
-
+func (parser *spdxTVParser) parseNextPairFromFile(tag string, value string) error {
+	f := parser.currentFile
+	switch tag {
+	case "SPDXID":
+		f.SPDXIdentifier = value
+	case "FileType":
+		f.FileTypes = append(f.FileTypes, value)
 	case "FileChecksum":
 		return parser.parseFileChecksum(value)
+	// LicenseFoundInFile isn't an SPDX tag, but was used by earlier
+	// versions of peridot
+	case "LicenseInfoInFile", "LicenseFoundInFile":
+		f.LicenseInfoInFile = append(f.LicenseInfoInFile, value)
+	case "LicenseConcluded":
+		f.LicenseConcluded = value
+	case "LicenseComments":
+		f.LicenseComments = value
+	case "FileCopyrightText":
+		f.CopyrightText = value
+	case "FileNotice":
+		f.Notice = value
+	case "FileContributor":
+		f.Contributors = append(f.Contributors, value)
+	case "FileAttributionText":
+		f.AttributionTexts = append(f.AttributionTexts, value)
+	case "FileComment":
+		f.Comment = value
+	case "FileDependency":
+		f.Dependencies = append(f.Dependencies, value)
+	case "ArtifactOfProjectName":
+		f.ArtifactOf = append(f.ArtifactOf, &ArtifactOfProject{Name: value})
+	case "ArtifactOfProjectHomePage", "ArtifactOfProjectURI":
+		if len(f.ArtifactOf) == 0 {
+			return fmt.Errorf("%s without preceding ArtifactOfProjectName", tag)
+		}
+		aop := f.ArtifactOf[len(f.ArtifactOf)-1]
+		if tag == "ArtifactOfProjectHomePage" {
+			aop.HomePage = value
+		} else {
+			aop.URI = value
+		}
+	}
+	return nil
+}
 
-	case "FileName":
-		// save old record from currentFileData to fdList
-		parser.fdList = append(parser.fdList, parser.currentFileData)
-		// and start a new one with this path
-		parser.currentFileData = &filedata{path: value}
+func (parser *spdxTVParser) parseNextPairFromSnippet(tag string, value string) error {
+	sn := parser.currentSnippet
+	var err error
+	switch tag {
+	case "SnippetFromFileSPDXID":
+		sn.FromFile = value
+	case "SnippetByteRange":
+		sn.ByteRange, err = parseRange(tag, value)
+	case "SnippetLineRange":
+		sn.LineRange, err = parseRange(tag, value)
+	case "SnippetLicenseConcluded":
+		sn.LicenseConcluded = value
+	case "LicenseInfoInSnippet":
+		sn.LicenseInfoInSnippet = append(sn.LicenseInfoInSnippet, value)
+	case "SnippetLicenseComments":
+		sn.LicenseComments = value
+	case "SnippetCopyrightText":
+		sn.CopyrightText = value
+	case "SnippetComment":
+		sn.Comment = value
+	case "SnippetName":
+		sn.Name = value
+	case "SnippetAttributionText":
+		sn.AttributionTexts = append(sn.AttributionTexts, value)
 	}
+	return err
+}
 
+// parseNextPairFromOtherLicense handles tags after LicenseID, which
+// describe a license that isn't on the SPDX License List. Unknown tags are
+// ignored.
+func (parser *spdxTVParser) parseNextPairFromOtherLicense(tag string, value string) error {
+	ol := parser.currentLicense
+	switch tag {
+	case "ExtractedText":
+		ol.ExtractedText = value
+	case "LicenseName":
+		ol.Name = value
+	case "LicenseCrossReference":
+		ol.CrossReferences = append(ol.CrossReferences, value)
+	case "LicenseComment":
+		ol.Comment = value
+	}
+	return nil
+}
+
+// parseNextPairFromAnywhere handles relationships, annotations and reviews,
+// whose tags can appear in any section of the document.
+func (parser *spdxTVParser) parseNextPairFromAnywhere(tag string, value string) error {
+	switch tag {
+	case "Relationship":
+		sp := strings.Fields(value)
+		if len(sp) != 3 {
+			return fmt.Errorf("invalid Relationship format: %s", value)
+		}
+		parser.currentRelation = &Relationship{RefA: sp[0], Type: sp[1], RefB: sp[2]}
+		parser.doc.Relationships = append(parser.doc.Relationships, parser.currentRelation)
+	case "RelationshipComment":
+		if parser.currentRelation == nil {
+			return fmt.Errorf("RelationshipComment without preceding Relationship")
+		}
+		parser.currentRelation.Comment = value
+
+	case "Annotator":
+		c, err := parseCreator(value)
+		if err != nil {
+			return err
+		}
+		parser.currentAnnotation = &Annotation{Annotator: c}
+		parser.doc.Annotations = append(parser.doc.Annotations, parser.currentAnnotation)
+	case "AnnotationDate", "AnnotationType", "SPDXREF", "AnnotationComment":
+		if parser.currentAnnotation == nil {
+			return fmt.Errorf("%s without preceding Annotator", tag)
+		}
+		switch tag {
+		case "AnnotationDate":
+			parser.currentAnnotation.Date = value
+		case "AnnotationType":
+			parser.currentAnnotation.Type = value
+		case "SPDXREF":
+			parser.currentAnnotation.SPDXIdentifier = value
+		case "AnnotationComment":
+			parser.currentAnnotation.Comment = value
+		}
+
+	case "Reviewer":
+		c, err := parseCreator(value)
+		if err != nil {
+			return err
+		}
+		parser.currentReview = &Review{Reviewer: c}
+		parser.doc.Reviews = append(parser.doc.Reviews, parser.currentReview)
+	case "ReviewDate", "ReviewComment":
+		if parser.currentReview == nil {
+			return fmt.Errorf("%s without preceding Reviewer", tag)
+		}
+		if tag == "ReviewDate" {
+			parser.currentReview.Date = value
+		} else {
+			parser.currentReview.Comment = value
+		}
+	}
 	return nil
 }
 
 func (parser *spdxTVParser) parseFileChecksum(checksumTV string) error {
-	// parse the FileChecksum value to see if it's a valid checksum type
+	if parser.currentFile.Checksums == nil {
+		parser.currentFile.Checksums = make(map[string]string)
+	}
+	return parseChecksum("FileChecksum", checksumTV, parser.currentFile.Checksums)
+}
+
+// checksumAlgorithms are the checksum algorithms allowed in SPDX 2.1 and
+// 2.2 documents.
+var checksumAlgorithms = map[string]bool{
+	"SHA1": true, "SHA224": true, "SHA256": true, "SHA384": true, "SHA512": true,
+	"MD2": true, "MD4": true, "MD5": true, "MD6": true,
+}
+
+// parseChecksum parses a checksum value of the form "ALGORITHM: value"
+// from tag, and records it in checksums.
+func parseChecksum(tag string, checksumTV string, checksums map[string]string) error {
+	cType, cSum, err := splitChecksum(tag, checksumTV)
+	if err != nil {
+		return err
+	}
+	checksums[cType] = cSum
+	return nil
+}
+
+// splitChecksum splits a checksum value of the form "ALGORITHM: value",
+// and returns the algorithm and the lowercased value.
+func splitChecksum(tag string, checksumTV string) (string, string, error) {
+	// parse the value to see if it's a valid checksum type
 	sp := strings.SplitN(checksumTV, ":", 2)
 	if len(sp) == 1 {
-		return fmt.Errorf("invalid FileChecksum format: %s", checksumTV)
+		return "", "", fmt.Errorf("invalid %s format: %s", tag, checksumTV)
 	}
 
-	cType := sp[0]
+	cType := strings.TrimSpace(sp[0])
 	cSum := sp[1]
 
 	// fail if there's another colon in the checksum section
 	colon := strings.SplitN(cSum, ":", 2)
 	if len(colon) != 1 {
-		return fmt.Errorf("invalid FileChecksum format: %s", checksumTV)
+		return "", "", fmt.Errorf("invalid %s format: %s", tag, checksumTV)
 	}
 
-	switch cType {
-	case "SHA1":
-		parser.currentFileData.sha1 = strings.TrimSpace(cSum)
-
-	case "SHA256":
-		parser.currentFileData.sha256 = strings.TrimSpace(cSum)
-
-	case "MD5":
-		parser.currentFileData.md5 = strings.TrimSpace(cSum)
+	if !checksumAlgorithms[cType] {
+		return "", "", fmt.Errorf("unknown %s type: %s", tag, cType)
+	}
+	return cType, strings.ToLower(strings.TrimSpace(cSum)), nil
+}
 
+// parseCreator parses a value of the form "Type: name", as used by the
+// Creator, Annotator and Reviewer tags.
+func parseCreator(value string) (Creator, error) {
+	sp := strings.SplitN(value, ":", 2)
+	if len(sp) == 1 {
+		return Creator{}, fmt.Errorf("invalid creator format: %s", value)
+	}
+	cType := strings.TrimSpace(sp[0])
+	switch cType {
+	case "Person", "Organization", "Tool":
 	default:
-		return fmt.Errorf("unknown FileChecksum type: %s", cType)
+		return Creator{}, fmt.Errorf("unknown creator type: %s", cType)
+	}
+	return Creator{Type: cType, Name: strings.TrimSpace(sp[1])}, nil
+}
 
+// parseExternalDocumentRef parses a value of the form
+// "DocumentRef-ID URI ALGORITHM: checksum".
+func parseExternalDocumentRef(value string) (ExternalDocumentRef, error) {
+	sp := strings.SplitN(strings.TrimSpace(value), " ", 3)
+	if len(sp) != 3 {
+		return ExternalDocumentRef{}, fmt.Errorf("invalid ExternalDocumentRef format: %s", value)
+	}
+	cType, cSum, err := splitChecksum("ExternalDocumentRef", sp[2])
+	if err != nil {
+		return ExternalDocumentRef{}, err
 	}
+	return ExternalDocumentRef{ID: sp[0], URI: sp[1], ChecksumAlgorithm: cType, Checksum: cSum}, nil
+}
 
-	return nil
+// parseVerificationCode parses a value of the form "code" or
+// "code (excludes: file1, file2)". Earlier SPDX versions separate the
+// excluded files with spaces rather than commas, so both are accepted.
+func parseVerificationCode(value string) VerificationCode {
+	code, rest, found := strings.Cut(value, "(")
+	vc := VerificationCode{Value: strings.ToLower(strings.TrimSpace(code))}
+	if !found {
+		return vc
+	}
+	rest = strings.TrimSuffix(strings.TrimSpace(rest), ")")
+	rest = strings.TrimPrefix(strings.TrimSpace(rest), "excludes:")
+	for _, f := range strings.FieldsFunc(rest, func(r rune) bool { return r == ',' || r == ' ' }) {
+		vc.ExcludedFiles = append(vc.ExcludedFiles, f)
+	}
+	return vc
+}
+
+// parseRange parses a value of the form "start:end".
+func parseRange(tag string, value string) (Range, error) {
+	sp := strings.SplitN(value, ":", 2)
+	if len(sp) != 2 {
+		return Range{}, fmt.Errorf("invalid %s format: %s", tag, value)
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(sp[0]))
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid %s start: %s", tag, sp[0])
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(sp[1]))
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid %s end: %s", tag, sp[1])
+	}
+	return Range{Start: start, End: end}, nil
 }
@@ -11,32 +11,32 @@ func TestFilenameTagInReadyMovesToMidfile(t *testing.T) {
 	if err != nil {
 		t.Errorf("got error when calling parseNextPair: %v", err)
 	}
-	if parser.midfile == false {
-		t.Errorf("expected midfile to be true, got false")
+	if parser.state != stateFile {
+		t.Errorf("expected state to be stateFile, got %v", parser.state)
 	}
-	if parser.currentFileData == nil {
-		t.Errorf("currentFileData is nil after calling parseNextPair")
+	if parser.currentFile == nil {
+		t.Errorf("currentFile is nil after calling parseNextPair")
 	}
-	if parser.currentFileData.path != "/tmp/hi" {
-		t.Errorf("expected %s for currentFileData.path, got %s", "/tmp/hi", parser.currentFileData.path)
+	if parser.currentFile.Path != "/tmp/hi" {
+		t.Errorf("expected %s for currentFile.Path, got %s", "/tmp/hi", parser.currentFile.Path)
 	}
-	if parser.currentFileData.licenseConcluded != "" {
-		t.Errorf("expected empty string for currentFileData.licenseConcluded, got %s", parser.currentFileData.licenseConcluded)
+	if parser.currentFile.LicenseConcluded != "" {
+		t.Errorf("expected empty string for currentFile.LicenseConcluded, got %s", parser.currentFile.LicenseConcluded)
 	}
-	if parser.currentFileData.licenseFoundInFile != nil {
-		t.Errorf("expected empty string for currentFileData.licenseFoundInFile, got %s", parser.currentFileData.licenseFoundInFile)
+	if parser.currentFile.LicenseInfoInFile != nil {
+		t.Errorf("expected empty string for currentFile.LicenseInfoInFile, got %s", parser.currentFile.LicenseInfoInFile)
 	}
-	if parser.currentFileData.sha1 != "" {
-		t.Errorf("expected empty string for currentFileData.sha1, got %s", parser.currentFileData.sha1)
+	if parser.currentFile.Checksums["SHA1"] != "" {
+		t.Errorf("expected empty string for currentFile.Checksums[SHA1], got %s", parser.currentFile.Checksums["SHA1"])
 	}
-	if parser.currentFileData.sha256 != "" {
-		t.Errorf("expected empty string for currentFileData.sha256, got %s", parser.currentFileData.sha256)
+	if parser.currentFile.Checksums["SHA256"] != "" {
+		t.Errorf("expected empty string for currentFile.Checksums[SHA256], got %s", parser.currentFile.Checksums["SHA256"])
 	}
-	if parser.currentFileData.md5 != "" {
-		t.Errorf("expected empty string for currentFileData.md5, got %s", parser.currentFileData.md5)
+	if parser.currentFile.Checksums["MD5"] != "" {
+		t.Errorf("expected empty string for currentFile.Checksums[MD5], got %s", parser.currentFile.Checksums["MD5"])
 	}
-	if len(parser.fdList) != 0 {
-		t.Errorf("expected fdList to be empty, got %v", parser.fdList)
+	if len(parser.doc.Files) != 1 || parser.doc.Files[0] != parser.currentFile {
+		t.Errorf("expected doc.Files to hold only currentFile, got %v", parser.doc.Files)
 	}
 }
 
@@ -46,11 +46,11 @@ func TestNonFilenameTagInReadyStaysInReady(t *testing.T) {
 	if err != nil {
 		t.Errorf("got error when calling parseNextPair: %v", err)
 	}
-	if parser.midfile == true {
-		t.Errorf("expected midfile to be false, got true")
+	if parser.state != stateReady {
+		t.Errorf("expected state to be stateReady, got %v", parser.state)
 	}
-	if parser.currentFileData != nil {
-		t.Errorf("expected currentFileData to be nil after calling parseNextPair, got %v", parser.currentFileData)
+	if parser.currentFile != nil {
+		t.Errorf("expected currentFile to be nil after calling parseNextPair, got %v", parser.currentFile)
 	}
 }
 
@@ -65,42 +65,42 @@ func TestCanExtractChecksumTypes(t *testing.T) {
 	if err != nil {
 		t.Errorf("got error when calling parseFileChecksum: %v", err)
 	}
-	if parser.currentFileData == nil {
-		t.Errorf("currentFileData is nil after calling parseFileChecksum")
+	if parser.currentFile == nil {
+		t.Errorf("currentFile is nil after calling parseFileChecksum")
 	}
-	if parser.currentFileData.sha1 != "abc123" {
-		t.Errorf("expected %s for currentFileData.sha1, got %s", "abc123", parser.currentFileData.sha1)
+	if parser.currentFile.Checksums["SHA1"] != "abc123" {
+		t.Errorf("expected %s for currentFile.Checksums[SHA1], got %s", "abc123", parser.currentFile.Checksums["SHA1"])
 	}
 
 	err = parser.parseFileChecksum("SHA256: def432")
 	if err != nil {
 		t.Errorf("got error when calling parseFileChecksum: %v", err)
 	}
-	if parser.currentFileData == nil {
-		t.Errorf("currentFileData is nil after calling parseFileChecksum")
+	if parser.currentFile == nil {
+		t.Errorf("currentFile is nil after calling parseFileChecksum")
 	}
-	if parser.currentFileData.sha1 != "abc123" {
-		t.Errorf("expected %s for currentFileData.sha1, got %s", "abc123", parser.currentFileData.sha1)
+	if parser.currentFile.Checksums["SHA1"] != "abc123" {
+		t.Errorf("expected %s for currentFile.Checksums[SHA1], got %s", "abc123", parser.currentFile.Checksums["SHA1"])
 	}
-	if parser.currentFileData.sha256 != "def432" {
-		t.Errorf("expected %s for currentFileData.sha256, got %s", "def432", parser.currentFileData.sha1)
+	if parser.currentFile.Checksums["SHA256"] != "def432" {
+		t.Errorf("expected %s for currentFile.Checksums[SHA256], got %s", "def432", parser.currentFile.Checksums["SHA1"])
 	}
 
 	err = parser.parseFileChecksum("MD5: 035183")
 	if err != nil {
 		t.Errorf("got error when calling parseFileChecksum: %v", err)
 	}
-	if parser.currentFileData == nil {
-		t.Errorf("currentFileData is nil after calling parseFileChecksum")
+	if parser.currentFile == nil {
+		t.Errorf("currentFile is nil after calling parseFileChecksum")
 	}
-	if parser.currentFileData.sha1 != "abc123" {
-		t.Errorf("expected %s for currentFileData.sha1, got %s", "abc123", parser.currentFileData.sha1)
+	if parser.currentFile.Checksums["SHA1"] != "abc123" {
+		t.Errorf("expected %s for currentFile.Checksums[SHA1], got %s", "abc123", parser.currentFile.Checksums["SHA1"])
 	}
-	if parser.currentFileData.sha256 != "def432" {
-		t.Errorf("expected %s for currentFileData.sha256, got %s", "def432", parser.currentFileData.sha1)
+	if parser.currentFile.Checksums["SHA256"] != "def432" {
+		t.Errorf("expected %s for currentFile.Checksums[SHA256], got %s", "def432", parser.currentFile.Checksums["SHA1"])
 	}
-	if parser.currentFileData.md5 != "035183" {
-		t.Errorf("expected %s for currentFileData.md5, got %s", "035183", parser.currentFileData.sha1)
+	if parser.currentFile.Checksums["MD5"] != "035183" {
+		t.Errorf("expected %s for currentFile.Checksums[MD5], got %s", "035183", parser.currentFile.Checksums["SHA1"])
 	}
 
 }
@@ -179,35 +179,35 @@ func TestRecordsDataInCurrentFileData(t *testing.T) {
 		t.Errorf("got error when calling parseNextPair: %v", err)
 	}
 
-	if parser.midfile == false {
-		t.Errorf("expected midfile to be true, got false")
+	if parser.state != stateFile {
+		t.Errorf("expected state to be stateFile, got %v", parser.state)
 	}
-	if parser.currentFileData == nil {
-		t.Errorf("currentFileData is nil after calls to parseNextPair")
+	if parser.currentFile == nil {
+		t.Errorf("currentFile is nil after calls to parseNextPair")
 	}
-	if parser.currentFileData.path != "/tmp/hi" {
-		t.Errorf("expected %s for currentFileData.path, got %s", "/tmp/hi", parser.currentFileData.path)
+	if parser.currentFile.Path != "/tmp/hi" {
+		t.Errorf("expected %s for currentFile.Path, got %s", "/tmp/hi", parser.currentFile.Path)
 	}
-	if parser.currentFileData.licenseConcluded != "Apache-2.0 AND (MIT OR BSD-2-Clause)" {
-		t.Errorf("expected Apache-2.0 AND (MIT OR BSD-2-Clause) for currentFileData.licenseConcluded, got %s", parser.currentFileData.licenseConcluded)
+	if parser.currentFile.LicenseConcluded != "Apache-2.0 AND (MIT OR BSD-2-Clause)" {
+		t.Errorf("expected Apache-2.0 AND (MIT OR BSD-2-Clause) for currentFile.LicenseConcluded, got %s", parser.currentFile.LicenseConcluded)
 	}
-	if parser.currentFileData.licenseFoundInFile == nil ||
-		len(parser.currentFileData.licenseFoundInFile) != 2 ||
-		parser.currentFileData.licenseFoundInFile[0] != "MIT" ||
-		parser.currentFileData.licenseFoundInFile[1] != "Apache-2.0" {
-		t.Errorf("expected [MIT Apache-2.0] for currentFileData.licenseFoundInFile, got %s", parser.currentFileData.licenseFoundInFile)
+	if parser.currentFile.LicenseInfoInFile == nil ||
+		len(parser.currentFile.LicenseInfoInFile) != 2 ||
+		parser.currentFile.LicenseInfoInFile[0] != "MIT" ||
+		parser.currentFile.LicenseInfoInFile[1] != "Apache-2.0" {
+		t.Errorf("expected [MIT Apache-2.0] for currentFile.LicenseInfoInFile, got %s", parser.currentFile.LicenseInfoInFile)
 	}
-	if parser.currentFileData.sha1 != "abc123" {
-		t.Errorf("expected abc123 for currentFileData.sha1, got %s", parser.currentFileData.sha1)
+	if parser.currentFile.Checksums["SHA1"] != "abc123" {
+		t.Errorf("expected abc123 for currentFile.Checksums[SHA1], got %s", parser.currentFile.Checksums["SHA1"])
 	}
-	if parser.currentFileData.sha256 != "456789" {
-		t.Errorf("expected 456789 for currentFileData.sha256, got %s", parser.currentFileData.sha256)
+	if parser.currentFile.Checksums["SHA256"] != "456789" {
+		t.Errorf("expected 456789 for currentFile.Checksums[SHA256], got %s", parser.currentFile.Checksums["SHA256"])
 	}
-	if parser.currentFileData.md5 != "0def12" {
-		t.Errorf("expected 0def12 for currentFileData.md5, got %s", parser.currentFileData.md5)
+	if parser.currentFile.Checksums["MD5"] != "0def12" {
+		t.Errorf("expected 0def12 for currentFile.Checksums[MD5], got %s", parser.currentFile.Checksums["MD5"])
 	}
-	if len(parser.fdList) != 0 {
-		t.Errorf("expected fdList to be empty, got %v", parser.fdList)
+	if len(parser.doc.Files) != 1 || parser.doc.Files[0] != parser.currentFile {
+		t.Errorf("expected doc.Files to hold only currentFile, got %v", parser.doc.Files)
 	}
 
 }
@@ -278,60 +278,60 @@ func TestSavesAndProceedsToNextFileData(t *testing.T) {
 		t.Errorf("got error when calling parseNextPair: %v", err)
 	}
 
-	// ===== Check that FIRST file data got saved in fdList
+	// ===== Check that FIRST file data got saved in doc.Files
 
-	if parser.midfile == false {
-		t.Errorf("expected midfile to be true, got false")
+	if parser.state != stateFile {
+		t.Errorf("expected state to be stateFile, got %v", parser.state)
 	}
-	if len(parser.fdList) != 1 {
-		t.Errorf("expected len(fdList) to be 1, got %d", len(parser.fdList))
+	if len(parser.doc.Files) != 2 {
+		t.Errorf("expected len(doc.Files) to be 2, got %d", len(parser.doc.Files))
 	}
-	if parser.fdList[0].path != "/tmp/hi" {
-		t.Errorf("expected %s for fdList[0].path, got %s", "/tmp/hi", parser.fdList[0].path)
+	if parser.doc.Files[0].Path != "/tmp/hi" {
+		t.Errorf("expected %s for doc.Files[0].Path, got %s", "/tmp/hi", parser.doc.Files[0].Path)
 	}
-	if parser.fdList[0].licenseConcluded != "Apache-2.0 AND (MIT OR BSD-2-Clause)" {
-		t.Errorf("expected Apache-2.0 AND (MIT OR BSD-2-Clause) for fdList[0].licenseConcluded, got %s", parser.fdList[0].licenseConcluded)
+	if parser.doc.Files[0].LicenseConcluded != "Apache-2.0 AND (MIT OR BSD-2-Clause)" {
+		t.Errorf("expected Apache-2.0 AND (MIT OR BSD-2-Clause) for doc.Files[0].LicenseConcluded, got %s", parser.doc.Files[0].LicenseConcluded)
 	}
-	if parser.fdList[0].licenseFoundInFile == nil ||
-		len(parser.fdList[0].licenseFoundInFile) != 2 ||
-		parser.fdList[0].licenseFoundInFile[0] != "MIT" ||
-		parser.fdList[0].licenseFoundInFile[1] != "Apache-2.0" {
-		t.Errorf("expected [MIT Apache-2.0] for fdList[0].licenseFoundInFile, got %s", parser.fdList[0].licenseFoundInFile)
+	if parser.doc.Files[0].LicenseInfoInFile == nil ||
+		len(parser.doc.Files[0].LicenseInfoInFile) != 2 ||
+		parser.doc.Files[0].LicenseInfoInFile[0] != "MIT" ||
+		parser.doc.Files[0].LicenseInfoInFile[1] != "Apache-2.0" {
+		t.Errorf("expected [MIT Apache-2.0] for doc.Files[0].LicenseInfoInFile, got %s", parser.doc.Files[0].LicenseInfoInFile)
 	}
-	if parser.fdList[0].sha1 != "abc123" {
-		t.Errorf("expected abc123 for fdList[0].sha1, got %s", parser.fdList[0].sha1)
+	if parser.doc.Files[0].Checksums["SHA1"] != "abc123" {
+		t.Errorf("expected abc123 for doc.Files[0].Checksums[SHA1], got %s", parser.doc.Files[0].Checksums["SHA1"])
 	}
-	if parser.fdList[0].sha256 != "456789" {
-		t.Errorf("expected 456789 for fdList[0].sha256, got %s", parser.fdList[0].sha256)
+	if parser.doc.Files[0].Checksums["SHA256"] != "456789" {
+		t.Errorf("expected 456789 for doc.Files[0].Checksums[SHA256], got %s", parser.doc.Files[0].Checksums["SHA256"])
 	}
-	if parser.fdList[0].md5 != "0def12" {
-		t.Errorf("expected 0def12 for fdList[0].md5, got %s", parser.fdList[0].md5)
+	if parser.doc.Files[0].Checksums["MD5"] != "0def12" {
+		t.Errorf("expected 0def12 for doc.Files[0].Checksums[MD5], got %s", parser.doc.Files[0].Checksums["MD5"])
 	}
 
-	// ===== Check that SECOND file data is stored in currentFileData
+	// ===== Check that SECOND file data is stored in currentFile
 
-	if parser.currentFileData == nil {
-		t.Errorf("currentFileData is nil after calls to parseNextPair")
+	if parser.currentFile == nil {
+		t.Errorf("currentFile is nil after calls to parseNextPair")
 	}
-	if parser.currentFileData.path != "/tmp/bye" {
-		t.Errorf("expected %s for currentFileData.path, got %s", "/tmp/bye", parser.currentFileData.path)
+	if parser.currentFile.Path != "/tmp/bye" {
+		t.Errorf("expected %s for currentFile.Path, got %s", "/tmp/bye", parser.currentFile.Path)
 	}
-	if parser.currentFileData.licenseConcluded != "LicenseRef-Whatever" {
-		t.Errorf("expected LicenseRef-Whatever for currentFileData.licenseConcluded, got %s", parser.currentFileData.licenseConcluded)
+	if parser.currentFile.LicenseConcluded != "LicenseRef-Whatever" {
+		t.Errorf("expected LicenseRef-Whatever for currentFile.LicenseConcluded, got %s", parser.currentFile.LicenseConcluded)
 	}
-	if parser.currentFileData.licenseFoundInFile == nil ||
-		len(parser.currentFileData.licenseFoundInFile) != 1 ||
-		parser.currentFileData.licenseFoundInFile[0] != "LicenseRef-Whatever" {
-		t.Errorf("expected [LicenseRef-Whatever] for currentFileData.licenseFoundInFile, got %s", parser.currentFileData.licenseFoundInFile)
+	if parser.currentFile.LicenseInfoInFile == nil ||
+		len(parser.currentFile.LicenseInfoInFile) != 1 ||
+		parser.currentFile.LicenseInfoInFile[0] != "LicenseRef-Whatever" {
+		t.Errorf("expected [LicenseRef-Whatever] for currentFile.LicenseInfoInFile, got %s", parser.currentFile.LicenseInfoInFile)
 	}
-	if parser.currentFileData.sha1 != "abc" {
-		t.Errorf("expected abc for currentFileData.sha1, got %s", parser.currentFileData.sha1)
+	if parser.currentFile.Checksums["SHA1"] != "abc" {
+		t.Errorf("expected abc for currentFile.Checksums[SHA1], got %s", parser.currentFile.Checksums["SHA1"])
 	}
-	if parser.currentFileData.sha256 != "def" {
-		t.Errorf("expected def for currentFileData.sha256, got %s", parser.currentFileData.sha256)
+	if parser.currentFile.Checksums["SHA256"] != "def" {
+		t.Errorf("expected def for currentFile.Checksums[SHA256], got %s", parser.currentFile.Checksums["SHA256"])
 	}
-	if parser.currentFileData.md5 != "123" {
-		t.Errorf("expected 123 for currentFileData.md5, got %s", parser.currentFileData.md5)
+	if parser.currentFile.Checksums["MD5"] != "123" {
+		t.Errorf("expected 123 for currentFile.Checksums[MD5], got %s", parser.currentFile.Checksums["MD5"])
 	}
 
 }
@@ -372,41 +372,42 @@ func TestFinalizeSavesLastFileData(t *testing.T) {
 		t.Errorf("got error when calling parseNextPair: %v", err)
 	}
 
-	fdList, err := parser.finalize()
+	doc, err := parser.finalize()
 	if err != nil {
-		t.Errorf("got error when calling finalize: %v", err)
+		t.Fatalf("got error when calling finalize: %v", err)
 	}
+	fdList := doc.Files
 
-	if parser.midfile == true {
-		t.Errorf("expected midfile to be false, got true")
+	if parser.state != stateReady {
+		t.Errorf("expected state to be stateReady, got %v", parser.state)
 	}
 	if len(fdList) != 1 {
 		t.Errorf("expected len(fdList) to be 1, got %d", len(fdList))
 	}
-	if fdList[0].path != "/tmp/hi" {
-		t.Errorf("expected %s for fdList[0].path, got %s", "/tmp/hi", fdList[0].path)
+	if fdList[0].Path != "/tmp/hi" {
+		t.Errorf("expected %s for doc.Files[0].Path, got %s", "/tmp/hi", fdList[0].Path)
 	}
-	if fdList[0].licenseConcluded != "Apache-2.0 AND (MIT OR BSD-2-Clause)" {
-		t.Errorf("expected Apache-2.0 AND (MIT OR BSD-2-Clause) for fdList[0].licenseConcluded, got %s", fdList[0].licenseConcluded)
+	if fdList[0].LicenseConcluded != "Apache-2.0 AND (MIT OR BSD-2-Clause)" {
+		t.Errorf("expected Apache-2.0 AND (MIT OR BSD-2-Clause) for doc.Files[0].LicenseConcluded, got %s", fdList[0].LicenseConcluded)
 	}
-	if fdList[0].licenseFoundInFile == nil ||
-		len(fdList[0].licenseFoundInFile) != 2 ||
-		fdList[0].licenseFoundInFile[0] != "MIT" ||
-		fdList[0].licenseFoundInFile[1] != "Apache-2.0" {
-		t.Errorf("expected [MIT Apache-2.0] for fdList[0].licenseFoundInFile, got %s", fdList[0].licenseFoundInFile)
+	if fdList[0].LicenseInfoInFile == nil ||
+		len(fdList[0].LicenseInfoInFile) != 2 ||
+		fdList[0].LicenseInfoInFile[0] != "MIT" ||
+		fdList[0].LicenseInfoInFile[1] != "Apache-2.0" {
+		t.Errorf("expected [MIT Apache-2.0] for doc.Files[0].LicenseInfoInFile, got %s", fdList[0].LicenseInfoInFile)
 	}
-	if fdList[0].sha1 != "abc123" {
-		t.Errorf("expected abc123 for fdList[0].sha1, got %s", fdList[0].sha1)
+	if fdList[0].Checksums["SHA1"] != "abc123" {
+		t.Errorf("expected abc123 for doc.Files[0].Checksums[SHA1], got %s", fdList[0].Checksums["SHA1"])
 	}
-	if fdList[0].sha256 != "456789" {
-		t.Errorf("expected 456789 for fdList[0].sha256, got %s", fdList[0].sha256)
+	if fdList[0].Checksums["SHA256"] != "456789" {
+		t.Errorf("expected 456789 for doc.Files[0].Checksums[SHA256], got %s", fdList[0].Checksums["SHA256"])
 	}
-	if fdList[0].md5 != "0def12" {
-		t.Errorf("expected 0def12 for fdList[0].md5, got %s", fdList[0].md5)
+	if fdList[0].Checksums["MD5"] != "0def12" {
+		t.Errorf("expected 0def12 for doc.Files[0].Checksums[MD5], got %s", fdList[0].Checksums["MD5"])
 	}
 
-	if parser.currentFileData != nil {
-		t.Errorf("expected currentFileData to be nil, got %v", parser.currentFileData)
+	if parser.currentFile != nil {
+		t.Errorf("expected currentFile to be nil, got %v", parser.currentFile)
 	}
 
 }
@@ -0,0 +1,1094 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/swinslow/peridot/licenses"
+)
+
+// SPDX RDF/XML documents are read by parsing the XML into a tree of
+// rdfNodes, and then walking it from the SpdxDocument node, resolving
+// rdf:resource references to nodes defined elsewhere in the document.
+// License expressions are written in the structured form that RDF uses, so
+// they are read back in the normalized form that the licenses package
+// gives; expressions that it can't parse are written as plain text.
+
+const (
+	rdfNS        = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	rdfsNS       = "http://www.w3.org/2000/01/rdf-schema#"
+	spdxNS       = "http://spdx.org/rdf/terms#"
+	doapNS       = "http://usefulinc.com/ns/doap#"
+	ptrNS        = "http://www.w3.org/2009/pointers#"
+	licensesURI  = "http://spdx.org/licenses/"
+	referenceURI = "http://spdx.org/rdf/references/"
+)
+
+// rdfNode is an XML element in an RDF/XML document. For a node element,
+// about is its URI; for a property element, resource is the URI of the
+// node it refers to, and either text or children give its value otherwise.
+type rdfNode struct {
+	name     xml.Name
+	about    string
+	resource string
+	text     string
+	children []*rdfNode
+	line     int
+}
+
+// child returns the first property of n with the given name.
+func (n *rdfNode) child(space string, local string) *rdfNode {
+	for _, c := range n.children {
+		if c.name.Space == space && c.name.Local == local {
+			return c
+		}
+	}
+	return nil
+}
+
+// all returns every property of n with the given name.
+func (n *rdfNode) all(space string, local string) []*rdfNode {
+	var nodes []*rdfNode
+	for _, c := range n.children {
+		if c.name.Space == space && c.name.Local == local {
+			nodes = append(nodes, c)
+		}
+	}
+	return nodes
+}
+
+// ===== Reading =====
+
+type rdfReader struct {
+	namespace string
+	byURI     map[string]*rdfNode
+	order     []*rdfNode
+	edrs      []ExternalDocumentRef
+	doc       *Document
+	files     map[*rdfNode]*File
+	hasFiles  map[*Package][]*rdfNode
+	licenses  map[string]bool
+}
+
+// ParseRDF reads an SPDX RDF/XML document from r. Errors in the document
+// are returned as a *ParseError.
+func ParseRDF(r io.Reader) (*Document, error) {
+	root, err := parseRDFXML(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rr := &rdfReader{
+		byURI:    map[string]*rdfNode{},
+		doc:      &Document{},
+		files:    map[*rdfNode]*File{},
+		hasFiles: map[*Package][]*rdfNode{},
+		licenses: map[string]bool{},
+	}
+	var docNode *rdfNode
+	var index func(n *rdfNode)
+	index = func(n *rdfNode) {
+		if n.about != "" {
+			if prev, ok := rr.byURI[n.about]; ok {
+				prev.children = append(prev.children, n.children...)
+			} else {
+				rr.byURI[n.about] = n
+				rr.order = append(rr.order, n)
+			}
+		}
+		if n.name.Space == spdxNS && n.name.Local == "SpdxDocument" && docNode == nil {
+			docNode = n
+		}
+		for _, c := range n.children {
+			index(c)
+		}
+	}
+	index(root)
+	if docNode == nil {
+		return nil, fmt.Errorf("no SpdxDocument found")
+	}
+	err = rr.readDocument(docNode)
+	if err != nil {
+		return nil, err
+	}
+	return rr.doc, nil
+}
+
+func parseRDFXML(r io.Reader) (*rdfNode, error) {
+	dec := xml.NewDecoder(r)
+	root := &rdfNode{}
+	stack := []*rdfNode{root}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line, _ := dec.InputPos()
+			return nil, &ParseError{Line: line, Err: err}
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			line, _ := dec.InputPos()
+			n := &rdfNode{name: t.Name, line: line}
+			for _, attr := range t.Attr {
+				if attr.Name.Space != rdfNS {
+					continue
+				}
+				switch attr.Name.Local {
+				case "about":
+					n.about = attr.Value
+				case "resource":
+					n.resource = attr.Value
+				}
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			n := stack[len(stack)-1]
+			n.text += string(t)
+		}
+	}
+	return root, nil
+}
+
+// node returns the node that property p refers to, either nested in it or
+// by rdf:resource.
+func (rr *rdfReader) node(p *rdfNode) *rdfNode {
+	if p == nil {
+		return nil
+	}
+	if p.resource != "" {
+		return rr.byURI[p.resource]
+	}
+	if len(p.children) > 0 {
+		return p.children[0]
+	}
+	return nil
+}
+
+// literal returns the text of the property space:local of n, or "" if it
+// isn't there.
+func literal(n *rdfNode, space string, local string) string {
+	p := n.child(space, local)
+	if p == nil {
+		return ""
+	}
+	return strings.TrimSpace(p.text)
+}
+
+// literals returns the text of every property space:local of n.
+func literals(n *rdfNode, space string, local string) []string {
+	var values []string
+	for _, p := range n.all(space, local) {
+		values = append(values, strings.TrimSpace(p.text))
+	}
+	return values
+}
+
+// value returns the value of the property space:local of n, which is
+// either text or a reference to NONE or NOASSERTION.
+func value(n *rdfNode, space string, local string) string {
+	p := n.child(space, local)
+	if p == nil {
+		return ""
+	}
+	switch p.resource {
+	case spdxNS + "none":
+		return "NONE"
+	case spdxNS + "noassertion":
+		return "NOASSERTION"
+	}
+	return strings.TrimSpace(p.text)
+}
+
+// vocab returns the suffix of a vocabulary URI such as
+// "http://spdx.org/rdf/terms#fileType_source" after the given prefix, in
+// upper case with words separated by sep, such as "SOURCE".
+func vocab(uri string, prefix string, sep string) string {
+	s := strings.TrimPrefix(uri, spdxNS+prefix)
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// vocabURI is the inverse of vocab.
+func vocabURI(prefix string, value string) string {
+	var b strings.Builder
+	upper := false
+	for i, r := range strings.ToLower(value) {
+		switch {
+		case r == '_' || r == '-':
+			upper = i > 0
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return spdxNS + prefix + b.String()
+}
+
+// id returns the SPDX identifier for an element's URI, which is either in
+// this document or an external document.
+func (rr *rdfReader) id(uri string) string {
+	switch uri {
+	case spdxNS + "none":
+		return "NONE"
+	case spdxNS + "noassertion":
+		return "NOASSERTION"
+	}
+	base, frag, found := strings.Cut(uri, "#")
+	if !found {
+		return uri
+	}
+	if base == rr.namespace {
+		return frag
+	}
+	for _, edr := range rr.edrs {
+		if edr.URI == base {
+			return edr.ID + ":" + frag
+		}
+	}
+	return frag
+}
+
+// ref returns the SPDX identifier of the element that property p refers
+// to.
+func (rr *rdfReader) ref(p *rdfNode) string {
+	if p == nil {
+		return ""
+	}
+	if p.resource != "" {
+		return rr.id(p.resource)
+	}
+	if n := rr.node(p); n != nil {
+		return rr.id(n.about)
+	}
+	return ""
+}
+
+func (rr *rdfReader) readDocument(n *rdfNode) error {
+	rr.namespace, _, _ = strings.Cut(n.about, "#")
+	ci := &rr.doc.CreationInfo
+	ci.SPDXVersion = literal(n, spdxNS, "specVersion")
+	if p := n.child(spdxNS, "dataLicense"); p != nil {
+		ci.DataLicense = rr.license(p)
+	}
+	ci.SPDXIdentifier = rr.id(n.about)
+	ci.DocumentName = literal(n, spdxNS, "name")
+	ci.DocumentNamespace = rr.namespace
+	for _, p := range n.all(spdxNS, "externalDocumentRef") {
+		en := rr.node(p)
+		if en == nil {
+			continue
+		}
+		edr := ExternalDocumentRef{
+			ID:  literal(en, spdxNS, "externalDocumentId"),
+			URI: en.child(spdxNS, "spdxDocument").resourceOrText(),
+		}
+		if cn := rr.node(en.child(spdxNS, "checksum")); cn != nil {
+			edr.ChecksumAlgorithm, edr.Checksum = rr.checksum(cn)
+		}
+		ci.ExternalDocumentRefs = append(ci.ExternalDocumentRefs, edr)
+	}
+	rr.edrs = ci.ExternalDocumentRefs
+	if cin := rr.node(n.child(spdxNS, "creationInfo")); cin != nil {
+		ci.LicenseListVersion = literal(cin, spdxNS, "licenseListVersion")
+		for _, s := range literals(cin, spdxNS, "creator") {
+			c, err := parseCreator(s)
+			if err != nil {
+				return &ParseError{Line: cin.line, Err: err}
+			}
+			ci.Creators = append(ci.Creators, c)
+		}
+		ci.Created = literal(cin, spdxNS, "created")
+		ci.CreatorComment = literal(cin, rdfsNS, "comment")
+	}
+	ci.DocumentComment = literal(n, rdfsNS, "comment")
+
+	for _, p := range n.all(spdxNS, "reviewed") {
+		rn := rr.node(p)
+		if rn == nil {
+			continue
+		}
+		reviewer, err := parseCreator(literal(rn, spdxNS, "reviewer"))
+		if err != nil {
+			return &ParseError{Line: rn.line, Err: err}
+		}
+		rr.doc.Reviews = append(rr.doc.Reviews, &Review{
+			Reviewer: reviewer,
+			Date:     literal(rn, spdxNS, "reviewDate"),
+			Comment:  literal(rn, rdfsNS, "comment"),
+		})
+	}
+
+	for _, p := range n.all(spdxNS, "hasExtractedLicensingInfo") {
+		if on := rr.node(p); on != nil {
+			rr.readOtherLicense(on)
+		}
+	}
+
+	// elements are read in the order they first appear in the document,
+	// and files are then added to the packages that have them
+	err := rr.readElement(n)
+	if err != nil {
+		return err
+	}
+	for _, en := range rr.order {
+		if en.name.Space != spdxNS || en == n {
+			continue
+		}
+		switch en.name.Local {
+		case "Package", "File", "Snippet":
+			err = rr.readElement(en)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, pkg := range rr.doc.Packages {
+		for _, p := range rr.hasFiles[pkg] {
+			f, ok := rr.files[rr.node(p)]
+			if !ok {
+				return &ParseError{Line: p.line, Err: fmt.Errorf("package %s has unknown file %s", pkg.SPDXIdentifier, p.resource)}
+			}
+			pkg.Files = append(pkg.Files, f)
+		}
+	}
+	return nil
+}
+
+func (n *rdfNode) resourceOrText() string {
+	if n == nil {
+		return ""
+	}
+	if n.resource != "" {
+		return n.resource
+	}
+	return strings.TrimSpace(n.text)
+}
+
+func (rr *rdfReader) checksum(n *rdfNode) (string, string) {
+	alg := ""
+	if p := n.child(spdxNS, "algorithm"); p != nil {
+		alg = vocab(p.resourceOrText(), "checksumAlgorithm_", "")
+	}
+	return alg, strings.ToLower(literal(n, spdxNS, "checksumValue"))
+}
+
+func (rr *rdfReader) checksums(n *rdfNode) (map[string]string, error) {
+	var checksums map[string]string
+	for _, p := range n.all(spdxNS, "checksum") {
+		cn := rr.node(p)
+		if cn == nil {
+			continue
+		}
+		alg, sum := rr.checksum(cn)
+		if !checksumAlgorithms[alg] {
+			return nil, &ParseError{Line: cn.line, Err: fmt.Errorf("unknown checksum type: %s", alg)}
+		}
+		if checksums == nil {
+			checksums = map[string]string{}
+		}
+		checksums[alg] = sum
+	}
+	return checksums, nil
+}
+
+// readElement reads a document, package, file or snippet node, along with
+// its relationships and annotations.
+func (rr *rdfReader) readElement(n *rdfNode) error {
+	var err error
+	switch n.name.Local {
+	case "Package":
+		err = rr.readPackage(n)
+	case "File":
+		err = rr.readFile(n)
+	case "Snippet":
+		err = rr.readSnippet(n)
+	}
+	if err != nil {
+		return err
+	}
+
+	id := rr.id(n.about)
+	for _, p := range n.all(spdxNS, "relationship") {
+		rn := rr.node(p)
+		if rn == nil {
+			continue
+		}
+		rr.doc.Relationships = append(rr.doc.Relationships, &Relationship{
+			RefA:    id,
+			Type:    vocab(rn.child(spdxNS, "relationshipType").resourceOrText(), "relationshipType_", "_"),
+			RefB:    rr.ref(rn.child(spdxNS, "relatedSpdxElement")),
+			Comment: literal(rn, rdfsNS, "comment"),
+		})
+	}
+	for _, p := range n.all(spdxNS, "annotation") {
+		an := rr.node(p)
+		if an == nil {
+			continue
+		}
+		annotator, err := parseCreator(literal(an, spdxNS, "annotator"))
+		if err != nil {
+			return &ParseError{Line: an.line, Err: err}
+		}
+		rr.doc.Annotations = append(rr.doc.Annotations, &Annotation{
+			Annotator:      annotator,
+			Date:           literal(an, spdxNS, "annotationDate"),
+			Type:           vocab(an.child(spdxNS, "annotationType").resourceOrText(), "annotationType_", "_"),
+			SPDXIdentifier: id,
+			Comment:        literal(an, rdfsNS, "comment"),
+		})
+	}
+
+	return nil
+}
+
+func (rr *rdfReader) readPackage(n *rdfNode) error {
+	checksums, err := rr.checksums(n)
+	if err != nil {
+		return err
+	}
+	pkg := &Package{
+		Name:                 literal(n, spdxNS, "name"),
+		SPDXIdentifier:       rr.id(n.about),
+		Version:              literal(n, spdxNS, "versionInfo"),
+		FileName:             literal(n, spdxNS, "packageFileName"),
+		Supplier:             value(n, spdxNS, "supplier"),
+		Originator:           value(n, spdxNS, "originator"),
+		DownloadLocation:     value(n, spdxNS, "downloadLocation"),
+		FilesAnalyzed:        literal(n, spdxNS, "filesAnalyzed") != "false",
+		Checksums:            checksums,
+		HomePage:             value(n, doapNS, "homepage"),
+		SourceInfo:           literal(n, spdxNS, "sourceInfo"),
+		LicenseConcluded:     rr.license(n.child(spdxNS, "licenseConcluded")),
+		LicenseInfoFromFiles: rr.licenseList(n.all(spdxNS, "licenseInfoFromFiles")),
+		LicenseDeclared:      rr.license(n.child(spdxNS, "licenseDeclared")),
+		LicenseComments:      literal(n, spdxNS, "licenseComments"),
+		CopyrightText:        value(n, spdxNS, "copyrightText"),
+		Summary:              literal(n, spdxNS, "summary"),
+		Description:          literal(n, spdxNS, "description"),
+		Comment:              literal(n, rdfsNS, "comment"),
+		AttributionTexts:     literals(n, spdxNS, "attributionText"),
+	}
+	if vn := rr.node(n.child(spdxNS, "packageVerificationCode")); vn != nil {
+		pkg.VerificationCode = VerificationCode{
+			Value:         strings.ToLower(literal(vn, spdxNS, "packageVerificationCodeValue")),
+			ExcludedFiles: literals(vn, spdxNS, "packageVerificationCodeExcludedFile"),
+		}
+	}
+	for _, p := range n.all(spdxNS, "externalRef") {
+		en := rr.node(p)
+		if en == nil {
+			continue
+		}
+		refType := en.child(spdxNS, "referenceType").resourceOrText()
+		pkg.ExternalRefs = append(pkg.ExternalRefs, &ExternalRef{
+			Category: vocab(en.child(spdxNS, "referenceCategory").resourceOrText(), "referenceCategory_", "-"),
+			Type:     strings.TrimPrefix(refType, referenceURI),
+			Locator:  literal(en, spdxNS, "referenceLocator"),
+			Comment:  literal(en, rdfsNS, "comment"),
+		})
+	}
+	rr.doc.Packages = append(rr.doc.Packages, pkg)
+	rr.hasFiles[pkg] = n.all(spdxNS, "hasFile")
+	return nil
+}
+
+func (rr *rdfReader) readFile(n *rdfNode) error {
+	checksums, err := rr.checksums(n)
+	if err != nil {
+		return err
+	}
+	f := &File{
+		Path:              literal(n, spdxNS, "fileName"),
+		SPDXIdentifier:    rr.id(n.about),
+		Checksums:         checksums,
+		LicenseConcluded:  rr.license(n.child(spdxNS, "licenseConcluded")),
+		LicenseInfoInFile: rr.licenseList(n.all(spdxNS, "licenseInfoInFile")),
+		LicenseComments:   literal(n, spdxNS, "licenseComments"),
+		CopyrightText:     value(n, spdxNS, "copyrightText"),
+		Notice:            value(n, spdxNS, "noticeText"),
+		Contributors:      literals(n, spdxNS, "fileContributor"),
+		AttributionTexts:  literals(n, spdxNS, "attributionText"),
+		Comment:           literal(n, rdfsNS, "comment"),
+	}
+	for _, p := range n.all(spdxNS, "fileType") {
+		f.FileTypes = append(f.FileTypes, vocab(p.resourceOrText(), "fileType_", "_"))
+	}
+	for _, p := range n.all(spdxNS, "fileDependency") {
+		f.Dependencies = append(f.Dependencies, rr.ref(p))
+	}
+	for _, p := range n.all(spdxNS, "artifactOf") {
+		pn := rr.node(p)
+		if pn == nil {
+			continue
+		}
+		f.ArtifactOf = append(f.ArtifactOf, &ArtifactOfProject{
+			Name:     literal(pn, doapNS, "name"),
+			HomePage: pn.child(doapNS, "homepage").resourceOrText(),
+			URI:      pn.about,
+		})
+	}
+	rr.doc.Files = append(rr.doc.Files, f)
+	rr.files[n] = f
+	return nil
+}
+
+func (rr *rdfReader) readSnippet(n *rdfNode) error {
+	sn := &Snippet{
+		SPDXIdentifier:       rr.id(n.about),
+		FromFile:             rr.ref(n.child(spdxNS, "snippetFromFile")),
+		LicenseConcluded:     rr.license(n.child(spdxNS, "licenseConcluded")),
+		LicenseInfoInSnippet: rr.licenseList(n.all(spdxNS, "licenseInfoInSnippet")),
+		LicenseComments:      literal(n, spdxNS, "licenseComments"),
+		CopyrightText:        value(n, spdxNS, "copyrightText"),
+		Comment:              literal(n, rdfsNS, "comment"),
+		Name:                 literal(n, spdxNS, "name"),
+		AttributionTexts:     literals(n, spdxNS, "attributionText"),
+	}
+	for _, p := range n.all(spdxNS, "range") {
+		rn := rr.node(p)
+		if rn == nil {
+			continue
+		}
+		start := rr.node(rn.child(ptrNS, "startPointer"))
+		end := rr.node(rn.child(ptrNS, "endPointer"))
+		if start == nil || end == nil {
+			continue
+		}
+		var rng Range
+		_, errStart := fmt.Sscan(literal(start, ptrNS, "offset")+literal(start, ptrNS, "lineNumber"), &rng.Start)
+		_, errEnd := fmt.Sscan(literal(end, ptrNS, "offset")+literal(end, ptrNS, "lineNumber"), &rng.End)
+		if errStart != nil || errEnd != nil {
+			return &ParseError{Line: rn.line, Err: fmt.Errorf("invalid snippet range")}
+		}
+		if start.name.Local == "ByteOffsetPointer" {
+			sn.ByteRange = rng
+		} else {
+			sn.LineRange = rng
+		}
+	}
+	rr.doc.Snippets = append(rr.doc.Snippets, sn)
+	return nil
+}
+
+func (rr *rdfReader) readOtherLicense(n *rdfNode) {
+	id := literal(n, spdxNS, "licenseId")
+	if id == "" {
+		id = rr.id(n.about)
+	}
+	if rr.licenses[id] {
+		return
+	}
+	rr.licenses[id] = true
+	rr.doc.OtherLicenses = append(rr.doc.OtherLicenses, &OtherLicense{
+		LicenseID:       id,
+		ExtractedText:   literal(n, spdxNS, "extractedText"),
+		Name:            literal(n, spdxNS, "name"),
+		CrossReferences: literals(n, rdfsNS, "seeAlso"),
+		Comment:         literal(n, rdfsNS, "comment"),
+	})
+}
+
+func (rr *rdfReader) licenseList(props []*rdfNode) []string {
+	var exprs []string
+	for _, p := range props {
+		exprs = append(exprs, rr.license(p))
+	}
+	return exprs
+}
+
+// license returns the license expression that property p gives, as text.
+func (rr *rdfReader) license(p *rdfNode) string {
+	if p == nil {
+		return ""
+	}
+	if p.resource != "" {
+		return rr.licenseURI(p.resource)
+	}
+	n := rr.node(p)
+	if n == nil {
+		return strings.TrimSpace(p.text)
+	}
+	return rr.licenseNode(n)
+}
+
+func (rr *rdfReader) licenseURI(uri string) string {
+	if strings.HasPrefix(uri, licensesURI) {
+		return strings.TrimPrefix(uri, licensesURI)
+	}
+	return rr.id(uri)
+}
+
+func (rr *rdfReader) licenseNode(n *rdfNode) string {
+	member := func(p *rdfNode) string {
+		expr := rr.license(p)
+		if mn := rr.node(p); p.resource == "" && mn != nil {
+			switch mn.name.Local {
+			case "ConjunctiveLicenseSet", "DisjunctiveLicenseSet", "WithExceptionOperator":
+				expr = "(" + expr + ")"
+			}
+		}
+		return expr
+	}
+
+	switch n.name.Local {
+	case "ConjunctiveLicenseSet", "DisjunctiveLicenseSet":
+		op := " AND "
+		if n.name.Local == "DisjunctiveLicenseSet" {
+			op = " OR "
+		}
+		var members []string
+		for _, p := range n.all(spdxNS, "member") {
+			members = append(members, member(p))
+		}
+		return strings.Join(members, op)
+	case "WithExceptionOperator":
+		exception := ""
+		if en := rr.node(n.child(spdxNS, "licenseException")); en != nil {
+			exception = literal(en, spdxNS, "licenseExceptionId")
+		}
+		return member(n.child(spdxNS, "member")) + " WITH " + exception
+	case "OrLaterOperator":
+		return rr.license(n.child(spdxNS, "member")) + "+"
+	case "ExtractedLicensingInfo":
+		rr.readOtherLicense(n)
+	}
+	if id := literal(n, spdxNS, "licenseId"); id != "" {
+		return id
+	}
+	return rr.licenseURI(n.about)
+}
+
+// ===== Writing =====
+
+// rdfElem is an XML element to be written, whose name includes its
+// namespace prefix, such as "spdx:Package".
+type rdfElem struct {
+	name     string
+	attrs    []string
+	text     string
+	children []*rdfElem
+}
+
+func newRDFElem(name string, attrs ...string) *rdfElem {
+	return &rdfElem{name: name, attrs: attrs}
+}
+
+// add adds a child element, and returns it.
+func (e *rdfElem) add(name string, attrs ...string) *rdfElem {
+	c := newRDFElem(name, attrs...)
+	e.children = append(e.children, c)
+	return c
+}
+
+// literal adds a property with text, unless text is empty.
+func (e *rdfElem) literal(name string, text string) {
+	if text != "" {
+		e.add(name).text = text
+	}
+}
+
+// resource adds a property referring to uri.
+func (e *rdfElem) resource(name string, uri string) {
+	e.add(name, "rdf:resource", uri)
+}
+
+// value adds a property with text, or a reference to NONE or NOASSERTION,
+// unless text is empty.
+func (e *rdfElem) value(name string, text string) {
+	switch text {
+	case "":
+	case "NONE":
+		e.resource(name, spdxNS+"none")
+	case "NOASSERTION":
+		e.resource(name, spdxNS+"noassertion")
+	default:
+		e.literal(name, text)
+	}
+}
+
+var rdfTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+var rdfAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;", "\n", "&#xA;", "\r", "&#xD;", "\t", "&#x9;")
+
+func (e *rdfElem) write(w *bufio.Writer, indent int) {
+	pad := strings.Repeat("  ", indent)
+	w.WriteString(pad + "<" + e.name)
+	for i := 0; i+1 < len(e.attrs); i += 2 {
+		w.WriteString(" " + e.attrs[i] + "=\"" + rdfAttrEscaper.Replace(e.attrs[i+1]) + "\"")
+	}
+	switch {
+	case len(e.children) > 0:
+		w.WriteString(">\n")
+		for _, c := range e.children {
+			c.write(w, indent+1)
+		}
+		w.WriteString(pad + "</" + e.name + ">\n")
+	case e.text != "":
+		w.WriteString(">" + rdfTextEscaper.Replace(e.text) + "</" + e.name + ">\n")
+	default:
+		w.WriteString("/>\n")
+	}
+}
+
+type rdfWriter struct {
+	doc         *Document
+	namespace   string
+	edrs        map[string]string
+	annotations map[string][]*Annotation
+	relations   map[string][]*Relationship
+}
+
+// WriteRDF writes doc to w as an SPDX RDF/XML document. Packages, files
+// and snippets are written as separate nodes after the SpdxDocument, and
+// relationships and annotations are written on the element they belong to.
+func WriteRDF(w io.Writer, doc *Document) error {
+	rw := &rdfWriter{
+		doc:         doc,
+		namespace:   doc.CreationInfo.DocumentNamespace,
+		edrs:        map[string]string{},
+		annotations: map[string][]*Annotation{},
+		relations:   map[string][]*Relationship{},
+	}
+	for _, edr := range doc.CreationInfo.ExternalDocumentRefs {
+		rw.edrs[edr.ID] = edr.URI
+	}
+	for _, ann := range doc.Annotations {
+		rw.annotations[ann.SPDXIdentifier] = append(rw.annotations[ann.SPDXIdentifier], ann)
+	}
+	for _, rln := range doc.Relationships {
+		rw.relations[rln.RefA] = append(rw.relations[rln.RefA], rln)
+	}
+
+	root := newRDFElem("rdf:RDF",
+		"xmlns:rdf", rdfNS,
+		"xmlns:rdfs", rdfsNS,
+		"xmlns:spdx", spdxNS,
+		"xmlns:doap", doapNS,
+		"xmlns:ptr", ptrNS)
+	root.children = append(root.children, rw.documentElem())
+	for _, pkg := range doc.Packages {
+		root.children = append(root.children, rw.packageElem(pkg))
+	}
+	for _, f := range doc.Files {
+		root.children = append(root.children, rw.fileElem(f))
+	}
+	for _, sn := range doc.Snippets {
+		root.children = append(root.children, rw.snippetElem(sn))
+	}
+
+	// relationships and annotations for elements that aren't in the
+	// document are kept on the document
+	docElem := root.children[0]
+	ids := make([]string, 0, len(rw.relations)+len(rw.annotations))
+	for id := range rw.relations {
+		ids = append(ids, id)
+	}
+	for id := range rw.annotations {
+		if _, ok := rw.relations[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		rw.addRelationsAndAnnotations(docElem, id)
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	root.write(bw, 0)
+	return bw.Flush()
+}
+
+// uri returns the URI of the element with the given SPDX identifier, which
+// may be in an external document.
+func (rw *rdfWriter) uri(id string) string {
+	switch id {
+	case "NONE":
+		return spdxNS + "none"
+	case "NOASSERTION":
+		return spdxNS + "noassertion"
+	}
+	if docRef, frag, found := strings.Cut(id, ":"); found {
+		if base, ok := rw.edrs[docRef]; ok {
+			return base + "#" + frag
+		}
+	}
+	return rw.namespace + "#" + id
+}
+
+func (rw *rdfWriter) documentElem() *rdfElem {
+	ci := rw.doc.CreationInfo
+	e := newRDFElem("spdx:SpdxDocument", "rdf:about", rw.uri(ci.SPDXIdentifier))
+	e.literal("spdx:specVersion", ci.SPDXVersion)
+	if ci.DataLicense != "" {
+		e.resource("spdx:dataLicense", licensesURI+ci.DataLicense)
+	}
+	e.literal("spdx:name", ci.DocumentName)
+	for _, edr := range ci.ExternalDocumentRefs {
+		en := e.add("spdx:externalDocumentRef").add("spdx:ExternalDocumentRef")
+		en.literal("spdx:externalDocumentId", edr.ID)
+		en.resource("spdx:spdxDocument", edr.URI)
+		addChecksum(en, edr.ChecksumAlgorithm, edr.Checksum)
+	}
+	cin := e.add("spdx:creationInfo").add("spdx:CreationInfo")
+	cin.literal("spdx:licenseListVersion", ci.LicenseListVersion)
+	for _, c := range ci.Creators {
+		cin.literal("spdx:creator", c.String())
+	}
+	cin.literal("spdx:created", ci.Created)
+	cin.literal("rdfs:comment", ci.CreatorComment)
+	e.literal("rdfs:comment", ci.DocumentComment)
+	for _, rev := range rw.doc.Reviews {
+		rn := e.add("spdx:reviewed").add("spdx:Review")
+		rn.literal("spdx:reviewer", rev.Reviewer.String())
+		rn.literal("spdx:reviewDate", rev.Date)
+		rn.literal("rdfs:comment", rev.Comment)
+	}
+	for _, ol := range rw.doc.OtherLicenses {
+		on := e.add("spdx:hasExtractedLicensingInfo").add("spdx:ExtractedLicensingInfo", "rdf:about", rw.uri(ol.LicenseID))
+		on.literal("spdx:licenseId", ol.LicenseID)
+		on.literal("spdx:extractedText", ol.ExtractedText)
+		on.literal("spdx:name", ol.Name)
+		for _, xref := range ol.CrossReferences {
+			on.literal("rdfs:seeAlso", xref)
+		}
+		on.literal("rdfs:comment", ol.Comment)
+	}
+	rw.addRelationsAndAnnotations(e, ci.SPDXIdentifier)
+	return e
+}
+
+func addChecksum(e *rdfElem, alg string, sum string) {
+	cn := e.add("spdx:checksum").add("spdx:Checksum")
+	cn.resource("spdx:algorithm", spdxNS+"checksumAlgorithm_"+strings.ToLower(alg))
+	cn.literal("spdx:checksumValue", sum)
+}
+
+func addChecksums(e *rdfElem, checksums map[string]string) {
+	for _, alg := range checksumOrder(checksums) {
+		addChecksum(e, alg, checksums[alg])
+	}
+}
+
+// addRelationsAndAnnotations adds the relationships from, and annotations
+// on, the element with the given identifier to e, and forgets them so they
+// aren't written twice.
+func (rw *rdfWriter) addRelationsAndAnnotations(e *rdfElem, id string) {
+	for _, rln := range rw.relations[id] {
+		rn := e.add("spdx:relationship").add("spdx:Relationship")
+		rn.resource("spdx:relationshipType", vocabURI("relationshipType_", rln.Type))
+		rn.resource("spdx:relatedSpdxElement", rw.uri(rln.RefB))
+		rn.literal("rdfs:comment", rln.Comment)
+	}
+	delete(rw.relations, id)
+	for _, ann := range rw.annotations[id] {
+		an := e.add("spdx:annotation").add("spdx:Annotation")
+		an.literal("spdx:annotator", ann.Annotator.String())
+		an.literal("spdx:annotationDate", ann.Date)
+		an.resource("spdx:annotationType", vocabURI("annotationType_", ann.Type))
+		an.literal("rdfs:comment", ann.Comment)
+	}
+	delete(rw.annotations, id)
+}
+
+func (rw *rdfWriter) packageElem(pkg *Package) *rdfElem {
+	e := newRDFElem("spdx:Package", "rdf:about", rw.uri(pkg.SPDXIdentifier))
+	e.literal("spdx:name", pkg.Name)
+	e.literal("spdx:versionInfo", pkg.Version)
+	e.literal("spdx:packageFileName", pkg.FileName)
+	e.value("spdx:supplier", pkg.Supplier)
+	e.value("spdx:originator", pkg.Originator)
+	e.value("spdx:downloadLocation", pkg.DownloadLocation)
+	e.literal("spdx:filesAnalyzed", fmt.Sprintf("%t", pkg.FilesAnalyzed))
+	if pkg.VerificationCode.Value != "" {
+		vn := e.add("spdx:packageVerificationCode").add("spdx:PackageVerificationCode")
+		vn.literal("spdx:packageVerificationCodeValue", pkg.VerificationCode.Value)
+		for _, f := range pkg.VerificationCode.ExcludedFiles {
+			vn.literal("spdx:packageVerificationCodeExcludedFile", f)
+		}
+	}
+	addChecksums(e, pkg.Checksums)
+	e.value("doap:homepage", pkg.HomePage)
+	e.literal("spdx:sourceInfo", pkg.SourceInfo)
+	rw.addLicense(e, "spdx:licenseConcluded", pkg.LicenseConcluded)
+	for _, lic := range pkg.LicenseInfoFromFiles {
+		rw.addLicense(e, "spdx:licenseInfoFromFiles", lic)
+	}
+	rw.addLicense(e, "spdx:licenseDeclared", pkg.LicenseDeclared)
+	e.literal("spdx:licenseComments", pkg.LicenseComments)
+	e.value("spdx:copyrightText", pkg.CopyrightText)
+	e.literal("spdx:summary", pkg.Summary)
+	e.literal("spdx:description", pkg.Description)
+	e.literal("rdfs:comment", pkg.Comment)
+	for _, er := range pkg.ExternalRefs {
+		en := e.add("spdx:externalRef").add("spdx:ExternalRef")
+		en.resource("spdx:referenceCategory", vocabURI("referenceCategory_", er.Category))
+		refType := er.Type
+		if !strings.Contains(refType, ":") {
+			refType = referenceURI + refType
+		}
+		en.resource("spdx:referenceType", refType)
+		en.literal("spdx:referenceLocator", er.Locator)
+		en.literal("rdfs:comment", er.Comment)
+	}
+	for _, at := range pkg.AttributionTexts {
+		e.literal("spdx:attributionText", at)
+	}
+	for _, f := range pkg.Files {
+		e.resource("spdx:hasFile", rw.uri(f.SPDXIdentifier))
+	}
+	rw.addRelationsAndAnnotations(e, pkg.SPDXIdentifier)
+	return e
+}
+
+func (rw *rdfWriter) fileElem(f *File) *rdfElem {
+	e := newRDFElem("spdx:File", "rdf:about", rw.uri(f.SPDXIdentifier))
+	e.literal("spdx:fileName", f.Path)
+	for _, ft := range f.FileTypes {
+		e.resource("spdx:fileType", vocabURI("fileType_", ft))
+	}
+	addChecksums(e, f.Checksums)
+	rw.addLicense(e, "spdx:licenseConcluded", f.LicenseConcluded)
+	for _, lic := range f.LicenseInfoInFile {
+		rw.addLicense(e, "spdx:licenseInfoInFile", lic)
+	}
+	e.literal("spdx:licenseComments", f.LicenseComments)
+	e.value("spdx:copyrightText", f.CopyrightText)
+	e.value("spdx:noticeText", f.Notice)
+	for _, c := range f.Contributors {
+		e.literal("spdx:fileContributor", c)
+	}
+	for _, at := range f.AttributionTexts {
+		e.literal("spdx:attributionText", at)
+	}
+	e.literal("rdfs:comment", f.Comment)
+	for _, dep := range f.Dependencies {
+		e.resource("spdx:fileDependency", rw.uri(dep))
+	}
+	for _, aop := range f.ArtifactOf {
+		var pn *rdfElem
+		if aop.URI != "" {
+			pn = e.add("spdx:artifactOf").add("doap:Project", "rdf:about", aop.URI)
+		} else {
+			pn = e.add("spdx:artifactOf").add("doap:Project")
+		}
+		pn.literal("doap:name", aop.Name)
+		if aop.HomePage != "" {
+			pn.resource("doap:homepage", aop.HomePage)
+		}
+	}
+	rw.addRelationsAndAnnotations(e, f.SPDXIdentifier)
+	return e
+}
+
+func (rw *rdfWriter) snippetElem(sn *Snippet) *rdfElem {
+	e := newRDFElem("spdx:Snippet", "rdf:about", rw.uri(sn.SPDXIdentifier))
+	e.resource("spdx:snippetFromFile", rw.uri(sn.FromFile))
+	addRange := func(rng Range, pointer string, prop string) {
+		rn := e.add("spdx:range").add("ptr:StartEndPointer")
+		for _, p := range []struct {
+			name  string
+			value int
+		}{{"ptr:startPointer", rng.Start}, {"ptr:endPointer", rng.End}} {
+			pn := rn.add(p.name).add(pointer)
+			pn.resource("ptr:reference", rw.uri(sn.FromFile))
+			pn.literal(prop, fmt.Sprintf("%d", p.value))
+		}
+	}
+	if sn.ByteRange.Start != 0 {
+		addRange(sn.ByteRange, "ptr:ByteOffsetPointer", "ptr:offset")
+	}
+	if sn.LineRange.Start != 0 {
+		addRange(sn.LineRange, "ptr:LineCharPointer", "ptr:lineNumber")
+	}
+	rw.addLicense(e, "spdx:licenseConcluded", sn.LicenseConcluded)
+	for _, lic := range sn.LicenseInfoInSnippet {
+		rw.addLicense(e, "spdx:licenseInfoInSnippet", lic)
+	}
+	e.literal("spdx:licenseComments", sn.LicenseComments)
+	e.value("spdx:copyrightText", sn.CopyrightText)
+	e.literal("rdfs:comment", sn.Comment)
+	e.literal("spdx:name", sn.Name)
+	for _, at := range sn.AttributionTexts {
+		e.literal("spdx:attributionText", at)
+	}
+	rw.addRelationsAndAnnotations(e, sn.SPDXIdentifier)
+	return e
+}
+
+// addLicense adds a property giving the license expression expr, unless
+// it is empty.
+func (rw *rdfWriter) addLicense(e *rdfElem, name string, expr string) {
+	switch expr {
+	case "":
+		return
+	case "NONE", "NOASSERTION":
+		e.value(name, expr)
+		return
+	}
+	pln, err := licenses.GetNodesForExpression(expr)
+	if err != nil || pln == nil {
+		e.literal(name, expr)
+		return
+	}
+	p := e.add(name)
+	rw.addLicenseNode(p, pln)
+}
+
+// addLicenseNode sets property p to refer to the license given by pln.
+// Chains of the same operator, such as "A AND B AND C", are written as a
+// single set.
+func (rw *rdfWriter) addLicenseNode(p *rdfElem, pln *licenses.ParsedLicenseNode) {
+	switch pln.NodeType {
+	case licenses.NodeIdentifier:
+		if strings.HasPrefix(pln.Expression, "LicenseRef-") {
+			p.attrs = append(p.attrs, "rdf:resource", rw.uri(pln.Expression))
+		} else {
+			p.attrs = append(p.attrs, "rdf:resource", licensesURI+pln.Expression)
+		}
+	case licenses.NodePlus:
+		op := p.add("spdx:OrLaterOperator")
+		rw.addLicenseNode(op.add("spdx:member"), pln.LeftChild)
+	case licenses.NodeWith:
+		op := p.add("spdx:WithExceptionOperator")
+		rw.addLicenseNode(op.add("spdx:member"), pln.LeftChild)
+		op.add("spdx:licenseException").add("spdx:LicenseException").literal("spdx:licenseExceptionId", pln.RightChild.Expression)
+	case licenses.NodeAnd, licenses.NodeOr:
+		setName := "spdx:ConjunctiveLicenseSet"
+		if pln.NodeType == licenses.NodeOr {
+			setName = "spdx:DisjunctiveLicenseSet"
+		}
+		set := p.add(setName)
+		var addMembers func(n *licenses.ParsedLicenseNode)
+		addMembers = func(n *licenses.ParsedLicenseNode) {
+			if n.NodeType == pln.NodeType {
+				addMembers(n.LeftChild)
+				addMembers(n.RightChild)
+				return
+			}
+			rw.addLicenseNode(set.add("spdx:member"), n)
+		}
+		addMembers(pln)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRDFRoundTrip(t *testing.T) {
+	doc, err := Parse(strings.NewReader(testFullDocument))
+	if err != nil {
+		t.Fatalf("got error when parsing document: %v", err)
+	}
+	doc.OtherLicenses = []*OtherLicense{{LicenseID: "LicenseRef-x", ExtractedText: "<some> & terms", Name: "X"}}
+
+	got := roundTrip(t, doc, FormatRDF)
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("expected RDF document to parse the same as the original")
+	}
+}
+
+func TestRDFLicenseExpressions(t *testing.T) {
+	doc := &Document{
+		CreationInfo: CreationInfo{SPDXIdentifier: "SPDXRef-DOCUMENT", DocumentNamespace: "https://example.com/doc"},
+	}
+	exprs := []string{
+		"MIT",
+		"MIT AND Apache-2.0 AND BSD-3-Clause",
+		"MIT OR (Apache-2.0 AND GPL-2.0+)",
+		"(GPL-2.0+ WITH Classpath-exception-2.0) OR LicenseRef-x",
+		"NOASSERTION",
+		"DocumentRef-a:LicenseRef-b",
+	}
+	for i, expr := range exprs {
+		doc.Files = append(doc.Files, &File{
+			Path:             "./f",
+			SPDXIdentifier:   "SPDXRef-File" + string(rune('A'+i)),
+			LicenseConcluded: expr,
+		})
+	}
+
+	got := roundTrip(t, doc, FormatRDF)
+	if len(got.Files) != len(exprs) {
+		t.Fatalf("expected %d files, got %d", len(exprs), len(got.Files))
+	}
+	for i, f := range got.Files {
+		if f.LicenseConcluded != exprs[i] {
+			t.Errorf("expected %s, got %s", exprs[i], f.LicenseConcluded)
+		}
+	}
+}
@@ -9,9 +9,11 @@ import (
 	"unicode"
 )
 
+// tagvalue is a tag and its value, and the line number where the tag was.
 type tagvalue struct {
 	tag   string
 	value string
+	line  int
 }
 
 type spdxTVReader struct {
@@ -20,6 +22,7 @@ type spdxTVReader struct {
 	currentLine  int
 	currentTag   string
 	currentValue string
+	tagLine      int
 }
 
 func (reader *spdxTVReader) finalize() ([]tagvalue, error) {
@@ -64,6 +67,7 @@ func (reader *spdxTVReader) readNextLineFromReady(line string) error {
 
 	// the first substring is the tag
 	reader.currentTag = strings.TrimSpace(substrings[0])
+	reader.tagLine = reader.currentLine
 
 	// determine whether the value contains (or starts) a <text> line
 	substrings = strings.SplitN(substrings[1], "<text>", 2)
@@ -88,7 +92,7 @@ func (reader *spdxTVReader) readNextLineFromReady(line string) error {
 
 	// if we got here, the value was on a single line
 	// so go ahead and add it to the tag-value list
-	tv := tagvalue{reader.currentTag, reader.currentValue}
+	tv := tagvalue{reader.currentTag, reader.currentValue, reader.tagLine}
 	reader.tvList = append(reader.tvList, tv)
 
 	// and reset
@@ -109,7 +113,7 @@ func (reader *spdxTVReader) readNextLineFromMidtext(line string) error {
 
 	// contains </text>, so end and record this pair
 	reader.currentValue += substrings[0]
-	tv := tagvalue{reader.currentTag, reader.currentValue}
+	tv := tagvalue{reader.currentTag, reader.currentValue, reader.tagLine}
 	reader.tvList = append(reader.tvList, tv)
 
 	// and reset
@@ -0,0 +1,1384 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SPDX 3.0 documents are JSON-LD graphs of elements, which are connected by
+// Relationship elements rather than by fields, and which are identified by
+// IRIs rather than by SPDXRef- identifiers. They are read and written by
+// mapping them to and from the 2.x Document model:
+//
+//   - an element's IRI is the document namespace, "#" and its SPDX
+//     identifier, and IRIs in other namespaces are given identifiers that
+//     start with "SPDXRef-"
+//   - a package's files, the licenses of packages, files and snippets, and
+//     a file's LicenseInfoInFile are "contains", "hasConcludedLicense" and
+//     "hasDeclaredLicense" relationships, with license expressions as
+//     LicenseExpression elements and other licenses as CustomLicense
+//     elements
+//   - creators, suppliers, originators, file contributors and annotators
+//     are agent elements, and reviews are annotations of type "review"
+//   - 2.x relationship types are mapped to their 3.0 equivalents, some of
+//     which go the other way, such as DEPENDENCY_OF to "dependsOn"
+//
+// 2.x fields that SPDX 3.0 doesn't have, such as PackageFileName,
+// FileNotice and FileDependency, are dropped, and a package's
+// LicenseInfoFromFiles is computed from its files when read. Only the
+// compacted form that the SPDX 3.0 JSON-LD context gives, as written by
+// SPDX 3.0 tools, can be read.
+
+const (
+	spdx3Context     = "https://spdx.org/rdf/3.0.1/spdx-context.jsonld"
+	spdx3SpecVersion = "3.0.1"
+	spdx3Expanded    = "https://spdx.org/rdf/3.0.1/terms/Expanded/"
+	spdx3LicensesURI = "https://spdx.org/licenses/"
+
+	spdx3CreationInfoID = "_:creationinfo"
+)
+
+// spdx3Graph is an SPDX 3.0 JSON-LD document.
+type spdx3Graph struct {
+	Context string          `json:"@context"`
+	Graph   []*spdx3Element `json:"@graph"`
+}
+
+// spdx3Element is any node in an SPDX 3.0 graph. It has the properties of
+// all the kinds of node that are mapped to the 2.x model, and only those
+// for its Type are set. CreationInfo is either the ID of a CreationInfo
+// node, or the node itself.
+type spdx3Element struct {
+	Type         string          `json:"type"`
+	BlankID      string          `json:"@id,omitempty"`
+	SPDXID       string          `json:"spdxId,omitempty"`
+	CreationInfo json.RawMessage `json:"creationInfo,omitempty"`
+
+	// CreationInfo
+	SpecVersion  string       `json:"specVersion,omitempty"`
+	Created      string       `json:"created,omitempty"`
+	CreatedBy    spdx3Strings `json:"createdBy,omitempty"`
+	CreatedUsing spdx3Strings `json:"createdUsing,omitempty"`
+
+	// Element
+	Name               string                     `json:"name,omitempty"`
+	Summary            string                     `json:"summary,omitempty"`
+	Description        string                     `json:"description,omitempty"`
+	Comment            string                     `json:"comment,omitempty"`
+	VerifiedUsing      []*spdx3Integrity          `json:"verifiedUsing,omitempty"`
+	ExternalRef        []*spdx3ExternalRef        `json:"externalRef,omitempty"`
+	ExternalIdentifier []*spdx3ExternalIdentifier `json:"externalIdentifier,omitempty"`
+
+	// SpdxDocument
+	DataLicense        string               `json:"dataLicense,omitempty"`
+	RootElement        spdx3Strings         `json:"rootElement,omitempty"`
+	Element            spdx3Strings         `json:"element,omitempty"`
+	ProfileConformance spdx3Strings         `json:"profileConformance,omitempty"`
+	Import             []*spdx3ExternalMap  `json:"import,omitempty"`
+	NamespaceMap       []*spdx3NamespaceMap `json:"namespaceMap,omitempty"`
+
+	// Artifact
+	OriginatedBy spdx3Strings `json:"originatedBy,omitempty"`
+	SuppliedBy   string       `json:"suppliedBy,omitempty"`
+
+	// software_Package, software_File and software_Snippet
+	PackageVersion    string       `json:"software_packageVersion,omitempty"`
+	DownloadLocation  string       `json:"software_downloadLocation,omitempty"`
+	HomePage          string       `json:"software_homePage,omitempty"`
+	PackageURL        string       `json:"software_packageUrl,omitempty"`
+	SourceInfo        string       `json:"software_sourceInfo,omitempty"`
+	CopyrightText     string       `json:"software_copyrightText,omitempty"`
+	AttributionText   spdx3Strings `json:"software_attributionText,omitempty"`
+	PrimaryPurpose    string       `json:"software_primaryPurpose,omitempty"`
+	AdditionalPurpose spdx3Strings `json:"software_additionalPurpose,omitempty"`
+	ContentType       string       `json:"software_contentType,omitempty"`
+	FileKind          string       `json:"software_fileKind,omitempty"`
+	SnippetFromFile   string       `json:"software_snippetFromFile,omitempty"`
+	ByteRange         *spdx3Range  `json:"software_byteRange,omitempty"`
+	LineRange         *spdx3Range  `json:"software_lineRange,omitempty"`
+
+	// Relationship and LifecycleScopedRelationship
+	From             string       `json:"from,omitempty"`
+	RelationshipType string       `json:"relationshipType,omitempty"`
+	To               spdx3Strings `json:"to,omitempty"`
+	Completeness     string       `json:"completeness,omitempty"`
+	Scope            string       `json:"scope,omitempty"`
+
+	// Annotation
+	AnnotationType string `json:"annotationType,omitempty"`
+	Subject        string `json:"subject,omitempty"`
+	Statement      string `json:"statement,omitempty"`
+
+	// simplelicensing_LicenseExpression, simplelicensing_SimpleLicensingText
+	// and expandedlicensing_CustomLicense
+	LicenseExpression  string                  `json:"simplelicensing_licenseExpression,omitempty"`
+	LicenseListVersion string                  `json:"simplelicensing_licenseListVersion,omitempty"`
+	CustomIDToURI      []*spdx3DictionaryEntry `json:"simplelicensing_customIdToUri,omitempty"`
+	LicenseText        string                  `json:"simplelicensing_licenseText,omitempty"`
+	SeeAlso            spdx3Strings            `json:"expandedlicensing_seeAlso,omitempty"`
+}
+
+// spdx3Integrity is a Hash or a PackageVerificationCode.
+type spdx3Integrity struct {
+	Type          string       `json:"type"`
+	Algorithm     string       `json:"algorithm"`
+	HashValue     string       `json:"hashValue"`
+	ExcludedFiles spdx3Strings `json:"packageVerificationCodeExcludedFile,omitempty"`
+}
+
+type spdx3ExternalRef struct {
+	Type            string       `json:"type"`
+	ExternalRefType string       `json:"externalRefType"`
+	Locator         spdx3Strings `json:"locator"`
+	Comment         string       `json:"comment,omitempty"`
+}
+
+type spdx3ExternalIdentifier struct {
+	Type                   string `json:"type"`
+	ExternalIdentifierType string `json:"externalIdentifierType"`
+	Identifier             string `json:"identifier"`
+	Comment                string `json:"comment,omitempty"`
+}
+
+type spdx3ExternalMap struct {
+	Type           string            `json:"type"`
+	ExternalSPDXID string            `json:"externalSpdxId"`
+	VerifiedUsing  []*spdx3Integrity `json:"verifiedUsing,omitempty"`
+	LocationHint   string            `json:"locationHint,omitempty"`
+}
+
+type spdx3NamespaceMap struct {
+	Type      string `json:"type"`
+	Prefix    string `json:"prefix"`
+	Namespace string `json:"namespace"`
+}
+
+type spdx3Range struct {
+	Type  string `json:"type"`
+	Begin int    `json:"beginIntegerRange"`
+	End   int    `json:"endIntegerRange"`
+}
+
+type spdx3DictionaryEntry struct {
+	Type  string `json:"type"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// spdx3Strings is a property with any number of values, which JSON-LD
+// gives as a single string when there is only one.
+type spdx3Strings []string
+
+func (s *spdx3Strings) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*s = spdx3Strings{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*s = many
+	return nil
+}
+
+// spdx3RelationshipTypes maps 2.x relationship types to 3.0 ones. For
+// reverse types, the 3.0 relationship goes from the 2.x RefB to RefA. Where
+// several 2.x types map to the same 3.0 type and scope, the first is used
+// when reading.
+var spdx3RelationshipTypes = []struct {
+	v2      string
+	v3      string
+	scope   string
+	reverse bool
+}{
+	{"DESCRIBES", "describes", "", false},
+	{"DESCRIBED_BY", "describes", "", true},
+	{"CONTAINS", "contains", "", false},
+	{"CONTAINED_BY", "contains", "", true},
+	{"DEPENDS_ON", "dependsOn", "", false},
+	{"DEPENDENCY_OF", "dependsOn", "", true},
+	{"OPTIONAL_DEPENDENCY_OF", "dependsOn", "", true},
+	{"PROVIDED_DEPENDENCY_OF", "dependsOn", "", true},
+	{"BUILD_DEPENDENCY_OF", "dependsOn", "build", true},
+	{"DEV_DEPENDENCY_OF", "dependsOn", "development", true},
+	{"TEST_DEPENDENCY_OF", "dependsOn", "test", true},
+	{"RUNTIME_DEPENDENCY_OF", "dependsOn", "runtime", true},
+	{"DEPENDENCY_MANIFEST_OF", "hasDependencyManifest", "", true},
+	{"BUILD_TOOL_OF", "usesTool", "build", true},
+	{"DEV_TOOL_OF", "usesTool", "development", true},
+	{"TEST_TOOL_OF", "usesTool", "test", true},
+	{"GENERATES", "generates", "", false},
+	{"GENERATED_FROM", "generates", "", true},
+	{"ANCESTOR_OF", "ancestorOf", "", false},
+	{"DESCENDANT_OF", "descendantOf", "", false},
+	{"VARIANT_OF", "variantOf", "", false},
+	{"COPY_OF", "copiedTo", "", true},
+	{"EXPANDED_FROM_ARCHIVE", "expandsTo", "", true},
+	{"FILE_ADDED", "hasAddedFile", "", true},
+	{"FILE_DELETED", "hasDeletedFile", "", true},
+	{"FILE_MODIFIED", "modifiedBy", "", false},
+	{"DYNAMIC_LINK", "hasDynamicLink", "", false},
+	{"STATIC_LINK", "hasStaticLink", "", false},
+	{"DATA_FILE_OF", "hasDataFile", "", true},
+	{"TEST_CASE_OF", "hasTestCase", "", true},
+	{"TEST_OF", "hasTest", "", true},
+	{"DISTRIBUTION_ARTIFACT", "hasDistributionArtifact", "", false},
+	{"PATCH_FOR", "patchedBy", "", true},
+	{"PATCH_APPLIED", "patchedBy", "", true},
+	{"DOCUMENTATION_OF", "hasDocumentation", "", true},
+	{"OPTIONAL_COMPONENT_OF", "hasOptionalComponent", "", true},
+	{"METAFILE_OF", "hasMetadata", "", true},
+	{"AMENDS", "amendedBy", "", true},
+	{"HAS_PREREQUISITE", "hasPrerequisite", "", false},
+	{"PREREQUISITE_FOR", "hasPrerequisite", "", true},
+	{"EXAMPLE_OF", "hasExample", "", true},
+	{"REQUIREMENT_DESCRIPTION_FOR", "hasRequirement", "", true},
+	{"SPECIFICATION_FOR", "hasSpecification", "", true},
+	{"OTHER", "other", "", false},
+}
+
+// spdx3ExternalRefTypes maps 2.x external reference types to 3.0 external
+// identifier types, for identifiers, or external reference types. Other
+// 2.x types are written as "other" external references.
+var spdx3ExternalRefTypes = []struct {
+	category   string
+	v2         string
+	v3         string
+	identifier bool
+}{
+	{"PACKAGE-MANAGER", "purl", "packageUrl", true},
+	{"SECURITY", "cpe22Type", "cpe22", true},
+	{"SECURITY", "cpe23Type", "cpe23", true},
+	{"PERSISTENT-ID", "swh", "swhid", true},
+	{"PERSISTENT-ID", "gitoid", "gitoid", true},
+	{"SECURITY", "advisory", "securityAdvisory", false},
+	{"SECURITY", "fix", "securityFix", false},
+	{"SECURITY", "url", "securityOther", false},
+	{"PACKAGE-MANAGER", "maven-central", "mavenCentral", false},
+	{"PACKAGE-MANAGER", "npm", "npm", false},
+	{"PACKAGE-MANAGER", "nuget", "nuget", false},
+	{"PACKAGE-MANAGER", "bower", "bower", false},
+}
+
+// spdx3FileTypes maps 2.x file types to 3.0 software purposes, or for
+// types that describe a file's content rather than its purpose, to a media
+// type.
+var spdx3FileTypes = []struct {
+	v2          string
+	purpose     string
+	contentType string
+}{
+	{"SOURCE", "source", ""},
+	{"BINARY", "executable", ""},
+	{"BINARY", "library", ""},
+	{"ARCHIVE", "archive", ""},
+	{"APPLICATION", "application", ""},
+	{"DOCUMENTATION", "documentation", ""},
+	{"SPDX", "bom", ""},
+	{"OTHER", "other", ""},
+	{"TEXT", "", "text/*"},
+	{"IMAGE", "", "image/*"},
+	{"AUDIO", "", "audio/*"},
+	{"VIDEO", "", "video/*"},
+}
+
+// spdx3HashAlgorithm returns the 3.0 name of a 2.x checksum algorithm,
+// such as "sha3_256" for "SHA3-256".
+func spdx3HashAlgorithm(alg string) string {
+	alg = strings.ToLower(alg)
+	if strings.HasPrefix(alg, "sha3-") {
+		return strings.ReplaceAll(alg, "-", "_")
+	}
+	return strings.ReplaceAll(alg, "-", "")
+}
+
+// spdx2ChecksumAlgorithm is the inverse of spdx3HashAlgorithm.
+func spdx2ChecksumAlgorithm(alg string) string {
+	if n, ok := strings.CutPrefix(alg, "blake2b"); ok {
+		return "BLAKE2b-" + n
+	}
+	return strings.ToUpper(strings.ReplaceAll(alg, "_", "-"))
+}
+
+// WriteSPDX3 writes doc to w as an SPDX 3.0 JSON-LD document. Since the
+// IRIs of its elements are made from doc's DocumentNamespace, doc must have
+// one.
+func WriteSPDX3(w io.Writer, doc *Document) error {
+	ci := doc.CreationInfo
+	if ci.DocumentNamespace == "" {
+		return fmt.Errorf("document has no DocumentNamespace, which SPDX 3.0 needs for its element IDs")
+	}
+	sw := &spdx3Writer{
+		doc:       doc,
+		namespace: ci.DocumentNamespace,
+		edrs:      map[string]string{},
+		agents:    map[Creator]string{},
+		exprs:     map[string]string{},
+		fileIRIs:  map[*File]string{},
+	}
+	for _, edr := range ci.ExternalDocumentRefs {
+		sw.edrs[edr.ID] = edr.URI
+	}
+	g := sw.graph()
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// spdx3Writer builds an SPDX 3.0 graph for a document. Agents and license
+// expressions become elements the first time they are used, and elems
+// holds those, and then the document's other elements, in order.
+type spdx3Writer struct {
+	doc       *Document
+	namespace string
+	edrs      map[string]string
+	agents    map[Creator]string
+	exprs     map[string]string
+	fileIRIs  map[*File]string
+
+	agentElems []*spdx3Element
+	exprElems  []*spdx3Element
+	elems      []*spdx3Element
+	rlnCount   int
+}
+
+// iri returns the IRI of the element with the given SPDX identifier, which
+// may be in an external document.
+func (sw *spdx3Writer) iri(id string) string {
+	if docRef, frag, found := strings.Cut(id, ":"); found {
+		if base, ok := sw.edrs[docRef]; ok {
+			return base + "#" + frag
+		}
+	}
+	return sw.namespace + "#" + id
+}
+
+// creationInfoRef returns the ID of the document's CreationInfo node,
+// which every element refers to.
+func creationInfoRef() json.RawMessage {
+	return json.RawMessage(strconv.Quote(spdx3CreationInfoID))
+}
+
+func (sw *spdx3Writer) newElement(typ string, iri string) *spdx3Element {
+	return &spdx3Element{Type: typ, SPDXID: iri, CreationInfo: creationInfoRef()}
+}
+
+// agent returns the IRI of the agent element for c, adding it if it's new.
+func (sw *spdx3Writer) agent(c Creator) string {
+	if iri, ok := sw.agents[c]; ok {
+		return iri
+	}
+	typ := c.Type
+	switch typ {
+	case "Person", "Organization", "Tool":
+	default:
+		typ = "Agent"
+	}
+	iri := sw.namespace + "#" + typ + "-" + strconv.Itoa(len(sw.agentElems)+1)
+	e := sw.newElement(typ, iri)
+	e.Name = c.Name
+	sw.agents[c] = iri
+	sw.agentElems = append(sw.agentElems, e)
+	return iri
+}
+
+// agentFromValue returns the IRI of the agent for a 2.x value such as
+// "Organization: Acme", or "" if the value doesn't name one, such as
+// "NOASSERTION".
+func (sw *spdx3Writer) agentFromValue(value string) string {
+	c, err := parseCreator(value)
+	if err != nil {
+		return ""
+	}
+	return sw.agent(c)
+}
+
+// creators splits cs into the IRIs of agents, for createdBy, and of tools,
+// for createdUsing.
+func (sw *spdx3Writer) creators(cs []Creator) (spdx3Strings, spdx3Strings) {
+	var by, using spdx3Strings
+	for _, c := range cs {
+		if c.Type == "Tool" {
+			using = append(using, sw.agent(c))
+		} else {
+			by = append(by, sw.agent(c))
+		}
+	}
+	return by, using
+}
+
+// license returns the IRI of the license element for a 2.x license
+// expression, adding a LicenseExpression element if it's new.
+func (sw *spdx3Writer) license(expr string) string {
+	switch expr {
+	case "NONE":
+		return spdx3Expanded + "NoneLicense"
+	case "NOASSERTION":
+		return spdx3Expanded + "NoAssertionLicense"
+	}
+	if iri, ok := sw.exprs[expr]; ok {
+		return iri
+	}
+	iri := sw.namespace + "#LicenseExpression-" + strconv.Itoa(len(sw.exprElems)+1)
+	e := sw.newElement("simplelicensing_LicenseExpression", iri)
+	e.LicenseExpression = expr
+	e.LicenseListVersion = sw.doc.CreationInfo.LicenseListVersion
+	tokens := strings.FieldsFunc(expr, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '(' || r == ')'
+	})
+	for _, ol := range sw.doc.OtherLicenses {
+		for _, tok := range tokens {
+			if tok == ol.LicenseID {
+				e.CustomIDToURI = append(e.CustomIDToURI, &spdx3DictionaryEntry{
+					Type:  "DictionaryEntry",
+					Key:   ol.LicenseID,
+					Value: sw.iri(ol.LicenseID),
+				})
+				break
+			}
+		}
+	}
+	sw.exprs[expr] = iri
+	sw.exprElems = append(sw.exprElems, e)
+	return iri
+}
+
+// addRelationship adds a relationship element from one IRI to others, or
+// to nothing with the given completeness if to is empty.
+func (sw *spdx3Writer) addRelationship(typ string, scope string, from string, to []string, completeness string, comment string) {
+	sw.rlnCount++
+	elemType := "Relationship"
+	if scope != "" {
+		elemType = "LifecycleScopedRelationship"
+	}
+	e := sw.newElement(elemType, sw.namespace+"#Relationship-"+strconv.Itoa(sw.rlnCount))
+	e.From = from
+	e.RelationshipType = typ
+	e.To = to
+	if len(to) == 0 {
+		e.Completeness = completeness
+	}
+	e.Scope = scope
+	e.Comment = comment
+	sw.elems = append(sw.elems, e)
+}
+
+// addLicenses adds the hasConcludedLicense and hasDeclaredLicense
+// relationships for an element.
+func (sw *spdx3Writer) addLicenses(from string, concluded string, comments string, declared []string) {
+	if concluded != "" {
+		sw.addRelationship("hasConcludedLicense", "", from, []string{sw.license(concluded)}, "", comments)
+	}
+	for _, expr := range declared {
+		sw.addRelationship("hasDeclaredLicense", "", from, []string{sw.license(expr)}, "", "")
+	}
+}
+
+func (sw *spdx3Writer) graph() *spdx3Graph {
+	doc := sw.doc
+	ci := doc.CreationInfo
+	docIRI := sw.iri(ci.SPDXIdentifier)
+	if ci.SPDXIdentifier == "" {
+		docIRI = sw.iri("SPDXRef-DOCUMENT")
+	}
+
+	createdBy, createdUsing := sw.creators(ci.Creators)
+	creationInfo := &spdx3Element{
+		Type:         "CreationInfo",
+		BlankID:      spdx3CreationInfoID,
+		SpecVersion:  spdx3SpecVersion,
+		Created:      ci.Created,
+		CreatedBy:    createdBy,
+		CreatedUsing: createdUsing,
+		Comment:      ci.CreatorComment,
+	}
+
+	spdxDoc := sw.newElement("SpdxDocument", docIRI)
+	spdxDoc.Name = ci.DocumentName
+	spdxDoc.Comment = ci.DocumentComment
+	spdxDoc.ProfileConformance = spdx3Strings{"core", "software", "simpleLicensing", "expandedLicensing"}
+	if ci.DataLicense != "" {
+		spdxDoc.DataLicense = spdx3LicensesURI + ci.DataLicense
+	}
+	for _, edr := range ci.ExternalDocumentRefs {
+		em := &spdx3ExternalMap{
+			Type:           "ExternalMap",
+			ExternalSPDXID: edr.URI + "#SPDXRef-DOCUMENT",
+			LocationHint:   edr.URI,
+		}
+		if edr.Checksum != "" {
+			em.VerifiedUsing = []*spdx3Integrity{{
+				Type:      "Hash",
+				Algorithm: spdx3HashAlgorithm(edr.ChecksumAlgorithm),
+				HashValue: edr.Checksum,
+			}}
+		}
+		spdxDoc.Import = append(spdxDoc.Import, em)
+		spdxDoc.NamespaceMap = append(spdxDoc.NamespaceMap, &spdx3NamespaceMap{
+			Type:      "NamespaceMap",
+			Prefix:    edr.ID,
+			Namespace: edr.URI + "#",
+		})
+	}
+
+	for i, f := range doc.Files {
+		id := f.SPDXIdentifier
+		if id == "" {
+			id = "SPDXRef-File-" + strconv.Itoa(i+1)
+		}
+		sw.fileIRIs[f] = sw.iri(id)
+	}
+	for _, pkg := range doc.Packages {
+		sw.elems = append(sw.elems, sw.packageElement(pkg))
+	}
+	for _, f := range doc.Files {
+		sw.elems = append(sw.elems, sw.fileElement(f))
+	}
+	for _, sn := range doc.Snippets {
+		sw.elems = append(sw.elems, sw.snippetElement(sn))
+	}
+	for _, ol := range doc.OtherLicenses {
+		e := sw.newElement("expandedlicensing_CustomLicense", sw.iri(ol.LicenseID))
+		e.Name = ol.Name
+		e.LicenseText = ol.ExtractedText
+		e.SeeAlso = ol.CrossReferences
+		e.Comment = ol.Comment
+		sw.elems = append(sw.elems, e)
+	}
+
+	// packages' files and elements' licenses are relationships in 3.0
+	for _, pkg := range doc.Packages {
+		pkgIRI := sw.iri(pkg.SPDXIdentifier)
+		if len(pkg.Files) > 0 {
+			var to []string
+			for _, f := range pkg.Files {
+				to = append(to, sw.fileIRIs[f])
+			}
+			sw.addRelationship("contains", "", pkgIRI, to, "", "")
+		}
+		var declared []string
+		if pkg.LicenseDeclared != "" {
+			declared = []string{pkg.LicenseDeclared}
+		}
+		sw.addLicenses(pkgIRI, pkg.LicenseConcluded, pkg.LicenseComments, declared)
+	}
+	for _, f := range doc.Files {
+		sw.addLicenses(sw.fileIRIs[f], f.LicenseConcluded, f.LicenseComments, f.LicenseInfoInFile)
+	}
+	for _, sn := range doc.Snippets {
+		sw.addLicenses(sw.iri(sn.SPDXIdentifier), sn.LicenseConcluded, sn.LicenseComments, sn.LicenseInfoInSnippet)
+	}
+
+	// the document's DESCRIBES relationships are its root elements, and
+	// are only also written as relationships to keep their comments;
+	// CONTAINS relationships for a package's files are already written
+	inPackage := map[[2]string]bool{}
+	for _, pkg := range doc.Packages {
+		for _, f := range pkg.Files {
+			inPackage[[2]string{pkg.SPDXIdentifier, f.SPDXIdentifier}] = true
+		}
+	}
+	for _, rln := range doc.Relationships {
+		if rln.RefA == ci.SPDXIdentifier && rln.Type == "DESCRIBES" && rln.RefB != "NONE" && rln.RefB != "NOASSERTION" {
+			spdxDoc.RootElement = append(spdxDoc.RootElement, sw.iri(rln.RefB))
+			if rln.Comment == "" {
+				continue
+			}
+		}
+		if rln.Type == "CONTAINS" && inPackage[[2]string{rln.RefA, rln.RefB}] {
+			continue
+		}
+		sw.addRelationshipFromV2(rln)
+	}
+
+	for i, ann := range doc.Annotations {
+		typ := "other"
+		if ann.Type == "REVIEW" {
+			typ = "review"
+		}
+		sw.addAnnotation(i, typ, ann.Annotator, ann.Date, sw.iri(ann.SPDXIdentifier), ann.Comment)
+	}
+	for i, rev := range doc.Reviews {
+		sw.addAnnotation(len(doc.Annotations)+i, "review", rev.Reviewer, rev.Date, docIRI, rev.Comment)
+	}
+
+	elems := append(append(sw.agentElems, sw.exprElems...), sw.elems...)
+	for _, e := range elems {
+		spdxDoc.Element = append(spdxDoc.Element, e.SPDXID)
+	}
+	g := &spdx3Graph{Context: spdx3Context}
+	g.Graph = append(g.Graph, creationInfo)
+	g.Graph = append(g.Graph, spdxDoc)
+	g.Graph = append(g.Graph, elems...)
+	return g
+}
+
+// addRelationshipFromV2 adds the 3.0 relationship for a 2.x one. Types
+// without a 3.0 equivalent are written as "other", with the 2.x type in
+// the comment if there isn't one already.
+func (sw *spdx3Writer) addRelationshipFromV2(rln *Relationship) {
+	typ, scope, reverse := "other", "", false
+	comment := rln.Comment
+	found := false
+	for _, rt := range spdx3RelationshipTypes {
+		if rt.v2 == rln.Type {
+			typ, scope, reverse = rt.v3, rt.scope, rt.reverse
+			found = true
+			break
+		}
+	}
+	if !found && comment == "" {
+		comment = "SPDX 2 relationship type " + rln.Type
+	}
+
+	from, to := rln.RefA, rln.RefB
+	if reverse {
+		from, to = to, from
+	}
+	switch to {
+	case "NONE":
+		sw.addRelationship(typ, scope, sw.iri(from), nil, "complete", comment)
+	case "NOASSERTION":
+		sw.addRelationship(typ, scope, sw.iri(from), nil, "noAssertion", comment)
+	default:
+		sw.addRelationship(typ, scope, sw.iri(from), []string{sw.iri(to)}, "", comment)
+	}
+}
+
+// addAnnotation adds an annotation, which has its own creation info for
+// who made it and when.
+func (sw *spdx3Writer) addAnnotation(i int, typ string, annotator Creator, date string, subject string, statement string) {
+	by, using := sw.creators([]Creator{annotator})
+	ci, _ := json.Marshal(&spdx3Element{
+		Type:         "CreationInfo",
+		SpecVersion:  spdx3SpecVersion,
+		Created:      date,
+		CreatedBy:    by,
+		CreatedUsing: using,
+	})
+	sw.elems = append(sw.elems, &spdx3Element{
+		Type:           "Annotation",
+		SPDXID:         sw.namespace + "#Annotation-" + strconv.Itoa(i+1),
+		CreationInfo:   ci,
+		AnnotationType: typ,
+		Subject:        subject,
+		Statement:      statement,
+	})
+}
+
+func spdx3Hashes(checksums map[string]string) []*spdx3Integrity {
+	var hs []*spdx3Integrity
+	for _, alg := range checksumOrder(checksums) {
+		hs = append(hs, &spdx3Integrity{Type: "Hash", Algorithm: spdx3HashAlgorithm(alg), HashValue: checksums[alg]})
+	}
+	return hs
+}
+
+// spdx3Value returns value, or "" for "NOASSERTION", which 3.0 gives by
+// leaving a property out.
+func spdx3Value(value string) string {
+	if value == "NOASSERTION" {
+		return ""
+	}
+	return value
+}
+
+func (sw *spdx3Writer) packageElement(pkg *Package) *spdx3Element {
+	e := sw.newElement("software_Package", sw.iri(pkg.SPDXIdentifier))
+	e.Name = pkg.Name
+	e.PackageVersion = pkg.Version
+	e.DownloadLocation = spdx3Value(pkg.DownloadLocation)
+	e.HomePage = spdx3Value(pkg.HomePage)
+	e.SourceInfo = pkg.SourceInfo
+	e.CopyrightText = pkg.CopyrightText
+	e.Summary = pkg.Summary
+	e.Description = pkg.Description
+	e.Comment = pkg.Comment
+	e.AttributionText = pkg.AttributionTexts
+	e.SuppliedBy = sw.agentFromValue(pkg.Supplier)
+	if originator := sw.agentFromValue(pkg.Originator); originator != "" {
+		e.OriginatedBy = spdx3Strings{originator}
+	}
+	if pkg.VerificationCode.Value != "" {
+		e.VerifiedUsing = append(e.VerifiedUsing, &spdx3Integrity{
+			Type:          "PackageVerificationCode",
+			Algorithm:     "sha1",
+			HashValue:     pkg.VerificationCode.Value,
+			ExcludedFiles: pkg.VerificationCode.ExcludedFiles,
+		})
+	}
+	e.VerifiedUsing = append(e.VerifiedUsing, spdx3Hashes(pkg.Checksums)...)
+
+	for _, er := range pkg.ExternalRefs {
+		// the package URL property has no comment, so only a purl without
+		// one goes there
+		if er.Type == "purl" && er.Comment == "" && e.PackageURL == "" {
+			e.PackageURL = er.Locator
+			continue
+		}
+		v3, identifier := "other", false
+		for _, ert := range spdx3ExternalRefTypes {
+			if ert.v2 == er.Type {
+				v3, identifier = ert.v3, ert.identifier
+				break
+			}
+		}
+		if identifier {
+			e.ExternalIdentifier = append(e.ExternalIdentifier, &spdx3ExternalIdentifier{
+				Type:                   "ExternalIdentifier",
+				ExternalIdentifierType: v3,
+				Identifier:             er.Locator,
+				Comment:                er.Comment,
+			})
+		} else {
+			e.ExternalRef = append(e.ExternalRef, &spdx3ExternalRef{
+				Type:            "ExternalRef",
+				ExternalRefType: v3,
+				Locator:         spdx3Strings{er.Locator},
+				Comment:         er.Comment,
+			})
+		}
+	}
+	return e
+}
+
+func (sw *spdx3Writer) fileElement(f *File) *spdx3Element {
+	e := sw.newElement("software_File", sw.fileIRIs[f])
+	e.Name = f.Path
+	e.FileKind = "file"
+	e.VerifiedUsing = spdx3Hashes(f.Checksums)
+	e.CopyrightText = f.CopyrightText
+	e.AttributionText = f.AttributionTexts
+	e.Comment = f.Comment
+	for _, ft := range f.FileTypes {
+		for _, t := range spdx3FileTypes {
+			if t.v2 != ft {
+				continue
+			}
+			switch {
+			case t.purpose != "" && e.PrimaryPurpose == "":
+				e.PrimaryPurpose = t.purpose
+			case t.purpose != "":
+				e.AdditionalPurpose = append(e.AdditionalPurpose, t.purpose)
+			case e.ContentType == "":
+				e.ContentType = t.contentType
+			}
+			break
+		}
+	}
+	for _, name := range f.Contributors {
+		e.OriginatedBy = append(e.OriginatedBy, sw.agent(Creator{Type: "Person", Name: name}))
+	}
+	return e
+}
+
+func (sw *spdx3Writer) snippetElement(sn *Snippet) *spdx3Element {
+	e := sw.newElement("software_Snippet", sw.iri(sn.SPDXIdentifier))
+	e.Name = sn.Name
+	e.SnippetFromFile = sw.iri(sn.FromFile)
+	e.CopyrightText = sn.CopyrightText
+	e.AttributionText = sn.AttributionTexts
+	e.Comment = sn.Comment
+	if sn.ByteRange.Start != 0 {
+		e.ByteRange = &spdx3Range{Type: "PositiveIntegerRange", Begin: sn.ByteRange.Start, End: sn.ByteRange.End}
+	}
+	if sn.LineRange.Start != 0 {
+		e.LineRange = &spdx3Range{Type: "PositiveIntegerRange", Begin: sn.LineRange.Start, End: sn.LineRange.End}
+	}
+	return e
+}
+
+// ParseSPDX3 reads an SPDX 3.0 JSON-LD document from r, and maps it to the
+// 2.x model as an SPDX-2.3 document. Syntax errors in the document are
+// returned as a *ParseError.
+func ParseSPDX3(r io.Reader) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var g spdx3Graph
+	err = json.Unmarshal(data, &g)
+	if err != nil {
+		return nil, jsonParseError(data, err)
+	}
+
+	sr := &spdx3Reader{
+		nodes:    map[string]*spdx3Element{},
+		ids:      map[string]string{},
+		usedIDs:  map[string]bool{},
+		packages: map[string]*Package{},
+		files:    map[string]*File{},
+		snippets: map[string]*Snippet{},
+	}
+	var spdxDoc *spdx3Element
+	for _, e := range g.Graph {
+		if e.SPDXID != "" {
+			sr.nodes[e.SPDXID] = e
+		}
+		if e.BlankID != "" {
+			sr.nodes[e.BlankID] = e
+		}
+		if e.Type == "SpdxDocument" {
+			if spdxDoc != nil {
+				return nil, fmt.Errorf("document has more than one SpdxDocument element")
+			}
+			spdxDoc = e
+		}
+	}
+	if spdxDoc == nil {
+		return nil, fmt.Errorf("document has no SpdxDocument element")
+	}
+	err = sr.readDocument(spdxDoc, g.Graph)
+	if err != nil {
+		return nil, err
+	}
+	return sr.doc, nil
+}
+
+// spdx3Reader maps an SPDX 3.0 graph to a Document. ids holds the SPDX
+// identifier given to each element IRI.
+type spdx3Reader struct {
+	doc       *Document
+	namespace string
+	nsMaps    []*spdx3NamespaceMap
+	nodes     map[string]*spdx3Element
+	ids       map[string]string
+	usedIDs   map[string]bool
+	packages  map[string]*Package
+	files     map[string]*File
+	snippets  map[string]*Snippet
+}
+
+// id returns the SPDX identifier for an element IRI. IRIs in an imported
+// document's namespace are prefixed with its DocumentRef-, and others are
+// identified by the end of the IRI, which is kept as is if it's already an
+// SPDXRef- identifier.
+func (sr *spdx3Reader) id(iri string) string {
+	if id, ok := sr.ids[iri]; ok {
+		return id
+	}
+	for _, nm := range sr.nsMaps {
+		if rest, ok := strings.CutPrefix(iri, nm.Namespace); ok && nm.Namespace != "" {
+			id := spdx3DocumentRef(nm.Prefix) + ":" + rest
+			sr.ids[iri] = id
+			return id
+		}
+	}
+
+	return sr.newID(iri, "SPDXRef-")
+}
+
+// newID gives iri a new identifier that starts with prefix, made from the
+// end of the IRI, and unique in the document.
+func (sr *spdx3Reader) newID(iri string, prefix string) string {
+	frag := iri
+	if i := strings.LastIndexAny(iri, "#/:"); i >= 0 {
+		frag = iri[i+1:]
+	}
+	frag = strings.Map(func(r rune) rune {
+		if r < 128 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-') {
+			return r
+		}
+		return '-'
+	}, frag)
+	if !strings.HasPrefix(frag, prefix) {
+		frag = prefix + frag
+	}
+	id := frag
+	for n := 2; sr.usedIDs[id]; n++ {
+		id = frag + "-" + strconv.Itoa(n)
+	}
+	sr.ids[iri] = id
+	sr.usedIDs[id] = true
+	return id
+}
+
+// spdx3DocumentRef returns a namespace prefix as a 2.x DocumentRef- ID.
+func spdx3DocumentRef(prefix string) string {
+	if strings.HasPrefix(prefix, "DocumentRef-") {
+		return prefix
+	}
+	return "DocumentRef-" + prefix
+}
+
+// creationInfo returns the CreationInfo node that e refers to or holds.
+func (sr *spdx3Reader) creationInfo(e *spdx3Element) (*spdx3Element, error) {
+	if len(e.CreationInfo) == 0 {
+		return &spdx3Element{}, nil
+	}
+	var ref string
+	if err := json.Unmarshal(e.CreationInfo, &ref); err == nil {
+		ci, ok := sr.nodes[ref]
+		if !ok {
+			return nil, fmt.Errorf("element %s has unknown creationInfo %s", e.SPDXID, ref)
+		}
+		return ci, nil
+	}
+	var ci spdx3Element
+	if err := json.Unmarshal(e.CreationInfo, &ci); err != nil {
+		return nil, fmt.Errorf("element %s has invalid creationInfo: %v", e.SPDXID, err)
+	}
+	return &ci, nil
+}
+
+// creator returns the 2.x Creator for an agent IRI.
+func (sr *spdx3Reader) creator(iri string) Creator {
+	e, ok := sr.nodes[iri]
+	if !ok {
+		return Creator{Type: "Person", Name: iri}
+	}
+	switch e.Type {
+	case "Organization":
+		return Creator{Type: "Organization", Name: e.Name}
+	case "Tool", "SoftwareAgent":
+		return Creator{Type: "Tool", Name: e.Name}
+	}
+	return Creator{Type: "Person", Name: e.Name}
+}
+
+func (sr *spdx3Reader) creators(ci *spdx3Element) []Creator {
+	var cs []Creator
+	for _, iri := range ci.CreatedBy {
+		cs = append(cs, sr.creator(iri))
+	}
+	for _, iri := range ci.CreatedUsing {
+		cs = append(cs, sr.creator(iri))
+	}
+	return cs
+}
+
+// license returns the 2.x license expression for a license IRI.
+func (sr *spdx3Reader) license(iri string) string {
+	if e, ok := sr.nodes[iri]; ok {
+		switch e.Type {
+		case "simplelicensing_LicenseExpression":
+			return e.LicenseExpression
+		case "expandedlicensing_CustomLicense", "simplelicensing_SimpleLicensingText":
+			return sr.id(iri)
+		}
+	}
+	switch {
+	case strings.HasSuffix(iri, "NoAssertionLicense"):
+		return "NOASSERTION"
+	case strings.HasSuffix(iri, "NoneLicense"):
+		return "NONE"
+	}
+	return iri[strings.LastIndex(iri, "/")+1:]
+}
+
+func (sr *spdx3Reader) readDocument(spdxDoc *spdx3Element, graph []*spdx3Element) error {
+	sr.namespace, _, _ = strings.Cut(spdxDoc.SPDXID, "#")
+	sr.nsMaps = spdxDoc.NamespaceMap
+	sr.ids[spdxDoc.SPDXID] = "SPDXRef-DOCUMENT"
+	sr.usedIDs["SPDXRef-DOCUMENT"] = true
+
+	ci, err := sr.creationInfo(spdxDoc)
+	if err != nil {
+		return err
+	}
+	sr.doc = &Document{CreationInfo: CreationInfo{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       spdxDoc.DataLicense[strings.LastIndex(spdxDoc.DataLicense, "/")+1:],
+		SPDXIdentifier:    "SPDXRef-DOCUMENT",
+		DocumentName:      spdxDoc.Name,
+		DocumentNamespace: sr.namespace,
+		Creators:          sr.creators(ci),
+		Created:           ci.Created,
+		CreatorComment:    ci.Comment,
+		DocumentComment:   spdxDoc.Comment,
+	}}
+	doc := sr.doc
+	for _, nm := range spdxDoc.NamespaceMap {
+		edr := ExternalDocumentRef{
+			ID:  spdx3DocumentRef(nm.Prefix),
+			URI: strings.TrimSuffix(nm.Namespace, "#"),
+		}
+		for _, em := range spdxDoc.Import {
+			if !strings.HasPrefix(em.ExternalSPDXID, nm.Namespace) {
+				continue
+			}
+			for _, h := range em.VerifiedUsing {
+				if h.Type == "Hash" {
+					edr.ChecksumAlgorithm = spdx2ChecksumAlgorithm(h.Algorithm)
+					edr.Checksum = strings.ToLower(h.HashValue)
+					break
+				}
+			}
+		}
+		doc.CreationInfo.ExternalDocumentRefs = append(doc.CreationInfo.ExternalDocumentRefs, edr)
+	}
+
+	// elements first, then the relationships between them, which may come
+	// before them in the graph
+	for _, e := range graph {
+		switch e.Type {
+		case "software_Package":
+			sr.readPackage(e)
+		case "software_File":
+			if e.FileKind != "directory" {
+				sr.readFile(e)
+			}
+		case "software_Snippet":
+			sr.readSnippet(e)
+		case "expandedlicensing_CustomLicense", "simplelicensing_SimpleLicensingText":
+			sr.readOtherLicense(e, graph)
+		case "simplelicensing_LicenseExpression":
+			if doc.CreationInfo.LicenseListVersion == "" {
+				doc.CreationInfo.LicenseListVersion = e.LicenseListVersion
+			}
+		}
+	}
+	for _, e := range graph {
+		switch e.Type {
+		case "Relationship", "LifecycleScopedRelationship":
+			sr.readRelationship(e)
+		case "Annotation":
+			err = sr.readAnnotation(e)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// root elements are DESCRIBES relationships, unless the document has a
+	// describes relationship for them already
+	var describes []*Relationship
+	for _, iri := range spdxDoc.RootElement {
+		id := sr.id(iri)
+		found := false
+		for _, rln := range doc.Relationships {
+			if rln.RefA == "SPDXRef-DOCUMENT" && rln.Type == "DESCRIBES" && rln.RefB == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			describes = append(describes, &Relationship{RefA: "SPDXRef-DOCUMENT", Type: "DESCRIBES", RefB: id})
+		}
+	}
+	doc.Relationships = append(describes, doc.Relationships...)
+
+	// 3.0 has no fields for these, so they are filled in from the rest of
+	// the document
+	for _, pkg := range doc.Packages {
+		pkg.FilesAnalyzed = len(pkg.Files) > 0 || pkg.VerificationCode.Value != ""
+		seen := map[string]bool{}
+		for _, f := range pkg.Files {
+			for _, lic := range f.LicenseInfoInFile {
+				if !seen[lic] {
+					seen[lic] = true
+					pkg.LicenseInfoFromFiles = append(pkg.LicenseInfoFromFiles, lic)
+				}
+			}
+		}
+		sort.Strings(pkg.LicenseInfoFromFiles)
+		if pkg.LicenseConcluded == "" {
+			pkg.LicenseConcluded = "NOASSERTION"
+		}
+		if pkg.LicenseDeclared == "" {
+			pkg.LicenseDeclared = "NOASSERTION"
+		}
+	}
+	for _, f := range doc.Files {
+		if f.LicenseConcluded == "" {
+			f.LicenseConcluded = "NOASSERTION"
+		}
+	}
+	return nil
+}
+
+// spdx3Checksums returns the 2.x checksums for hashes, and the package
+// verification code if there is one.
+func spdx3Checksums(hs []*spdx3Integrity) (map[string]string, VerificationCode) {
+	var checksums map[string]string
+	var vc VerificationCode
+	for _, h := range hs {
+		switch h.Type {
+		case "Hash":
+			if checksums == nil {
+				checksums = map[string]string{}
+			}
+			checksums[spdx2ChecksumAlgorithm(h.Algorithm)] = strings.ToLower(h.HashValue)
+		case "PackageVerificationCode":
+			vc = VerificationCode{Value: strings.ToLower(h.HashValue), ExcludedFiles: h.ExcludedFiles}
+		}
+	}
+	return checksums, vc
+}
+
+// spdx2Value returns value, or "NOASSERTION" if it's missing.
+func spdx2Value(value string) string {
+	if value == "" {
+		return "NOASSERTION"
+	}
+	return value
+}
+
+func (sr *spdx3Reader) readPackage(e *spdx3Element) {
+	checksums, vc := spdx3Checksums(e.VerifiedUsing)
+	pkg := &Package{
+		Name:             e.Name,
+		SPDXIdentifier:   sr.id(e.SPDXID),
+		Version:          e.PackageVersion,
+		DownloadLocation: spdx2Value(e.DownloadLocation),
+		VerificationCode: vc,
+		Checksums:        checksums,
+		HomePage:         e.HomePage,
+		SourceInfo:       e.SourceInfo,
+		CopyrightText:    spdx2Value(e.CopyrightText),
+		Summary:          e.Summary,
+		Description:      e.Description,
+		Comment:          e.Comment,
+		AttributionTexts: e.AttributionText,
+	}
+	if e.SuppliedBy != "" {
+		pkg.Supplier = sr.creator(e.SuppliedBy).String()
+	}
+	if len(e.OriginatedBy) > 0 {
+		pkg.Originator = sr.creator(e.OriginatedBy[0]).String()
+	}
+	if e.PackageURL != "" {
+		pkg.ExternalRefs = append(pkg.ExternalRefs, &ExternalRef{Category: "PACKAGE-MANAGER", Type: "purl", Locator: e.PackageURL})
+	}
+	for _, ei := range e.ExternalIdentifier {
+		pkg.ExternalRefs = append(pkg.ExternalRefs, spdx2ExternalRef(ei.ExternalIdentifierType, true, ei.Identifier, ei.Comment))
+	}
+	for _, er := range e.ExternalRef {
+		for _, loc := range er.Locator {
+			pkg.ExternalRefs = append(pkg.ExternalRefs, spdx2ExternalRef(er.ExternalRefType, false, loc, er.Comment))
+		}
+	}
+	sr.doc.Packages = append(sr.doc.Packages, pkg)
+	sr.packages[e.SPDXID] = pkg
+}
+
+// spdx2ExternalRef returns the 2.x external reference for a 3.0 external
+// identifier or reference.
+func spdx2ExternalRef(v3 string, identifier bool, locator string, comment string) *ExternalRef {
+	for _, ert := range spdx3ExternalRefTypes {
+		if ert.v3 == v3 && ert.identifier == identifier {
+			return &ExternalRef{Category: ert.category, Type: ert.v2, Locator: locator, Comment: comment}
+		}
+	}
+	return &ExternalRef{Category: "OTHER", Type: v3, Locator: locator, Comment: comment}
+}
+
+func (sr *spdx3Reader) readFile(e *spdx3Element) {
+	checksums, _ := spdx3Checksums(e.VerifiedUsing)
+	f := &File{
+		Path:             e.Name,
+		SPDXIdentifier:   sr.id(e.SPDXID),
+		Checksums:        checksums,
+		CopyrightText:    spdx2Value(e.CopyrightText),
+		AttributionTexts: e.AttributionText,
+		Comment:          e.Comment,
+	}
+	purposes := append(spdx3Strings{e.PrimaryPurpose}, e.AdditionalPurpose...)
+	for _, p := range purposes {
+		if p == "" {
+			continue
+		}
+		ft := "OTHER"
+		for _, t := range spdx3FileTypes {
+			if t.purpose == p {
+				ft = t.v2
+				break
+			}
+		}
+		f.FileTypes = append(f.FileTypes, ft)
+	}
+	if mediaType, _, ok := strings.Cut(e.ContentType, "/"); ok {
+		for _, t := range spdx3FileTypes {
+			if t.contentType == mediaType+"/*" {
+				f.FileTypes = append(f.FileTypes, t.v2)
+			}
+		}
+	}
+	for _, iri := range e.OriginatedBy {
+		f.Contributors = append(f.Contributors, sr.creator(iri).Name)
+	}
+	sr.doc.Files = append(sr.doc.Files, f)
+	sr.files[e.SPDXID] = f
+}
+
+func (sr *spdx3Reader) readSnippet(e *spdx3Element) {
+	sn := &Snippet{
+		SPDXIdentifier:   sr.id(e.SPDXID),
+		FromFile:         sr.id(e.SnippetFromFile),
+		CopyrightText:    spdx2Value(e.CopyrightText),
+		Comment:          e.Comment,
+		Name:             e.Name,
+		AttributionTexts: e.AttributionText,
+	}
+	if e.ByteRange != nil {
+		sn.ByteRange = Range{Start: e.ByteRange.Begin, End: e.ByteRange.End}
+	}
+	if e.LineRange != nil {
+		sn.LineRange = Range{Start: e.LineRange.Begin, End: e.LineRange.End}
+	}
+	sr.doc.Snippets = append(sr.doc.Snippets, sn)
+	sr.snippets[e.SPDXID] = sn
+}
+
+// readOtherLicense reads a custom license. Its LicenseID is the key that
+// license expressions use for it, if they map one to it.
+func (sr *spdx3Reader) readOtherLicense(e *spdx3Element, graph []*spdx3Element) {
+	for _, expr := range graph {
+		for _, de := range expr.CustomIDToURI {
+			if de.Value == e.SPDXID && !sr.usedIDs[de.Key] {
+				sr.ids[e.SPDXID] = de.Key
+				sr.usedIDs[de.Key] = true
+			}
+		}
+	}
+	id, ok := sr.ids[e.SPDXID]
+	if !ok {
+		id = sr.newID(e.SPDXID, "LicenseRef-")
+	}
+	sr.doc.OtherLicenses = append(sr.doc.OtherLicenses, &OtherLicense{
+		LicenseID:       id,
+		ExtractedText:   e.LicenseText,
+		Name:            e.Name,
+		CrossReferences: e.SeeAlso,
+		Comment:         e.Comment,
+	})
+}
+
+// readRelationship reads a relationship into the fields of the elements
+// that it's from, for package files and licenses, or as 2.x relationships
+// otherwise.
+func (sr *spdx3Reader) readRelationship(e *spdx3Element) {
+	doc := sr.doc
+	switch e.RelationshipType {
+	case "contains":
+		if pkg, ok := sr.packages[e.From]; ok {
+			var others spdx3Strings
+			for _, iri := range e.To {
+				if f, ok := sr.files[iri]; ok {
+					pkg.Files = append(pkg.Files, f)
+				} else {
+					others = append(others, iri)
+				}
+			}
+			if len(others) == 0 {
+				return
+			}
+			e2 := *e
+			e2.To = others
+			e = &e2
+		}
+
+	case "hasConcludedLicense":
+		var exprs []string
+		for _, iri := range e.To {
+			exprs = append(exprs, sr.license(iri))
+		}
+		expr := strings.Join(exprs, " AND ")
+		switch {
+		case sr.packages[e.From] != nil:
+			sr.packages[e.From].LicenseConcluded = expr
+			sr.packages[e.From].LicenseComments = e.Comment
+		case sr.files[e.From] != nil:
+			sr.files[e.From].LicenseConcluded = expr
+			sr.files[e.From].LicenseComments = e.Comment
+		case sr.snippets[e.From] != nil:
+			sr.snippets[e.From].LicenseConcluded = expr
+			sr.snippets[e.From].LicenseComments = e.Comment
+		}
+		return
+
+	case "hasDeclaredLicense":
+		for _, iri := range e.To {
+			expr := sr.license(iri)
+			switch {
+			case sr.packages[e.From] != nil:
+				pkg := sr.packages[e.From]
+				if pkg.LicenseDeclared == "" {
+					pkg.LicenseDeclared = expr
+				} else {
+					pkg.LicenseDeclared += " AND " + expr
+				}
+			case sr.files[e.From] != nil:
+				sr.files[e.From].LicenseInfoInFile = append(sr.files[e.From].LicenseInfoInFile, expr)
+			case sr.snippets[e.From] != nil:
+				sr.snippets[e.From].LicenseInfoInSnippet = append(sr.snippets[e.From].LicenseInfoInSnippet, expr)
+			}
+		}
+		return
+	}
+
+	typ, reverse := "", false
+	for _, rt := range spdx3RelationshipTypes {
+		if rt.v3 == e.RelationshipType && rt.scope == e.Scope {
+			typ, reverse = rt.v2, rt.reverse
+			break
+		}
+	}
+	if typ == "" {
+		for _, rt := range spdx3RelationshipTypes {
+			if rt.v3 == e.RelationshipType {
+				typ, reverse = rt.v2, rt.reverse
+				break
+			}
+		}
+	}
+	comment := e.Comment
+	if typ == "" {
+		typ = "OTHER"
+		if comment == "" {
+			comment = "SPDX 3 relationship type " + e.RelationshipType
+		}
+	}
+
+	to := []string{}
+	for _, iri := range e.To {
+		to = append(to, sr.id(iri))
+	}
+	if len(to) == 0 {
+		if e.Completeness == "complete" {
+			to = append(to, "NONE")
+		} else {
+			to = append(to, "NOASSERTION")
+		}
+	}
+	from := sr.id(e.From)
+	for _, id := range to {
+		rln := &Relationship{RefA: from, Type: typ, RefB: id, Comment: comment}
+		if reverse && id != "NONE" && id != "NOASSERTION" {
+			rln.RefA, rln.RefB = id, from
+		}
+		doc.Relationships = append(doc.Relationships, rln)
+	}
+}
+
+func (sr *spdx3Reader) readAnnotation(e *spdx3Element) error {
+	ci, err := sr.creationInfo(e)
+	if err != nil {
+		return err
+	}
+	ann := &Annotation{
+		Date:           ci.Created,
+		Type:           "OTHER",
+		SPDXIdentifier: sr.id(e.Subject),
+		Comment:        e.Statement,
+	}
+	if e.AnnotationType == "review" {
+		ann.Type = "REVIEW"
+	}
+	if cs := sr.creators(ci); len(cs) > 0 {
+		ann.Annotator = cs[0]
+	}
+	sr.doc.Annotations = append(sr.doc.Annotations, ann)
+	return nil
+}
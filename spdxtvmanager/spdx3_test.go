@@ -0,0 +1,191 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSPDX3RoundTrip(t *testing.T) {
+	doc, err := Parse(strings.NewReader(testFullDocument))
+	if err != nil {
+		t.Fatalf("got error when parsing document: %v", err)
+	}
+	got := roundTrip(t, doc, FormatSPDX3)
+
+	ci := got.CreationInfo
+	if ci.SPDXVersion != "SPDX-2.3" || ci.DataLicense != "CC0-1.0" || ci.SPDXIdentifier != "SPDXRef-DOCUMENT" ||
+		ci.DocumentName != "hello" || ci.DocumentNamespace != "https://example.com/spdx/hello-1.0" ||
+		ci.LicenseListVersion != "3.9" || ci.Created != "2020-01-02T03:04:05Z" || ci.DocumentComment != "A test\ndocument" {
+		t.Errorf("got wrong creation info: %+v", ci)
+	}
+	if !reflect.DeepEqual(ci.Creators, doc.CreationInfo.Creators) {
+		t.Errorf("expected creators %v, got %v", doc.CreationInfo.Creators, ci.Creators)
+	}
+	if !reflect.DeepEqual(ci.ExternalDocumentRefs, doc.CreationInfo.ExternalDocumentRefs) {
+		t.Errorf("expected external document refs %+v, got %+v", doc.CreationInfo.ExternalDocumentRefs, ci.ExternalDocumentRefs)
+	}
+
+	if len(got.Packages) != 1 {
+		t.Fatalf("expected 1 package, got %d", len(got.Packages))
+	}
+	pkg, want := got.Packages[0], doc.Packages[0]
+	if pkg.Name != want.Name || pkg.SPDXIdentifier != want.SPDXIdentifier || pkg.Version != want.Version ||
+		pkg.Supplier != want.Supplier || pkg.DownloadLocation != want.DownloadLocation ||
+		!pkg.FilesAnalyzed || pkg.LicenseConcluded != "MIT" || pkg.LicenseDeclared != "MIT" ||
+		pkg.CopyrightText != want.CopyrightText || !reflect.DeepEqual(pkg.LicenseInfoFromFiles, []string{"MIT"}) {
+		t.Errorf("got wrong package: %+v", pkg)
+	}
+	if !reflect.DeepEqual(pkg.VerificationCode, want.VerificationCode) || !reflect.DeepEqual(pkg.Checksums, want.Checksums) {
+		t.Errorf("got wrong verification code or checksums: %+v, %v", pkg.VerificationCode, pkg.Checksums)
+	}
+	if len(pkg.ExternalRefs) != 1 || *pkg.ExternalRefs[0] != (ExternalRef{Category: "PACKAGE-MANAGER",
+		Type: "purl", Locator: "pkg:generic/hello@1.0", Comment: "a purl"}) {
+		t.Errorf("got wrong external refs: %+v", pkg.ExternalRefs)
+	}
+
+	if len(got.Files) != 2 || len(pkg.Files) != 2 || pkg.Files[1] != got.Files[1] {
+		t.Fatalf("expected 2 files in package and document, got %d and %d", len(pkg.Files), len(got.Files))
+	}
+	f, wantFile := got.Files[0], doc.Files[0]
+	if f.Path != wantFile.Path || f.SPDXIdentifier != wantFile.SPDXIdentifier ||
+		!reflect.DeepEqual(f.FileTypes, wantFile.FileTypes) || !reflect.DeepEqual(f.Checksums, wantFile.Checksums) ||
+		f.LicenseConcluded != "MIT" || !reflect.DeepEqual(f.LicenseInfoInFile, []string{"MIT"}) ||
+		!reflect.DeepEqual(f.Contributors, wantFile.Contributors) || f.Comment != wantFile.Comment {
+		t.Errorf("got wrong file: %+v", f)
+	}
+	if !reflect.DeepEqual(got.Files[1].FileTypes, []string{"DOCUMENTATION", "TEXT"}) {
+		t.Errorf("expected file types DOCUMENTATION and TEXT, got %v", got.Files[1].FileTypes)
+	}
+
+	if len(got.Snippets) != 1 || !reflect.DeepEqual(got.Snippets[0], doc.Snippets[0]) {
+		t.Errorf("expected snippet %+v, got %+v", doc.Snippets[0], got.Snippets)
+	}
+	if !reflect.DeepEqual(got.Relationships, doc.Relationships) {
+		t.Errorf("expected relationships %+v, got %+v", doc.Relationships, got.Relationships)
+	}
+	if !reflect.DeepEqual(got.OtherLicenses, doc.OtherLicenses) {
+		t.Errorf("expected other licenses %+v, got %+v", doc.OtherLicenses, got.OtherLicenses)
+	}
+
+	// the review becomes an annotation of the document
+	if len(got.Annotations) != 2 || !reflect.DeepEqual(got.Annotations[0], doc.Annotations[0]) ||
+		*got.Annotations[1] != (Annotation{
+			Annotator:      Creator{Type: "Person", Name: "Joe Reviewer"},
+			Date:           "2020-02-03T04:05:06Z",
+			Type:           "REVIEW",
+			SPDXIdentifier: "SPDXRef-DOCUMENT",
+			Comment:        "looks fine",
+		}) {
+		t.Errorf("got wrong annotations: %+v", got.Annotations)
+	}
+}
+
+func TestWriteSPDX3NeedsNamespace(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteSPDX3(&buf, &Document{})
+	if err == nil {
+		t.Errorf("expected error for document without a namespace, got nil")
+	}
+}
+
+func TestParseSPDX3(t *testing.T) {
+	doc, err := ParseSPDX3(strings.NewReader(`{
+  "@context": "https://spdx.org/rdf/3.0.1/spdx-context.jsonld",
+  "@graph": [
+    {"type": "CreationInfo", "@id": "_:ci", "specVersion": "3.0.1", "created": "2024-05-06T07:08:09Z",
+     "createdBy": "https://example.org/agents/acme", "createdUsing": ["https://example.org/tools/gen"]},
+    {"type": "Organization", "spdxId": "https://example.org/agents/acme", "creationInfo": "_:ci", "name": "Acme"},
+    {"type": "Tool", "spdxId": "https://example.org/tools/gen", "creationInfo": "_:ci", "name": "gen-1.0"},
+    {"type": "SpdxDocument", "spdxId": "https://example.org/doc", "creationInfo": "_:ci", "name": "app",
+     "rootElement": ["https://example.org/pkg/app"]},
+    {"type": "Relationship", "spdxId": "https://example.org/rel/1", "creationInfo": "_:ci",
+     "from": "https://example.org/pkg/app", "relationshipType": "contains", "to": ["https://example.org/file/main.go"]},
+    {"type": "LifecycleScopedRelationship", "spdxId": "https://example.org/rel/2", "creationInfo": "_:ci",
+     "from": "https://example.org/pkg/app", "relationshipType": "dependsOn", "scope": "build", "to": ["https://example.org/pkg/lib"]},
+    {"type": "Relationship", "spdxId": "https://example.org/rel/3", "creationInfo": "_:ci",
+     "from": "https://example.org/pkg/lib", "relationshipType": "hasDeclaredLicense", "to": ["https://example.org/lic/1"]},
+    {"type": "Relationship", "spdxId": "https://example.org/rel/4", "creationInfo": "_:ci",
+     "from": "https://example.org/file/main.go", "relationshipType": "hasConcludedLicense",
+     "to": ["https://spdx.org/licenses/MIT"]},
+    {"type": "Relationship", "spdxId": "https://example.org/rel/5", "creationInfo": "_:ci",
+     "from": "https://example.org/pkg/app", "relationshipType": "hasEvidence", "to": "https://example.org/pkg/lib"},
+    {"type": "software_Package", "spdxId": "https://example.org/pkg/app", "creationInfo": "_:ci", "name": "app",
+     "software_packageUrl": "pkg:golang/example.org/app@1.2.0",
+     "externalIdentifier": [{"type": "ExternalIdentifier", "externalIdentifierType": "cpe23", "identifier": "cpe:2.3:a:example:app:1.2.0:*:*:*:*:*:*:*"}]},
+    {"type": "software_Package", "spdxId": "https://example.org/pkg/lib", "creationInfo": "_:ci", "name": "lib",
+     "software_downloadLocation": "https://example.org/lib.tar.gz"},
+    {"type": "software_File", "spdxId": "https://example.org/file/main.go", "creationInfo": "_:ci", "name": "main.go",
+     "software_primaryPurpose": "source",
+     "verifiedUsing": [{"type": "Hash", "algorithm": "sha3_256", "hashValue": "ABC123"}]},
+    {"type": "simplelicensing_LicenseExpression", "spdxId": "https://example.org/lic/1", "creationInfo": "_:ci",
+     "simplelicensing_licenseExpression": "Apache-2.0 OR LicenseRef-mine",
+     "simplelicensing_customIdToUri": [{"type": "DictionaryEntry", "key": "LicenseRef-mine", "value": "https://example.org/lic/mine"}]},
+    {"type": "simplelicensing_SimpleLicensingText", "spdxId": "https://example.org/lic/mine", "creationInfo": "_:ci",
+     "simplelicensing_licenseText": "Mine."}
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("got error when parsing document: %v", err)
+	}
+
+	ci := doc.CreationInfo
+	if ci.DocumentNamespace != "https://example.org/doc" || ci.Created != "2024-05-06T07:08:09Z" ||
+		!reflect.DeepEqual(ci.Creators, []Creator{{Type: "Organization", Name: "Acme"}, {Type: "Tool", Name: "gen-1.0"}}) {
+		t.Errorf("got wrong creation info: %+v", ci)
+	}
+
+	if len(doc.Packages) != 2 {
+		t.Fatalf("expected 2 packages, got %d", len(doc.Packages))
+	}
+	app, lib := doc.Packages[0], doc.Packages[1]
+	if app.SPDXIdentifier != "SPDXRef-app" || len(app.Files) != 1 || !app.FilesAnalyzed ||
+		app.DownloadLocation != "NOASSERTION" || app.LicenseDeclared != "NOASSERTION" {
+		t.Errorf("got wrong package: %+v", app)
+	}
+	if len(app.ExternalRefs) != 2 || app.ExternalRefs[0].Type != "purl" || app.ExternalRefs[1].Type != "cpe23Type" {
+		t.Errorf("got wrong external refs: %+v", app.ExternalRefs)
+	}
+	if lib.SPDXIdentifier != "SPDXRef-lib" || lib.FilesAnalyzed || lib.LicenseDeclared != "Apache-2.0 OR LicenseRef-mine" {
+		t.Errorf("got wrong package: %+v", lib)
+	}
+
+	f := doc.Files[0]
+	if f.Path != "main.go" || f.SPDXIdentifier != "SPDXRef-main.go" || f.LicenseConcluded != "MIT" ||
+		f.Checksums["SHA3-256"] != "abc123" || !reflect.DeepEqual(f.FileTypes, []string{"SOURCE"}) {
+		t.Errorf("got wrong file: %+v", f)
+	}
+
+	if len(doc.OtherLicenses) != 1 || doc.OtherLicenses[0].LicenseID != "LicenseRef-mine" || doc.OtherLicenses[0].ExtractedText != "Mine." {
+		t.Errorf("got wrong other licenses: %+v", doc.OtherLicenses)
+	}
+
+	wantRelationships := []Relationship{
+		{RefA: "SPDXRef-DOCUMENT", Type: "DESCRIBES", RefB: "SPDXRef-app"},
+		{RefA: "SPDXRef-lib", Type: "BUILD_DEPENDENCY_OF", RefB: "SPDXRef-app"},
+		{RefA: "SPDXRef-app", Type: "OTHER", RefB: "SPDXRef-lib", Comment: "SPDX 3 relationship type hasEvidence"},
+	}
+	if len(doc.Relationships) != len(wantRelationships) {
+		t.Fatalf("expected %d relationships, got %+v", len(wantRelationships), doc.Relationships)
+	}
+	for i, rln := range doc.Relationships {
+		if *rln != wantRelationships[i] {
+			t.Errorf("expected relationship %+v, got %+v", wantRelationships[i], *rln)
+		}
+	}
+}
+
+func TestFormatForPathSPDX3(t *testing.T) {
+	for _, p := range []string{"app.spdx3.json", "APP.JSONLD"} {
+		if f := FormatForPath(p); f != FormatSPDX3 {
+			t.Errorf("expected %s to be %s, got %s", p, FormatSPDX3, f)
+		}
+	}
+	if f := FormatForPath("app.spdx.json"); f != FormatJSON {
+		t.Errorf("expected app.spdx.json to be %s, got %s", FormatJSON, f)
+	}
+}
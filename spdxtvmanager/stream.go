@@ -0,0 +1,163 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import "io"
+
+// StreamHandler receives the parts of an SPDX tag-value document from
+// Stream. Each function is called with a part as soon as it is complete,
+// and can be nil to ignore that kind of part. If a function returns an
+// error, Stream stops and returns it.
+//
+// CreationInfo is called first, once. Packages, files, snippets and other
+// licenses are passed in the order that they appear in the document, and
+// relationships, annotations and reviews as soon as they end. A Package's
+// Files are left empty; instead, File is passed the package that the file
+// belongs to, or nil if it isn't in one.
+type StreamHandler struct {
+	CreationInfo func(ci *CreationInfo) error
+	Package      func(pkg *Package) error
+	File         func(f *File, pkg *Package) error
+	Snippet      func(sn *Snippet) error
+	OtherLicense func(ol *OtherLicense) error
+	Relationship func(rln *Relationship) error
+	Annotation   func(ann *Annotation) error
+	Review       func(rev *Review) error
+}
+
+// Stream reads an SPDX tag-value document from r like Parse, but passes
+// its parts to h as it goes, instead of returning a Document. Parts are
+// dropped once h has them, so memory use doesn't grow with the size of the
+// document. Any error in the document itself is returned as a *ParseError,
+// after h has been passed the parts before it.
+func Stream(r io.Reader, h *StreamHandler) error {
+	st := &tvStreamer{parser: &spdxTVParser{doc: &Document{}}, h: h}
+	err := streamTagValues(r, func(tv tagvalue) error {
+		if err := st.emitCompleted(tv.tag); err != nil {
+			return err
+		}
+		if err := st.parser.parseNextPair(tv.tag, tv.value); err != nil {
+			return &ParseError{Line: tv.line, Err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return st.emitCompleted("")
+}
+
+// tvStreamer passes the parts of a document to a StreamHandler once the
+// parser has finished with them. Relationships, annotations and reviews
+// are held back until the creation info is passed, at the first package,
+// file, snippet or other license.
+type tvStreamer struct {
+	parser           *spdxTVParser
+	h                *StreamHandler
+	sentCreationInfo bool
+}
+
+// emitCompleted passes h the parts of the document that can't be changed
+// by tag, which is the next tag to be parsed, or "" at the end of the
+// document.
+func (st *tvStreamer) emitCompleted(tag string) error {
+	parser := st.parser
+	doc := parser.doc
+
+	switch tag {
+	case "PackageName", "FileName", "SnippetSPDXID", "LicenseID", "":
+		if !st.sentCreationInfo {
+			st.sentCreationInfo = true
+			if st.h.CreationInfo != nil {
+				if err := st.h.CreationInfo(&doc.CreationInfo); err != nil {
+					return err
+				}
+			}
+		}
+		if err := st.emitSection(); err != nil {
+			return err
+		}
+	}
+	if !st.sentCreationInfo {
+		return nil
+	}
+
+	if tag != "RelationshipComment" && len(doc.Relationships) > 0 {
+		for _, rln := range doc.Relationships {
+			if st.h.Relationship != nil {
+				if err := st.h.Relationship(rln); err != nil {
+					return err
+				}
+			}
+		}
+		doc.Relationships = doc.Relationships[:0]
+		parser.currentRelation = nil
+	}
+
+	switch tag {
+	case "AnnotationDate", "AnnotationType", "SPDXREF", "AnnotationComment":
+	default:
+		for _, ann := range doc.Annotations {
+			if st.h.Annotation != nil {
+				if err := st.h.Annotation(ann); err != nil {
+					return err
+				}
+			}
+		}
+		doc.Annotations = doc.Annotations[:0]
+		parser.currentAnnotation = nil
+	}
+
+	switch tag {
+	case "ReviewDate", "ReviewComment":
+	default:
+		for _, rev := range doc.Reviews {
+			if st.h.Review != nil {
+				if err := st.h.Review(rev); err != nil {
+					return err
+				}
+			}
+		}
+		doc.Reviews = doc.Reviews[:0]
+		parser.currentReview = nil
+	}
+
+	return nil
+}
+
+// emitSection passes h the package, file, snippet or other license that
+// the parser is in, which is complete because another is starting or the
+// document has ended.
+func (st *tvStreamer) emitSection() error {
+	parser := st.parser
+	doc := parser.doc
+
+	var err error
+	switch parser.state {
+	case statePackage:
+		if st.h.Package != nil {
+			err = st.h.Package(parser.currentPackage)
+		}
+		doc.Packages = doc.Packages[:0]
+	case stateFile:
+		if parser.currentPackage != nil {
+			parser.currentPackage.Files = nil
+		}
+		if st.h.File != nil {
+			err = st.h.File(parser.currentFile, parser.currentPackage)
+		}
+		doc.Files = doc.Files[:0]
+	case stateSnippet:
+		if st.h.Snippet != nil {
+			err = st.h.Snippet(parser.currentSnippet)
+		}
+		doc.Snippets = doc.Snippets[:0]
+	case stateOtherLicense:
+		if st.h.OtherLicense != nil {
+			err = st.h.OtherLicense(parser.currentLicense)
+		}
+		doc.OtherLicenses = doc.OtherLicenses[:0]
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStreamPassesPartsInOrder(t *testing.T) {
+	doc, err := Parse(strings.NewReader(testFullDocument))
+	if err != nil {
+		t.Fatalf("got error when calling Parse: %v", err)
+	}
+
+	var got []string
+	var files []*File
+	h := &StreamHandler{
+		CreationInfo: func(ci *CreationInfo) error {
+			if !reflect.DeepEqual(*ci, doc.CreationInfo) {
+				t.Errorf("got wrong creation info: %+v", ci)
+			}
+			got = append(got, "creation info")
+			return nil
+		},
+		Package: func(pkg *Package) error {
+			got = append(got, "package "+pkg.SPDXIdentifier)
+			return nil
+		},
+		File: func(f *File, pkg *Package) error {
+			if pkg == nil || pkg.Name != "hello" || len(pkg.Files) != 0 {
+				t.Errorf("got wrong package for file %s: %+v", f.Path, pkg)
+			}
+			files = append(files, f)
+			got = append(got, "file "+f.SPDXIdentifier)
+			return nil
+		},
+		Snippet: func(sn *Snippet) error {
+			got = append(got, "snippet "+sn.SPDXIdentifier)
+			return nil
+		},
+		OtherLicense: func(ol *OtherLicense) error {
+			got = append(got, "license "+ol.LicenseID)
+			return nil
+		},
+		Relationship: func(rln *Relationship) error {
+			if rln.Comment != "the only package" {
+				t.Errorf("got wrong relationship: %+v", rln)
+			}
+			got = append(got, "relationship "+rln.Type)
+			return nil
+		},
+		Annotation: func(ann *Annotation) error {
+			got = append(got, "annotation "+ann.SPDXIdentifier)
+			return nil
+		},
+		Review: func(rev *Review) error {
+			got = append(got, "review "+rev.Reviewer.Name)
+			return nil
+		},
+	}
+	err = Stream(strings.NewReader(testFullDocument), h)
+	if err != nil {
+		t.Fatalf("got error when calling Stream: %v", err)
+	}
+
+	want := []string{
+		"creation info",
+		"relationship DESCRIBES",
+		"review Joe Reviewer",
+		"package SPDXRef-Package",
+		"file SPDXRef-File",
+		"snippet SPDXRef-Snippet",
+		"file SPDXRef-File2",
+		"annotation SPDXRef-File2",
+		"license LicenseRef-Custom",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected parts %v, got %v", want, got)
+	}
+	if !reflect.DeepEqual(files, doc.Files) {
+		t.Errorf("expected streamed files to match parsed files")
+	}
+}
+
+// generatedDocument writes a document with n files to w.
+func generatedDocument(w *io.PipeWriter, n int) {
+	fmt.Fprintf(w, "SPDXVersion: SPDX-2.2\nDataLicense: CC0-1.0\n\nPackageName: big\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(w, "\nFileName: ./f%d\nSPDXID: SPDXRef-File-%d\nFileCopyrightText: <text>Copyright\n%d</text>\n", i, i, i)
+		fmt.Fprintf(w, "Relationship: SPDXRef-big CONTAINS SPDXRef-File-%d\n", i)
+	}
+	w.Close()
+}
+
+func TestStreamDropsPartsOnceHandled(t *testing.T) {
+	const n = 1000
+	r, w := io.Pipe()
+	go generatedDocument(w, n)
+
+	var files, relationships int
+	h := &StreamHandler{
+		File: func(f *File, pkg *Package) error {
+			if f.Path != fmt.Sprintf("./f%d", files) {
+				t.Errorf("expected file %d, got %s", files, f.Path)
+			}
+			if len(pkg.Files) != 0 {
+				t.Errorf("expected package to hold no files, got %d", len(pkg.Files))
+			}
+			files++
+			return nil
+		},
+		Relationship: func(rln *Relationship) error {
+			relationships++
+			return nil
+		},
+	}
+	err := Stream(r, h)
+	if err != nil {
+		t.Fatalf("got error when calling Stream: %v", err)
+	}
+	if files != n || relationships != n {
+		t.Errorf("expected %d files and relationships, got %d and %d", n, files, relationships)
+	}
+}
+
+func TestStreamStopsAtHandlerError(t *testing.T) {
+	stop := fmt.Errorf("stop")
+	var files int
+	h := &StreamHandler{
+		File: func(f *File, pkg *Package) error {
+			files++
+			return stop
+		},
+	}
+	err := Stream(strings.NewReader(testDocument), h)
+	if err != stop || files != 1 {
+		t.Errorf("expected to stop after 1 file with handler's error, got %d files and %v", files, err)
+	}
+
+	_, ok := Stream(strings.NewReader("FileName: a\nFileChecksum: SHA3: abc\n"), &StreamHandler{}).(*ParseError)
+	if !ok {
+		t.Errorf("expected *ParseError for invalid document")
+	}
+}
@@ -0,0 +1,511 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/swinslow/peridot/licenses"
+)
+
+// Severity is how serious a Problem in an SPDX document is.
+type Severity int
+
+const (
+	// SeverityWarning indicates something that the SPDX specification
+	// allows, but that is probably a mistake
+	SeverityWarning Severity = iota
+
+	// SeverityError indicates something that the SPDX specification
+	// doesn't allow
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Problem is a problem found by Validate. Line is 0 if the position of the
+// problem isn't known.
+type Problem struct {
+	Line     int
+	Severity Severity
+	Message  string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.Severity, p.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", p.Line, p.Severity, p.Message)
+}
+
+var (
+	spdxIDRegexp      = regexp.MustCompile(`^SPDXRef-[A-Za-z0-9.\-]+$`)
+	documentRefRegexp = regexp.MustCompile(`^DocumentRef-[A-Za-z0-9.\-]+$`)
+	hexRegexp         = regexp.MustCompile(`^[0-9a-f]+$`)
+	externalLicRegexp = regexp.MustCompile(`(DocumentRef-[A-Za-z0-9.\-]+):(LicenseRef-[A-Za-z0-9.\-]+)`)
+)
+
+// checksumLengths gives the length in hex digits of each checksum
+// algorithm's values; MD6 values can have any length.
+var checksumLengths = map[string]int{
+	"SHA1": 40, "SHA224": 56, "SHA256": 64, "SHA384": 96, "SHA512": 128,
+	"MD2": 32, "MD4": 32, "MD5": 32,
+}
+
+var relationshipTypes = map[string]bool{}
+var fileTypes = map[string]bool{}
+
+func init() {
+	for _, t := range strings.Fields(`DESCRIBES DESCRIBED_BY CONTAINS
+		CONTAINED_BY DEPENDS_ON DEPENDENCY_OF DEPENDENCY_MANIFEST_OF
+		BUILD_DEPENDENCY_OF DEV_DEPENDENCY_OF OPTIONAL_DEPENDENCY_OF
+		PROVIDED_DEPENDENCY_OF TEST_DEPENDENCY_OF RUNTIME_DEPENDENCY_OF
+		EXAMPLE_OF GENERATES GENERATED_FROM ANCESTOR_OF DESCENDANT_OF
+		VARIANT_OF DISTRIBUTION_ARTIFACT PATCH_FOR PATCH_APPLIED COPY_OF
+		FILE_ADDED FILE_DELETED FILE_MODIFIED EXPANDED_FROM_ARCHIVE
+		DYNAMIC_LINK STATIC_LINK DATA_FILE_OF TEST_CASE_OF BUILD_TOOL_OF
+		DEV_TOOL_OF TEST_OF TEST_TOOL_OF DOCUMENTATION_OF
+		OPTIONAL_COMPONENT_OF METAFILE_OF PACKAGE_OF AMENDS PREREQUISITE_FOR
+		HAS_PREREQUISITE REQUIREMENT_DESCRIPTION_FOR SPECIFICATION_FOR OTHER`) {
+		relationshipTypes[t] = true
+	}
+	for _, t := range strings.Fields(`SOURCE BINARY ARCHIVE APPLICATION AUDIO
+		IMAGE TEXT VIDEO DOCUMENTATION SPDX OTHER`) {
+		fileTypes[t] = true
+	}
+}
+
+type validator struct {
+	doc      *Document
+	pos      *Positions
+	list     map[string]*licenses.SPDXLicense
+	minor    int
+	ids      map[string]bool
+	seen     map[string]bool
+	docRefs  map[string]bool
+	others   map[string]bool
+	problems []Problem
+}
+
+// Validate checks doc against the SPDX 2.x specification for its
+// SPDXVersion, and returns every Problem found, in order of line. pos
+// gives the lines for problems, and may be nil. License identifiers are
+// checked against ll if it isn't nil.
+func Validate(doc *Document, pos *Positions, ll *licenses.SPDXLicenseList) []Problem {
+	v := &validator{
+		doc:     doc,
+		pos:     pos,
+		ids:     map[string]bool{},
+		seen:    map[string]bool{},
+		docRefs: map[string]bool{},
+		others:  map[string]bool{},
+	}
+	if ll != nil {
+		v.list = map[string]*licenses.SPDXLicense{}
+		for i := range ll.Licenses {
+			v.list[ll.Licenses[i].Identifier] = &ll.Licenses[i]
+		}
+	}
+
+	v.validateCreationInfo()
+	for _, ol := range doc.OtherLicenses {
+		v.validateOtherLicense(ol)
+	}
+	for _, pkg := range doc.Packages {
+		v.validatePackage(pkg)
+	}
+	for _, f := range doc.Files {
+		v.validateFile(f)
+	}
+	for _, sn := range doc.Snippets {
+		v.validateSnippet(sn)
+	}
+	for _, rln := range doc.Relationships {
+		v.validateRelationship(rln)
+	}
+	for _, ann := range doc.Annotations {
+		v.validateAnnotation(ann)
+	}
+	for _, rev := range doc.Reviews {
+		v.checkDate(rev, "review by "+rev.Reviewer.String(), "ReviewDate", rev.Date)
+	}
+
+	sort.SliceStable(v.problems, func(i, j int) bool { return v.problems[i].Line < v.problems[j].Line })
+	return v.problems
+}
+
+// add records a problem with the given tag of elem, or with elem as a
+// whole if the tag isn't there.
+func (v *validator) add(sev Severity, elem interface{}, tag string, format string, args ...interface{}) {
+	line := v.pos.Line(elem, tag)
+	if line == 0 {
+		line = v.pos.Line(elem, "")
+	}
+	v.problems = append(v.problems, Problem{Line: line, Severity: sev, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) errorf(elem interface{}, tag string, format string, args ...interface{}) {
+	v.add(SeverityError, elem, tag, format, args...)
+}
+
+func (v *validator) warnf(elem interface{}, tag string, format string, args ...interface{}) {
+	v.add(SeverityWarning, elem, tag, format, args...)
+}
+
+// required records an error if value is empty.
+func (v *validator) required(elem interface{}, what string, tag string, value string) {
+	if value == "" {
+		v.errorf(elem, tag, "%s is missing required %s", what, tag)
+	}
+}
+
+// requiredBefore23 records an error if value is empty in an SPDX 2.1 or
+// 2.2 document, since SPDX 2.3 made some fields optional.
+func (v *validator) requiredBefore23(elem interface{}, what string, tag string, value string) {
+	if v.minor < 3 {
+		v.required(elem, what, tag, value)
+	}
+}
+
+// checkID records an error if id isn't a valid SPDX identifier, or is
+// used by another element.
+func (v *validator) checkID(elem interface{}, what string, tag string, id string) {
+	switch {
+	case id == "":
+		v.errorf(elem, tag, "%s is missing required %s", what, tag)
+	case !spdxIDRegexp.MatchString(id):
+		v.errorf(elem, tag, "%s has invalid SPDX identifier %q; expected SPDXRef- followed by letters, numbers, . and -", what, id)
+	case v.seen[id]:
+		v.errorf(elem, tag, "%s has duplicate SPDX identifier %s", what, id)
+	}
+	v.seen[id] = true
+}
+
+// checkRef records an error if id doesn't refer to an element of this
+// document, or of a document it refers to. NONE and NOASSERTION are
+// allowed if noneOK is true.
+func (v *validator) checkRef(elem interface{}, what string, tag string, id string, noneOK bool) {
+	if noneOK && (id == "NONE" || id == "NOASSERTION") {
+		return
+	}
+	if docRef, _, found := strings.Cut(id, ":"); found && strings.HasPrefix(docRef, "DocumentRef-") {
+		if !v.docRefs[docRef] {
+			v.errorf(elem, tag, "%s refers to %s, which has no ExternalDocumentRef", what, docRef)
+		}
+		return
+	}
+	if !v.ids[id] {
+		v.errorf(elem, tag, "%s refers to %s, which isn't in the document", what, id)
+	}
+}
+
+func (v *validator) checkChecksums(elem interface{}, what string, tag string, checksums map[string]string) {
+	for _, alg := range checksumOrder(checksums) {
+		v.checkChecksum(elem, what, tag, alg, checksums[alg])
+	}
+}
+
+func (v *validator) checkChecksum(elem interface{}, what string, tag string, alg string, sum string) {
+	n, ok := checksumLengths[alg]
+	if !hexRegexp.MatchString(sum) || (ok && len(sum) != n) {
+		v.errorf(elem, tag, "%s has invalid %s checksum %q", what, alg, sum)
+	}
+}
+
+func (v *validator) checkDate(elem interface{}, what string, tag string, date string) {
+	if date == "" {
+		return
+	}
+	if _, err := time.Parse("2006-01-02T15:04:05Z", date); err != nil {
+		v.errorf(elem, tag, "%s has %s %q, which is not of the form YYYY-MM-DDThh:mm:ssZ", what, tag, date)
+	}
+}
+
+// checkLicense records problems with a license expression, which is the
+// i'th value of tag. NONE and NOASSERTION are always allowed, and an empty
+// value is left to the checks for required fields.
+func (v *validator) checkLicense(elem interface{}, what string, tag string, i int, expr string) {
+	if expr == "" || expr == "NONE" || expr == "NOASSERTION" {
+		return
+	}
+	line := v.pos.ValueLine(elem, tag, i)
+	if line == 0 {
+		line = v.pos.Line(elem, "")
+	}
+	problem := func(sev Severity, format string, args ...interface{}) {
+		v.problems = append(v.problems, Problem{Line: line, Severity: sev, Message: what + ": " + fmt.Sprintf(format, args...)})
+	}
+
+	// the licenses package doesn't parse references to other documents'
+	// licenses, so check and remove those first
+	external := map[string]bool{}
+	for _, m := range externalLicRegexp.FindAllStringSubmatch(expr, -1) {
+		external[m[2]] = true
+		if !v.docRefs[m[1]] {
+			problem(SeverityError, "%s refers to %s, which has no ExternalDocumentRef", tag, m[1])
+		}
+	}
+	pln, err := licenses.GetNodesForExpression(externalLicRegexp.ReplaceAllString(expr, "$2"))
+	if err != nil || pln == nil {
+		problem(SeverityError, "invalid license expression %q in %s", expr, tag)
+		return
+	}
+
+	var walk func(n *licenses.ParsedLicenseNode)
+	walk = func(n *licenses.ParsedLicenseNode) {
+		switch n.NodeType {
+		case licenses.NodeIdentifier:
+			id := n.Expression
+			switch {
+			case id == "NONE" || id == "NOASSERTION":
+				problem(SeverityError, "%s can't be part of a license expression in %s", id, tag)
+			case strings.HasPrefix(id, "LicenseRef-"):
+				if !external[id] && !v.others[id] {
+					problem(SeverityError, "%s in %s has no LicenseID and ExtractedText in the document", id, tag)
+				}
+			case v.list == nil:
+			case v.list[id] == nil:
+				problem(SeverityError, "unknown license identifier %s in %s; licenses that aren't on the SPDX License List need a LicenseRef- identifier", id, tag)
+			case v.list[id].IsDeprecated:
+				problem(SeverityWarning, "deprecated license identifier %s in %s", id, tag)
+			}
+		case licenses.NodePlus:
+			walk(n.LeftChild)
+		case licenses.NodeWith:
+			// exceptions aren't on the license list
+			walk(n.LeftChild)
+		default:
+			walk(n.LeftChild)
+			walk(n.RightChild)
+		}
+	}
+	walk(pln)
+}
+
+func (v *validator) validateCreationInfo() {
+	ci := &v.doc.CreationInfo
+	const what = "document"
+
+	switch ci.SPDXVersion {
+	case "SPDX-2.1":
+		v.minor = 1
+	case "SPDX-2.2":
+		v.minor = 2
+	case "SPDX-2.3":
+		v.minor = 3
+	case "":
+		v.errorf(ci, "SPDXVersion", "document is missing required SPDXVersion")
+		v.minor = 3
+	default:
+		v.errorf(ci, "SPDXVersion", "unsupported SPDXVersion %s; expected SPDX-2.1, SPDX-2.2 or SPDX-2.3", ci.SPDXVersion)
+		v.minor = 3
+	}
+	switch ci.DataLicense {
+	case "":
+		v.errorf(ci, "DataLicense", "document is missing required DataLicense")
+	case "CC0-1.0":
+	default:
+		v.errorf(ci, "DataLicense", "DataLicense must be CC0-1.0, not %s", ci.DataLicense)
+	}
+	if ci.SPDXIdentifier != "SPDXRef-DOCUMENT" {
+		v.errorf(ci, "SPDXID", "document SPDXID must be SPDXRef-DOCUMENT, not %q", ci.SPDXIdentifier)
+	}
+	v.ids[ci.SPDXIdentifier] = true
+	v.seen[ci.SPDXIdentifier] = true
+	v.required(ci, what, "DocumentName", ci.DocumentName)
+	switch {
+	case ci.DocumentNamespace == "":
+		v.errorf(ci, "DocumentNamespace", "document is missing required DocumentNamespace")
+	case strings.Contains(ci.DocumentNamespace, "#"):
+		v.errorf(ci, "DocumentNamespace", "DocumentNamespace %s must not contain #", ci.DocumentNamespace)
+	case !strings.Contains(ci.DocumentNamespace, ":"):
+		v.errorf(ci, "DocumentNamespace", "DocumentNamespace %s is not an absolute URI", ci.DocumentNamespace)
+	}
+	for i, edr := range ci.ExternalDocumentRefs {
+		line := v.pos.ValueLine(ci, "ExternalDocumentRef", i)
+		if !documentRefRegexp.MatchString(edr.ID) {
+			v.problems = append(v.problems, Problem{Line: line, Severity: SeverityError,
+				Message: fmt.Sprintf("invalid ExternalDocumentRef ID %q; expected DocumentRef- followed by letters, numbers, . and -", edr.ID)})
+		}
+		if v.docRefs[edr.ID] {
+			v.problems = append(v.problems, Problem{Line: line, Severity: SeverityError,
+				Message: fmt.Sprintf("duplicate ExternalDocumentRef ID %s", edr.ID)})
+		}
+		if edr.ChecksumAlgorithm != "SHA1" {
+			v.problems = append(v.problems, Problem{Line: line, Severity: SeverityError,
+				Message: fmt.Sprintf("ExternalDocumentRef %s must have a SHA1 checksum, not %s", edr.ID, edr.ChecksumAlgorithm)})
+		} else if len(edr.Checksum) != 40 || !hexRegexp.MatchString(edr.Checksum) {
+			v.problems = append(v.problems, Problem{Line: line, Severity: SeverityError,
+				Message: fmt.Sprintf("ExternalDocumentRef %s has invalid SHA1 checksum %q", edr.ID, edr.Checksum)})
+		}
+		v.docRefs[edr.ID] = true
+	}
+	if len(ci.Creators) == 0 {
+		v.errorf(ci, "Creator", "document is missing required Creator")
+	}
+	v.required(ci, what, "Created", ci.Created)
+	v.checkDate(ci, what, "Created", ci.Created)
+
+	// references can be to elements later in the document, so collect
+	// every identifier before checking them
+	for _, pkg := range v.doc.Packages {
+		v.ids[pkg.SPDXIdentifier] = true
+	}
+	for _, f := range v.doc.Files {
+		v.ids[f.SPDXIdentifier] = true
+	}
+	for _, sn := range v.doc.Snippets {
+		v.ids[sn.SPDXIdentifier] = true
+	}
+}
+
+func (v *validator) validatePackage(pkg *Package) {
+	what := "package " + pkg.Name
+	if pkg.SPDXIdentifier != "" {
+		what = "package " + pkg.SPDXIdentifier
+	}
+	v.required(pkg, what, "PackageName", pkg.Name)
+	v.checkID(pkg, what, "SPDXID", pkg.SPDXIdentifier)
+	v.required(pkg, what, "PackageDownloadLocation", pkg.DownloadLocation)
+	v.checkChecksums(pkg, what, "PackageChecksum", pkg.Checksums)
+
+	v.requiredBefore23(pkg, what, "PackageLicenseConcluded", pkg.LicenseConcluded)
+	v.requiredBefore23(pkg, what, "PackageLicenseDeclared", pkg.LicenseDeclared)
+	v.requiredBefore23(pkg, what, "PackageCopyrightText", pkg.CopyrightText)
+	v.checkLicense(pkg, what, "PackageLicenseConcluded", 0, pkg.LicenseConcluded)
+	v.checkLicense(pkg, what, "PackageLicenseDeclared", 0, pkg.LicenseDeclared)
+	for i, lic := range pkg.LicenseInfoFromFiles {
+		v.checkLicense(pkg, what, "PackageLicenseInfoFromFiles", i, lic)
+	}
+
+	if pkg.FilesAnalyzed {
+		if v.minor < 3 && len(pkg.LicenseInfoFromFiles) == 0 {
+			v.errorf(pkg, "PackageLicenseInfoFromFiles", "%s is missing PackageLicenseInfoFromFiles, which is required when FilesAnalyzed is true", what)
+		}
+		if v.minor < 3 && pkg.VerificationCode.Value == "" {
+			v.errorf(pkg, "PackageVerificationCode", "%s is missing PackageVerificationCode, which is required when FilesAnalyzed is true", what)
+		}
+		if len(pkg.Files) == 0 {
+			v.warnf(pkg, "FilesAnalyzed", "%s has FilesAnalyzed true, but no files", what)
+		}
+	} else {
+		if len(pkg.Files) > 0 {
+			v.errorf(pkg, "FilesAnalyzed", "%s has FilesAnalyzed false, but lists files", what)
+		}
+		if len(pkg.LicenseInfoFromFiles) > 0 {
+			v.errorf(pkg, "PackageLicenseInfoFromFiles", "%s has FilesAnalyzed false, but has PackageLicenseInfoFromFiles", what)
+		}
+		if pkg.VerificationCode.Value != "" {
+			v.errorf(pkg, "PackageVerificationCode", "%s has FilesAnalyzed false, but has a PackageVerificationCode", what)
+		}
+	}
+	if vc := pkg.VerificationCode.Value; vc != "" && (len(vc) != 40 || !hexRegexp.MatchString(vc)) {
+		v.errorf(pkg, "PackageVerificationCode", "%s has invalid PackageVerificationCode %q", what, vc)
+	}
+}
+
+func (v *validator) validateFile(f *File) {
+	what := "file " + f.Path
+	v.required(f, what, "FileName", f.Path)
+	v.checkID(f, what, "SPDXID", f.SPDXIdentifier)
+	for i, ft := range f.FileTypes {
+		if !fileTypes[ft] {
+			v.problems = append(v.problems, Problem{Line: v.pos.ValueLine(f, "FileType", i), Severity: SeverityError,
+				Message: fmt.Sprintf("%s has unknown FileType %s", what, ft)})
+		}
+	}
+	if f.Checksums["SHA1"] == "" {
+		v.errorf(f, "FileChecksum", "%s is missing required SHA1 FileChecksum", what)
+	}
+	v.checkChecksums(f, what, "FileChecksum", f.Checksums)
+
+	v.requiredBefore23(f, what, "LicenseConcluded", f.LicenseConcluded)
+	if v.minor < 3 && len(f.LicenseInfoInFile) == 0 {
+		v.errorf(f, "LicenseInfoInFile", "%s is missing required LicenseInfoInFile", what)
+	}
+	v.requiredBefore23(f, what, "FileCopyrightText", f.CopyrightText)
+	v.checkLicense(f, what, "LicenseConcluded", 0, f.LicenseConcluded)
+	for i, lic := range f.LicenseInfoInFile {
+		v.checkLicense(f, what, "LicenseInfoInFile", i, lic)
+	}
+}
+
+func (v *validator) validateSnippet(sn *Snippet) {
+	what := "snippet " + sn.SPDXIdentifier
+	v.checkID(sn, what, "SnippetSPDXID", sn.SPDXIdentifier)
+	if sn.FromFile == "" {
+		v.errorf(sn, "SnippetFromFileSPDXID", "%s is missing required SnippetFromFileSPDXID", what)
+	} else {
+		v.checkRef(sn, what, "SnippetFromFileSPDXID", sn.FromFile, false)
+	}
+	if sn.ByteRange.Start == 0 {
+		v.errorf(sn, "SnippetByteRange", "%s is missing required SnippetByteRange", what)
+	}
+	for _, r := range []struct {
+		tag string
+		rng Range
+	}{{"SnippetByteRange", sn.ByteRange}, {"SnippetLineRange", sn.LineRange}} {
+		if r.rng.Start < 0 || r.rng.End < r.rng.Start {
+			v.errorf(sn, r.tag, "%s has invalid %s %d:%d", what, r.tag, r.rng.Start, r.rng.End)
+		}
+	}
+
+	v.requiredBefore23(sn, what, "SnippetLicenseConcluded", sn.LicenseConcluded)
+	v.requiredBefore23(sn, what, "SnippetCopyrightText", sn.CopyrightText)
+	v.checkLicense(sn, what, "SnippetLicenseConcluded", 0, sn.LicenseConcluded)
+	for i, lic := range sn.LicenseInfoInSnippet {
+		v.checkLicense(sn, what, "LicenseInfoInSnippet", i, lic)
+	}
+}
+
+func (v *validator) validateRelationship(rln *Relationship) {
+	what := "relationship " + rln.RefA + " " + rln.Type + " " + rln.RefB
+	if !relationshipTypes[rln.Type] {
+		v.errorf(rln, "Relationship", "%s has unknown type %s", what, rln.Type)
+	}
+	v.checkRef(rln, what, "Relationship", rln.RefA, false)
+	v.checkRef(rln, what, "Relationship", rln.RefB, true)
+}
+
+func (v *validator) validateAnnotation(ann *Annotation) {
+	what := "annotation by " + ann.Annotator.String()
+	v.required(ann, what, "AnnotationDate", ann.Date)
+	v.checkDate(ann, what, "AnnotationDate", ann.Date)
+	switch ann.Type {
+	case "":
+		v.errorf(ann, "AnnotationType", "%s is missing required AnnotationType", what)
+	case "REVIEW", "OTHER":
+	default:
+		v.errorf(ann, "AnnotationType", "%s has unknown AnnotationType %s; expected REVIEW or OTHER", what, ann.Type)
+	}
+	if ann.SPDXIdentifier == "" {
+		v.errorf(ann, "SPDXREF", "%s is missing required SPDXREF", what)
+	} else {
+		v.checkRef(ann, what, "SPDXREF", ann.SPDXIdentifier, false)
+	}
+}
+
+func (v *validator) validateOtherLicense(ol *OtherLicense) {
+	what := "license " + ol.LicenseID
+	switch {
+	case !strings.HasPrefix(ol.LicenseID, "LicenseRef-"):
+		v.errorf(ol, "LicenseID", "invalid LicenseID %q; expected LicenseRef- followed by letters, numbers, . and -", ol.LicenseID)
+	case v.others[ol.LicenseID]:
+		v.errorf(ol, "LicenseID", "duplicate LicenseID %s", ol.LicenseID)
+	}
+	v.others[ol.LicenseID] = true
+	v.required(ol, what, "ExtractedText", ol.ExtractedText)
+	if v.minor < 3 {
+		v.required(ol, what, "LicenseName", ol.Name)
+	}
+}
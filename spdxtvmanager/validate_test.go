@@ -0,0 +1,130 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/swinslow/peridot/licenses"
+)
+
+var testLicenseList = &licenses.SPDXLicenseList{
+	Licenses: []licenses.SPDXLicense{
+		{Identifier: "MIT"},
+		{Identifier: "Apache-2.0"},
+		{Identifier: "GPL-2.0", IsDeprecated: true},
+		{Identifier: "GPL-2.0-only"},
+	},
+}
+
+const validDocument = `SPDXVersion: SPDX-2.2
+DataLicense: CC0-1.0
+SPDXID: SPDXRef-DOCUMENT
+DocumentName: example
+DocumentNamespace: https://example.com/spdxdocs/example-1
+Creator: Tool: peridot
+Created: 2020-01-02T03:04:05Z
+
+PackageName: example
+SPDXID: SPDXRef-Package
+PackageDownloadLocation: NOASSERTION
+PackageVerificationCode: 2fd4e1c67a2d28fced849ee1bb76e7391b93eb12
+PackageLicenseConcluded: MIT OR Apache-2.0
+PackageLicenseInfoFromFiles: MIT
+PackageLicenseInfoFromFiles: LicenseRef-Custom
+PackageLicenseDeclared: MIT
+PackageCopyrightText: NOASSERTION
+
+FileName: ./main.c
+SPDXID: SPDXRef-File-1
+FileType: SOURCE
+FileChecksum: SHA1: 2fd4e1c67a2d28fced849ee1bb76e7391b93eb12
+LicenseConcluded: MIT
+LicenseInfoInFile: MIT
+FileCopyrightText: NONE
+
+Relationship: SPDXRef-DOCUMENT DESCRIBES SPDXRef-Package
+
+LicenseID: LicenseRef-Custom
+ExtractedText: <text>Do what you like.</text>
+LicenseName: Custom License
+`
+
+func TestValidateValidDocument(t *testing.T) {
+	doc, pos, err := ParseWithPositions(strings.NewReader(validDocument))
+	if err != nil {
+		t.Fatalf("got error when calling ParseWithPositions: %v", err)
+	}
+	problems := Validate(doc, pos, testLicenseList)
+	if len(problems) != 0 {
+		t.Errorf("expected no problems, got %v", problems)
+	}
+}
+
+func TestValidateReportsProblems(t *testing.T) {
+	const doc = `SPDXVersion: SPDX-2.2
+DataLicense: CC0-1.0
+SPDXID: SPDXRef-DOCUMENT
+DocumentNamespace: https://example.com/spdxdocs/example-1#x
+Creator: Tool: peridot
+Created: 2020-01-02
+
+PackageName: example
+SPDXID: SPDXRef-Package
+PackageDownloadLocation: NOASSERTION
+FilesAnalyzed: false
+PackageLicenseConcluded: GPL-2.0 AND
+PackageLicenseDeclared: Bogus-1.0
+PackageCopyrightText: NOASSERTION
+
+FileName: ./main.c
+SPDXID: SPDXRef-Package
+FileChecksum: SHA1: 2fd4
+LicenseConcluded: GPL-2.0
+LicenseInfoInFile: LicenseRef-Missing
+FileCopyrightText: NONE
+
+Relationship: SPDXRef-DOCUMENT DESCRIBES SPDXRef-Missing
+`
+	d, pos, err := ParseWithPositions(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("got error when calling ParseWithPositions: %v", err)
+	}
+	got := []string{}
+	for _, p := range Validate(d, pos, testLicenseList) {
+		got = append(got, p.String())
+	}
+	want := []string{
+		"line 1: error: document is missing required DocumentName",
+		"line 4: error: DocumentNamespace https://example.com/spdxdocs/example-1#x must not contain #",
+		`line 6: error: document has Created "2020-01-02", which is not of the form YYYY-MM-DDThh:mm:ssZ`,
+		"line 11: error: package SPDXRef-Package has FilesAnalyzed false, but lists files",
+		`line 12: error: package SPDXRef-Package: invalid license expression "GPL-2.0 AND" in PackageLicenseConcluded`,
+		"line 13: error: package SPDXRef-Package: unknown license identifier Bogus-1.0 in PackageLicenseDeclared; licenses that aren't on the SPDX License List need a LicenseRef- identifier",
+		"line 17: error: file ./main.c has duplicate SPDX identifier SPDXRef-Package",
+		`line 18: error: file ./main.c has invalid SHA1 checksum "2fd4"`,
+		"line 19: warning: file ./main.c: deprecated license identifier GPL-2.0 in LicenseConcluded",
+		"line 20: error: file ./main.c: LicenseRef-Missing in LicenseInfoInFile has no LicenseID and ExtractedText in the document",
+		"line 23: error: relationship SPDXRef-DOCUMENT DESCRIBES SPDXRef-Missing refers to SPDXRef-Missing, which isn't in the document",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got problems:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidateWithoutPositions(t *testing.T) {
+	doc := &Document{CreationInfo: CreationInfo{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXIdentifier:    "SPDXRef-DOCUMENT",
+		DocumentName:      "example",
+		DocumentNamespace: "https://example.com/spdxdocs/example-1",
+		Created:           "2020-01-02T03:04:05Z",
+	}}
+	problems := Validate(doc, nil, nil)
+	if len(problems) != 1 || problems[0].String() != "error: document is missing required Creator" {
+		t.Errorf("got wrong problems: %v", problems)
+	}
+}
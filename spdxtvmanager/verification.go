@@ -0,0 +1,48 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ComputeVerificationCode returns the package verification code for files
+// with the given SHA1s, as lowercase hex strings: the SHA1 of the SHA1s,
+// sorted and concatenated.
+func ComputeVerificationCode(sha1s []string) string {
+	sorted := append([]string(nil), sha1s...)
+	for i := range sorted {
+		sorted[i] = strings.ToLower(sorted[i])
+	}
+	sort.Strings(sorted)
+	sum := sha1.Sum([]byte(strings.Join(sorted, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// ComputeVerificationCodeExcluding is like ComputeVerificationCode, but
+// takes the SHA1s of files by path, and leaves out the files at the paths
+// in excluded, such as those from a PackageVerificationCode's excludes
+// list. Paths are compared relative to the package root, so "./a/b" and
+// "a/b" are the same file.
+func ComputeVerificationCodeExcluding(pathsToSHA1s map[string]string, excluded []string) string {
+	skip := map[string]bool{}
+	for _, p := range excluded {
+		skip[packageRelativePath(p)] = true
+	}
+	var sha1s []string
+	for p, sha1 := range pathsToSHA1s {
+		if !skip[packageRelativePath(p)] {
+			sha1s = append(sha1s, sha1)
+		}
+	}
+	return ComputeVerificationCode(sha1s)
+}
+
+func packageRelativePath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
@@ -0,0 +1,264 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// tvWriter writes tag-value pairs, and keeps the first error so that
+// callers only need to check it once at the end.
+type tvWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// value writes a single-line tag-value pair, unless value is empty.
+func (tw *tvWriter) value(tag string, value string) {
+	if value == "" || tw.err != nil {
+		return
+	}
+	_, tw.err = fmt.Fprintf(tw.w, "%s: %s\n", tag, value)
+}
+
+// text writes a free-text tag-value pair wrapped in <text> tags, unless
+// value is empty. NONE and NOASSERTION aren't wrapped, since they aren't
+// text.
+func (tw *tvWriter) text(tag string, value string) {
+	if value == "NONE" || value == "NOASSERTION" {
+		tw.value(tag, value)
+		return
+	}
+	if value == "" || tw.err != nil {
+		return
+	}
+	_, tw.err = fmt.Fprintf(tw.w, "%s: <text>%s</text>\n", tag, value)
+}
+
+// blank writes an empty line between sections.
+func (tw *tvWriter) blank() {
+	if tw.err != nil {
+		return
+	}
+	_, tw.err = tw.w.WriteString("\n")
+}
+
+// checksumOrder returns the algorithms in checksums in the order they are
+// written: SHA1, SHA256 and MD5 first, and any others in alphabetical order.
+func checksumOrder(checksums map[string]string) []string {
+	var first, rest []string
+	for _, alg := range []string{"SHA1", "SHA256", "MD5"} {
+		if _, ok := checksums[alg]; ok {
+			first = append(first, alg)
+		}
+	}
+	for alg := range checksums {
+		if alg != "SHA1" && alg != "SHA256" && alg != "MD5" {
+			rest = append(rest, alg)
+		}
+	}
+	sort.Strings(rest)
+	return append(first, rest...)
+}
+
+func (tw *tvWriter) checksums(tag string, checksums map[string]string) {
+	for _, alg := range checksumOrder(checksums) {
+		tw.value(tag, alg+": "+checksums[alg])
+	}
+}
+
+// Write writes doc to w as an SPDX tag-value document. Files that aren't in
+// any package come first, then each package followed by its files, and
+// each file is followed by its snippets. Relationships, annotations,
+// reviews and other licenses come last.
+func Write(w io.Writer, doc *Document) error {
+	tw := &tvWriter{w: bufio.NewWriter(w)}
+
+	ci := doc.CreationInfo
+	tw.value("SPDXVersion", ci.SPDXVersion)
+	tw.value("DataLicense", ci.DataLicense)
+	tw.value("SPDXID", ci.SPDXIdentifier)
+	tw.value("DocumentName", ci.DocumentName)
+	tw.value("DocumentNamespace", ci.DocumentNamespace)
+	for _, edr := range ci.ExternalDocumentRefs {
+		tw.value("ExternalDocumentRef", fmt.Sprintf("%s %s %s: %s", edr.ID, edr.URI, edr.ChecksumAlgorithm, edr.Checksum))
+	}
+	tw.value("LicenseListVersion", ci.LicenseListVersion)
+	for _, c := range ci.Creators {
+		tw.value("Creator", c.String())
+	}
+	tw.value("Created", ci.Created)
+	tw.text("CreatorComment", ci.CreatorComment)
+	tw.text("DocumentComment", ci.DocumentComment)
+
+	// snippets are written after the file they come from
+	snippetsByFile := map[string][]*Snippet{}
+	for _, sn := range doc.Snippets {
+		snippetsByFile[sn.FromFile] = append(snippetsByFile[sn.FromFile], sn)
+	}
+	writeFile := func(f *File) {
+		tw.blank()
+		tw.writeFile(f)
+		for _, sn := range snippetsByFile[f.SPDXIdentifier] {
+			tw.blank()
+			tw.writeSnippet(sn)
+		}
+		delete(snippetsByFile, f.SPDXIdentifier)
+	}
+
+	inPackage := map[*File]bool{}
+	for _, pkg := range doc.Packages {
+		for _, f := range pkg.Files {
+			inPackage[f] = true
+		}
+	}
+	for _, f := range doc.Files {
+		if !inPackage[f] {
+			writeFile(f)
+		}
+	}
+	for _, pkg := range doc.Packages {
+		tw.blank()
+		tw.writePackage(pkg)
+		for _, f := range pkg.Files {
+			writeFile(f)
+		}
+	}
+	for _, sn := range doc.Snippets {
+		if _, ok := snippetsByFile[sn.FromFile]; ok {
+			tw.blank()
+			tw.writeSnippet(sn)
+		}
+	}
+
+	for _, ol := range doc.OtherLicenses {
+		tw.blank()
+		tw.value("LicenseID", ol.LicenseID)
+		tw.text("ExtractedText", ol.ExtractedText)
+		tw.value("LicenseName", ol.Name)
+		for _, xref := range ol.CrossReferences {
+			tw.value("LicenseCrossReference", xref)
+		}
+		tw.text("LicenseComment", ol.Comment)
+	}
+	for _, rln := range doc.Relationships {
+		tw.blank()
+		tw.value("Relationship", rln.RefA+" "+rln.Type+" "+rln.RefB)
+		tw.text("RelationshipComment", rln.Comment)
+	}
+	for _, ann := range doc.Annotations {
+		tw.blank()
+		tw.value("Annotator", ann.Annotator.String())
+		tw.value("AnnotationDate", ann.Date)
+		tw.value("AnnotationType", ann.Type)
+		tw.value("SPDXREF", ann.SPDXIdentifier)
+		tw.text("AnnotationComment", ann.Comment)
+	}
+	for _, rev := range doc.Reviews {
+		tw.blank()
+		tw.value("Reviewer", rev.Reviewer.String())
+		tw.value("ReviewDate", rev.Date)
+		tw.text("ReviewComment", rev.Comment)
+	}
+
+	if tw.err != nil {
+		return tw.err
+	}
+	return tw.w.Flush()
+}
+
+func (tw *tvWriter) writePackage(pkg *Package) {
+	tw.value("PackageName", pkg.Name)
+	tw.value("SPDXID", pkg.SPDXIdentifier)
+	tw.value("PackageVersion", pkg.Version)
+	tw.value("PackageFileName", pkg.FileName)
+	tw.value("PackageSupplier", pkg.Supplier)
+	tw.value("PackageOriginator", pkg.Originator)
+	tw.value("PackageDownloadLocation", pkg.DownloadLocation)
+	tw.value("FilesAnalyzed", fmt.Sprintf("%t", pkg.FilesAnalyzed))
+	if pkg.VerificationCode.Value != "" {
+		vc := pkg.VerificationCode.Value
+		if len(pkg.VerificationCode.ExcludedFiles) > 0 {
+			vc += " (excludes: " + strings.Join(pkg.VerificationCode.ExcludedFiles, ", ") + ")"
+		}
+		tw.value("PackageVerificationCode", vc)
+	}
+	tw.checksums("PackageChecksum", pkg.Checksums)
+	tw.value("PackageHomePage", pkg.HomePage)
+	tw.text("PackageSourceInfo", pkg.SourceInfo)
+	tw.value("PackageLicenseConcluded", pkg.LicenseConcluded)
+	for _, lic := range pkg.LicenseInfoFromFiles {
+		tw.value("PackageLicenseInfoFromFiles", lic)
+	}
+	tw.value("PackageLicenseDeclared", pkg.LicenseDeclared)
+	tw.text("PackageLicenseComments", pkg.LicenseComments)
+	tw.text("PackageCopyrightText", pkg.CopyrightText)
+	tw.text("PackageSummary", pkg.Summary)
+	tw.text("PackageDescription", pkg.Description)
+	tw.text("PackageComment", pkg.Comment)
+	for _, er := range pkg.ExternalRefs {
+		tw.value("ExternalRef", er.Category+" "+er.Type+" "+er.Locator)
+		tw.text("ExternalRefComment", er.Comment)
+	}
+	for _, at := range pkg.AttributionTexts {
+		tw.text("PackageAttributionText", at)
+	}
+}
+
+func (tw *tvWriter) writeFile(f *File) {
+	tw.value("FileName", f.Path)
+	tw.value("SPDXID", f.SPDXIdentifier)
+	for _, ft := range f.FileTypes {
+		tw.value("FileType", ft)
+	}
+	tw.checksums("FileChecksum", f.Checksums)
+	tw.value("LicenseConcluded", f.LicenseConcluded)
+	for _, lic := range f.LicenseInfoInFile {
+		tw.value("LicenseInfoInFile", lic)
+	}
+	tw.text("LicenseComments", f.LicenseComments)
+	tw.text("FileCopyrightText", f.CopyrightText)
+	for _, aop := range f.ArtifactOf {
+		tw.value("ArtifactOfProjectName", aop.Name)
+		tw.value("ArtifactOfProjectHomePage", aop.HomePage)
+		tw.value("ArtifactOfProjectURI", aop.URI)
+	}
+	tw.text("FileComment", f.Comment)
+	tw.text("FileNotice", f.Notice)
+	for _, c := range f.Contributors {
+		tw.value("FileContributor", c)
+	}
+	for _, at := range f.AttributionTexts {
+		tw.text("FileAttributionText", at)
+	}
+	for _, dep := range f.Dependencies {
+		tw.value("FileDependency", dep)
+	}
+}
+
+func (tw *tvWriter) writeSnippet(sn *Snippet) {
+	tw.value("SnippetSPDXID", sn.SPDXIdentifier)
+	tw.value("SnippetFromFileSPDXID", sn.FromFile)
+	if sn.ByteRange.Start != 0 {
+		tw.value("SnippetByteRange", fmt.Sprintf("%d:%d", sn.ByteRange.Start, sn.ByteRange.End))
+	}
+	if sn.LineRange.Start != 0 {
+		tw.value("SnippetLineRange", fmt.Sprintf("%d:%d", sn.LineRange.Start, sn.LineRange.End))
+	}
+	tw.value("SnippetLicenseConcluded", sn.LicenseConcluded)
+	for _, lic := range sn.LicenseInfoInSnippet {
+		tw.value("LicenseInfoInSnippet", lic)
+	}
+	tw.text("SnippetLicenseComments", sn.LicenseComments)
+	tw.text("SnippetCopyrightText", sn.CopyrightText)
+	tw.text("SnippetComment", sn.Comment)
+	tw.value("SnippetName", sn.Name)
+	for _, at := range sn.AttributionTexts {
+		tw.text("SnippetAttributionText", at)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteRoundTrips(t *testing.T) {
+	doc, err := Parse(strings.NewReader(testFullDocument))
+	if err != nil {
+		t.Fatalf("got error when parsing document: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = Write(&buf, doc)
+	if err != nil {
+		t.Fatalf("got error when writing document: %v", err)
+	}
+	got, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("got error when parsing written document: %v", err)
+	}
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("expected written document to parse the same as the original")
+	}
+}
+
+func TestWriteWrapsText(t *testing.T) {
+	doc := &Document{
+		CreationInfo: CreationInfo{SPDXVersion: "SPDX-2.2", DocumentComment: "two\nlines"},
+		Files: []*File{{
+			Path:             "./a.c",
+			Checksums:        map[string]string{"MD5": "m", "SHA1": "s", "SHA512": "x"},
+			LicenseConcluded: "MIT",
+			CopyrightText:    "NOASSERTION",
+		}},
+	}
+	var buf bytes.Buffer
+	err := Write(&buf, doc)
+	if err != nil {
+		t.Fatalf("got error when writing document: %v", err)
+	}
+
+	want := `SPDXVersion: SPDX-2.2
+DocumentComment: <text>two
+lines</text>
+
+FileName: ./a.c
+FileChecksum: SHA1: s
+FileChecksum: MD5: m
+FileChecksum: SHA512: x
+LicenseConcluded: MIT
+FileCopyrightText: NOASSERTION
+`
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestComputeVerificationCode(t *testing.T) {
+	// the code doesn't depend on the order or case of the SHA1s
+	a := ComputeVerificationCode([]string{
+		"85ed0817af83a24ad8da68c2b5094de69833983c",
+		"D6A770BA38583ED4BB4525BD96E50461655D2758",
+	})
+	b := ComputeVerificationCode([]string{
+		"d6a770ba38583ed4bb4525bd96e50461655d2758",
+		"85ed0817af83a24ad8da68c2b5094de69833983c",
+	})
+	// SHA1 of the sorted, lowercased SHA1s concatenated together
+	want := "c33d5f0525e78ad34e7ff8befb239bba2245dffb"
+	if a != want {
+		t.Errorf("expected %s, got %s", want, a)
+	}
+	if b != want {
+		t.Errorf("expected %s, got %s", want, b)
+	}
+}
+
+func TestComputeVerificationCodeExcluding(t *testing.T) {
+	pathsToSHA1s := map[string]string{
+		"src/main.c":   "85ed0817af83a24ad8da68c2b5094de69833983c",
+		"package.spdx": "d6a770ba38583ed4bb4525bd96e50461655d2758",
+	}
+	got := ComputeVerificationCodeExcluding(pathsToSHA1s, []string{"./package.spdx"})
+	want := ComputeVerificationCode([]string{"85ed0817af83a24ad8da68c2b5094de69833983c"})
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
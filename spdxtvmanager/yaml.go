@@ -0,0 +1,886 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SPDX YAML documents have the same structure as SPDX JSON documents, so
+// they are read by parsing the YAML into a tree of yamlNodes and converting
+// that to JSON, guided by the json* types so that scalars such as "1.0" are
+// read as strings where a string is expected. Only the parts of YAML that
+// can appear in such documents are supported: block and flow mappings and
+// sequences, plain, quoted and block scalars, and comments. Anchors,
+// aliases, tags and multiple documents aren't.
+
+// ParseYAML reads an SPDX YAML document from r. Errors in the document are
+// returned as a *ParseError.
+func ParseYAML(r io.Reader) (*Document, error) {
+	root, err := parseYAML(r)
+	if err != nil {
+		return nil, err
+	}
+	v, err := root.toJSONValue(reflect.TypeOf(jsonDocument{}))
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var jd jsonDocument
+	err = json.Unmarshal(data, &jd)
+	if err != nil {
+		return nil, err
+	}
+	return jd.toDocument()
+}
+
+// WriteYAML writes doc to w as an SPDX YAML document.
+func WriteYAML(w io.Writer, doc *Document) error {
+	var buf bytes.Buffer
+	err := WriteJSON(&buf, doc)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(&buf)
+	dec.UseNumber()
+	root, err := yamlNodeFromJSON(dec)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	root.write(bw, 0, false)
+	return bw.Flush()
+}
+
+type yamlKind int
+
+const (
+	yamlScalar yamlKind = iota
+	yamlMapping
+	yamlSequence
+)
+
+// yamlNode is a node in a YAML document. For a scalar, plain is true if it
+// wasn't quoted, so that null, booleans and numbers can be told apart from
+// strings.
+type yamlNode struct {
+	kind   yamlKind
+	value  string
+	plain  bool
+	keys   []string
+	values []*yamlNode
+	items  []*yamlNode
+	line   int
+}
+
+func (n *yamlNode) isNull() bool {
+	return n == nil || (n.kind == yamlScalar && n.plain && (n.value == "" || n.value == "~" || n.value == "null" || n.value == "Null" || n.value == "NULL"))
+}
+
+// ===== Reading =====
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func parseYAML(r io.Reader) (*yamlNode, error) {
+	p := &yamlParser{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	num := 0
+	for scanner.Scan() {
+		num++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		text := strings.TrimLeft(line, " ")
+		if num == 1 && strings.HasPrefix(text, "%YAML") {
+			continue
+		}
+		if text == "---" || text == "..." {
+			if len(p.lines) > 0 && text == "---" {
+				return nil, &ParseError{Line: num, Err: fmt.Errorf("multiple YAML documents aren't supported")}
+			}
+			continue
+		}
+		p.lines = append(p.lines, yamlLine{num: num, indent: len(line) - len(text), text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	root, err := p.parseNode(0)
+	if err != nil {
+		return nil, err
+	}
+	if l, ok := p.next(); ok {
+		return nil, &ParseError{Line: l.num, Err: fmt.Errorf("unexpected content at indentation %d", l.indent)}
+	}
+	if root == nil {
+		return &yamlNode{kind: yamlMapping}, nil
+	}
+	return root, nil
+}
+
+// next returns the next line with content, skipping blank and comment
+// lines.
+func (p *yamlParser) next() (yamlLine, bool) {
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.text != "" && !strings.HasPrefix(l.text, "#") {
+			return l, true
+		}
+		p.pos++
+	}
+	return yamlLine{}, false
+}
+
+// parseNode parses the node that starts on the next line, if that line is
+// indented by at least minIndent; otherwise it returns nil.
+func (p *yamlParser) parseNode(minIndent int) (*yamlNode, error) {
+	l, ok := p.next()
+	if !ok || l.indent < minIndent {
+		return nil, nil
+	}
+	if isSequenceEntry(l.text) {
+		return p.parseSequence(l.indent)
+	}
+	if _, _, isKey, err := splitMappingKey(l.text); err != nil {
+		return nil, &ParseError{Line: l.num, Err: err}
+	} else if isKey {
+		return p.parseMapping(l.indent)
+	}
+	p.pos++
+	return p.parseScalar(l, l.text, minIndent-1)
+}
+
+func isSequenceEntry(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseSequence(indent int) (*yamlNode, error) {
+	l, _ := p.next()
+	n := &yamlNode{kind: yamlSequence, line: l.num}
+	for {
+		l, ok := p.next()
+		if !ok || l.indent != indent || !isSequenceEntry(l.text) {
+			return n, nil
+		}
+		rest := strings.TrimLeft(l.text[1:], " ")
+		var item *yamlNode
+		var err error
+		if rest == "" || strings.HasPrefix(rest, "#") {
+			p.pos++
+			item, err = p.parseNode(indent + 1)
+		} else {
+			// the entry's content starts on the same line, so parse it as
+			// if it were on a line of its own at that indentation
+			p.lines[p.pos] = yamlLine{num: l.num, indent: l.indent + len(l.text) - len(rest), text: rest}
+			item, err = p.parseNode(indent + 1)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			item = &yamlNode{kind: yamlScalar, plain: true, line: l.num}
+		}
+		n.items = append(n.items, item)
+	}
+}
+
+func (p *yamlParser) parseMapping(indent int) (*yamlNode, error) {
+	l, _ := p.next()
+	n := &yamlNode{kind: yamlMapping, line: l.num}
+	for {
+		l, ok := p.next()
+		if !ok || l.indent != indent || isSequenceEntry(l.text) {
+			return n, nil
+		}
+		key, rest, isKey, err := splitMappingKey(l.text)
+		if err != nil {
+			return nil, &ParseError{Line: l.num, Err: err}
+		}
+		if !isKey {
+			return nil, &ParseError{Line: l.num, Err: fmt.Errorf("expected a mapping key")}
+		}
+		p.pos++
+
+		var value *yamlNode
+		if rest == "" || strings.HasPrefix(rest, "#") {
+			// the value is on the following lines; a sequence may be at
+			// the same indentation as its key
+			next, ok := p.next()
+			if ok && next.indent == indent && isSequenceEntry(next.text) {
+				value, err = p.parseSequence(indent)
+			} else {
+				value, err = p.parseNode(indent + 1)
+			}
+		} else {
+			value, err = p.parseScalar(l, rest, indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			value = &yamlNode{kind: yamlScalar, plain: true, line: l.num}
+		}
+		n.keys = append(n.keys, key)
+		n.values = append(n.values, value)
+	}
+}
+
+// splitMappingKey splits a line of the form "key: value" or "key:", where
+// key may be quoted. isKey is false if the line isn't a mapping entry.
+func splitMappingKey(text string) (key string, rest string, isKey bool, err error) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 {
+			return "", "", false, nil
+		}
+		after := strings.TrimLeft(text[end+1:], " ")
+		if after != ":" && !strings.HasPrefix(after, ": ") {
+			return "", "", false, nil
+		}
+		key, err = unquoteYAML(text[:end+1])
+		return key, strings.TrimSpace(after[1:]), true, err
+	}
+	if strings.ContainsAny(text[:1], "[{|>") {
+		return "", "", false, nil
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '#' && i > 0 && text[i-1] == ' ' {
+			return "", "", false, nil
+		}
+		if text[i] == ':' && (i == len(text)-1 || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true, nil
+		}
+	}
+	return "", "", false, nil
+}
+
+// closingQuote returns the index of the quote that closes the quoted
+// scalar at the start of text, or -1 if it isn't on this line.
+func closingQuote(text string) int {
+	q := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case q == '"' && text[i] == '\\':
+			i++
+		case q == '\'' && text[i] == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// parseScalar parses a scalar or flow collection that starts with text on
+// line l, and may continue on following lines indented by more than
+// parentIndent.
+func (p *yamlParser) parseScalar(l yamlLine, text string, parentIndent int) (*yamlNode, error) {
+	switch text[0] {
+	case '|', '>':
+		return p.parseBlockScalar(l, text, parentIndent)
+	case '"', '\'':
+		// join lines until the closing quote
+		for closingQuote(text) < 0 {
+			if p.pos >= len(p.lines) {
+				return nil, &ParseError{Line: l.num, Err: fmt.Errorf("unterminated quoted scalar")}
+			}
+			text += "\n" + p.lines[p.pos].text
+			p.pos++
+		}
+		end := closingQuote(text)
+		if after := strings.TrimSpace(text[end+1:]); after != "" && !strings.HasPrefix(after, "#") {
+			return nil, &ParseError{Line: l.num, Err: fmt.Errorf("unexpected text after quoted scalar: %s", after)}
+		}
+		value, err := unquoteYAML(text[:end+1])
+		if err != nil {
+			return nil, &ParseError{Line: l.num, Err: err}
+		}
+		return &yamlNode{kind: yamlScalar, value: value, line: l.num}, nil
+	case '[', '{':
+		// join lines until the brackets balance
+		for !flowBalanced(text) {
+			if p.pos >= len(p.lines) {
+				return nil, &ParseError{Line: l.num, Err: fmt.Errorf("unterminated flow collection")}
+			}
+			text += " " + p.lines[p.pos].text
+			p.pos++
+		}
+		fp := &yamlFlowParser{text: text, line: l.num}
+		n, err := fp.parse()
+		if err != nil {
+			return nil, &ParseError{Line: l.num, Err: err}
+		}
+		if rest := strings.TrimSpace(fp.text[fp.pos:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, &ParseError{Line: l.num, Err: fmt.Errorf("unexpected text after flow collection: %s", rest)}
+		}
+		return n, nil
+	}
+
+	// a plain scalar, which may continue on more-indented lines, with
+	// single line breaks folded into spaces
+	value := stripComment(text)
+	pendingBreaks := 0
+	for p.pos < len(p.lines) {
+		next := p.lines[p.pos]
+		if next.text == "" {
+			pendingBreaks++
+			p.pos++
+			continue
+		}
+		if next.indent <= parentIndent || strings.HasPrefix(next.text, "#") {
+			break
+		}
+		if pendingBreaks > 0 {
+			value += strings.Repeat("\n", pendingBreaks)
+		} else {
+			value += " "
+		}
+		pendingBreaks = 0
+		value += stripComment(next.text)
+		p.pos++
+	}
+	return &yamlNode{kind: yamlScalar, value: value, plain: true, line: l.num}, nil
+}
+
+// stripComment removes a trailing comment from a plain scalar.
+func stripComment(text string) string {
+	if i := strings.Index(text, " #"); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+// parseBlockScalar parses a literal (|) or folded (>) block scalar, whose
+// header is text.
+func (p *yamlParser) parseBlockScalar(l yamlLine, text string, parentIndent int) (*yamlNode, error) {
+	header := stripComment(text)
+	folded := header[0] == '>'
+	chomp := byte(0)
+	contentIndent := 0
+	for i := 1; i < len(header); i++ {
+		switch c := header[i]; {
+		case c == '-' || c == '+':
+			chomp = c
+		case c >= '1' && c <= '9':
+			contentIndent = parentIndent + int(c-'0')
+		default:
+			return nil, &ParseError{Line: l.num, Err: fmt.Errorf("invalid block scalar header: %s", header)}
+		}
+	}
+
+	var lines []string
+	for p.pos < len(p.lines) {
+		next := p.lines[p.pos]
+		if next.text == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		if contentIndent == 0 {
+			if next.indent <= parentIndent {
+				break
+			}
+			contentIndent = next.indent
+		}
+		if next.indent < contentIndent {
+			break
+		}
+		lines = append(lines, strings.Repeat(" ", next.indent-contentIndent)+next.text)
+		p.pos++
+	}
+
+	// trailing blank lines only matter for chomping
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var value string
+	if folded {
+		var b strings.Builder
+		for i, line := range lines {
+			if i > 0 {
+				prev := lines[i-1]
+				if prev == "" || line == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(prev, " ") {
+					b.WriteString("\n")
+				} else {
+					b.WriteString(" ")
+				}
+			}
+			b.WriteString(line)
+		}
+		value = b.String()
+	} else {
+		value = strings.Join(lines, "\n")
+	}
+
+	switch {
+	case len(lines) == 0:
+	case chomp == '-':
+	case chomp == '+':
+		value += strings.Repeat("\n", trailing+1)
+	default:
+		value += "\n"
+	}
+	return &yamlNode{kind: yamlScalar, value: value, line: l.num}, nil
+}
+
+// unquoteYAML returns the value of a single- or double-quoted scalar,
+// folding any line breaks in it.
+func unquoteYAML(s string) (string, error) {
+	q := s[0]
+	body := s[1 : len(s)-1]
+	// fold line breaks: a single break becomes a space, and each further
+	// one a newline; in double quotes, an escaped break disappears
+	var b strings.Builder
+	lines := strings.Split(body, "\n")
+	breaks := 0
+	for i, line := range lines {
+		last := i == len(lines)-1
+		if i > 0 {
+			line = strings.TrimLeft(line, " \t")
+		}
+		escaped := false
+		if !last {
+			if q == '"' && oddBackslashSuffix(line) {
+				line, escaped = line[:len(line)-1], true
+			} else {
+				line = strings.TrimRight(line, " \t")
+			}
+		}
+		if i > 0 && !last && line == "" && !escaped {
+			breaks++
+			continue
+		}
+		if breaks == 1 {
+			b.WriteString(" ")
+		} else if breaks > 1 {
+			b.WriteString(strings.Repeat("\n", breaks-1))
+		}
+		b.WriteString(line)
+		breaks = 1
+		if escaped {
+			breaks = 0
+		}
+	}
+	body = b.String()
+
+	if q == '\'' {
+		return strings.ReplaceAll(body, "''", "'"), nil
+	}
+	return unescapeYAML(body)
+}
+
+// oddBackslashSuffix returns true if s ends with an odd number of
+// backslashes, so that the last one escapes the line break after it.
+func oddBackslashSuffix(s string) bool {
+	n := len(s) - len(strings.TrimRight(s, "\\"))
+	return n%2 == 1
+}
+
+// unescapeYAML replaces the escape sequences in the body of a
+// double-quoted scalar.
+func unescapeYAML(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("invalid escape at end of quoted scalar")
+		}
+		simple := map[byte]string{
+			'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+			'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+			'/': "/", '\\': "\\", 'N': "\u0085", '_': " ", 'L': " ", 'P': " ",
+		}
+		if r, ok := simple[s[i]]; ok {
+			b.WriteString(r)
+			continue
+		}
+		size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+		if size == 0 || i+size >= len(s)+1 {
+			return "", fmt.Errorf("invalid escape \\%c in quoted scalar", s[i])
+		}
+		code, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape \\%s in quoted scalar", s[i:i+1+size])
+		}
+		b.WriteRune(rune(code))
+		i += size
+	}
+	return b.String(), nil
+}
+
+// flowBalanced returns true if every bracket in text, outside quotes, is
+// closed.
+func flowBalanced(text string) bool {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '"', '\'':
+			end := closingQuote(text[i:])
+			if end < 0 {
+				return false
+			}
+			i += end
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+	return depth == 0
+}
+
+// yamlFlowParser parses a flow collection, such as "[a, b]" or
+// "{k: v}".
+type yamlFlowParser struct {
+	text string
+	pos  int
+	line int
+}
+
+func (fp *yamlFlowParser) skipSpace() {
+	for fp.pos < len(fp.text) && (fp.text[fp.pos] == ' ' || fp.text[fp.pos] == '\t') {
+		fp.pos++
+	}
+}
+
+func (fp *yamlFlowParser) parse() (*yamlNode, error) {
+	fp.skipSpace()
+	if fp.pos >= len(fp.text) {
+		return nil, fmt.Errorf("unexpected end of flow collection")
+	}
+	switch fp.text[fp.pos] {
+	case '[':
+		fp.pos++
+		n := &yamlNode{kind: yamlSequence, line: fp.line}
+		for {
+			fp.skipSpace()
+			if fp.pos < len(fp.text) && fp.text[fp.pos] == ']' {
+				fp.pos++
+				return n, nil
+			}
+			item, err := fp.parse()
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+			if err := fp.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		fp.pos++
+		n := &yamlNode{kind: yamlMapping, line: fp.line}
+		for {
+			fp.skipSpace()
+			if fp.pos < len(fp.text) && fp.text[fp.pos] == '}' {
+				fp.pos++
+				return n, nil
+			}
+			key, err := fp.parse()
+			if err != nil {
+				return nil, err
+			}
+			if key.kind != yamlScalar {
+				return nil, fmt.Errorf("flow mapping keys must be scalars")
+			}
+			fp.skipSpace()
+			value := &yamlNode{kind: yamlScalar, plain: true, line: fp.line}
+			if fp.pos < len(fp.text) && fp.text[fp.pos] == ':' {
+				fp.pos++
+				value, err = fp.parse()
+				if err != nil {
+					return nil, err
+				}
+			}
+			n.keys = append(n.keys, key.value)
+			n.values = append(n.values, value)
+			if err := fp.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"', '\'':
+		end := closingQuote(fp.text[fp.pos:])
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated quoted scalar")
+		}
+		value, err := unquoteYAML(fp.text[fp.pos : fp.pos+end+1])
+		if err != nil {
+			return nil, err
+		}
+		fp.pos += end + 1
+		return &yamlNode{kind: yamlScalar, value: value, line: fp.line}, nil
+	}
+
+	start := fp.pos
+	for fp.pos < len(fp.text) {
+		c := fp.text[fp.pos]
+		if c == ',' || c == ']' || c == '}' || (c == ':' && (fp.pos+1 == len(fp.text) || fp.text[fp.pos+1] == ' ')) {
+			break
+		}
+		fp.pos++
+	}
+	return &yamlNode{kind: yamlScalar, value: strings.TrimSpace(fp.text[start:fp.pos]), plain: true, line: fp.line}, nil
+}
+
+// separator consumes the comma after an entry in a flow collection, unless
+// the collection ends with close instead.
+func (fp *yamlFlowParser) separator(close byte) error {
+	fp.skipSpace()
+	if fp.pos >= len(fp.text) {
+		return fmt.Errorf("unterminated flow collection")
+	}
+	switch fp.text[fp.pos] {
+	case ',':
+		fp.pos++
+		return nil
+	case close:
+		return nil
+	}
+	return fmt.Errorf("expected , or %c in flow collection", close)
+}
+
+// toJSONValue converts n to a value that encoding/json will marshal into
+// JSON that can be unmarshalled into a value of type t.
+func (n *yamlNode) toJSONValue(t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if n.isNull() {
+		return nil, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.kind != yamlMapping {
+			return nil, &ParseError{Line: n.line, Err: fmt.Errorf("expected a mapping")}
+		}
+		fields := map[string]reflect.StructField{}
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			fields[name] = t.Field(i)
+		}
+		m := map[string]interface{}{}
+		for i, key := range n.keys {
+			field, ok := fields[key]
+			if !ok {
+				continue
+			}
+			v, err := n.values[i].toJSONValue(field.Type)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+		return m, nil
+
+	case reflect.Slice:
+		if n.kind != yamlSequence {
+			return nil, &ParseError{Line: n.line, Err: fmt.Errorf("expected a sequence")}
+		}
+		s := []interface{}{}
+		for _, item := range n.items {
+			v, err := item.toJSONValue(t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+		}
+		return s, nil
+	}
+
+	if n.kind != yamlScalar {
+		return nil, &ParseError{Line: n.line, Err: fmt.Errorf("expected a scalar")}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(n.value)
+		if err != nil {
+			return nil, &ParseError{Line: n.line, Err: fmt.Errorf("expected true or false, got %s", n.value)}
+		}
+		return b, nil
+	case reflect.Int:
+		i, err := strconv.Atoi(n.value)
+		if err != nil {
+			return nil, &ParseError{Line: n.line, Err: fmt.Errorf("expected an integer, got %s", n.value)}
+		}
+		return i, nil
+	}
+	return n.value, nil
+}
+
+// ===== Writing =====
+
+// yamlNodeFromJSON reads the next JSON value from dec as a yamlNode,
+// keeping the order of object members.
+func yamlNodeFromJSON(dec *json.Decoder) (*yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			n := &yamlNode{kind: yamlSequence}
+			for dec.More() {
+				item, err := yamlNodeFromJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				n.items = append(n.items, item)
+			}
+			_, err = dec.Token()
+			return n, err
+		}
+		n := &yamlNode{kind: yamlMapping}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := yamlNodeFromJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key.(string))
+			n.values = append(n.values, value)
+		}
+		_, err = dec.Token()
+		return n, err
+	case string:
+		return &yamlNode{kind: yamlScalar, value: t}, nil
+	case json.Number:
+		return &yamlNode{kind: yamlScalar, value: t.String(), plain: true}, nil
+	case bool:
+		return &yamlNode{kind: yamlScalar, value: strconv.FormatBool(t), plain: true}, nil
+	}
+	return &yamlNode{kind: yamlScalar, value: "null", plain: true}, nil
+}
+
+// write writes n in block style at the given indentation. If inline is
+// true, the first line of n continues a line that has already been
+// started, such as after "- ".
+func (n *yamlNode) write(w *bufio.Writer, indent int, inline bool) {
+	pad := strings.Repeat(" ", indent)
+	switch n.kind {
+	case yamlMapping:
+		for i, key := range n.keys {
+			if !(inline && i == 0) {
+				w.WriteString(pad)
+			}
+			w.WriteString(yamlScalarText(key, false) + ":")
+			n.values[i].writeValue(w, indent)
+		}
+	case yamlSequence:
+		for i, item := range n.items {
+			if !(inline && i == 0) {
+				w.WriteString(pad)
+			}
+			w.WriteString("-")
+			switch {
+			case item.kind == yamlScalar || item.isEmptyCollection():
+				w.WriteString(" " + item.flowText() + "\n")
+			case item.kind == yamlMapping:
+				w.WriteString(" ")
+				item.write(w, indent+2, true)
+			default:
+				w.WriteString("\n")
+				item.write(w, indent+2, false)
+			}
+		}
+	default:
+		w.WriteString(pad + n.flowText() + "\n")
+	}
+}
+
+// writeValue writes n as the value of a mapping entry at indent, whose key
+// has just been written.
+func (n *yamlNode) writeValue(w *bufio.Writer, indent int) {
+	if n.kind == yamlScalar || n.isEmptyCollection() {
+		w.WriteString(" " + n.flowText() + "\n")
+		return
+	}
+	w.WriteString("\n")
+	n.write(w, indent+2, false)
+}
+
+func (n *yamlNode) isEmptyCollection() bool {
+	return (n.kind == yamlMapping && len(n.keys) == 0) || (n.kind == yamlSequence && len(n.items) == 0)
+}
+
+// flowText returns a scalar or empty collection as it is written on a
+// single line.
+func (n *yamlNode) flowText() string {
+	switch n.kind {
+	case yamlMapping:
+		return "{}"
+	case yamlSequence:
+		return "[]"
+	}
+	return yamlScalarText(n.value, n.plain)
+}
+
+// yamlScalarText returns s as a YAML scalar. Unless plain is true, s is a
+// string, and is quoted if it would otherwise be read as something else.
+func yamlScalarText(s string, plain bool) string {
+	if plain || !needsQuotes(s) {
+		return s
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func needsQuotes(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return true
+	}
+	if strings.ContainsAny(s[:1], "0123456789.+-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return true
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f || r == 0x85 || r == 0x2028 || r == 0x2029 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+// Copyright The Linux Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package spdxtvmanager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestYAMLRoundTrip(t *testing.T) {
+	doc, err := Parse(strings.NewReader(testFullDocument))
+	if err != nil {
+		t.Fatalf("got error when parsing document: %v", err)
+	}
+	doc.Files[0].Comment = "  leading spaces, \"quotes\": and\n\nlines\t"
+	doc.Packages[0].Version = "1.0"
+
+	got := roundTrip(t, doc, FormatYAML)
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("expected YAML document to parse the same as the original")
+	}
+}
+
+const testYAMLDocument = `%YAML 1.2
+---
+# written by hand
+spdxVersion: SPDX-2.2
+SPDXID: SPDXRef-DOCUMENT
+name: hello   # trailing comment
+creationInfo:
+  creators:
+  - 'Tool: it''s'
+  - "Person: Jane\tDoe"
+  created: 2020-01-02T03:04:05Z
+comment: a plain scalar
+  folded over
+
+  two lines
+packages:
+- name: hello
+  SPDXID: SPDXRef-Package
+  versionInfo: 1.0
+  filesAnalyzed: false
+  licenseInfoFromFiles: [MIT, "Apache-2.0"]
+  description: |
+    line one
+      indented
+  summary: >-
+    folded
+    text
+  copyrightText: "a double-quoted \
+    continuation"
+`
+
+func TestParseYAMLDocument(t *testing.T) {
+	doc, err := ParseYAML(strings.NewReader(testYAMLDocument))
+	if err != nil {
+		t.Fatalf("got error when parsing document: %v", err)
+	}
+	ci := doc.CreationInfo
+	if ci.DocumentName != "hello" || ci.Created != "2020-01-02T03:04:05Z" {
+		t.Errorf("got wrong creation info: %+v", ci)
+	}
+	if len(ci.Creators) != 2 || ci.Creators[0].Name != "it's" || ci.Creators[1].Name != "Jane\tDoe" {
+		t.Errorf("got wrong creators: %+v", ci.Creators)
+	}
+	if ci.DocumentComment != "a plain scalar folded over\ntwo lines" {
+		t.Errorf("got wrong comment: %q", ci.DocumentComment)
+	}
+	if len(doc.Packages) != 1 {
+		t.Fatalf("expected 1 package, got %d", len(doc.Packages))
+	}
+	pkg := doc.Packages[0]
+	if pkg.Version != "1.0" || pkg.FilesAnalyzed {
+		t.Errorf("got wrong version or FilesAnalyzed: %s, %v", pkg.Version, pkg.FilesAnalyzed)
+	}
+	if !reflect.DeepEqual(pkg.LicenseInfoFromFiles, []string{"MIT", "Apache-2.0"}) {
+		t.Errorf("got wrong licenses: %v", pkg.LicenseInfoFromFiles)
+	}
+	if pkg.Description != "line one\n  indented\n" {
+		t.Errorf("got wrong description: %q", pkg.Description)
+	}
+	if pkg.Summary != "folded text" {
+		t.Errorf("got wrong summary: %q", pkg.Summary)
+	}
+	if pkg.CopyrightText != "a double-quoted continuation" {
+		t.Errorf("got wrong copyright: %q", pkg.CopyrightText)
+	}
+}
+
+func TestParseYAMLReportsLineNumbers(t *testing.T) {
+	_, err := ParseYAML(strings.NewReader("spdxVersion: SPDX-2.2\npackages:\n  - name: a\n    filesAnalyzed: maybe\n"))
+	pe, ok := err.(*ParseError)
+	if !ok || pe.Line != 4 {
+		t.Errorf("expected ParseError on line 4, got %v", err)
+	}
+}